@@ -0,0 +1,236 @@
+// Command admin manages user accounts from the command line, for setting up
+// the first admin and for recovering access when nobody can sign in.
+//
+//	go run ./cmd/admin create-admin -email ops@example.com [-username ops]
+//	go run ./cmd/admin reset-password -user ops@example.com [-must-change]
+//	go run ./cmd/admin list-users [-role ADMIN]
+//	go run ./cmd/admin disable-user -user ops@example.com
+//	go run ./cmd/admin enable-user -user ops@example.com
+//	go run ./cmd/admin reset-mfa -user ops@example.com
+//
+// Passwords are read from ADMIN_PASSWORD or, if unset, from a line on stdin,
+// so they don't end up in shell history. Run it from the backend directory
+// with the same DATABASE_URL as the server.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  create-admin    create an admin account
+  reset-password  set a user's password
+  list-users      list user accounts
+  disable-user    block a user from signing in
+  enable-user     undo disable-user
+  reset-mfa       remove a user's two-factor authentication
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	_ = godotenv.Load()
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		log.Fatal("DATABASE_URL is not set")
+	}
+	if err := db.Connect(dbURL); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	// Bring the schema up to date so this works before the server first ran
+	if err := db.InitSchema(ctx); err != nil {
+		log.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	auth := service.NewAuthService()
+	var err error
+	switch cmd {
+	case "create-admin":
+		err = createAdmin(ctx, auth, args)
+	case "reset-password":
+		err = resetPassword(ctx, auth, args)
+	case "list-users":
+		err = listUsers(ctx, auth, args)
+	case "disable-user":
+		err = setDisabled(ctx, auth, cmd, args, true)
+	case "enable-user":
+		err = setDisabled(ctx, auth, cmd, args, false)
+	case "reset-mfa":
+		err = resetMFA(ctx, auth, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func createAdmin(ctx context.Context, auth *service.AuthService, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "email address (required)")
+	username := fs.String("username", "", "username (defaults to the part of the email before @)")
+	fs.Parse(args)
+	if *email == "" {
+		fs.Usage()
+		return errors.New("-email is required")
+	}
+	if *username == "" {
+		*username, _, _ = strings.Cut(*email, "@")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	id, err := auth.CreateAdmin(ctx, *email, *username, password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created admin %s (username %s, id %s)\n", *email, *username, id)
+	return nil
+}
+
+func resetPassword(ctx context.Context, auth *service.AuthService, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	user := fs.String("user", "", "email or username (required)")
+	mustChange := fs.Bool("must-change", false, "require a new password at next sign-in")
+	fs.Parse(args)
+	if *user == "" {
+		fs.Usage()
+		return errors.New("-user is required")
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	if err := auth.SetPassword(ctx, *user, password, *mustChange); err != nil {
+		return err
+	}
+	fmt.Printf("Password updated for %s\n", *user)
+	return nil
+}
+
+func listUsers(ctx context.Context, auth *service.AuthService, args []string) error {
+	fs := flag.NewFlagSet("list-users", flag.ExitOnError)
+	role := fs.String("role", "", "only show users with this role")
+	fs.Parse(args)
+
+	users, err := auth.ListUsers(ctx, "")
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tUSERNAME\tROLE\tSTATUS\tCREATED")
+	for _, u := range users {
+		if *role != "" && !strings.EqualFold(u.Role, *role) {
+			continue
+		}
+		status := "active"
+		switch {
+		case u.DisabledAt != nil:
+			status = "disabled"
+		case u.ActivatedAt == nil:
+			status = "invited"
+		case u.LockedUntil != nil && u.LockedUntil.After(time.Now()):
+			status = "locked"
+		case u.MustChangePassword:
+			status = "must change password"
+		}
+		if u.MFAEnabled {
+			status += ", 2FA"
+		}
+		if u.ServiceAccount {
+			status += ", service account"
+		}
+		username := ""
+		if u.Username != nil {
+			username = *u.Username
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, username, u.Role, status, u.CreatedAt.Format("2006-01-02"))
+	}
+	return w.Flush()
+}
+
+func setDisabled(ctx context.Context, auth *service.AuthService, name string, args []string, disabled bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	user := fs.String("user", "", "email or username (required)")
+	fs.Parse(args)
+	if *user == "" {
+		fs.Usage()
+		return errors.New("-user is required")
+	}
+
+	if err := auth.SetDisabled(ctx, *user, disabled); err != nil {
+		return err
+	}
+	if disabled {
+		fmt.Printf("Disabled %s. Sessions already signed in end when their token expires (at most 24 hours).\n", *user)
+	} else {
+		fmt.Printf("Enabled %s\n", *user)
+	}
+	return nil
+}
+
+func resetMFA(ctx context.Context, auth *service.AuthService, args []string) error {
+	fs := flag.NewFlagSet("reset-mfa", flag.ExitOnError)
+	user := fs.String("user", "", "email or username (required)")
+	fs.Parse(args)
+	if *user == "" {
+		fs.Usage()
+		return errors.New("-user is required")
+	}
+
+	id, err := auth.LookupUserID(ctx, *user)
+	if err != nil {
+		return err
+	}
+	if err := auth.ResetMFA(ctx, id.String()); err != nil {
+		return err
+	}
+	fmt.Printf("Two-factor authentication removed for %s\n", *user)
+	return nil
+}
+
+// readPassword takes the password from ADMIN_PASSWORD or the first line of
+// stdin. The line is echoed when typed at a terminal.
+func readPassword() (string, error) {
+	if p := os.Getenv("ADMIN_PASSWORD"); p != "" {
+		return p, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given")
+	}
+	return password, nil
+}
@@ -0,0 +1,74 @@
+// Command fakeocr is a stand-in for the OCR service used in development and
+// integration tests. It accepts the same multipart upload as the real
+// service and answers with deterministic markdown.
+//
+// FAKE_OCR_FAIL_EVERY=n makes every nth request fail with a 503 so retry
+// behaviour can be exercised; FAKE_OCR_DELAY adds latency (e.g. "2s").
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8000"
+	}
+
+	failEvery, _ := strconv.Atoi(os.Getenv("FAKE_OCR_FAIL_EVERY"))
+	delay, _ := time.ParseDuration(os.Getenv("FAKE_OCR_DELAY"))
+
+	var requests atomic.Int64
+	http.HandleFunc("/ocr", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		n := requests.Add(1)
+		if failEvery > 0 && n%int64(failEvery) == 0 {
+			http.Error(w, "simulated failure", http.StatusServiceUnavailable)
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		h := sha256.New()
+		size, err := io.Copy(h, file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		time.Sleep(delay)
+
+		markdown := fmt.Sprintf("# %s\n\nFake OCR output.\n\n- Size: %d bytes\n- SHA-256: %s\n",
+			header.Filename, size, hex.EncodeToString(h.Sum(nil)))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"markdown":        markdown,
+			"processing_time": time.Since(start).Seconds(),
+		})
+	})
+
+	log.Printf("Fake OCR server listening on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
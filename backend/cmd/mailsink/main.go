@@ -0,0 +1,160 @@
+// Command mailsink is a minimal SMTP server for development. It accepts any
+// message without authentication or TLS, writes it to MAILSINK_DIR as an
+// .eml file and logs the envelope. Point the backend at it with
+// MAIL_TRANSPORT=smtp SMTP_HOST=localhost SMTP_PORT=1025 SMTP_TLS=none.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxMessageSize bounds DATA so a misbehaving client can't fill the disk.
+const maxMessageSize = 25 << 20
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "1025"
+	}
+	dir := os.Getenv("MAILSINK_DIR")
+	if dir == "" {
+		dir = "./data/mailsink"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("mailsink listening on :%s, writing to %s", port, dir)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("accept: %v", err)
+			continue
+		}
+		go serve(conn, dir)
+	}
+}
+
+func serve(conn net.Conn, dir string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(w, format+"\r\n", args...)
+		w.Flush()
+	}
+
+	var from string
+	var to []string
+	reply("220 mailsink ESMTP ready")
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 mailsink")
+		case "EHLO":
+			reply("250-mailsink")
+			reply("250-SIZE %d", maxMessageSize)
+			reply("250 8BITMIME")
+		case "MAIL":
+			from, to = envelopeAddress(arg), nil
+			reply("250 OK")
+		case "RCPT":
+			to = append(to, envelopeAddress(arg))
+			reply("250 OK")
+		case "DATA":
+			if len(to) == 0 {
+				reply("503 RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := readData(r)
+			if err != nil {
+				reply("552 %v", err)
+				return
+			}
+			name, err := save(dir, data)
+			if err != nil {
+				reply("451 %v", err)
+				continue
+			}
+			subject := ""
+			if msg, err := mail.ReadMessage(bytes.NewReader(data)); err == nil {
+				subject = msg.Header.Get("Subject")
+			}
+			log.Printf("from=%s to=%s subject=%q file=%s", from, strings.Join(to, ","), subject, name)
+			from, to = "", nil
+			reply("250 OK")
+		case "RSET":
+			from, to = "", nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// envelopeAddress extracts the address from "FROM:<a@b>" or "TO:<a@b>".
+func envelopeAddress(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr = strings.TrimSpace(addr)
+	if i := strings.Index(addr, ">"); i >= 0 {
+		addr = addr[:i+1]
+	}
+	return strings.Trim(addr, "<>")
+}
+
+// readData reads a dot-terminated DATA section, undoing dot-stuffing.
+func readData(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == ".\r\n" || line == ".\n" {
+			return buf.Bytes(), nil
+		}
+		if strings.HasPrefix(line, "..") {
+			line = line[1:]
+		}
+		if buf.Len()+len(line) > maxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+		}
+		buf.WriteString(line)
+	}
+}
+
+func save(dir string, data []byte) (string, error) {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := filepath.Join(dir, fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), hex.EncodeToString(suffix)))
+	return name, os.WriteFile(name, data, 0o644)
+}
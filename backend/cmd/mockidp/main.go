@@ -0,0 +1,53 @@
+// Command mockidp is an OpenID Connect provider for development and
+// integration tests. Every sign-in is approved straight away as the
+// configured user. Point the backend at it with
+// OIDC_ISSUER=http://localhost:9999 OIDC_CLIENT_ID=platform
+// OIDC_CLIENT_SECRET=secret OIDC_ROLE_MAPPING=admins=ADMIN.
+//
+// MOCKIDP_EMAIL, MOCKIDP_SUBJECT, MOCKIDP_NAME and MOCKIDP_GROUPS (comma
+// separated) choose who signs in; MOCKIDP_ISSUER overrides the issuer when
+// the backend reaches it under another address.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/oidc/oidctest"
+)
+
+func main() {
+	port := getenv("PORT", "9999")
+	issuer := getenv("MOCKIDP_ISSUER", "http://localhost:"+port)
+
+	p, err := oidctest.New(issuer, getenv("MOCKIDP_CLIENT_ID", "platform"), getenv("MOCKIDP_CLIENT_SECRET", "secret"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	email := getenv("MOCKIDP_EMAIL", "mock.admin@example.com")
+	var groups []string
+	for _, g := range strings.Split(getenv("MOCKIDP_GROUPS", "admins"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	p.SetUser(oidctest.User{
+		Subject:       getenv("MOCKIDP_SUBJECT", email),
+		Email:         email,
+		EmailVerified: true,
+		Name:          getenv("MOCKIDP_NAME", "Mock Admin"),
+		Groups:        groups,
+	})
+
+	log.Printf("Mock identity provider %s signing in %s (groups %v)", issuer, email, groups)
+	log.Fatal(http.ListenAndServe(":"+port, p))
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
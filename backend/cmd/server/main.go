@@ -6,14 +6,22 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // SCHEDULER_TIMEZONE works without zoneinfo on the host
 
 	"github.com/dubai/platform/backend/internal/api"
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/jobs"
 	appMiddleware "github.com/dubai/platform/backend/internal/middleware"
+	"github.com/dubai/platform/backend/internal/notify"
+	"github.com/dubai/platform/backend/internal/scheduler"
 	"github.com/dubai/platform/backend/internal/service"
+	"github.com/dubai/platform/backend/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -40,6 +48,64 @@ func main() {
 	if err := db.InitSchema(ctx); err != nil {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
+	if err := service.BootstrapAdminFromEnv(ctx); err != nil {
+		log.Fatalf("Failed to bootstrap admin user: %v", err)
+	}
+
+	// File Storage
+	if err := storage.InitFromEnv(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	retention := service.RetentionConfig{
+		Trash:             envDays("TRASH_RETENTION_DAYS", 30),
+		LoginHistory:      envDays("LOGIN_HISTORY_RETENTION_DAYS", 90),
+		WebhookDeliveries: envDays("WEBHOOK_DELIVERY_RETENTION_DAYS", 30),
+		Outbox:            envDays("OUTBOX_RETENTION_DAYS", 7),
+	}
+	webhookService := service.NewWebhookService()
+	jobs.Register(service.WebhookJobKind, webhookService.HandleJob)
+	jobs.StartWorkers(jobsCtx, service.WebhookJobKind, 2, time.Minute)
+
+	ocrConcurrency := envInt("OCR_CONCURRENCY", 2, 0)
+	ocrService := service.NewOCRService()
+	jobs.Register(service.OCRJobKind, ocrService.HandleJob)
+	jobs.StartWorkers(jobsCtx, service.OCRJobKind, ocrConcurrency, 10*time.Minute)
+
+	mailTransport, err := notify.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize mail transport: %v", err)
+	}
+	notificationService := service.NewNotificationService(mailTransport)
+	jobs.Register(service.EmailJobKind, notificationService.HandleJob)
+	jobs.StartWorkers(jobsCtx, service.EmailJobKind, 2, time.Minute)
+
+	// Domain events: subscribers react to what services publish to the outbox
+	service.RegisterEventSubscribers()
+	events.StartDispatcher(jobsCtx)
+
+	// Recurring tasks, each run by one instance at a time
+	if tz := os.Getenv("SCHEDULER_TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("Invalid SCHEDULER_TIMEZONE: %v", err)
+		}
+		scheduler.Location = loc
+	}
+	err = service.RegisterScheduledTasks(retention)
+	if err != nil {
+		log.Fatalf("Failed to register scheduled tasks: %v", err)
+	}
+	if err := scheduler.Start(jobsCtx); err != nil {
+		log.Fatalf("Failed to start scheduler: %v", err)
+	}
+
+	trashService := service.NewTrashService()
+	contractService := service.NewContractService()
 
 	// Setup Router
 	r := chi.NewRouter()
@@ -54,14 +120,29 @@ func main() {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key", "X-Request-Id", "If-Match"},
+		ExposedHeaders:   []string{"Link", "X-Request-Id", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
+	// Forwarded client addresses are only believed from our own proxies, so
+	// callers can't pick the IP that login throttling and audit logs see
+	trustedProxies, err := appMiddleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(appMiddleware.RealIP(trustedProxies))
+	r.Use(middleware.RequestID)
+	r.Use(appMiddleware.EchoRequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		httperr.WriteStatus(w, http.StatusNotFound, "Not Found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		httperr.WriteStatus(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	})
 
 	// SERVICES & HANDLERS
 
@@ -70,6 +151,23 @@ func main() {
 	authHandler := api.NewAuthHandler(authService)
 	// r.Post("/api/auth/register", authHandler.Register) // MOVED TO PROTECTED /api/users
 	r.Post("/api/auth/login", authHandler.Login)
+	r.Post("/api/auth/login/mfa", authHandler.VerifyMFA)
+	r.Post("/api/auth/forgot", authHandler.ForgotPassword)
+	r.Post("/api/auth/reset", authHandler.ResetPassword)
+	r.Post("/api/auth/accept-invite", authHandler.AcceptInvite)
+
+	// Single sign-on (routes answer 404 unless OIDC_ISSUER is set)
+	oidcService, err := service.NewOIDCServiceFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure SSO: %v", err)
+	}
+	oidcHandler := api.NewOIDCHandler(oidcService)
+	r.Get("/api/auth/oidc/login", oidcHandler.Login)
+	r.Get("/api/auth/oidc/callback", oidcHandler.Callback)
+
+	// Files (local storage backend only; access is granted by signed URL)
+	uploadHandler := api.NewUploadHandler(storage.Default)
+	r.Get(storage.LocalRoute+"*", uploadHandler.ServeLocal)
 
 	// Protected Routes
 	r.Group(func(r chi.Router) {
@@ -78,6 +176,53 @@ func main() {
 		// Auth (Profile)
 		r.Get("/api/auth/me", authHandler.GetProfile)
 		r.Put("/api/auth/me", authHandler.UpdateProfile)
+		r.Post("/api/auth/password", authHandler.ChangePassword)
+		r.Get("/api/auth/me/logins", authHandler.MyLoginHistory)
+		r.Post("/api/auth/mfa/setup", authHandler.SetupMFA)
+		r.Post("/api/auth/mfa/enable", authHandler.EnableMFA)
+		r.Post("/api/auth/mfa/disable", authHandler.DisableMFA)
+		r.Post("/api/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+		r.Get("/api/login-history", authHandler.LoginHistory)
+
+		// Service accounts and API keys (Admin Only - Enforced in Handler)
+		apiKeyHandler := api.NewAPIKeyHandler(service.NewAPIKeyService())
+		r.Route("/api/service-accounts", func(r chi.Router) {
+			r.Get("/", apiKeyHandler.ListServiceAccounts)
+			r.Post("/", apiKeyHandler.CreateServiceAccount)
+			r.Get("/{id}/keys", apiKeyHandler.ListKeys)
+			r.Post("/{id}/keys", apiKeyHandler.CreateKey)
+			r.Delete("/{id}/keys/{keyId}", apiKeyHandler.RevokeKey)
+		})
+
+		// Webhooks (Admin Only - Enforced in Handler)
+		webhookHandler := api.NewWebhookHandler(webhookService)
+		r.Route("/api/webhooks", func(r chi.Router) {
+			r.Get("/", webhookHandler.List)
+			r.Post("/", webhookHandler.Create)
+			r.Get("/{id}", webhookHandler.Get)
+			r.Put("/{id}", webhookHandler.Update)
+			r.Delete("/{id}", webhookHandler.Delete)
+			r.Post("/{id}/rotate-secret", webhookHandler.RotateSecret)
+			r.Get("/{id}/deliveries", webhookHandler.Deliveries)
+			r.Post("/{id}/deliveries/{deliveryId}/replay", webhookHandler.Replay)
+		})
+
+		// Scheduled tasks (Admin Only - Enforced in Handler)
+		schedulerHandler := api.NewSchedulerHandler(service.NewSchedulerService())
+		r.Route("/api/scheduler/tasks", func(r chi.Router) {
+			r.Get("/", schedulerHandler.List)
+			r.Get("/{name}/runs", schedulerHandler.Runs)
+			r.Post("/{name}/run", schedulerHandler.Run)
+			r.Post("/{name}/pause", schedulerHandler.Pause)
+			r.Post("/{name}/resume", schedulerHandler.Resume)
+		})
+
+		// Notifications
+		notificationHandler := api.NewNotificationHandler(notificationService)
+		r.Get("/api/auth/me/notifications", notificationHandler.Preferences)
+		r.Put("/api/auth/me/notifications", notificationHandler.UpdatePreferences)
+		r.Get("/api/notifications/outbox", notificationHandler.Outbox)
+		r.Post("/api/notifications/outbox/{id}/retry", notificationHandler.Retry)
 
 		// USERS (Admin Only - Enforced in Handler)
 		// USERS (Admin Only - Enforced in Handler)
@@ -93,6 +238,9 @@ func main() {
 					authHandler.UpdateUser(w, r.WithContext(ctx))
 				})
 				r.Delete("/", authHandler.DeleteUser)
+				r.Post("/invite", authHandler.ResendInvite)
+				r.Post("/unlock", authHandler.UnlockUser)
+				r.Delete("/mfa", authHandler.ResetMFA)
 			})
 		})
 
@@ -104,6 +252,8 @@ func main() {
 			r.Get("/{id}", talentHandler.Get)
 			r.Post("/", talentHandler.Create)
 			r.Put("/{id}", talentHandler.Update)
+			r.Patch("/{id}", talentHandler.Patch)
+			r.Delete("/{id}", talentHandler.Delete)
 		})
 
 		// Clients
@@ -112,7 +262,10 @@ func main() {
 		r.Route("/api/clients", func(r chi.Router) {
 			r.Get("/", clientHandler.List)
 			r.Post("/", clientHandler.Create)
+			r.Get("/{id}", clientHandler.Get)
 			r.Put("/{id}", clientHandler.Update)
+			r.Patch("/{id}", clientHandler.Patch)
+			r.Delete("/{id}", clientHandler.Delete)
 			r.Put("/{id}/archive", clientHandler.Archive)
 			r.Get("/{id}/contacts", clientHandler.ListContacts)
 			r.Post("/{id}/contacts", clientHandler.CreateContact)
@@ -132,6 +285,7 @@ func main() {
 			r.Get("/{id}", projectHandler.Get)
 			r.Post("/", projectHandler.Create)
 			r.Put("/{id}", projectHandler.Update)
+			r.Patch("/{id}", projectHandler.Patch)
 			r.Delete("/{id}", projectHandler.Delete)
 			r.Route("/{id}/assignments", func(r chi.Router) {
 				r.Get("/", assignmentHandler.ListByProject)
@@ -144,6 +298,7 @@ func main() {
 			r.Post("/", assignmentHandler.Create)
 			r.Get("/{id}", assignmentHandler.Get)
 			r.Put("/{id}", assignmentHandler.Update)
+			r.Patch("/{id}", assignmentHandler.Patch)
 			r.Delete("/{id}", assignmentHandler.Delete)
 		})
 
@@ -153,15 +308,32 @@ func main() {
 		r.Route("/api/invoices", func(r chi.Router) {
 			r.Get("/", invoiceHandler.List)
 			r.Post("/", invoiceHandler.Create)
+			r.Get("/{id}", invoiceHandler.Get)
+			r.Get("/{id}/pdf", invoiceHandler.PDF)
+			r.Post("/{id}/send", invoiceHandler.Send)
+			r.Post("/{id}/paid", invoiceHandler.MarkPaid)
 		})
 
 		// Contracts
-		contractService := service.NewContractService()
 		contractHandler := api.NewContractHandler(contractService)
+		contractTemplateHandler := api.NewContractTemplateHandler(service.NewContractTemplateService())
 		r.Route("/api/contracts", func(r chi.Router) {
 			r.Get("/", contractHandler.List)
 			r.Post("/", contractHandler.Create)
+			r.Post("/generate", contractTemplateHandler.Generate)
+			r.Get("/expiring", contractHandler.Expiring)
+			r.Get("/{id}", contractHandler.Get)
+			r.Put("/{id}", contractHandler.Update)
 			r.Delete("/{id}", contractHandler.Delete)
+			r.Post("/{id}/{action}", contractHandler.Transition)
+		})
+		r.Route("/api/contract-templates", func(r chi.Router) {
+			r.Get("/", contractTemplateHandler.List)
+			r.Post("/", contractTemplateHandler.Create)
+			r.Post("/preview", contractTemplateHandler.Preview)
+			r.Get("/{id}", contractTemplateHandler.Get)
+			r.Put("/{id}", contractTemplateHandler.Update)
+			r.Delete("/{id}", contractTemplateHandler.Delete)
 		})
 
 		// Skills
@@ -180,17 +352,33 @@ func main() {
 		r.Route("/api/payments", func(r chi.Router) {
 			r.Get("/", paymentHandler.List)
 			r.Post("/", paymentHandler.Create)
+			r.Post("/{id}/approve", paymentHandler.Approve)
 		})
 
 		// Documents
 		documentService := service.NewDocumentService()
+		extractionService := service.NewExtractionService()
 		documentHandler := api.NewDocumentHandler(documentService)
+		ocrHandler := api.NewOCRHandler(ocrService, documentService)
+		extractionHandler := api.NewExtractionHandler(extractionService, documentService)
 		r.Route("/api/documents", func(r chi.Router) {
 			r.Get("/", documentHandler.List)
+			r.Get("/search", documentHandler.Search)
 			r.Post("/", documentHandler.Create)
+			r.Post("/upload", documentHandler.Upload)
 			r.Delete("/{id}", documentHandler.Delete)
 			r.Put("/{id}", documentHandler.Update)
+			r.Get("/{id}/download", documentHandler.Download)
+			r.Get("/{id}/access-log", documentHandler.AccessLog)
+			r.Get("/{id}/versions", documentHandler.Versions)
+			r.Post("/{id}/versions", documentHandler.UploadVersion)
+			r.Get("/{id}/diff", documentHandler.Diff)
+			r.Post("/{id}/ocr", ocrHandler.Rerun)
+			r.Get("/{id}/extractions", extractionHandler.List)
+			r.Post("/{id}/extractions", extractionHandler.Propose)
 		})
+		r.Post("/api/extractions/{id}/accept", extractionHandler.Accept)
+		r.Post("/api/extractions/{id}/reject", extractionHandler.Reject)
 
 		// Finance
 		financeService := service.NewFinanceService()
@@ -205,6 +393,17 @@ func main() {
 			r.Get("/investments", financeHandler.ListInvestments)
 			r.Post("/investments", financeHandler.CreateInvestment)
 		})
+
+		// Uploads
+		r.Post("/api/uploads", uploadHandler.Upload)
+
+		// Trash (Admin Only - Enforced in Handler)
+		trashHandler := api.NewTrashHandler(trashService)
+		r.Route("/api/trash", func(r chi.Router) {
+			r.Get("/", trashHandler.List)
+			r.Post("/{entityType}/{id}/restore", trashHandler.Restore)
+			r.Delete("/{entityType}/{id}", trashHandler.Purge)
+		})
 	})
 
 	// Server config
@@ -239,3 +438,22 @@ func main() {
 
 	log.Println("Server exiting")
 }
+
+// envInt reads a whole number of at least min from name, or returns def when
+// it isn't set. Anything else stops the server.
+func envInt(name string, def, min int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		log.Fatalf("Invalid %s: %q", name, v)
+	}
+	return n
+}
+
+// envDays reads a retention period of at least a day, given in days.
+func envDays(name string, def int) time.Duration {
+	return time.Duration(envInt(name, def, 1)) * 24 * time.Hour
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// APIKeyHandler manages service accounts and their API keys. Admin only.
+type APIKeyHandler struct {
+	Service *service.APIKeyService
+}
+
+func NewAPIKeyHandler(s *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{Service: s}
+}
+
+// ListServiceAccounts handles GET /api/service-accounts.
+func (h *APIKeyHandler) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	accounts, err := h.Service.ListServiceAccounts(r.Context())
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// CreateServiceAccount handles POST /api/service-accounts. Disable or delete
+// the account through /api/users like any other user.
+func (h *APIKeyHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req models.CreateServiceAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	account, err := h.Service.CreateServiceAccount(r.Context(), req)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+// ListKeys handles GET /api/service-accounts/{id}/keys.
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	keys, err := h.Service.ListKeys(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateKey handles POST /api/service-accounts/{id}/keys. The response is
+// the only time the key is shown.
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req models.CreateAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	resp, err := h.Service.CreateKey(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RevokeKey handles DELETE /api/service-accounts/{id}/keys/{keyId}.
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := h.Service.RevokeKey(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "keyId")); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
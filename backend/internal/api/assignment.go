@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -18,42 +20,71 @@ func NewAssignmentHandler(s *service.AssignmentService) *AssignmentHandler {
 }
 
 func (h *AssignmentHandler) List(w http.ResponseWriter, r *http.Request) {
-	assignments, err := h.Service.List(r.Context())
+	q, errs := listquery.Parse(r.URL.Query(), service.AssignmentList)
+	if len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid list query", errs...))
+		return
+	}
+	expand, ok := expandParam(w, r, service.AssignmentRelations)
+	if !ok {
+		return
+	}
+	page, err := h.Service.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
-	if assignments == nil {
-		assignments = []models.ProjectAssignment{}
+	if err := service.ExpandAssignments(r.Context(), expand, service.Pointers(page.Data)...); err != nil {
+		httperr.Write(w, err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(assignments)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *AssignmentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
+		return
+	}
+	expand, ok := expandParam(w, r, service.AssignmentRelations)
+	if !ok {
+		return
+	}
+	// Client users only see their own company's assignments
+	allowed, err := service.CanAccessEntity(r.Context(), "ASSIGNMENT", id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if !allowed {
+		httperr.Write(w, service.ErrAssignmentNotFound)
 		return
 	}
+
 	a, err := h.Service.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		httperr.Write(w, err)
 		return
 	}
+	if err := service.ExpandAssignments(r.Context(), expand, a); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	setETag(w, a.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(a)
 }
 
 func (h *AssignmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var a models.ProjectAssignment
-	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &a) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &a); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -65,35 +96,80 @@ func (h *AssignmentHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *AssignmentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
 		return
 	}
 
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
 	var a models.ProjectAssignment
-	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeReplacement(w, r, &a) {
 		return
 	}
+	a.Version = version
 
 	if err := h.Service.Update(r.Context(), id, &a); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
+	setETag(w, a.Version)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(a)
 }
 
+// Patch changes the fields named in a JSON Merge Patch and keeps the rest.
+func (h *AssignmentHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	a, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if version == 0 {
+		version = a.Version
+	}
+	if _, ok := decodePatch(w, r, a); !ok {
+		return
+	}
+	a.Version = version
+
+	if err := h.Service.Update(r.Context(), id, a); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	setETag(w, a.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
 func (h *AssignmentHandler) ListByProject(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "id")
 	if projectID == "" {
-		http.Error(w, "Missing Project ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing Project ID")
+		return
+	}
+	expand, ok := expandParam(w, r, service.AssignmentRelations)
+	if !ok {
 		return
 	}
 
 	assignments, err := h.Service.ListByProject(r.Context(), projectID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandAssignments(r.Context(), expand, service.Pointers(assignments)...); err != nil {
+		httperr.Write(w, err)
 		return
 	}
 	if assignments == nil {
@@ -106,12 +182,12 @@ func (h *AssignmentHandler) ListByProject(w http.ResponseWriter, r *http.Request
 func (h *AssignmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
 		return
 	}
 
 	if err := h.Service.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
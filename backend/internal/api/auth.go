@@ -2,10 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -25,16 +29,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	roleVal := r.Context().Value("role")
 	if roleVal == nil {
 		log.Println("ERROR: Role context is nil")
-		http.Error(w, "Internal Server Error: Missing Context", http.StatusInternalServerError)
+		httperr.WriteStatus(w, http.StatusInternalServerError, "Internal Server Error: Missing Context")
 		return
 	}
 	role := roleVal.(string)
 	log.Printf("DEBUG: Register caller role: %s", role)
 
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("ERROR: JSON decode failed: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 	log.Printf("DEBUG: Register request payload for user: %s (Client: %v)", req.Email, req.ClientID)
@@ -47,33 +49,33 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		clientID, ok := r.Context().Value("client_id").(string)
 		if !ok || clientID == "" {
 			log.Println("ERROR: Client Admin missing client_id context")
-			http.Error(w, "Forbidden: No client context", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: No client context")
 			return
 		}
 
 		// Enforce client_id match
 		if req.ClientID == nil || *req.ClientID != uuid.MustParse(clientID) {
 			log.Println("ERROR: Client Admin tried to create user for different client or no client")
-			http.Error(w, "Forbidden: Can only create users for your own client", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Can only create users for your own client")
 			return
 		}
 
 		// Enforce allowed roles (Client User or Client Admin)
 		if req.Role != models.RoleClientUser && req.Role != models.RoleClientAdmin {
 			log.Printf("ERROR: Client Admin tried to assign invalid role: %s", req.Role)
-			http.Error(w, "Forbidden: Invalid role assignment", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Invalid role assignment")
 			return
 		}
 	} else {
 		log.Printf("ERROR: Access denied for role: %s", role)
-		http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
 		return
 	}
 
 	user, err := h.Service.Register(r.Context(), req)
 	if err != nil {
 		log.Printf("ERROR: Service.Register failed: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -85,14 +87,24 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	resp, err := h.Service.Login(r.Context(), req)
-	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	resp, err := h.Service.Login(r.Context(), req, clientIP(r), r.UserAgent())
+	writeLoginResult(w, resp, err)
+}
+
+// writeLoginResult answers a sign-in step: the session or MFA challenge, or
+// why there isn't one.
+func writeLoginResult(w http.ResponseWriter, resp *models.LoginResponse, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		// A wrong code fails the sign-in, it isn't a form field to correct
+		httperr.WriteStatus(w, http.StatusUnauthorized, err.Error())
+		return
+	case err != nil:
+		httperr.Write(w, err)
 		return
 	}
 
@@ -106,7 +118,7 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.Service.GetProfile(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		httperr.Write(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -116,15 +128,16 @@ func (h *AuthHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(string)
 
-	var req map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Role and client are changed by admins through UpdateUser; sending
+	// them here is rejected as an unknown field
+	var req models.UpdateProfileRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	user, err := h.Service.UpdateProfile(r.Context(), userID, req)
+	user, err := h.Service.UpdateProfile(r.Context(), userID, &models.UpdateUserRequest{UpdateProfileRequest: req})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -139,7 +152,7 @@ func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	roleVal := r.Context().Value("role")
 	if roleVal == nil {
 		log.Println("ERROR: Role context is nil")
-		http.Error(w, "Internal Server Error: Missing Context", http.StatusInternalServerError)
+		httperr.WriteStatus(w, http.StatusInternalServerError, "Internal Server Error: Missing Context")
 		return
 	}
 	role := roleVal.(string)
@@ -156,20 +169,20 @@ func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		ownClientID, ok := r.Context().Value("client_id").(string)
 		if !ok || ownClientID == "" {
 			log.Printf("ERROR: Client User/Admin (role=%s) missing client_id context. Context Dump: %v", role, r.Context())
-			http.Error(w, fmt.Sprintf("Forbidden: User has role %s but no client_id associated. Please contact support.", role), http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, fmt.Sprintf("Forbidden: User has role %s but no client_id associated. Please contact support.", role))
 			return
 		}
 		targetClientID = ownClientID
 	} else {
 		log.Printf("ERROR: Access denied for role: %s. ClientID Context: %v", role, r.Context().Value("client_id"))
-		http.Error(w, fmt.Sprintf("Forbidden: Insufficient permissions for role %s", role), http.StatusForbidden)
+		httperr.WriteStatus(w, http.StatusForbidden, fmt.Sprintf("Forbidden: Insufficient permissions for role %s", role))
 		return
 	}
 
 	users, err := h.Service.ListUsers(r.Context(), targetClientID)
 	if err != nil {
 		log.Printf("ERROR: Service.ListUsers failed: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -183,14 +196,13 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	roleVal := r.Context().Value("role")
 	if roleVal == nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httperr.WriteStatus(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 	role := roleVal.(string)
 
-	var req map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req models.UpdateUserRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -202,7 +214,7 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		// 1. We need to fetch the target user first to check their client_id
 		targetUser, err := h.Service.GetProfile(r.Context(), targetUserID)
 		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+			httperr.Write(w, err)
 			return
 		}
 
@@ -210,39 +222,37 @@ func (h *AuthHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 		// Check invalid access
 		if targetUser.ClientID == nil || *targetUser.ClientID != uuid.MustParse(ownClientID) {
-			http.Error(w, "Forbidden: Can only update your own team members", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Can only update your own team members")
 			return
 		}
 
 		// Prevent changing their own role to ADMIN or changing others to ADMIN
-		if roleUpdate, ok := req["role"].(string); ok {
-			if roleUpdate != string(models.RoleClientUser) && roleUpdate != string(models.RoleClientAdmin) {
-				http.Error(w, "Forbidden: Invalid role assignment", http.StatusForbidden)
+		if req.Role != nil {
+			if *req.Role != models.RoleClientUser && *req.Role != models.RoleClientAdmin {
+				httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Invalid role assignment")
 				return
 			}
 		}
 
 		// Prevent changing client_id
-		if _, ok := req["client_id"]; ok {
-			http.Error(w, "Forbidden: Cannot move users between clients", http.StatusForbidden)
+		if req.ClientID != nil {
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Cannot move users between clients")
 			return
 		}
 
 	} else {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden")
 		return
 	}
 
-	user, err := h.Service.UpdateProfile(r.Context(), targetUserID, req)
+	user, err := h.Service.UpdateProfile(r.Context(), targetUserID, &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
 }
 
 func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -251,7 +261,7 @@ func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	// RBAC: Only ADMIN can delete users (for now, or Client Admin for their own users)
 	roleVal := r.Context().Value("role")
 	if roleVal == nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		httperr.WriteStatus(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 	role := roleVal.(string)
@@ -262,29 +272,195 @@ func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		// Client Admin can only delete users of their own client
 		ownClientID, ok := r.Context().Value("client_id").(string)
 		if !ok || ownClientID == "" {
-			http.Error(w, "Forbidden: No client context", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: No client context")
 			return
 		}
 
 		targetUser, err := h.Service.GetProfile(r.Context(), targetUserID)
 		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+			httperr.Write(w, err)
 			return
 		}
 
 		if targetUser.ClientID == nil || *targetUser.ClientID != uuid.MustParse(ownClientID) {
-			http.Error(w, "Forbidden: Can only delete your own team members", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Can only delete your own team members")
 			return
 		}
 	} else {
-		http.Error(w, "Forbidden: Insufficient permissions", http.StatusForbidden)
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
 		return
 	}
 
 	if err := h.Service.DeleteUser(r.Context(), targetUserID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendInvite emails a new invitation to a user who hasn't activated their
+// account. Client admins can only do so for their own team.
+func (h *AuthHandler) ResendInvite(w http.ResponseWriter, r *http.Request) {
+	targetUserID := chi.URLParam(r, "id")
+
+	role, _ := r.Context().Value("role").(string)
+	if role == "ADMIN" {
+		// Admin can invite anyone
+	} else if role == "CLIENT_ADMIN" {
+		ownClientID, ok := r.Context().Value("client_id").(string)
+		if !ok || ownClientID == "" {
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: No client context")
+			return
+		}
+
+		targetUser, err := h.Service.GetProfile(r.Context(), targetUserID)
+		if err != nil {
+			httperr.Write(w, err)
+			return
+		}
+
+		if targetUser.ClientID == nil || *targetUser.ClientID != uuid.MustParse(ownClientID) {
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Can only invite your own team members")
+			return
+		}
+	} else {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
+		return
+	}
+
+	if err := h.Service.ResendInvite(r.Context(), targetUserID); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword always answers 202 so it can't be used to find out which
+// accounts exist.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.Service.ForgotPassword(r.Context(), req.Email); err != nil {
+		log.Printf("ERROR: ForgotPassword failed: %v", err)
+		httperr.WriteStatus(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.SetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.Service.ResetPassword(r.Context(), req); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var req models.SetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.Service.AcceptInvite(r.Context(), req); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req models.ChangePasswordRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
+	resp, err := h.Service.ChangePassword(r.Context(), userID, req)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// UnlockUser handles POST /api/users/{id}/unlock, lifting a lockout after
+// failed sign-ins.
+func (h *AuthHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := h.Service.UnlockUser(r.Context(), chi.URLParam(r, "id")); err != nil {
+		httperr.Write(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// LoginHistory handles GET /api/login-history for admins, filtered by
+// user_id, ip and success.
+func (h *AuthHandler) LoginHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	filter := service.LoginHistoryFilter{UserID: q.Get("user_id"), IP: q.Get("ip")}
+	if v := q.Get("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid success")
+			return
+		}
+		filter.Success = &success
+	}
+	h.writeLoginHistory(w, r, filter)
+}
+
+// MyLoginHistory handles GET /api/auth/me/logins so users can spot sign-ins
+// that weren't them.
+func (h *AuthHandler) MyLoginHistory(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+	h.writeLoginHistory(w, r, service.LoginHistoryFilter{UserID: userID})
+}
+
+func (h *AuthHandler) writeLoginHistory(w http.ResponseWriter, r *http.Request, filter service.LoginHistoryFilter) {
+	filter.Limit = 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		filter.Limit = n
+	}
+
+	attempts, err := h.Service.LoginHistory(r.Context(), filter)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
+
+// clientIP is the caller's address without the port. The RealIP middleware
+// has already taken it from X-Forwarded-For when the request came through a
+// trusted proxy.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
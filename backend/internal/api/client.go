@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -19,27 +21,67 @@ func NewClientHandler(s *service.ClientService) *ClientHandler {
 }
 
 func (h *ClientHandler) List(w http.ResponseWriter, r *http.Request) {
-	clients, err := h.Service.List(r.Context())
+	q, errs := listquery.Parse(r.URL.Query(), service.ClientList)
+	if len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid list query", errs...))
+		return
+	}
+	expand, ok := expandParam(w, r, service.ClientRelations)
+	if !ok {
+		return
+	}
+	page, err := h.Service.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
-	if clients == nil {
-		clients = []models.Client{}
+	if err := service.ExpandClients(r.Context(), expand, service.Pointers(page.Data)...); err != nil {
+		httperr.Write(w, err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(clients)
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *ClientHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	expand, ok := expandParam(w, r, service.ClientRelations)
+	if !ok {
+		return
+	}
+	// Client users only see their own company
+	allowed, err := service.CanAccessEntity(r.Context(), "CLIENT", id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if !allowed {
+		httperr.Write(w, service.ErrClientNotFound)
+		return
+	}
+
+	c, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandClients(r.Context(), expand, c); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	setETag(w, c.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
 }
 
 func (h *ClientHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var c models.Client
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &c) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -51,30 +93,67 @@ func (h *ClientHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
 		return
 	}
 
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
 	var c models.Client
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeReplacement(w, r, &c) {
 		return
 	}
+	c.Version = version
 
 	if err := h.Service.Update(r.Context(), id, &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
+	setETag(w, c.Version)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(c)
 }
 
+// Patch changes the fields named in a JSON Merge Patch and keeps the rest.
+func (h *ClientHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if version == 0 {
+		version = c.Version
+	}
+	if _, ok := decodePatch(w, r, c); !ok {
+		return
+	}
+	c.Version = version
+
+	if err := h.Service.Update(r.Context(), id, c); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	setETag(w, c.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
 func (h *ClientHandler) ListContacts(w http.ResponseWriter, r *http.Request) {
 	clientID := chi.URLParam(r, "id")
 	contacts, err := h.Service.ListContacts(r.Context(), clientID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	if contacts == nil {
@@ -89,19 +168,18 @@ func (h *ClientHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
 	// Parse UUID to ensure validity and assigning to struct
 	cid, err := uuid.Parse(clientID)
 	if err != nil {
-		http.Error(w, "Invalid Client ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid Client ID")
 		return
 	}
 
 	var c models.ClientContact
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &c) {
 		return
 	}
 	c.ClientID = cid // Force assignment from URL
 
 	if err := h.Service.AddContact(r.Context(), &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -112,18 +190,17 @@ func (h *ClientHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 	contactID := chi.URLParam(r, "contactId")
 	if contactID == "" {
-		http.Error(w, "Missing Contact ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing Contact ID")
 		return
 	}
 
 	var c models.ClientContact
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &c) {
 		return
 	}
 
 	if err := h.Service.UpdateContact(r.Context(), contactID, &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -134,12 +211,12 @@ func (h *ClientHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 	contactID := chi.URLParam(r, "contactId")
 	if contactID == "" {
-		http.Error(w, "Missing Contact ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing Contact ID")
 		return
 	}
 
 	if err := h.Service.DeleteContact(r.Context(), contactID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -149,12 +226,30 @@ func (h *ClientHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
 func (h *ClientHandler) Archive(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing Client ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing Client ID")
 		return
 	}
 
 	if err := h.Service.Archive(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ClientHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing Client ID")
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err)
 		return
 	}
 
@@ -3,7 +3,11 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -17,27 +21,69 @@ func NewContractHandler(s *service.ContractService) *ContractHandler {
 	return &ContractHandler{Service: s}
 }
 
+// List handles GET /api/contracts?client_id=&status=&type=
 func (h *ContractHandler) List(w http.ResponseWriter, r *http.Request) {
-	contracts, err := h.Service.List(r.Context())
+	q, errs := listquery.Parse(r.URL.Query(), service.ContractList)
+	if len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid list query", errs...))
+		return
+	}
+	expand, ok := expandParam(w, r, service.ContractRelations)
+	if !ok {
+		return
+	}
+	page, err := h.Service.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
-	if contracts == nil {
-		contracts = []models.Contract{}
+	if err := service.ExpandContracts(r.Context(), expand, service.Pointers(page.Data)...); err != nil {
+		httperr.Write(w, err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(contracts)
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *ContractHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	expand, ok := expandParam(w, r, service.ContractRelations)
+	if !ok {
+		return
+	}
+	allowed, err := service.CanAccessEntity(r.Context(), "CONTRACT", id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if !allowed {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	c, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandContracts(r.Context(), expand, c); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
 }
 
 func (h *ContractHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
 	var c models.Contract
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &c) {
 		return
 	}
 	if err := h.Service.Create(r.Context(), &c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -45,17 +91,111 @@ func (h *ContractHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(c)
 }
 
+func (h *ContractHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	var input service.ContractUpdateInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	c, err := h.Service.Update(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
 func (h *ContractHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "ID is required")
 		return
 	}
 
 	if err := h.Service.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Transition handles POST /api/contracts/{id}/{action} for send, sign,
+// terminate and renew. Renew responds with the new draft contract.
+func (h *ContractHandler) Transition(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	var c *models.Contract
+	var err error
+	status := http.StatusOK
+	switch chi.URLParam(r, "action") {
+	case "send":
+		c, err = h.Service.Send(r.Context(), id)
+	case "sign":
+		var input struct {
+			SignedAt *time.Time `json:"signed_at"`
+		}
+		if !decodeOptional(w, r, &input) {
+			return
+		}
+		c, err = h.Service.Sign(r.Context(), id, input.SignedAt)
+	case "terminate":
+		var input service.ContractTerminateInput
+		if !decodeOptional(w, r, &input) {
+			return
+		}
+		c, err = h.Service.Terminate(r.Context(), id, input)
+	case "renew":
+		var input service.ContractRenewInput
+		if !decodeOptional(w, r, &input) {
+			return
+		}
+		c, err = h.Service.Renew(r.Context(), id, input)
+		status = http.StatusCreated
+	default:
+		httperr.WriteStatus(w, http.StatusNotFound, "Unknown action")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Expiring handles GET /api/contracts/expiring?within_days=
+// listing signed contracts inside (or within_days of) their notice window.
+func (h *ContractHandler) Expiring(w http.ResponseWriter, r *http.Request) {
+	within := 0
+	if v := r.URL.Query().Get("within_days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid within_days")
+			return
+		}
+		within = n
+	}
+
+	contracts, err := h.Service.Expiring(r.Context(), within)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if contracts == nil {
+		contracts = []models.Contract{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contracts)
+}
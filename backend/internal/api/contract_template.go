@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type ContractTemplateHandler struct {
+	Service *service.ContractTemplateService
+}
+
+func NewContractTemplateHandler(s *service.ContractTemplateService) *ContractTemplateHandler {
+	return &ContractTemplateHandler{Service: s}
+}
+
+// List handles GET /api/contract-templates?type=
+// including the built-in template for each type.
+func (h *ContractTemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	templates, err := h.Service.List(r.Context(), r.URL.Query().Get("type"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func (h *ContractTemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	t, err := h.Service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *ContractTemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var t models.ContractTemplate
+	if !decodeJSON(w, r, &t) {
+		return
+	}
+	if err := h.Service.Create(r.Context(), &t); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *ContractTemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var input service.ContractTemplateUpdateInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	t, err := h.Service.Update(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *ContractTemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := h.Service.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Preview handles POST /api/contract-templates/preview with {type, body},
+// rendering the template against sample data without saving it.
+func (h *ContractTemplateHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var input struct {
+		Type string `json:"type"`
+		Body string `json:"body"`
+	}
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	content, err := h.Service.Preview(input.Type, input.Body)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="preview.pdf"`)
+	w.Write(content)
+}
+
+// Generate handles POST /api/contracts/generate, responding with the new
+// DRAFT contract.
+func (h *ContractTemplateHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	var input service.ContractGenerateInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	c, err := h.Service.Generate(r.Context(), input)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
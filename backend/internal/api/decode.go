@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/dubai/platform/backend/internal/validate"
+)
+
+// maxBodyBytes caps JSON request bodies. Files go through multipart uploads,
+// which have their own limit.
+const maxBodyBytes = 1 << 20
+
+// decodeJSON reads a JSON body into v and checks it against its `validate`
+// tags. Fields v doesn't have are rejected, so typos don't pass silently.
+// When it returns false the request has been answered.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, ok := readBody(w, r)
+	if !ok {
+		return false
+	}
+	return decodeBody(w, body, v)
+}
+
+// decodeReplacement is decodeJSON for PUT, which replaces the whole record:
+// every field of v that isn't readonly must be sent, if only as null, so a
+// forgotten field can't clear a column. Partial changes go through PATCH.
+func decodeReplacement(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, ok := readBody(w, r)
+	if !ok {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		// Not an object; let decodeBody say what's wrong with it
+		return decodeBody(w, body, v)
+	}
+	present := make(map[string]bool, len(fields))
+	for name := range fields {
+		present[name] = true
+	}
+	if errs := validate.Complete(v, present); len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("PUT replaces the whole record, so every field must be sent; use PATCH to change some of them", errs...))
+		return false
+	}
+	return decodeBody(w, body, v)
+}
+
+// decodePatch applies a JSON Merge Patch (RFC 7396) from the request to v,
+// which holds the current record, and validates the result: fields set to
+// null are cleared, fields left out keep their value. It returns the
+// top-level fields the patch named.
+func decodePatch(w http.ResponseWriter, r *http.Request, v any) (map[string]bool, bool) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ := mime.ParseMediaType(ct)
+		if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			httperr.WriteStatus(w, http.StatusUnsupportedMediaType, "PATCH bodies must be application/merge-patch+json")
+			return nil, false
+		}
+	}
+	body, ok := readBody(w, r)
+	if !ok {
+		return nil, false
+	}
+	var patch any
+	if err := json.Unmarshal(body, &patch); err != nil {
+		writeDecodeError(w, err)
+		return nil, false
+	}
+	fields, isObject := patch.(map[string]any)
+	if !isObject {
+		httperr.WriteStatus(w, http.StatusBadRequest, "a merge patch must be a JSON object")
+		return nil, false
+	}
+
+	current, err := json.Marshal(v)
+	if err != nil {
+		httperr.Write(w, err)
+		return nil, false
+	}
+	var doc any
+	if err := json.Unmarshal(current, &doc); err != nil {
+		httperr.Write(w, err)
+		return nil, false
+	}
+	merged, err := json.Marshal(mergePatch(doc, patch))
+	if err != nil {
+		httperr.Write(w, err)
+		return nil, false
+	}
+
+	// Decode into a zero value: cleared fields are missing from merged and
+	// must not keep what v had
+	fresh := reflect.New(reflect.TypeOf(v).Elem())
+	if !decodeBody(w, merged, fresh.Interface()) {
+		return nil, false
+	}
+	reflect.ValueOf(v).Elem().Set(fresh.Elem())
+
+	named := make(map[string]bool, len(fields))
+	for name := range fields {
+		named[name] = true
+	}
+	return named, true
+}
+
+// mergePatch applies patch to target as RFC 7396 describes.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+	return t
+}
+
+// readBody reads a request body of at most maxBodyBytes.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		writeDecodeError(w, err)
+		return nil, false
+	}
+	return body, true
+}
+
+// decodeBody decodes a JSON document into v, rejecting unknown fields, and
+// validates v.
+func decodeBody(w http.ResponseWriter, body []byte, v any) bool {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the JSON body")
+	}
+	if err != nil {
+		writeDecodeError(w, err)
+		return false
+	}
+	if errs := validate.Struct(v); len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid request", errs...))
+		return false
+	}
+	return true
+}
+
+// decodeOptional decodes a JSON body if one was sent.
+func decodeOptional(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	return decodeJSON(w, r, v)
+}
+
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		httperr.WriteStatus(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		httperr.WriteStatus(w, http.StatusBadRequest, "request body is required")
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		httperr.WriteStatus(w, http.StatusBadRequest, "request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		httperr.Write(w, service.ValidationError("invalid request", service.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be a " + jsonTypeName(typeErr.Type.Kind().String()),
+		}))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		httperr.Write(w, service.ValidationError("invalid request", service.FieldError{
+			Field:   field,
+			Message: "is not a known field",
+		}))
+	default:
+		httperr.WriteStatus(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+	}
+}
+
+// jsonTypeName names a Go kind the way a JSON client knows it.
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice", kind == "array":
+		return "list"
+	case kind == "struct", kind == "map":
+		return "object"
+	case kind == "bool":
+		return "boolean"
+	}
+	return kind
+}
+
+// setETag sends the record version as the response's ETag.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// ifMatch returns the record version an If-Match header asks to update, or 0
+// when the request has none or sends "*". An ETag that can't be one of ours
+// fails the request with 412.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version < 1 || !strings.HasPrefix(header, `"`) {
+		httperr.Write(w, service.ErrVersionMismatch)
+		return 0, false
+	}
+	return version, true
+}
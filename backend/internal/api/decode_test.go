@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+)
+
+type decodeInput struct {
+	Name   string `json:"name" validate:"required"`
+	Amount int    `json:"amount" validate:"min=1"`
+	Notes  string `json:"notes"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int // 0 when the body is accepted
+		wantField  string
+	}{
+		{name: "valid", body: `{"name":"Acme","amount":3}`},
+		{name: "unknown field", body: `{"name":"Acme","amonut":3}`, wantStatus: http.StatusUnprocessableEntity, wantField: "amonut"},
+		{name: "wrong type", body: `{"name":"Acme","amount":"3"}`, wantStatus: http.StatusUnprocessableEntity, wantField: "amount"},
+		{name: "failed rule", body: `{"amount":3}`, wantStatus: http.StatusUnprocessableEntity, wantField: "name"},
+		{name: "empty body", body: ``, wantStatus: http.StatusBadRequest},
+		{name: "not JSON", body: `name=Acme`, wantStatus: http.StatusBadRequest},
+		{name: "truncated", body: `{"name":"Acme"`, wantStatus: http.StatusBadRequest},
+		{name: "trailing data", body: `{"name":"Acme"} {"name":"Evil"}`, wantStatus: http.StatusBadRequest},
+		{
+			name:       "over the size limit",
+			body:       `{"name":"Acme","notes":"` + strings.Repeat("x", maxBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "just under the size limit",
+			body: `{"name":"Acme","notes":"` + strings.Repeat("x", maxBodyBytes-len(`{"name":"Acme","notes":""}`)) + `"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			var in decodeInput
+			ok := decodeJSON(w, r, &in)
+
+			if tt.wantStatus == 0 {
+				if !ok {
+					t.Fatalf("rejected with %d: %s", w.Code, w.Body)
+				}
+				return
+			}
+			if ok {
+				t.Fatal("accepted")
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			var body httperr.Body
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Error.Fields) != 1 || body.Error.Fields[0].Field != tt.wantField {
+				t.Errorf("fields = %v, want one for %s", body.Error.Fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestDecodeReplacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wantOK bool
+	}{
+		{name: "every field", body: `{"name":"Acme","amount":1,"notes":null}`, wantOK: true},
+		{name: "field left out", body: `{"name":"Acme","amount":1}`},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		var in decodeInput
+		if ok := decodeReplacement(w, r, &in); ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v (%d %s)", tt.name, ok, tt.wantOK, w.Code, w.Body)
+		}
+	}
+}
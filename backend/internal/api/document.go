@@ -2,14 +2,33 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
+	"github.com/dubai/platform/backend/internal/textdiff"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+// redactDocument replaces raw storage locations with the authorized download
+// endpoint so file links can't be shared outside the platform.
+func redactDocument(d *models.Document) {
+	d.FileURL = ""
+	d.FileKey = ""
+	d.DownloadURL = fmt.Sprintf("/api/documents/%s/download", d.ID)
+}
+
 type DocumentHandler struct {
 	Service *service.DocumentService
 }
@@ -32,7 +51,7 @@ func (h *DocumentHandler) List(w http.ResponseWriter, r *http.Request) {
 	if role == "CLIENT_ADMIN" || role == "CLIENT_USER" {
 		ownClientID, ok := r.Context().Value("client_id").(string)
 		if !ok || ownClientID == "" {
-			http.Error(w, "Forbidden: No client context", http.StatusForbidden)
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: No client context")
 			return
 		}
 		// Force filter to their client
@@ -49,45 +68,266 @@ func (h *DocumentHandler) List(w http.ResponseWriter, r *http.Request) {
 		entityID = ownClientID
 	}
 
-	docs, err := h.Service.List(r.Context(), entityType, entityID)
+	history := r.URL.Query().Get("history") == "true"
+	docs, err := h.Service.List(r.Context(), entityType, entityID, history)
 	if err != nil {
 		fmt.Printf("DocumentHandler List Error: %v\n", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	if docs == nil {
 		docs = []models.Document{}
 	}
+	for i := range docs {
+		redactDocument(&docs[i])
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(docs)
 }
 
+// Search handles GET /api/documents/search?q=&entity_type=&status=&from=&to=&limit=&history=
+// Dates are YYYY-MM-DD; "to" is inclusive.
+func (h *DocumentHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	input := service.DocumentSearchInput{
+		Query:      strings.TrimSpace(q.Get("q")),
+		EntityType: q.Get("entity_type"),
+		Status:     q.Get("status"),
+		History:    q.Get("history") == "true",
+	}
+	if input.Query == "" {
+		httperr.WriteStatus(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid from date")
+			return
+		}
+		input.From = &from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid to date")
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		input.To = &to
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		input.Limit = limit
+	}
+
+	results, err := h.Service.Search(r.Context(), input)
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			httperr.WriteStatus(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		httperr.Write(w, err)
+		return
+	}
+	if results == nil {
+		results = []models.DocumentSearchResult{}
+	}
+	for i := range results {
+		redactDocument(&results[i].Document)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// Create links a file uploaded straight to storage (e.g. UploadThing) to an
+// entity. The storage key is taken on trust, so this is staff only; client
+// portal users go through Upload.
 func (h *DocumentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
 	var d models.Document
-	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &d) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &d); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+	redactDocument(&d)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Upload accepts a multipart form (file, entity_type, entity_id, status) and
+// stores the file server-side before creating the document.
+func (h *DocumentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	file, header, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	entityID, err := uuid.Parse(r.FormValue("entity_id"))
+	if err != nil {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid entity_id")
+		return
+	}
+
+	d := models.Document{
+		EntityType: r.FormValue("entity_type"),
+		EntityID:   entityID,
+		FileName:   header.Filename,
+		FileType:   uploadContentType(header),
+		FileSize:   header.Size,
+		Status:     r.FormValue("status"),
+	}
+	if d.EntityType == "" {
+		httperr.WriteStatus(w, http.StatusBadRequest, "entity_type is required")
+		return
+	}
+
+	if err := h.Service.Upload(r.Context(), &d, file); err != nil {
+		httperr.Write(w, err)
 		return
 	}
+	redactDocument(&d)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(d)
 }
 
+// UploadVersion accepts a multipart form (file, optional status) with a
+// revised file for the document and makes it the latest version.
+func (h *DocumentHandler) UploadVersion(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.Service.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
+		return
+	}
+
+	file, header, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	d := models.Document{
+		FileName: header.Filename,
+		FileType: uploadContentType(header),
+		FileSize: header.Size,
+		Status:   r.FormValue("status"),
+	}
+	if err := h.Service.UploadVersion(r.Context(), id, &d, file); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			httperr.WriteStatus(w, http.StatusNotFound, "Document not found")
+			return
+		}
+		httperr.Write(w, err)
+		return
+	}
+	redactDocument(&d)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+// Versions lists every version of a document, oldest first.
+func (h *DocumentHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.Service.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
+		return
+	}
+
+	docs, err := h.Service.Versions(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if docs == nil {
+		docs = []models.Document{}
+	}
+	for i := range docs {
+		redactDocument(&docs[i])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(docs)
+}
+
+// Diff handles GET /api/documents/{id}/diff?from=&to=&format=
+// Versions default to the latest and the one before it; format=unified
+// returns a plain-text diff instead of JSON.
+func (h *DocumentHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.Service.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	var from, to int
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid from version")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid to version")
+			return
+		}
+	}
+
+	diff, err := h.Service.Diff(r.Context(), id, from, to)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	if q.Get("format") == "unified" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "--- version %d\n+++ version %d\n", diff.FromVersion, diff.ToVersion)
+		io.WriteString(w, textdiff.Unified(diff.Lines, 3))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func writeDocumentAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pgx.ErrNoRows) {
+		httperr.WriteStatus(w, http.StatusNotFound, "Document not found")
+		return
+	}
+	httperr.Write(w, err)
+}
+
 func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "ID is required")
+		return
+	}
+	if _, err := h.Service.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
 		return
 	}
 
 	if err := h.Service.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -97,7 +337,11 @@ func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 func (h *DocumentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "ID is required")
+		return
+	}
+	if _, err := h.Service.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
 		return
 	}
 
@@ -107,8 +351,7 @@ func (h *DocumentHandler) Update(w http.ResponseWriter, r *http.Request) {
 		EntityType *string `json:"entity_type"`
 		EntityID   *string `json:"entity_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &input) {
 		return
 	}
 
@@ -120,9 +363,99 @@ func (h *DocumentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.Service.Update(r.Context(), id, updateInput); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// Download checks the caller can see the document's owning entity, records the
+// access and then redirects to a short-lived signed URL. ?mode=stream proxies
+// the bytes through the API instead and ?mode=url returns the signed URL as JSON.
+func (h *DocumentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	d, err := h.Service.Authorize(r.Context(), id)
+	if err != nil {
+		writeDocumentAuthError(w, err)
+		return
+	}
+
+	mode := strings.ToUpper(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = "REDIRECT"
+	}
+	if mode != "REDIRECT" && mode != "STREAM" && mode != "URL" {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid mode")
+		return
+	}
+
+	access := models.DocumentAccess{DocumentID: d.ID, Mode: mode}
+	if userID, err := uuid.Parse(fmt.Sprint(r.Context().Value("user_id"))); err == nil {
+		access.UserID = &userID
+	}
+	if ip := clientIP(r); ip != "" {
+		access.IPAddress = &ip
+	}
+	if ua := r.UserAgent(); ua != "" {
+		access.UserAgent = &ua
+	}
+	if err := h.Service.LogAccess(r.Context(), &access); err != nil {
+		// Refuse to hand out the file if we can't record who took it
+		httperr.Write(w, err)
+		return
+	}
+
+	if mode == "STREAM" {
+		file, err := h.Service.Open(r.Context(), d)
+		if err != nil {
+			log.Printf("ERROR: opening document %s failed: %v", d.ID, err)
+			httperr.WriteStatus(w, http.StatusBadGateway, "File storage is unavailable")
+			return
+		}
+		defer file.Close()
+
+		contentType := d.FileType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": d.FileName}))
+		w.Header().Set("Cache-Control", "private, no-store")
+		io.Copy(w, file)
+		return
+	}
+
+	url, expiresAt, err := h.Service.SignedURL(r.Context(), d)
+	if err != nil {
+		log.Printf("ERROR: signing URL for document %s failed: %v", d.ID, err)
+		httperr.WriteStatus(w, http.StatusBadGateway, "File storage is unavailable")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	if mode == "URL" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"url": url, "expires_at": expiresAt})
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// AccessLog lists who downloaded a document (Admin Only).
+func (h *DocumentHandler) AccessLog(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	entries, err := h.Service.ListAccessLog(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if entries == nil {
+		entries = []models.DocumentAccess{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDocumentCreateRequiresStaff(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "role", "CLIENT_ADMIN")
+	body := strings.NewReader(`{"entity_type":"CLIENT","entity_id":"5d6c0f4e-8f0a-4a55-9d4e-2c1b7a0e9b11","file_name":"a.pdf","file_key":"someone-elses-key"}`)
+	r := httptest.NewRequest(http.MethodPost, "/api/documents", body).WithContext(ctx)
+	w := httptest.NewRecorder()
+	(&DocumentHandler{}).Create(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
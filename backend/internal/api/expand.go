@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
+)
+
+// expandParam reads ?expand= for a resource that can expand the given
+// relations. When it returns false the request has been answered.
+func expandParam(w http.ResponseWriter, r *http.Request, relations service.Expand) (service.Expand, bool) {
+	e, err := service.ParseExpand(r.URL.Query().Get("expand"), relations)
+	if err != nil {
+		httperr.Write(w, err)
+		return nil, false
+	}
+	return e, true
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+type ExtractionHandler struct {
+	Service   *service.ExtractionService
+	Documents *service.DocumentService
+}
+
+func NewExtractionHandler(s *service.ExtractionService, d *service.DocumentService) *ExtractionHandler {
+	return &ExtractionHandler{Service: s, Documents: d}
+}
+
+// requireStaff allows internal roles; client portal users may not write
+// contracts or expenses, or move records to the trash.
+func requireStaff(w http.ResponseWriter, r *http.Request) bool {
+	role, _ := r.Context().Value("role").(string)
+	switch role {
+	case "ADMIN", "HR", "SALES", "FINANCE":
+		return true
+	}
+	httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
+	return false
+}
+
+// Propose runs field extraction over a document's OCR content.
+// Body: {"document_type": "contract" | "invoice"}
+func (h *ExtractionHandler) Propose(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	d, ok := h.authorizeDocument(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DocumentType string `json:"document_type"`
+	}
+	if !decodeOptional(w, r, &req) {
+		return
+	}
+
+	e, err := h.Service.Propose(r.Context(), d, req.DocumentType)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+func (h *ExtractionHandler) List(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := h.authorizeDocument(w, r, id); !ok {
+		return
+	}
+
+	extractions, err := h.Service.ListByDocument(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if extractions == nil {
+		extractions = []models.DocumentExtraction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(extractions)
+}
+
+// Accept applies a proposed extraction, optionally with corrected values.
+// Body: {"target_id": "...", "fields": {"amount": "1200.00"}}
+func (h *ExtractionHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	if !h.authorizeExtraction(w, r) {
+		return
+	}
+
+	var input service.ExtractionAcceptInput
+	if !decodeOptional(w, r, &input) {
+		return
+	}
+
+	e, err := h.Service.Accept(r.Context(), chi.URLParam(r, "id"), input)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+func (h *ExtractionHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	if !h.authorizeExtraction(w, r) {
+		return
+	}
+
+	e, err := h.Service.Reject(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+func (h *ExtractionHandler) authorizeDocument(w http.ResponseWriter, r *http.Request, id string) (*models.Document, bool) {
+	d, err := h.Documents.Authorize(r.Context(), id)
+	if err != nil {
+		writeDocumentAuthError(w, err)
+		return nil, false
+	}
+	return d, true
+}
+
+func (h *ExtractionHandler) authorizeExtraction(w http.ResponseWriter, r *http.Request) bool {
+	e, err := h.Service.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		httperr.WriteStatus(w, http.StatusNotFound, "Extraction not found")
+		return false
+	}
+	if err != nil {
+		httperr.Write(w, err)
+		return false
+	}
+	_, ok := h.authorizeDocument(w, r, e.DocumentID.String())
+	return ok
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 )
@@ -20,7 +21,7 @@ func NewFinanceHandler(s *service.FinanceService) *FinanceHandler {
 func (h *FinanceHandler) ListCapital(w http.ResponseWriter, r *http.Request) {
 	caps, err := h.Service.ListCapital(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(caps)
@@ -28,13 +29,12 @@ func (h *FinanceHandler) ListCapital(w http.ResponseWriter, r *http.Request) {
 
 func (h *FinanceHandler) CreateCapital(w http.ResponseWriter, r *http.Request) {
 	var c models.Capital
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &c) {
 		return
 	}
 	created, err := h.Service.CreateCapital(r.Context(), c)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(created)
@@ -44,7 +44,7 @@ func (h *FinanceHandler) CreateCapital(w http.ResponseWriter, r *http.Request) {
 func (h *FinanceHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
 	budgets, err := h.Service.ListBudgets(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(budgets)
@@ -52,13 +52,12 @@ func (h *FinanceHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
 
 func (h *FinanceHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
 	var b models.Budget
-	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &b) {
 		return
 	}
 	created, err := h.Service.CreateBudget(r.Context(), b)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(created)
@@ -68,7 +67,7 @@ func (h *FinanceHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
 func (h *FinanceHandler) ListExpenses(w http.ResponseWriter, r *http.Request) {
 	expenses, err := h.Service.ListExpenses(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(expenses)
@@ -76,13 +75,12 @@ func (h *FinanceHandler) ListExpenses(w http.ResponseWriter, r *http.Request) {
 
 func (h *FinanceHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 	var e models.Expense
-	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &e) {
 		return
 	}
 	created, err := h.Service.CreateExpense(r.Context(), e)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(created)
@@ -92,7 +90,7 @@ func (h *FinanceHandler) CreateExpense(w http.ResponseWriter, r *http.Request) {
 func (h *FinanceHandler) ListInvestments(w http.ResponseWriter, r *http.Request) {
 	invs, err := h.Service.ListInvestments(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(invs)
@@ -100,13 +98,12 @@ func (h *FinanceHandler) ListInvestments(w http.ResponseWriter, r *http.Request)
 
 func (h *FinanceHandler) CreateInvestment(w http.ResponseWriter, r *http.Request) {
 	var i models.Investment
-	if err := json.NewDecoder(r.Body).Decode(&i); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &i) {
 		return
 	}
 	created, err := h.Service.CreateInvestment(r.Context(), i)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(created)
@@ -3,9 +3,13 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
 )
 
 type InvoiceHandler struct {
@@ -17,27 +21,36 @@ func NewInvoiceHandler(s *service.InvoiceService) *InvoiceHandler {
 }
 
 func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
-	invoices, err := h.Service.List(r.Context())
+	q, errs := listquery.Parse(r.URL.Query(), service.InvoiceList)
+	if len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid list query", errs...))
+		return
+	}
+	expand, ok := expandParam(w, r, service.InvoiceRelations)
+	if !ok {
+		return
+	}
+	page, err := h.Service.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
-	if invoices == nil {
-		invoices = []models.Invoice{}
+	if err := service.ExpandInvoices(r.Context(), expand, service.Pointers(page.Data)...); err != nil {
+		httperr.Write(w, err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(invoices)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *InvoiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var i models.Invoice
-	if err := json.NewDecoder(r.Body).Decode(&i); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &i) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &i); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -45,3 +58,95 @@ func (h *InvoiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(i)
 }
+
+func (h *InvoiceHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	expand, ok := expandParam(w, r, service.InvoiceRelations)
+	if !ok {
+		return
+	}
+	if !canAccessInvoice(w, r, id) {
+		return
+	}
+	i, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandInvoices(r.Context(), expand, i); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i)
+}
+
+// PDF handles GET /api/invoices/{id}/pdf. Add ?download=1 to get an
+// attachment instead of an inline document.
+func (h *InvoiceHandler) PDF(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !canAccessInvoice(w, r, id) {
+		return
+	}
+	content, i, err := h.Service.RenderPDF(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", disposition+`; filename="`+i.InvoiceNumber+`.pdf"`)
+	w.Write(content)
+}
+
+// Send handles POST /api/invoices/{id}/send, emailing the PDF to the client.
+func (h *InvoiceHandler) Send(w http.ResponseWriter, r *http.Request) {
+	if !requireFinance(w, r) {
+		return
+	}
+	i, err := h.Service.Send(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i)
+}
+
+// MarkPaid handles POST /api/invoices/{id}/paid with an optional
+// {"paid_at": ...} when the payment arrived earlier.
+func (h *InvoiceHandler) MarkPaid(w http.ResponseWriter, r *http.Request) {
+	if !requireFinance(w, r) {
+		return
+	}
+	var input struct {
+		PaidAt *time.Time `json:"paid_at"`
+	}
+	if !decodeOptional(w, r, &input) {
+		return
+	}
+	i, err := h.Service.MarkPaid(r.Context(), chi.URLParam(r, "id"), input.PaidAt)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i)
+}
+
+func canAccessInvoice(w http.ResponseWriter, r *http.Request, id string) bool {
+	ok, err := service.CanAccessEntity(r.Context(), "INVOICE", id)
+	if err != nil {
+		httperr.Write(w, err)
+		return false
+	}
+	if !ok {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden")
+		return false
+	}
+	return true
+}
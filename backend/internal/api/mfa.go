@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// VerifyMFA handles POST /api/auth/login/mfa, the second step of signing in
+// to an account with two-factor auth.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.Service.VerifyMFA(r.Context(), req, clientIP(r), r.UserAgent())
+	writeLoginResult(w, resp, err)
+}
+
+// SetupMFA handles POST /api/auth/mfa/setup. The provisioning URI is meant to
+// be shown as a QR code for the authenticator app.
+func (h *AuthHandler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	resp, err := h.Service.SetupMFA(r.Context(), userID)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// EnableMFA handles POST /api/auth/mfa/enable with the first code from the
+// authenticator app.
+func (h *AuthHandler) EnableMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req models.MFACodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	resp, err := h.Service.EnableMFA(r.Context(), userID, req.Code)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RegenerateRecoveryCodes handles POST /api/auth/mfa/recovery-codes.
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req models.MFACodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.Service.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA handles POST /api/auth/mfa/disable.
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(string)
+
+	var req models.MFADisableRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.Service.DisableMFA(r.Context(), userID, req); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetMFA handles DELETE /api/users/{id}/mfa for a user who lost their
+// authenticator and recovery codes.
+func (h *AuthHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := h.Service.ResetMFA(r.Context(), chi.URLParam(r, "id")); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type NotificationHandler struct {
+	Service *service.NotificationService
+}
+
+func NewNotificationHandler(s *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{Service: s}
+}
+
+// Preferences handles GET /api/auth/me/notifications.
+func (h *NotificationHandler) Preferences(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	prefs, err := h.Service.Preferences(r.Context(), userID)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// UpdatePreferences handles PUT /api/auth/me/notifications with a body like
+// {"contract.expiring": false}.
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	var settings map[string]bool
+	if !decodeJSON(w, r, &settings) {
+		return
+	}
+	prefs, err := h.Service.UpdatePreferences(r.Context(), userID, settings)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// Outbox handles GET /api/notifications/outbox?status=&limit=
+func (h *NotificationHandler) Outbox(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+	emails, err := h.Service.ListOutbox(r.Context(), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if emails == nil {
+		emails = []models.OutboxEmail{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emails)
+}
+
+// Retry handles POST /api/notifications/outbox/{id}/retry for failed emails.
+func (h *NotificationHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	err := h.Service.Retry(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, service.ErrOutboxNotFound) {
+		httperr.WriteStatus(w, http.StatusNotFound, "No failed email with that ID")
+		return
+	}
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type OCRHandler struct {
+	Service   *service.OCRService
+	Documents *service.DocumentService
+}
+
+func NewOCRHandler(s *service.OCRService, d *service.DocumentService) *OCRHandler {
+	return &OCRHandler{Service: s, Documents: d}
+}
+
+// Rerun queues OCR again for a document the caller can access.
+func (h *OCRHandler) Rerun(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.Documents.Authorize(r.Context(), id); err != nil {
+		writeDocumentAuthError(w, err)
+		return
+	}
+
+	if err := h.Service.Rerun(r.Context(), id); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/service"
+)
+
+const oidcStateCookie = "oidc_state"
+
+// OIDCHandler serves SSO sign-in. Service is nil when SSO isn't configured.
+type OIDCHandler struct {
+	Service *service.OIDCService
+}
+
+func NewOIDCHandler(s *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{Service: s}
+}
+
+// Login handles GET /api/auth/oidc/login?redirect=/path and sends the browser
+// to the identity provider.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if h.Service == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	authURL, state, err := h.Service.Begin(r.Context(), r.URL.Query().Get("redirect"))
+	if err != nil {
+		log.Printf("ERROR: SSO sign-in could not start: %v", err)
+		http.Redirect(w, r, h.Service.FailureURL("sso_unavailable"), http.StatusFound)
+		return
+	}
+	// Binds the sign-in to this browser, so a callback link from someone
+	// else's sign-in can't be used to log us in as them
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handles GET /api/auth/oidc/callback, where the identity provider
+// sends the browser back, and hands the session to the frontend.
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if h.Service == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Printf("SSO: provider returned %s: %s", e, q.Get("error_description"))
+		http.Redirect(w, r, h.Service.FailureURL("sso_failed"), http.StatusFound)
+		return
+	}
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || q.Get("state") == "" || cookie.Value != q.Get("state") {
+		http.Redirect(w, r, h.Service.FailureURL("sso_expired"), http.StatusFound)
+		return
+	}
+
+	resp, redirect, err := h.Service.Complete(r.Context(), q.Get("state"), q.Get("code"), clientIP(r), r.UserAgent())
+	switch {
+	case errors.Is(err, service.ErrInvalidSSOState):
+		http.Redirect(w, r, h.Service.FailureURL("sso_expired"), http.StatusFound)
+		return
+	case errors.Is(err, service.ErrSSODenied):
+		http.Redirect(w, r, h.Service.FailureURL("sso_denied"), http.StatusFound)
+		return
+	case err != nil:
+		log.Printf("ERROR: SSO sign-in failed: %v", err)
+		http.Redirect(w, r, h.Service.FailureURL("sso_failed"), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, h.Service.SuccessURL(resp.Token, redirect), http.StatusFound)
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
 )
 
 type PaymentHandler struct {
@@ -17,27 +20,36 @@ func NewPaymentHandler(s *service.PaymentService) *PaymentHandler {
 }
 
 func (h *PaymentHandler) List(w http.ResponseWriter, r *http.Request) {
-	payments, err := h.Service.List(r.Context())
+	q, errs := listquery.Parse(r.URL.Query(), service.PaymentList)
+	if len(errs) > 0 {
+		httperr.Write(w, service.ValidationError("invalid list query", errs...))
+		return
+	}
+	expand, ok := expandParam(w, r, service.PaymentRelations)
+	if !ok {
+		return
+	}
+	page, err := h.Service.List(r.Context(), q)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
-	if payments == nil {
-		payments = []models.ContractorPayment{}
+	if err := service.ExpandPayments(r.Context(), expand, service.Pointers(page.Data)...); err != nil {
+		httperr.Write(w, err)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(payments)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var p models.ContractorPayment
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &p) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -45,3 +57,27 @@ func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(p)
 }
+
+// Approve handles POST /api/payments/{id}/approve.
+func (h *PaymentHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	if !requireFinance(w, r) {
+		return
+	}
+	p, err := h.Service.Approve(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// requireFinance allows admins and finance staff.
+func requireFinance(w http.ResponseWriter, r *http.Request) bool {
+	role, _ := r.Context().Value("role").(string)
+	if role != "ADMIN" && role != "FINANCE" {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
+		return false
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -19,9 +20,17 @@ func NewProjectHandler(s *service.ProjectService) *ProjectHandler {
 }
 
 func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
+	expand, ok := expandParam(w, r, service.ProjectRelations)
+	if !ok {
+		return
+	}
 	projects, err := h.Service.List(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandProjects(r.Context(), expand, service.Pointers(projects)...); err != nil {
+		httperr.Write(w, err)
 		return
 	}
 	if projects == nil {
@@ -34,27 +43,46 @@ func (h *ProjectHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *ProjectHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
+		return
+	}
+	expand, ok := expandParam(w, r, service.ProjectRelations)
+	if !ok {
+		return
+	}
+	// Client users only see their own company's projects
+	allowed, err := service.CanAccessEntity(r.Context(), "PROJECT", id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if !allowed {
+		httperr.Write(w, service.ErrProjectNotFound)
 		return
 	}
+
 	p, err := h.Service.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandProjects(r.Context(), expand, p); err != nil {
+		httperr.Write(w, err)
 		return
 	}
+	setETag(w, p.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(p)
 }
 
 func (h *ProjectHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var p models.Project
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &p) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -67,35 +95,90 @@ func (h *ProjectHandler) Update(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
 	if err != nil {
-		http.Error(w, "Invalid UUID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid UUID")
 		return
 	}
 
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
 	var p models.Project
-	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeReplacement(w, r, &p) {
 		return
 	}
 	p.ID = id
+	p.Version = version
 
 	if err := h.Service.Update(r.Context(), id.String(), &p); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
+	setETag(w, p.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// Patch changes the fields named in a JSON Merge Patch and keeps the rest.
+// Planned roles are only replaced when the patch names them.
+func (h *ProjectHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid UUID")
+		return
+	}
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := h.Service.Get(r.Context(), id.String())
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	// Without If-Match the patch applies to the version just read, so a
+	// concurrent edit isn't overwritten with stale values
+	if version == 0 {
+		version = p.Version
+	}
+	roles := p.PlannedRoles
+	fields, ok := decodePatch(w, r, p)
+	if !ok {
+		return
+	}
+	p.ID = id
+	p.Version = version
+	if !fields["planned_roles"] {
+		p.PlannedRoles = nil
+	}
+
+	if err := h.Service.Update(r.Context(), id.String(), p); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if p.PlannedRoles == nil {
+		p.PlannedRoles = roles
+	}
+
+	setETag(w, p.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(p)
 }
 
 func (h *ProjectHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
 		return
 	}
 
 	if err := h.Service.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
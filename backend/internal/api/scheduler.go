@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// SchedulerHandler shows and controls the recurring background tasks. Admin
+// only.
+type SchedulerHandler struct {
+	Service *service.SchedulerService
+}
+
+func NewSchedulerHandler(s *service.SchedulerService) *SchedulerHandler {
+	return &SchedulerHandler{Service: s}
+}
+
+// List handles GET /api/scheduler/tasks.
+func (h *SchedulerHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	tasks, err := h.Service.List(r.Context())
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks)
+}
+
+// Runs handles GET /api/scheduler/tasks/{name}/runs?status=FAILED&limit=50.
+func (h *SchedulerHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+	runs, err := h.Service.Runs(r.Context(), chi.URLParam(r, "name"), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// Run handles POST /api/scheduler/tasks/{name}/run. The task runs shortly
+// after, so the response is 202; follow it through the task's runs.
+func (h *SchedulerHandler) Run(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	t, err := h.Service.Trigger(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(t)
+}
+
+// Pause handles POST /api/scheduler/tasks/{name}/pause.
+func (h *SchedulerHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	t, err := h.Service.Pause(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// Resume handles POST /api/scheduler/tasks/{name}/resume.
+func (h *SchedulerHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	t, err := h.Service.Resume(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
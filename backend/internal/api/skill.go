@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 )
@@ -19,7 +20,7 @@ func NewSkillHandler(s *service.SkillService) *SkillHandler {
 func (h *SkillHandler) List(w http.ResponseWriter, r *http.Request) {
 	skills, err := h.Service.List(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	if skills == nil {
@@ -31,12 +32,11 @@ func (h *SkillHandler) List(w http.ResponseWriter, r *http.Request) {
 
 func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var sk models.Skill
-	if err := json.NewDecoder(r.Body).Decode(&sk); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &sk) {
 		return
 	}
 	if err := h.Service.Create(r.Context(), &sk); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -47,11 +47,11 @@ func (h *SkillHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *SkillHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
-		http.Error(w, "missing id", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "missing id")
 		return
 	}
 	if err := h.Service.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -62,12 +62,11 @@ func (h *SkillHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		OldName string `json:"oldName"`
 		NewName string `json:"newName"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &body) {
 		return
 	}
 	if err := h.Service.UpdateCategory(r.Context(), body.OldName, body.NewName); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/dubai/platform/backend/internal/httperr"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/dubai/platform/backend/internal/service"
 	"github.com/go-chi/chi/v5"
@@ -19,10 +20,18 @@ func NewTalentHandler(s *service.TalentService) *TalentHandler {
 }
 
 func (h *TalentHandler) List(w http.ResponseWriter, r *http.Request) {
+	expand, ok := expandParam(w, r, service.TalentRelations)
+	if !ok {
+		return
+	}
 	talents, err := h.Service.ListTalent(r.Context())
 	if err != nil {
 		log.Printf("TalentHandler List Error: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandTalent(r.Context(), expand, service.Pointers(talents)...); err != nil {
+		httperr.Write(w, err)
 		return
 	}
 	if talents == nil {
@@ -34,13 +43,12 @@ func (h *TalentHandler) List(w http.ResponseWriter, r *http.Request) {
 
 func (h *TalentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var t models.Talent
-	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &t) {
 		return
 	}
 
 	if err := h.Service.Create(r.Context(), &t); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
@@ -52,38 +60,111 @@ func (h *TalentHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *TalentHandler) Get(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
+		return
+	}
+	expand, ok := expandParam(w, r, service.TalentRelations)
+	if !ok {
 		return
 	}
 
 	t, err := h.Service.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Not found", http.StatusNotFound)
+		httperr.Write(w, err)
+		return
+	}
+	if err := service.ExpandTalent(r.Context(), expand, t); err != nil {
+		httperr.Write(w, err)
 		return
 	}
 
+	setETag(w, t.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(t)
 }
 func (h *TalentHandler) Update(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "Missing ID", http.StatusBadRequest)
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
 		return
 	}
 
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
 	var t models.Talent
-	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeReplacement(w, r, &t) {
 		return
 	}
+	t.Version = version
 
 	if err := h.Service.Update(r.Context(), id, &t); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httperr.Write(w, err)
 		return
 	}
 
+	setETag(w, t.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(t)
 }
+
+// Patch changes the fields named in a JSON Merge Patch and keeps the rest.
+// Skills are only replaced when the patch names them.
+func (h *TalentHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	version, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if version == 0 {
+		version = t.Version
+	}
+	skills, history := t.Skills, t.History
+	fields, ok := decodePatch(w, r, t)
+	if !ok {
+		return
+	}
+	t.Version = version
+	if !fields["skills"] {
+		t.Skills = nil
+	}
+
+	if err := h.Service.Update(r.Context(), id, t); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if t.Skills == nil {
+		t.Skills = skills
+	}
+	t.History = history
+
+	setETag(w, t.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (h *TalentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireStaff(w, r) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing ID")
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), id); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+type TrashHandler struct {
+	Service *service.TrashService
+}
+
+func NewTrashHandler(s *service.TrashService) *TrashHandler {
+	return &TrashHandler{Service: s}
+}
+
+// requireAdmin rejects callers that are not ADMIN. Trash spans every client,
+// so it is never exposed to client-portal roles.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	role, _ := r.Context().Value("role").(string)
+	if role != "ADMIN" {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: Insufficient permissions")
+		return false
+	}
+	return true
+}
+
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	items, err := h.Service.List(r.Context(), r.URL.Query().Get("entity_type"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	entityType := chi.URLParam(r, "entityType")
+	id := chi.URLParam(r, "id")
+	if err := h.Service.Restore(r.Context(), entityType, id); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TrashHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	entityType := chi.URLParam(r, "entityType")
+	id := chi.URLParam(r, "id")
+	if err := h.Service.Purge(r.Context(), entityType, id); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
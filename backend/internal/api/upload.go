@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/storage"
+)
+
+// maxUploadBytes caps multipart uploads handled by the API.
+const maxUploadBytes = 32 << 20
+
+type UploadHandler struct {
+	Storage storage.Storage
+}
+
+func NewUploadHandler(s storage.Storage) *UploadHandler {
+	return &UploadHandler{Storage: s}
+}
+
+// readUpload parses a multipart request and returns the "file" part.
+func readUpload(w http.ResponseWriter, r *http.Request) (multipart.File, *multipart.FileHeader, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httperr.WriteStatus(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads must be at most %d MB", maxUploadBytes>>20))
+			return nil, nil, false
+		}
+		httperr.WriteStatus(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return nil, nil, false
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		httperr.WriteStatus(w, http.StatusBadRequest, "Missing file")
+		return nil, nil, false
+	}
+	return file, header, true
+}
+
+func uploadContentType(header *multipart.FileHeader) string {
+	if ct := header.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// Upload stores a file and returns its key and URL, mirroring what the
+// Uploadthing client hands the frontend, so the result can be attached to
+// a contract or document.
+func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	file, header, ok := readUpload(w, r)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	prefix := r.FormValue("prefix")
+	if prefix == "" || strings.Contains(prefix, "..") {
+		prefix = "uploads"
+	}
+
+	obj, err := h.Storage.Put(r.Context(), storage.NewKey(prefix, header.Filename), file, header.Size, uploadContentType(header))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(obj)
+}
+
+// ServeLocal streams files stored by the local backend. Requests must carry
+// a valid signature produced by the backend.
+func (h *UploadHandler) ServeLocal(w http.ResponseWriter, r *http.Request) {
+	local, ok := h.Storage.(*storage.Local)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, storage.LocalRoute)
+	q := r.URL.Query()
+	if !local.Verify(key, q.Get("expires"), q.Get("signature")) {
+		httperr.WriteStatus(w, http.StatusForbidden, "Invalid or expired link")
+		return
+	}
+
+	file, err := local.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		httperr.Write(w, err)
+		return
+	}
+	defer file.Close()
+
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, time.Time{}, seeker)
+		return
+	}
+	io.Copy(w, file)
+}
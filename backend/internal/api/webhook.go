@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+)
+
+// WebhookHandler manages outbound webhook endpoints. Admin only.
+type WebhookHandler struct {
+	Service *service.WebhookService
+}
+
+func NewWebhookHandler(s *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Service: s}
+}
+
+func (h *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	endpoints, err := h.Service.List(r.Context())
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+func (h *WebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	e, err := h.Service.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// Create handles POST /api/webhooks. The response holds the signing secret.
+func (h *WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req models.WebhookEndpointRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	e, err := h.Service.Create(r.Context(), req)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+// Update handles PUT /api/webhooks/{id}. Send "enabled": true to turn an
+// endpoint that was disabled after failures back on.
+func (h *WebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req models.WebhookEndpointRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	e, err := h.Service.Update(r.Context(), chi.URLParam(r, "id"), req)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+func (h *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := h.Service.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateSecret handles POST /api/webhooks/{id}/rotate-secret.
+func (h *WebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	e, err := h.Service.RotateSecret(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(e)
+}
+
+// Deliveries handles GET /api/webhooks/{id}/deliveries?status=FAILED&limit=50.
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			httperr.WriteStatus(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+	deliveries, err := h.Service.Deliveries(r.Context(), chi.URLParam(r, "id"), r.URL.Query().Get("status"), limit)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// Replay handles POST /api/webhooks/{id}/deliveries/{deliveryId}/replay.
+func (h *WebhookHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	d, err := h.Service.Replay(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(d)
+}
@@ -55,15 +55,14 @@ func InitSchema(ctx context.Context) error {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
 
-	// Ensure default admin user exists
-	defaultUserSQL := `
-		INSERT INTO users (email, username, password_hash, role)
-		VALUES ('admin@example.com', 'admin', '$2a$10$bpHeIIQnJ93ra8L2h.3L3OSH4BrfK7EclDdS.t.qjDMean/uY/rWK', 'ADMIN')
-		ON CONFLICT (email) DO UPDATE SET role = 'ADMIN', username = 'admin', password_hash = '$2a$10$bpHeIIQnJ93ra8L2h.3L3OSH4BrfK7EclDdS.t.qjDMean/uY/rWK';
-	`
-	_, err = Pool.Exec(ctx, defaultUserSQL)
+	// Deployments from before the bootstrap admin were seeded with a public
+	// default password. Keep such an account restricted until it's changed.
+	_, err = Pool.Exec(ctx, `
+		UPDATE users SET must_change_password = true
+		WHERE password_hash = '$2a$10$bpHeIIQnJ93ra8L2h.3L3OSH4BrfK7EclDdS.t.qjDMean/uY/rWK' AND NOT must_change_password
+	`)
 	if err != nil {
-		return fmt.Errorf("failed to create default user: %w", err)
+		return fmt.Errorf("failed to flag default admin password: %w", err)
 	}
 
 	return nil
@@ -285,6 +285,343 @@ CREATE TABLE IF NOT EXISTS investments (
   created_at TIMESTAMP NOT NULL DEFAULT now()
 );
 
+-- SOFT DELETE (Idempotent)
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE talent ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE talent ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS document_access_log (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  mode TEXT NOT NULL, -- REDIRECT, STREAM, URL
+  ip_address TEXT,
+  user_agent TEXT,
+  accessed_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- BACKGROUND JOBS
+
+CREATE TABLE IF NOT EXISTS jobs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  kind TEXT NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}',
+  status TEXT NOT NULL DEFAULT 'QUEUED', -- QUEUED, RUNNING, DONE, FAILED
+  attempts INTEGER NOT NULL DEFAULT 0,
+  max_attempts INTEGER NOT NULL DEFAULT 5,
+  run_at TIMESTAMP NOT NULL DEFAULT now(),
+  locked_at TIMESTAMP,
+  last_error TEXT,
+  finished_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS ocr_error TEXT;
+
+-- DOCUMENT SEARCH
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', coalesce(file_name, '')), 'A') ||
+  setweight(to_tsvector('english', coalesce(content, '')), 'B')
+) STORED;
+
+-- DOCUMENT EXTRACTION
+CREATE TABLE IF NOT EXISTS document_extractions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+  document_type TEXT NOT NULL, -- contract, invoice
+  fields JSONB NOT NULL DEFAULT '[]',
+  status TEXT NOT NULL DEFAULT 'PROPOSED', -- PROPOSED, ACCEPTED, REJECTED
+  target_type TEXT, -- CONTRACT, EXPENSE
+  target_id UUID,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  reviewed_at TIMESTAMP
+);
+
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS document_id UUID REFERENCES documents(id) ON DELETE SET NULL;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS rate NUMERIC(12,2);
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS rate_period TEXT; -- HOUR, DAY, MONTH, YEAR
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS currency TEXT;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS document_id UUID REFERENCES documents(id) ON DELETE SET NULL;
+ALTER TABLE expenses ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+
+-- DOCUMENT VERSIONS
+-- Each row is one file version; versions of the same logical document share
+-- logical_id (the id of the first version). The latest has superseded_by NULL.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS logical_id UUID;
+UPDATE documents SET logical_id = id WHERE logical_id IS NULL;
+ALTER TABLE documents ALTER COLUMN logical_id SET NOT NULL;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS superseded_by UUID REFERENCES documents(id) ON DELETE SET NULL;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS superseded_at TIMESTAMP;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- CONTRACT LIFECYCLE
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS msa_id UUID REFERENCES contracts(id) ON DELETE RESTRICT;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS sent_at TIMESTAMP;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS signed_at TIMESTAMP;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS terminated_at TIMESTAMP;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS termination_reason TEXT;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS renewed_from_id UUID REFERENCES contracts(id) ON DELETE SET NULL;
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS expiry_flagged_at TIMESTAMP;
+
+-- CONTRACT TEMPLATES
+CREATE TABLE IF NOT EXISTS contract_templates (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  contract_type contract_type NOT NULL,
+  name TEXT NOT NULL,
+  body TEXT NOT NULL, -- Go text/template producing the markup rendered to PDF
+  is_default BOOLEAN NOT NULL DEFAULT false,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS template_id UUID REFERENCES contract_templates(id) ON DELETE SET NULL;
+
+-- INVOICE PDF
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS billing_address TEXT; -- Multi-line, as printed on invoices
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tax_id TEXT;
+
+CREATE SEQUENCE IF NOT EXISTS invoice_number_seq;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS invoice_number TEXT;
+ALTER TABLE invoices ALTER COLUMN invoice_number SET DEFAULT 'INV-' || lpad(nextval('invoice_number_seq')::text, 6, '0');
+UPDATE invoices SET invoice_number = DEFAULT WHERE invoice_number IS NULL;
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS due_date DATE;
+
+-- NOTIFICATIONS
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  kind TEXT NOT NULL, -- user.invited, password.reset, invoice.sent, contract.expiring, payout.approved
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- Recipient, when they are a user
+  to_address TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT,
+  attachments JSONB NOT NULL DEFAULT '[]',
+  status TEXT NOT NULL DEFAULT 'PENDING', -- PENDING, SENT, FAILED
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  sent_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  email_enabled BOOLEAN NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, kind)
+);
+
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS sent_at TIMESTAMP;
+
+ALTER TYPE payment_status ADD VALUE IF NOT EXISTS 'APPROVED' BEFORE 'PAID';
+ALTER TABLE contractor_payments ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+ALTER TABLE contractor_payments ADD COLUMN IF NOT EXISTS approved_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- ACCOUNT ACTIVATION
+-- Accounts that existed before invitations are already active: the column is
+-- added with a default for them, which is then dropped so new users start
+-- unactivated until they set a password.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS activated_at TIMESTAMP DEFAULT now();
+ALTER TABLE users ALTER COLUMN activated_at DROP DEFAULT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT false;
+
+-- Single-use tokens for invitations and password resets. Only the SHA-256 of
+-- the token is stored; the token itself is only ever in the email.
+CREATE TABLE IF NOT EXISTS user_tokens (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  purpose TEXT NOT NULL, -- INVITE, RESET
+  token_hash TEXT NOT NULL UNIQUE,
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- USER ADMINISTRATION
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+-- LOGIN PROTECTION
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_count INTEGER NOT NULL DEFAULT 0; -- Since the last successful sign-in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL when the login matched no user
+  login TEXT NOT NULL, -- Username or email as typed
+  ip TEXT NOT NULL,
+  user_agent TEXT,
+  success BOOLEAN NOT NULL,
+  reason TEXT, -- Why a failed attempt failed: UNKNOWN_USER, BAD_PASSWORD, BAD_MFA_CODE, LOCKED, DISABLED, PASSWORD_LOGIN_DISABLED, SSO_DENIED, SERVICE_ACCOUNT
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- TWO-FACTOR AUTH
+-- totp_secret is set at enrollment; 2FA is on once totp_enabled_at is set.
+-- totp_last_step is the last time step accepted, so a code can't be replayed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- SINGLE SIGN-ON
+-- Users signed in through OpenID Connect are tied to the provider's subject,
+-- which unlike the email never changes.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_issuer TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject TEXT;
+
+-- Sign-ins in progress at the provider, keyed by the SHA-256 of the state
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  state_hash TEXT PRIMARY KEY,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  redirect_path TEXT,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- API KEYS
+-- Service accounts are users that can't sign in and act only through API keys
+ALTER TABLE users ADD COLUMN IF NOT EXISTS service_account BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- The service account
+  name TEXT NOT NULL,
+  key_prefix TEXT NOT NULL, -- Start of the key, so people can tell keys apart
+  key_hash TEXT NOT NULL UNIQUE, -- SHA-256 of the key; the key itself is only shown once
+  scopes TEXT[] NOT NULL, -- resource:read or resource:write, * for every resource
+  client_id UUID REFERENCES clients(id) ON DELETE CASCADE, -- Limits the key to one client's data
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  last_used_ip TEXT,
+  revoked_at TIMESTAMP,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- WEBHOOKS
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  url TEXT NOT NULL,
+  description TEXT,
+  secret TEXT NOT NULL, -- HMAC-SHA256 signing key, shared with the receiver
+  events TEXT[] NOT NULL, -- Event types to deliver, * for all
+  consecutive_failures INTEGER NOT NULL DEFAULT 0, -- Deliveries in a row that ran out of retries
+  disabled_at TIMESTAMP,
+  disabled_reason TEXT,
+  last_success_at TIMESTAMP,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- One row per event per endpoint; a replay adds a new row pointing at the original
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+  event_id UUID NOT NULL, -- Same for every delivery of one event, so receivers can deduplicate
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'PENDING', -- PENDING, SUCCEEDED, FAILED
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER,
+  response_body TEXT, -- Start of the last response
+  last_error TEXT,
+  duration_ms INTEGER,
+  replay_of UUID REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  delivered_at TIMESTAMP
+);
+
+-- Invoices are marked paid by finance once the money arrives
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS paid_at TIMESTAMP;
+
+-- OUTBOX
+-- Domain events written in the transaction of the change they describe and
+-- delivered to in-process subscribers by the events dispatcher.
+CREATE TABLE IF NOT EXISTS outbox_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+  locked_at TIMESTAMP,
+  last_error TEXT,
+  dispatched_at TIMESTAMP,
+  failed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+-- Which subscribers have handled an event, so a retry skips them
+CREATE TABLE IF NOT EXISTS outbox_handled (
+  event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+  subscriber TEXT NOT NULL,
+  handled_at TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY (event_id, subscriber)
+);
+
+-- SCHEDULER
+-- One row per recurring task; next_run_at is shared by every instance so an
+-- occurrence runs once
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+  name TEXT PRIMARY KEY,
+  schedule TEXT NOT NULL,
+  description TEXT,
+  next_run_at TIMESTAMP,
+  paused_at TIMESTAMP,
+  paused_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  run_requested_at TIMESTAMP,
+  run_requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_task_runs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  task_name TEXT NOT NULL REFERENCES scheduled_tasks(name) ON DELETE CASCADE,
+  status TEXT NOT NULL CHECK (status IN ('RUNNING', 'SUCCEEDED', 'FAILED')),
+  triggered_by UUID REFERENCES users(id) ON DELETE SET NULL, -- Set for manual runs
+  instance TEXT,
+  result TEXT,
+  error TEXT,
+  duration_ms BIGINT,
+  started_at TIMESTAMP NOT NULL DEFAULT now(),
+  finished_at TIMESTAMP
+);
+
+-- RECORD VERSIONS
+-- Every update bumps version, which the API sends as the ETag; updates with
+-- If-Match only apply to the version the client last read
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE talent ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE talent ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE project_assignments ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE project_assignments ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now();
+
 -- INDEXES
 CREATE INDEX IF NOT EXISTS idx_talent_role ON talent(role);
 CREATE INDEX IF NOT EXISTS idx_talent_status_history_status ON talent_status_history(status);
@@ -294,3 +631,36 @@ CREATE INDEX IF NOT EXISTS idx_contractor_payments_status ON contractor_payments
 CREATE INDEX IF NOT EXISTS idx_expenses_budget_id ON expenses(budget_id);
 CREATE INDEX IF NOT EXISTS idx_expenses_category ON expenses(category);
 CREATE INDEX IF NOT EXISTS idx_investments_investor ON investments(investor);
+CREATE INDEX IF NOT EXISTS idx_clients_deleted_at ON clients(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_projects_deleted_at ON projects(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_talent_deleted_at ON talent(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_contracts_deleted_at ON contracts(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_documents_deleted_at ON documents(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_document_access_log_document_id ON document_access_log(document_id, accessed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_runnable ON jobs(kind, run_at) WHERE status IN ('QUEUED', 'RUNNING');
+CREATE INDEX IF NOT EXISTS idx_documents_search_vector ON documents USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_document_extractions_document_id ON document_extractions(document_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_logical_version ON documents(logical_id, version);
+CREATE INDEX IF NOT EXISTS idx_documents_latest ON documents(entity_type, entity_id) WHERE superseded_by IS NULL;
+CREATE INDEX IF NOT EXISTS idx_contracts_msa_id ON contracts(msa_id);
+CREATE INDEX IF NOT EXISTS idx_contracts_expiry ON contracts(end_date) WHERE status = 'SIGNED';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_contract_templates_default ON contract_templates(contract_type) WHERE is_default;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_number ON invoices(invoice_number);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_status ON email_outbox(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user ON user_tokens(user_id, purpose) WHERE used_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_failed ON login_attempts(ip, created_at) WHERE NOT success;
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created_at ON login_attempts(created_at);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id) WHERE used_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_subject ON users(oidc_issuer, oidc_subject) WHERE oidc_subject IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(created_at) WHERE dispatched_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_events_dispatched_at ON outbox_events(dispatched_at) WHERE dispatched_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_scheduled_task_runs_task ON scheduled_task_runs(task_name, started_at);
+CREATE INDEX IF NOT EXISTS idx_invoices_created_at ON invoices(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_contractor_payments_created_at ON contractor_payments(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_project_assignments_created_at ON project_assignments(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_contracts_created_at ON contracts(created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_clients_company_name ON clients(company_name, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,251 @@
+// Package events is the domain event bus. Services publish typed events into
+// the outbox_events table in the same transaction as the change they
+// describe, and a dispatcher delivers each committed event to every
+// subscriber of its type at least once.
+//
+// Each subscriber runs in its own transaction that also records the event as
+// handled by that subscriber, so database-only reactions happen exactly once
+// and a failing subscriber is retried without re-running the others.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/jobs"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Dispatch settings. A failed event is retried with the jobs backoff until it
+// has been attempted MaxAttempts times.
+var (
+	PollInterval   = time.Second
+	HandlerTimeout = time.Minute
+	// LockTimeout is how long a claimed event stays claimed before another
+	// dispatcher may take it over. It must exceed the time all subscribers
+	// of one event can take together.
+	LockTimeout = 10 * time.Minute
+	MaxAttempts = 10
+)
+
+// Event is a typed domain event. EventType must not depend on the receiver's
+// fields: Subscribe calls it on the zero value.
+type Event interface {
+	EventType() string
+}
+
+// Meta describes a published event to its subscribers.
+type Meta struct {
+	ID         uuid.UUID
+	Type       string
+	OccurredAt time.Time
+}
+
+// Handler reacts to an event of type E inside tx. Returning an error rolls
+// tx back and the event is offered to the subscriber again later.
+type Handler[E Event] func(ctx context.Context, tx pgx.Tx, meta Meta, e E) error
+
+// Execer is satisfied by the pool and by pgx transactions.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+type subscriber struct {
+	name   string
+	handle func(ctx context.Context, tx pgx.Tx, meta Meta, payload json.RawMessage) error
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[string][]subscriber{}
+)
+
+// Subscribe adds a reaction to events of type E. name identifies the
+// subscriber in the outbox, so it must stay the same across releases or
+// pending events will be handled again under the new name. Subscribe must be
+// called before StartDispatcher.
+func Subscribe[E Event](name string, h Handler[E]) {
+	var zero E
+	eventType := zero.EventType()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, s := range subscribers[eventType] {
+		if s.name == name {
+			panic(fmt.Sprintf("events: %s already subscribes to %s", name, eventType))
+		}
+	}
+	subscribers[eventType] = append(subscribers[eventType], subscriber{
+		name: name,
+		handle: func(ctx context.Context, tx pgx.Tx, meta Meta, payload json.RawMessage) error {
+			var e E
+			if err := json.Unmarshal(payload, &e); err != nil {
+				return fmt.Errorf("decode %s: %w", meta.Type, err)
+			}
+			return h(ctx, tx, meta, e)
+		},
+	})
+}
+
+// Publish writes e to the outbox. Pass the transaction of the change, so the
+// event exists only if the change commits.
+func Publish(ctx context.Context, q Execer, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO outbox_events (event_type, payload) VALUES ($1, $2)`,
+		e.EventType(), payload,
+	)
+	return err
+}
+
+// StartDispatcher delivers committed events to their subscribers until ctx is
+// cancelled. Several instances can run it at once; each event is claimed by
+// one of them at a time.
+func StartDispatcher(ctx context.Context) {
+	go func() {
+		for {
+			worked, err := dispatchOne(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Events: dispatcher error: %v", err)
+			}
+			if worked {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(PollInterval):
+			}
+		}
+	}()
+}
+
+type outboxEvent struct {
+	Meta
+	Payload  json.RawMessage
+	Attempts int
+}
+
+// dispatchOne claims and delivers a single event. It returns false when there
+// is nothing to deliver.
+func dispatchOne(ctx context.Context) (bool, error) {
+	ev, err := claim(ctx)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	mu.RLock()
+	subs := subscribers[ev.Type]
+	mu.RUnlock()
+
+	var failures []error
+	for _, s := range subs {
+		if err := deliver(ctx, ev, s); err != nil {
+			log.Printf("Events: %s %s attempt %d/%d failed in %s: %v", ev.Type, ev.ID, ev.Attempts, MaxAttempts, s.name, err)
+			failures = append(failures, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+
+	if len(failures) == 0 {
+		_, err = db.Pool.Exec(ctx,
+			`UPDATE outbox_events SET dispatched_at = now(), locked_at = NULL, last_error = NULL WHERE id = $1`, ev.ID)
+		return true, err
+	}
+
+	lastError := errors.Join(failures...).Error()
+	if ev.Attempts >= MaxAttempts {
+		log.Printf("Events: giving up on %s %s after %d attempts", ev.Type, ev.ID, ev.Attempts)
+		_, err = db.Pool.Exec(ctx,
+			`UPDATE outbox_events SET failed_at = now(), locked_at = NULL, last_error = $2 WHERE id = $1`, ev.ID, lastError)
+		return true, err
+	}
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE outbox_events SET locked_at = NULL, last_error = $2, next_attempt_at = now() + $3::interval WHERE id = $1`,
+		ev.ID, lastError, fmt.Sprintf("%d seconds", int(jobs.Backoff(ev.Attempts).Seconds())),
+	)
+	return true, err
+}
+
+// claim locks the oldest due event. An event whose lock is older than
+// LockTimeout (e.g. the instance died) is claimed again.
+func claim(ctx context.Context) (*outboxEvent, error) {
+	var ev outboxEvent
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, locked_at = now()
+		WHERE id = (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_attempt_at <= now()
+			  AND (locked_at IS NULL OR locked_at < now() - $1::interval)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, payload, attempts, created_at
+	`, fmt.Sprintf("%d seconds", int(LockTimeout.Seconds()))).Scan(&ev.ID, &ev.Type, &ev.Payload, &ev.Attempts, &ev.OccurredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// deliver runs one subscriber for an event unless it already handled it.
+func deliver(ctx context.Context, ev *outboxEvent, s subscriber) error {
+	ctx, cancel := context.WithTimeout(ctx, HandlerTimeout)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO outbox_handled (event_id, subscriber) VALUES ($1, $2) ON CONFLICT DO NOTHING`, ev.ID, s.name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	if err := safeHandle(ctx, tx, ev, s); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// safeHandle turns a subscriber panic into a failed delivery instead of
+// killing the dispatcher.
+func safeHandle(ctx context.Context, tx pgx.Tx, ev *outboxEvent, s subscriber) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return s.handle(ctx, tx, ev.Meta, ev.Payload)
+}
+
+// Prune deletes dispatched events older than retention. Failed events are
+// kept until someone looks at them.
+func Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM outbox_events WHERE dispatched_at IS NOT NULL AND dispatched_at < $1`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
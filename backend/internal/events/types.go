@@ -0,0 +1,130 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types. Subscribers outside the backend (webhooks) see these names,
+// so they must not change.
+const (
+	TypeTalentCreated        = "talent.created"
+	TypeTalentStatusChanged  = "talent.status_changed"
+	TypeAssignmentCreated    = "assignment.created"
+	TypeAssignmentTrialEnded = "assignment.trial_ended"
+	TypeInvoiceSent          = "invoice.sent"
+	TypeInvoicePaid          = "invoice.paid"
+	TypeInvoiceOverdue       = "invoice.overdue"
+	TypeContractSigned       = "contract.signed"
+	TypeDocumentCreated      = "document.created"
+	TypeDocumentOCRCompleted = "document.ocr_completed"
+)
+
+// Events are stored as JSON, so a field can be added to an event but not
+// renamed or removed while events of the old shape may still be pending.
+
+type TalentCreated struct {
+	TalentID uuid.UUID `json:"talent_id"`
+	Status   string    `json:"status"`
+}
+
+func (TalentCreated) EventType() string { return TypeTalentCreated }
+
+type TalentStatusChanged struct {
+	TalentID       uuid.UUID `json:"talent_id"`
+	PreviousStatus string    `json:"previous_status"`
+	Status         string    `json:"status"`
+}
+
+func (TalentStatusChanged) EventType() string { return TypeTalentStatusChanged }
+
+type AssignmentCreated struct {
+	AssignmentID uuid.UUID `json:"assignment_id"`
+	ProjectID    uuid.UUID `json:"project_id"`
+	ClientID     uuid.UUID `json:"client_id"`
+	TalentID     uuid.UUID `json:"talent_id"`
+	Role         string    `json:"role"`
+	StartDate    time.Time `json:"start_date"`
+	Status       string    `json:"status"`
+}
+
+func (AssignmentCreated) EventType() string { return TypeAssignmentCreated }
+
+// AssignmentTrialEnded is published when an assignment's trial period runs
+// out and it becomes ACTIVE.
+type AssignmentTrialEnded struct {
+	AssignmentID uuid.UUID  `json:"assignment_id"`
+	ProjectID    uuid.UUID  `json:"project_id"`
+	ClientID     *uuid.UUID `json:"client_id"`
+	TalentID     *uuid.UUID `json:"talent_id"`
+	TrialEndDate time.Time  `json:"trial_end_date"`
+	Status       string     `json:"status"`
+}
+
+func (AssignmentTrialEnded) EventType() string { return TypeAssignmentTrialEnded }
+
+// Invoice is the state of an invoice carried by invoice events.
+type Invoice struct {
+	InvoiceID     uuid.UUID  `json:"invoice_id"`
+	InvoiceNumber string     `json:"invoice_number"`
+	ClientID      uuid.UUID  `json:"client_id"`
+	BillingMonth  string     `json:"billing_month"`
+	TotalAmount   float64    `json:"total_amount"`
+	Currency      string     `json:"currency"`
+	Status        string     `json:"status"`
+	DueDate       *time.Time `json:"due_date"`
+	PaidAt        *time.Time `json:"paid_at"`
+}
+
+type InvoiceSent struct {
+	Invoice
+}
+
+func (InvoiceSent) EventType() string { return TypeInvoiceSent }
+
+type InvoicePaid struct {
+	Invoice
+}
+
+func (InvoicePaid) EventType() string { return TypeInvoicePaid }
+
+type InvoiceOverdue struct {
+	Invoice
+}
+
+func (InvoiceOverdue) EventType() string { return TypeInvoiceOverdue }
+
+type ContractSigned struct {
+	ContractID   uuid.UUID  `json:"contract_id"`
+	ContractType string     `json:"contract_type"`
+	ClientID     *uuid.UUID `json:"client_id"`
+	TalentID     *uuid.UUID `json:"talent_id"`
+	ProjectID    *uuid.UUID `json:"project_id"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	SignedAt     *time.Time `json:"signed_at"`
+}
+
+func (ContractSigned) EventType() string { return TypeContractSigned }
+
+// DocumentCreated is published for every stored document version.
+// NeedsOCR is set when its text still has to be extracted.
+type DocumentCreated struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	LogicalID  uuid.UUID `json:"logical_id"`
+	Version    int       `json:"version"`
+	EntityType string    `json:"entity_type"`
+	EntityID   uuid.UUID `json:"entity_id"`
+	FileName   string    `json:"file_name"`
+	NeedsOCR   bool      `json:"needs_ocr"`
+}
+
+func (DocumentCreated) EventType() string { return TypeDocumentCreated }
+
+type DocumentOCRCompleted struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	FileName   string    `json:"file_name"`
+}
+
+func (DocumentOCRCompleted) EventType() string { return TypeDocumentOCRCompleted }
@@ -0,0 +1,119 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ContractExtractor reads parties, term, notice period, rate and currency
+// from MSAs, SOWs, NDAs and contractor agreements.
+type ContractExtractor struct{}
+
+func (ContractExtractor) DocumentType() string { return "contract" }
+
+var contractTypeRules = []struct {
+	Type string
+	re   *regexp.Regexp
+}{
+	{"MSA", regexp.MustCompile(`(?i)\bmaster\s+services?\s+agreement\b|\bMSA\b`)},
+	{"SOW", regexp.MustCompile(`(?i)\bstatement\s+of\s+work\b|\bSOW\b`)},
+	{"NDA", regexp.MustCompile(`(?i)\bnon[-\s]?disclosure\s+agreement\b|\bconfidentiality\s+agreement\b|\bNDA\b`)},
+	{"CONTRACTOR", regexp.MustCompile(`(?i)\b(independent\s+)?contractor\s+agreement\b|\bconsulting\s+agreement\b`)},
+}
+
+var partiesRules = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)\bbetween\s+(.{3,120}?)\s*(?:\(["“]?[^)]{1,40}["”]?\))?\s*,?\s+and\s+(.{3,120}?)\s*(?:\(["“]?[^)]{1,40}["”]?\)|[.;\n])`),
+}
+
+var startDateRules = []rule{
+	{regexp.MustCompile(`(?i)(?:start|commencement|effective)\s+date\s*[:\-]?\s*` + datePattern), 0.9},
+	{regexp.MustCompile(`(?i)(?:commenc\w*|effective|start\w*)\s+(?:on|from|as\s+of)\s+` + datePattern), 0.75},
+	{regexp.MustCompile(`(?i)dated\s+(?:as\s+of\s+)?` + datePattern), 0.6},
+}
+
+var endDateRules = []rule{
+	{regexp.MustCompile(`(?i)(?:end|expiry|expiration|termination)\s+date\s*[:\-]?\s*` + datePattern), 0.9},
+	{regexp.MustCompile(`(?i)(?:until|through|expires?\s+on|ending\s+on|terminat\w*\s+on)\s+` + datePattern), 0.7},
+}
+
+var noticeRules = []rule{
+	{regexp.MustCompile(`(?i)notice\s+period\s*(?:of|:|-)?\s*(\d{1,3})\s*(?:calendar\s+|business\s+)?days`), 0.9},
+	{regexp.MustCompile(`(?i)(\d{1,3})\s*(?:\(\w+\)\s*)?(?:calendar\s+|business\s+)?days[’']?\s*(?:prior\s+)?(?:written\s+)?notice`), 0.8},
+	{regexp.MustCompile(`(?i)notice\s+of\s+(?:at\s+least\s+)?(\d{1,3})\s*(?:calendar\s+|business\s+)?days`), 0.75},
+}
+
+var rateRe = regexp.MustCompile(`(?i)(?:rate|fee|compensation)[^.\n]{0,40}?` + moneyPattern + `\s*(?:per|/|an?)\s*(hour|day|month|year|annum)`)
+
+func (ContractExtractor) Extract(markdown string) []Field {
+	text := cleanMarkdown(markdown)
+	var fields []Field
+
+	for _, r := range contractTypeRules {
+		if loc := r.re.FindStringIndex(text); loc != nil {
+			// Title matches (near the top) are far more reliable than mentions
+			confidence := 0.6
+			if loc[0] < 300 {
+				confidence = 0.9
+			}
+			fields = append(fields, Field{Name: "contract_type", Value: r.Type, Confidence: confidence, Source: text[loc[0]:loc[1]]})
+			break
+		}
+	}
+
+	for _, re := range partiesRules {
+		if m := re.FindStringSubmatch(text); m != nil {
+			fields = append(fields,
+				Field{Name: "party_a", Value: cleanParty(m[1]), Confidence: 0.7, Source: strings.TrimSpace(m[0])},
+				Field{Name: "party_b", Value: cleanParty(m[2]), Confidence: 0.7, Source: strings.TrimSpace(m[0])},
+			)
+			break
+		}
+	}
+
+	if v, src, c := firstMatch(text, startDateRules); v != "" {
+		if d, ok := normalizeDate(v); ok {
+			fields = append(fields, Field{Name: "start_date", Value: d, Confidence: c, Source: src})
+		}
+	}
+	if v, src, c := firstMatch(text, endDateRules); v != "" {
+		if d, ok := normalizeDate(v); ok {
+			fields = append(fields, Field{Name: "end_date", Value: d, Confidence: c, Source: src})
+		}
+	}
+	if v, src, c := firstMatch(text, noticeRules); v != "" {
+		fields = append(fields, Field{Name: "notice_period_days", Value: v, Confidence: c, Source: src})
+	}
+
+	if m := rateRe.FindStringSubmatch(text); m != nil {
+		if amount, currency, ok := parseMoney(m[1], m[2], m[3]); ok {
+			src := strings.TrimSpace(m[0])
+			fields = append(fields,
+				Field{Name: "rate", Value: amount, Confidence: 0.75, Source: src},
+				Field{Name: "rate_period", Value: periodName(m[4]), Confidence: 0.75, Source: src},
+			)
+			if currency != "" {
+				fields = append(fields, Field{Name: "currency", Value: currency, Confidence: 0.8, Source: src})
+			}
+		}
+	}
+
+	return fields
+}
+
+func cleanParty(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, " ,;:\"“”")
+}
+
+func periodName(p string) string {
+	switch strings.ToLower(p) {
+	case "hour":
+		return "HOUR"
+	case "day":
+		return "DAY"
+	case "year", "annum":
+		return "YEAR"
+	default:
+		return "MONTH"
+	}
+}
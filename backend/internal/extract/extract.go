@@ -0,0 +1,130 @@
+// Package extract proposes structured field values from OCR markdown using
+// rule-based extractors registered per document type.
+package extract
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a proposed value. Value is normalised (dates as YYYY-MM-DD,
+// amounts as plain decimals, currencies as ISO codes); Source is the text
+// it was read from so a reviewer can check it.
+type Field struct {
+	Name       string  `json:"name"`
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"` // 0..1
+	Source     string  `json:"source"`
+}
+
+// Extractor turns OCR markdown into proposed fields for one document type.
+type Extractor interface {
+	DocumentType() string
+	Extract(markdown string) []Field
+}
+
+var registry = map[string]Extractor{}
+
+// Register makes an extractor available for its document type.
+func Register(e Extractor) {
+	registry[e.DocumentType()] = e
+}
+
+// For returns the extractor for a document type.
+func For(documentType string) (Extractor, bool) {
+	e, ok := registry[strings.ToLower(documentType)]
+	return e, ok
+}
+
+// Types lists the registered document types.
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func init() {
+	Register(ContractExtractor{})
+	Register(InvoiceExtractor{})
+}
+
+// rule is one way of finding a field; earlier rules win over later ones.
+type rule struct {
+	re         *regexp.Regexp
+	confidence float64
+}
+
+// firstMatch returns the first capture group matched by any rule, with the
+// confidence of that rule.
+func firstMatch(text string, rules []rule) (string, string, float64) {
+	for _, r := range rules {
+		if m := r.re.FindStringSubmatch(text); m != nil {
+			return strings.TrimSpace(m[1]), strings.TrimSpace(m[0]), r.confidence
+		}
+	}
+	return "", "", 0
+}
+
+// cleanMarkdown strips emphasis and table pipes that OCR output wraps around
+// labels and values, so rules can match plain text.
+func cleanMarkdown(s string) string {
+	r := strings.NewReplacer("**", "", "__", "", "`", "", "|", " ", " ", " ")
+	return r.Replace(s)
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"January 2, 2006",
+	"January 2 2006",
+	"2 January 2006",
+	"2 January, 2006",
+	"Jan 2, 2006",
+	"Jan 2 2006",
+	"2 Jan 2006",
+	"2/1/2006",
+}
+
+const datePattern = `(\d{4}-\d{2}-\d{2}|\d{1,2}(?:st|nd|rd|th)?\s+[A-Z][a-z]+\.?,?\s+\d{4}|[A-Z][a-z]+\.?\s+\d{1,2}(?:st|nd|rd|th)?,?\s+\d{4}|\d{1,2}[./]\d{1,2}[./]\d{4})`
+
+var ordinalSuffix = regexp.MustCompile(`(\d)(st|nd|rd|th)\b`)
+
+// normalizeDate parses the date formats common in contracts. Numeric dates
+// are read day first (dd/mm/yyyy) as our counterparties are outside the US.
+func normalizeDate(s string) (string, bool) {
+	s = ordinalSuffix.ReplaceAllString(strings.TrimSpace(s), "$1")
+	s = strings.Replace(s, ". ", " ", 1) // "Jan. 2" -> "Jan 2"
+	s = strings.Replace(s, " ,", ",", 1)
+	s = strings.ReplaceAll(s, ".", "/") // "02.01.2006" -> "02/01/2006"
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+var currencySymbols = map[string]string{
+	"$": "USD", "US$": "USD", "€": "EUR", "£": "GBP", "AED": "AED", "USD": "USD", "EUR": "EUR", "GBP": "GBP",
+	"د.إ": "AED", "SAR": "SAR", "INR": "INR", "₹": "INR", "CAD": "CAD", "AUD": "AUD", "CHF": "CHF",
+}
+
+const moneyPattern = `(US\$|\$|€|£|₹|AED|USD|EUR|GBP|SAR|INR|CAD|AUD|CHF)?\s?([0-9]{1,3}(?:[,\s][0-9]{3})*(?:\.[0-9]{1,2})?|[0-9]+(?:\.[0-9]{1,2})?)\s?(AED|USD|EUR|GBP|SAR|INR|CAD|AUD|CHF)?`
+
+// parseMoney splits a money match into a normalised amount and currency.
+func parseMoney(prefix, number, suffix string) (string, string, bool) {
+	n := strings.NewReplacer(",", "", " ", "").Replace(number)
+	if _, err := strconv.ParseFloat(n, 64); err != nil {
+		return "", "", false
+	}
+	cur := currencySymbols[prefix]
+	if cur == "" {
+		cur = currencySymbols[suffix]
+	}
+	return n, cur, true
+}
@@ -0,0 +1,86 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+// InvoiceExtractor reads vendor invoices so they can be booked as expenses.
+type InvoiceExtractor struct{}
+
+func (InvoiceExtractor) DocumentType() string { return "invoice" }
+
+var invoiceNumberRules = []rule{
+	{regexp.MustCompile(`(?i)invoice\s*(?:no\.?|number|num|#)\s*[:\-]?\s*([A-Z0-9][A-Z0-9\-/]{1,30})`), 0.9},
+	{regexp.MustCompile(`(?i)\binv[\-#]?\s*([0-9][A-Z0-9\-/]{1,30})`), 0.6},
+}
+
+var invoiceDateRules = []rule{
+	{regexp.MustCompile(`(?i)(?:invoice|issue|issued)\s+date\s*[:\-]?\s*` + datePattern), 0.9},
+	{regexp.MustCompile(`(?i)\bdate\s*[:\-]\s*` + datePattern), 0.7},
+}
+
+var dueDateRules = []rule{
+	{regexp.MustCompile(`(?i)(?:due\s+date|payment\s+due|due\s+by|due\s+on)\s*[:\-]?\s*` + datePattern), 0.9},
+}
+
+var vendorRules = []rule{
+	{regexp.MustCompile(`(?im)^\s*(?:from|vendor|supplier|bill\s+from|issued\s+by)\s*[:\-]\s*(.{2,80})$`), 0.85},
+}
+
+// Totals are tried from most to least specific so "Total Due" beats "Subtotal".
+var totalRules = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(?:total\s+(?:amount\s+)?due|amount\s+due|balance\s+due|grand\s+total)\s*[:\-]?\s*` + moneyPattern),
+	regexp.MustCompile(`(?i)(?:^|[^b])total\s*(?:\([A-Z]{3}\))?\s*[:\-]?\s*` + moneyPattern),
+}
+
+var firstHeadingRe = regexp.MustCompile(`(?m)^#{1,3}\s+(.{2,80})$`)
+
+func (InvoiceExtractor) Extract(markdown string) []Field {
+	text := cleanMarkdown(markdown)
+	var fields []Field
+
+	if v, src, c := firstMatch(text, vendorRules); v != "" {
+		fields = append(fields, Field{Name: "vendor", Value: v, Confidence: c, Source: src})
+	} else if m := firstHeadingRe.FindStringSubmatch(markdown); m != nil && !strings.Contains(strings.ToLower(m[1]), "invoice") {
+		// The letterhead is usually the first heading
+		fields = append(fields, Field{Name: "vendor", Value: strings.TrimSpace(m[1]), Confidence: 0.4, Source: m[0]})
+	}
+
+	if v, src, c := firstMatch(text, invoiceNumberRules); v != "" {
+		fields = append(fields, Field{Name: "invoice_number", Value: v, Confidence: c, Source: src})
+	}
+	if v, src, c := firstMatch(text, invoiceDateRules); v != "" {
+		if d, ok := normalizeDate(v); ok {
+			fields = append(fields, Field{Name: "invoice_date", Value: d, Confidence: c, Source: src})
+		}
+	}
+	if v, src, c := firstMatch(text, dueDateRules); v != "" {
+		if d, ok := normalizeDate(v); ok {
+			fields = append(fields, Field{Name: "due_date", Value: d, Confidence: c, Source: src})
+		}
+	}
+
+	for i, re := range totalRules {
+		m := re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		amount, currency, ok := parseMoney(m[1], m[2], m[3])
+		if !ok {
+			continue
+		}
+		confidence := 0.9
+		if i > 0 {
+			confidence = 0.7
+		}
+		src := strings.TrimSpace(m[0])
+		fields = append(fields, Field{Name: "amount", Value: amount, Confidence: confidence, Source: src})
+		if currency != "" {
+			fields = append(fields, Field{Name: "currency", Value: currency, Confidence: confidence, Source: src})
+		}
+		break
+	}
+
+	return fields
+}
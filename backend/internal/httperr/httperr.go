@@ -0,0 +1,95 @@
+// Package httperr writes API errors as JSON:
+//
+//	{"error": {"code": "validation_failed", "message": "...", "fields": [...], "request_id": "..."}}
+//
+// Clients branch on code, which follows the status, and quote request_id
+// when reporting a problem; it matches the X-Request-Id response header and
+// the server log.
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dubai/platform/backend/internal/service"
+)
+
+// RequestIDHeader carries the request ID on requests and responses.
+const RequestIDHeader = "X-Request-Id"
+
+type Body struct {
+	Error Detail `json:"error"`
+}
+
+type Detail struct {
+	Code      string               `json:"code"`
+	Message   string               `json:"message"`
+	Fields    []service.FieldError `json:"fields,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+}
+
+var kindStatus = map[service.ErrorKind]int{
+	service.KindValidation:         http.StatusUnprocessableEntity,
+	service.KindNotFound:           http.StatusNotFound,
+	service.KindConflict:           http.StatusConflict,
+	service.KindForbidden:          http.StatusForbidden,
+	service.KindUnauthorized:       http.StatusUnauthorized,
+	service.KindPreconditionFailed: http.StatusPreconditionFailed,
+}
+
+var statusCode = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          string(service.KindUnauthorized),
+	http.StatusForbidden:             string(service.KindForbidden),
+	http.StatusNotFound:              string(service.KindNotFound),
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              string(service.KindConflict),
+	http.StatusPreconditionFailed:    string(service.KindPreconditionFailed),
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   string(service.KindValidation),
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// Write answers with err. Service errors get their kind's status and
+// message; anything else is logged and answered with a bare 500 so
+// database and other internal messages never reach the client.
+func Write(w http.ResponseWriter, err error) {
+	var throttled *service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		WriteStatus(w, http.StatusTooManyRequests, throttled.Error())
+		return
+	}
+	if e := service.AsError(err); e != nil {
+		write(w, kindStatus[e.Kind], Detail{Code: string(e.Kind), Message: e.Message, Fields: e.Fields})
+		return
+	}
+	log.Printf("ERROR: request %s failed: %v", w.Header().Get(RequestIDHeader), err)
+	WriteStatus(w, http.StatusInternalServerError, "Internal Server Error")
+}
+
+// WriteStatus answers with a message and a code derived from status, for
+// errors found by the handler itself (malformed requests, missing roles).
+func WriteStatus(w http.ResponseWriter, status int, message string) {
+	code, ok := statusCode[status]
+	if !ok {
+		code = "error"
+	}
+	write(w, status, Detail{Code: code, Message: message})
+}
+
+func write(w http.ResponseWriter, status int, d Detail) {
+	d.RequestID = w.Header().Get(RequestIDHeader)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Body{Error: d})
+}
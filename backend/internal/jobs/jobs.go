@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	StatusQueued  = "QUEUED"
+	StatusRunning = "RUNNING"
+	StatusDone    = "DONE"
+	StatusFailed  = "FAILED"
+
+	DefaultMaxAttempts = 5
+
+	// errLockExpired is recorded on a job whose worker died or hung on its
+	// final attempt, leaving nothing to retry.
+	errLockExpired = "lock expired on the final attempt"
+)
+
+// Poll and backoff settings. Backoff doubles per attempt up to MaxBackoff.
+var (
+	PollInterval = 2 * time.Second
+	BaseBackoff  = 30 * time.Second
+	MaxBackoff   = time.Hour
+)
+
+// Job is a unit of work claimed from the jobs table.
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int // Including the current one
+	MaxAttempts int
+}
+
+// FinalAttempt reports whether a failure now will mark the job FAILED.
+func (j *Job) FinalAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Execer is satisfied by the pool and by pgx transactions, so jobs can be
+// enqueued atomically with the business change that triggers them.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]HandlerFunc{}
+)
+
+// Register sets the handler for a job kind. It must be called before StartWorkers.
+func Register(kind string, h HandlerFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[kind] = h
+}
+
+// Enqueue inserts a job that becomes runnable immediately.
+func Enqueue(ctx context.Context, q Execer, kind string, payload interface{}) error {
+	return EnqueueWithAttempts(ctx, q, kind, payload, DefaultMaxAttempts)
+}
+
+// EnqueueWithAttempts is Enqueue for jobs that should be retried more or
+// fewer times than DefaultMaxAttempts.
+func EnqueueWithAttempts(ctx context.Context, q Execer, kind string, payload interface{}, maxAttempts int) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx,
+		`INSERT INTO jobs (kind, payload, max_attempts) VALUES ($1, $2, $3)`,
+		kind, body, maxAttempts,
+	)
+	return err
+}
+
+// StartWorkers launches n workers for a kind. A job that runs longer than
+// timeout is cancelled, and a RUNNING job whose lock is older than timeout
+// (e.g. the instance died) is picked up again by any worker, or failed if
+// that was its final attempt.
+func StartWorkers(ctx context.Context, kind string, n int, timeout time.Duration) {
+	mu.RLock()
+	h, ok := handlers[kind]
+	mu.RUnlock()
+	if !ok {
+		log.Printf("Jobs: no handler registered for %s, workers not started", kind)
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				worked, err := runOne(ctx, pgQueue{}, kind, h, timeout)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Jobs: %s worker error: %v", kind, err)
+				}
+				if worked {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(PollInterval):
+				}
+			}
+		}()
+	}
+}
+
+// queue is where workers claim jobs and record how they went.
+type queue interface {
+	// expire fails RUNNING jobs of a kind whose lock is older than timeout
+	// and that have no attempts left, returning how many there were.
+	expire(ctx context.Context, kind string, timeout time.Duration) (int64, error)
+	// claim locks the next due job of a kind, or returns pgx.ErrNoRows.
+	claim(ctx context.Context, kind string, timeout time.Duration) (*Job, error)
+	done(ctx context.Context, job *Job) error
+	failed(ctx context.Context, job *Job, runErr error) error
+	retry(ctx context.Context, job *Job, runErr error, delay time.Duration) error
+}
+
+// runOne claims and runs a single job. It returns false when the queue is empty.
+func runOne(ctx context.Context, q queue, kind string, h HandlerFunc, timeout time.Duration) (bool, error) {
+	expired, err := q.expire(ctx, kind, timeout)
+	if err != nil {
+		return false, err
+	}
+	if expired > 0 {
+		log.Printf("Jobs: %d %s jobs failed, their lock expired on the final attempt", expired, kind)
+	}
+
+	job, err := q.claim(ctx, kind, timeout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	runErr := safeRun(jobCtx, h, job)
+	cancel()
+
+	if runErr == nil {
+		return true, q.done(ctx, job)
+	}
+
+	log.Printf("Jobs: %s %s attempt %d/%d failed: %v", job.Kind, job.ID, job.Attempts, job.MaxAttempts, runErr)
+	if job.FinalAttempt() {
+		return true, q.failed(ctx, job, runErr)
+	}
+	return true, q.retry(ctx, job, runErr, Backoff(job.Attempts))
+}
+
+// pgQueue keeps jobs in the jobs table.
+type pgQueue struct{}
+
+func (pgQueue) expire(ctx context.Context, kind string, timeout time.Duration) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE jobs
+		SET status = $2, locked_at = NULL, last_error = $3, finished_at = now(), updated_at = now()
+		WHERE kind = $1 AND status = 'RUNNING' AND locked_at < now() - $4::interval AND attempts >= max_attempts
+	`, kind, StatusFailed, errLockExpired, fmt.Sprintf("%d seconds", int(timeout.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (pgQueue) claim(ctx context.Context, kind string, timeout time.Duration) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'RUNNING', attempts = attempts + 1, locked_at = now(), updated_at = now()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = $1
+			  AND ((status = 'QUEUED' AND run_at <= now())
+			    OR (status = 'RUNNING' AND locked_at < now() - $2::interval AND attempts < max_attempts))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts, max_attempts
+	`
+	var j Job
+	err := db.Pool.QueryRow(ctx, query, kind, fmt.Sprintf("%d seconds", int(timeout.Seconds()))).Scan(
+		&j.ID, &j.Kind, &j.Payload, &j.Attempts, &j.MaxAttempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (pgQueue) done(ctx context.Context, job *Job) error {
+	_, err := db.Pool.Exec(ctx,
+		`UPDATE jobs SET status = $2, locked_at = NULL, last_error = NULL, finished_at = now(), updated_at = now() WHERE id = $1`,
+		job.ID, StatusDone,
+	)
+	return err
+}
+
+func (pgQueue) failed(ctx context.Context, job *Job, runErr error) error {
+	_, err := db.Pool.Exec(ctx,
+		`UPDATE jobs SET status = $2, locked_at = NULL, last_error = $3, finished_at = now(), updated_at = now() WHERE id = $1`,
+		job.ID, StatusFailed, runErr.Error(),
+	)
+	return err
+}
+
+func (pgQueue) retry(ctx context.Context, job *Job, runErr error, delay time.Duration) error {
+	_, err := db.Pool.Exec(ctx,
+		`UPDATE jobs SET status = $2, locked_at = NULL, last_error = $3, run_at = now() + $4::interval, updated_at = now() WHERE id = $1`,
+		job.ID, StatusQueued, runErr.Error(), fmt.Sprintf("%d seconds", int(delay.Seconds())),
+	)
+	return err
+}
+
+// safeRun turns a handler panic into a job failure instead of killing the worker.
+func safeRun(ctx context.Context, h HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// Backoff returns the delay before retrying after the given attempt.
+func Backoff(attempt int) time.Duration {
+	d := BaseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return d
+}
@@ -0,0 +1,390 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeQueue mirrors the claim rules of pgQueue in memory.
+type fakeQueue struct {
+	now  time.Time
+	jobs []*fakeJob
+}
+
+type fakeJob struct {
+	Job
+	status    string
+	runAt     time.Time
+	lockedAt  time.Time
+	lastError string
+}
+
+func (q *fakeQueue) add(kind string, maxAttempts int, runAt time.Time) *fakeJob {
+	j := &fakeJob{
+		Job:    Job{ID: uuid.New(), Kind: kind, MaxAttempts: maxAttempts},
+		status: StatusQueued,
+		runAt:  runAt,
+	}
+	q.jobs = append(q.jobs, j)
+	return j
+}
+
+// stale reports whether j is RUNNING under a lock older than timeout.
+func (q *fakeQueue) stale(j *fakeJob, timeout time.Duration) bool {
+	return j.status == StatusRunning && j.lockedAt.Before(q.now.Add(-timeout))
+}
+
+func (q *fakeQueue) expire(_ context.Context, kind string, timeout time.Duration) (int64, error) {
+	var n int64
+	for _, j := range q.jobs {
+		if j.Kind == kind && q.stale(j, timeout) && j.Attempts >= j.MaxAttempts {
+			j.status, j.lockedAt, j.lastError = StatusFailed, time.Time{}, errLockExpired
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (q *fakeQueue) claim(_ context.Context, kind string, timeout time.Duration) (*Job, error) {
+	due := make([]*fakeJob, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		if j.Kind != kind {
+			continue
+		}
+		queued := j.status == StatusQueued && !j.runAt.After(q.now)
+		stale := q.stale(j, timeout) && j.Attempts < j.MaxAttempts
+		if queued || stale {
+			due = append(due, j)
+		}
+	}
+	if len(due) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	sort.SliceStable(due, func(a, b int) bool { return due[a].runAt.Before(due[b].runAt) })
+
+	j := due[0]
+	j.status = StatusRunning
+	j.Attempts++
+	j.lockedAt = q.now
+	claimed := j.Job
+	return &claimed, nil
+}
+
+func (q *fakeQueue) find(id uuid.UUID) *fakeJob {
+	for _, j := range q.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	panic("unknown job " + id.String())
+}
+
+func (q *fakeQueue) done(_ context.Context, job *Job) error {
+	j := q.find(job.ID)
+	j.status, j.lockedAt, j.lastError = StatusDone, time.Time{}, ""
+	return nil
+}
+
+func (q *fakeQueue) failed(_ context.Context, job *Job, runErr error) error {
+	j := q.find(job.ID)
+	j.status, j.lockedAt, j.lastError = StatusFailed, time.Time{}, runErr.Error()
+	return nil
+}
+
+func (q *fakeQueue) retry(_ context.Context, job *Job, runErr error, delay time.Duration) error {
+	j := q.find(job.ID)
+	j.status, j.lockedAt, j.lastError = StatusQueued, time.Time{}, runErr.Error()
+	j.runAt = q.now.Add(delay)
+	return nil
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestFinalAttempt(t *testing.T) {
+	tests := []struct {
+		attempts, max int
+		want          bool
+	}{
+		{1, 3, false},
+		{2, 3, false},
+		{3, 3, true},
+		{1, 1, true},
+		{4, 3, true},
+	}
+	for _, tt := range tests {
+		j := &Job{Attempts: tt.attempts, MaxAttempts: tt.max}
+		if got := j.FinalAttempt(); got != tt.want {
+			t.Errorf("attempt %d/%d: FinalAttempt() = %v, want %v", tt.attempts, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestClaim(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeout := 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		setup   func(q *fakeQueue) *fakeJob // returns the job expected to be claimed, or nil
+		wantRan bool
+	}{
+		{
+			name:  "empty queue",
+			setup: func(q *fakeQueue) *fakeJob { return nil },
+		},
+		{
+			name: "not yet due",
+			setup: func(q *fakeQueue) *fakeJob {
+				q.add("email", 3, now.Add(time.Second))
+				return nil
+			},
+		},
+		{
+			name: "other kind",
+			setup: func(q *fakeQueue) *fakeJob {
+				q.add("ocr", 3, now)
+				return nil
+			},
+		},
+		{
+			name: "oldest due job first",
+			setup: func(q *fakeQueue) *fakeJob {
+				q.add("email", 3, now.Add(-time.Minute))
+				oldest := q.add("email", 3, now.Add(-time.Hour))
+				return oldest
+			},
+			wantRan: true,
+		},
+		{
+			name: "running job with a live lock is left alone",
+			setup: func(q *fakeQueue) *fakeJob {
+				j := q.add("email", 3, now.Add(-time.Hour))
+				j.status, j.Attempts, j.lockedAt = StatusRunning, 1, now.Add(-time.Minute)
+				return nil
+			},
+		},
+		{
+			name: "running job with an expired lock is reclaimed",
+			setup: func(q *fakeQueue) *fakeJob {
+				j := q.add("email", 3, now.Add(-time.Hour))
+				j.status, j.Attempts, j.lockedAt = StatusRunning, 1, now.Add(-timeout-time.Second)
+				return j
+			},
+			wantRan: true,
+		},
+		{
+			name: "expired lock on the final attempt isn't reclaimed",
+			setup: func(q *fakeQueue) *fakeJob {
+				j := q.add("email", 3, now.Add(-time.Hour))
+				j.status, j.Attempts, j.lockedAt = StatusRunning, 3, now.Add(-timeout-time.Second)
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{now: now}
+			want := tt.setup(q)
+			var attemptsBefore int
+			if want != nil {
+				attemptsBefore = want.Attempts
+			}
+
+			var ran *Job
+			h := func(ctx context.Context, job *Job) error {
+				ran = job
+				return nil
+			}
+			worked, err := runOne(context.Background(), q, "email", h, timeout)
+			if err != nil {
+				t.Fatalf("runOne: %v", err)
+			}
+			if worked != tt.wantRan {
+				t.Fatalf("worked = %v, want %v", worked, tt.wantRan)
+			}
+			if want == nil {
+				if ran != nil {
+					t.Fatalf("handler ran job %s, want none", ran.ID)
+				}
+				return
+			}
+			if ran == nil || ran.ID != want.ID {
+				t.Fatalf("handler ran %v, want job %s", ran, want.ID)
+			}
+			if ran.Attempts != attemptsBefore+1 {
+				t.Errorf("attempts = %d, want %d", ran.Attempts, attemptsBefore+1)
+			}
+		})
+	}
+}
+
+func TestRunOneExpiresExhaustedJobs(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	timeout := 5 * time.Minute
+	q := &fakeQueue{now: now}
+	exhausted := q.add("email", 3, now.Add(-2*time.Hour))
+	exhausted.status, exhausted.Attempts, exhausted.lockedAt = StatusRunning, 3, now.Add(-timeout-time.Second)
+	running := q.add("email", 3, now.Add(-2*time.Hour))
+	running.status, running.Attempts, running.lockedAt = StatusRunning, 3, now.Add(-time.Minute)
+	queued := q.add("email", 3, now.Add(-time.Hour))
+
+	var ran []uuid.UUID
+	h := func(_ context.Context, job *Job) error {
+		ran = append(ran, job.ID)
+		return nil
+	}
+	if _, err := runOne(context.Background(), q, "email", h, timeout); err != nil {
+		t.Fatalf("runOne: %v", err)
+	}
+
+	if exhausted.status != StatusFailed || exhausted.lastError != errLockExpired {
+		t.Errorf("exhausted job: status %s, last error %q; want %s, %q", exhausted.status, exhausted.lastError, StatusFailed, errLockExpired)
+	}
+	if exhausted.Attempts != 3 {
+		t.Errorf("exhausted job: attempts = %d, want 3", exhausted.Attempts)
+	}
+	// A final attempt still within its timeout may yet finish
+	if running.status != StatusRunning {
+		t.Errorf("job within its timeout: status %s, want %s", running.status, StatusRunning)
+	}
+	if len(ran) != 1 || ran[0] != queued.ID {
+		t.Errorf("ran %v, want only the queued job %s", ran, queued.ID)
+	}
+}
+
+func TestRunOneOutcome(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		priorTries  int
+		maxAttempts int
+		handler     HandlerFunc
+		wantStatus  string
+		wantRunAt   time.Time
+		wantError   string
+	}{
+		{
+			name:        "success",
+			maxAttempts: 3,
+			handler:     func(context.Context, *Job) error { return nil },
+			wantStatus:  StatusDone,
+		},
+		{
+			name:        "first failure is retried after the base backoff",
+			maxAttempts: 3,
+			handler:     func(context.Context, *Job) error { return errors.New("smtp down") },
+			wantStatus:  StatusQueued,
+			wantRunAt:   now.Add(30 * time.Second),
+			wantError:   "smtp down",
+		},
+		{
+			name:        "backoff doubles on the next attempt",
+			priorTries:  1,
+			maxAttempts: 3,
+			handler:     func(context.Context, *Job) error { return errors.New("smtp down") },
+			wantStatus:  StatusQueued,
+			wantRunAt:   now.Add(time.Minute),
+			wantError:   "smtp down",
+		},
+		{
+			name:        "final attempt fails the job",
+			priorTries:  2,
+			maxAttempts: 3,
+			handler:     func(context.Context, *Job) error { return errors.New("smtp down") },
+			wantStatus:  StatusFailed,
+			wantError:   "smtp down",
+		},
+		{
+			name:        "panic counts as a failure",
+			maxAttempts: 3,
+			handler:     func(context.Context, *Job) error { panic("nil map") },
+			wantStatus:  StatusQueued,
+			wantRunAt:   now.Add(30 * time.Second),
+			wantError:   "panic: nil map",
+		},
+		{
+			name:        "handler sees the job timeout",
+			maxAttempts: 1,
+			handler: func(ctx context.Context, _ *Job) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantStatus: StatusFailed,
+			wantError:  context.DeadlineExceeded.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{now: now}
+			j := q.add("email", tt.maxAttempts, now.Add(-time.Minute))
+			j.Attempts = tt.priorTries
+
+			worked, err := runOne(context.Background(), q, "email", tt.handler, 10*time.Millisecond)
+			if err != nil {
+				t.Fatalf("runOne: %v", err)
+			}
+			if !worked {
+				t.Fatal("runOne reported an empty queue")
+			}
+			if j.status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", j.status, tt.wantStatus)
+			}
+			if j.lastError != tt.wantError {
+				t.Errorf("last error = %q, want %q", j.lastError, tt.wantError)
+			}
+			if !tt.wantRunAt.IsZero() && !j.runAt.Equal(tt.wantRunAt) {
+				t.Errorf("run at = %v, want %v", j.runAt, tt.wantRunAt)
+			}
+			if j.Attempts != tt.priorTries+1 {
+				t.Errorf("attempts = %d, want %d", j.Attempts, tt.priorTries+1)
+			}
+		})
+	}
+}
+
+func TestRunOneClaimError(t *testing.T) {
+	boom := errors.New("connection refused")
+	q := errQueue{fakeQueue: &fakeQueue{}, err: boom}
+	worked, err := runOne(context.Background(), q, "email", func(context.Context, *Job) error {
+		t.Fatal("handler must not run")
+		return nil
+	}, time.Second)
+	if worked || !errors.Is(err, boom) {
+		t.Fatalf("runOne = %v, %v; want false, %v", worked, err, boom)
+	}
+}
+
+type errQueue struct {
+	*fakeQueue
+	err error
+}
+
+func (q errQueue) claim(context.Context, string, time.Duration) (*Job, error) {
+	return nil, q.err
+}
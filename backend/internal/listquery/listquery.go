@@ -0,0 +1,257 @@
+// Package listquery implements the conventions shared by list endpoints:
+//
+//	GET /api/invoices?status=SENT&billing_month_from=2024-01&sort=-billing_month&limit=20
+//
+// limit caps the page size (default 50, at most 200), sort names one of the
+// endpoint's sort fields with a leading "-" for descending order, and the
+// other parameters are the endpoint's filters. Responses are a Page: the
+// items, the total number of matches and, when there are more, next_cursor
+// to pass as ?cursor= for the following page with the same filters and sort.
+//
+// Cursors point past the last item (keyset pagination), so rows inserted or
+// deleted between requests don't shift pages.
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Spec describes a list endpoint. Column names are SQL expressions over the
+// endpoint's table and must never come from the request.
+type Spec[T any] struct {
+	Sorts       map[string]Sort[T]
+	DefaultSort string // A key of Sorts, with "-" for descending
+	Filters     map[string]Filter
+	// ID returns an item's primary key, which breaks ties between equal
+	// sort values.
+	ID func(T) uuid.UUID
+}
+
+// Sort is a field items can be ordered by. Its column must be NOT NULL.
+type Sort[T any] struct {
+	Column string
+	Type   string // Postgres type of Column
+	// Value returns the item's sort value as Postgres reads a Type literal.
+	Value func(T) string
+}
+
+// Filter is a query parameter narrowing the list.
+type Filter struct {
+	Column string
+	Op     string // =, >= or <=; = if empty
+	// Rules validate the parameter, as in validate tags. enum and oneof
+	// values are compared case-insensitively.
+	Rules string
+}
+
+// Query is a parsed list request.
+type Query[T any] struct {
+	spec       Spec[T]
+	limit      int
+	sortName   string
+	sort       Sort[T]
+	desc       bool
+	after      *cursor
+	conditions []condition
+}
+
+type condition struct {
+	column, op string
+	value      any
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Page is one page of a list response.
+type Page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+	Total      int     `json:"total"`
+}
+
+// Parse reads a list request. Unknown sort fields, malformed filters and
+// cursors are returned as field errors named after the parameter.
+func Parse[T any](values url.Values, spec Spec[T]) (*Query[T], []validate.FieldError) {
+	q := &Query[T]{spec: spec, limit: DefaultLimit}
+	var errs []validate.FieldError
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			errs = append(errs, validate.FieldError{Field: "limit", Message: fmt.Sprintf("must be a number from 1 to %d", MaxLimit)})
+		} else {
+			q.limit = n
+		}
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = spec.DefaultSort
+	}
+	q.desc = strings.HasPrefix(sortParam, "-")
+	q.sortName = strings.TrimPrefix(sortParam, "-")
+	sort, ok := spec.Sorts[q.sortName]
+	if !ok {
+		errs = append(errs, validate.FieldError{Field: "sort", Message: "must be one of " + strings.Join(sortNames(spec), ", ")})
+	}
+	q.sort = sort
+
+	if v := values.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		switch {
+		case err != nil:
+			errs = append(errs, validate.FieldError{Field: "cursor", Message: "is not a cursor from this list"})
+		case c.Sort != sortParam:
+			errs = append(errs, validate.FieldError{Field: "cursor", Message: "was issued for another sort order"})
+		default:
+			q.after = c
+		}
+	}
+
+	for _, name := range filterNames(spec) {
+		v := strings.TrimSpace(values.Get(name))
+		if v == "" {
+			continue
+		}
+		f := spec.Filters[name]
+		if strings.Contains(f.Rules, "enum=") || strings.Contains(f.Rules, "oneof=") {
+			v = strings.ToUpper(v)
+		}
+		if msg := validate.Value(v, f.Rules); msg != "" {
+			errs = append(errs, validate.FieldError{Field: name, Message: msg})
+			continue
+		}
+		op := f.Op
+		if op == "" {
+			op = "="
+		}
+		q.conditions = append(q.conditions, condition{column: f.Column, op: op, value: v})
+	}
+	return q, errs
+}
+
+// Where adds a condition the caller can't lift, such as limiting client
+// users to their own client. It overrides nothing: a filter on the same
+// column from the request still applies too.
+func (q *Query[T]) Where(column string, value any) {
+	q.conditions = append(q.conditions, condition{column: column, op: "=", value: value})
+}
+
+// Filter appends the filter conditions to sql, which must end in a WHERE
+// clause, numbering placeholders after args.
+func (q *Query[T]) Filter(sql string, args []any) (string, []any) {
+	for _, c := range q.conditions {
+		args = append(args, c.value)
+		sql += fmt.Sprintf(" AND %s %s $%d", c.column, c.op, len(args))
+	}
+	return sql, args
+}
+
+// Page appends the cursor condition, order and limit to a query built with
+// Filter. It fetches one row more than the limit so Result can tell whether
+// there is a next page.
+func (q *Query[T]) Page(sql string, args []any) (string, []any) {
+	dir, cmp := "ASC", ">"
+	if q.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.after != nil {
+		args = append(args, q.after.Value, q.after.ID)
+		sql += fmt.Sprintf(" AND (%s, id) %s ($%d::text::%s, $%d::text::uuid)", q.sort.Column, cmp, len(args)-1, q.sort.Type, len(args))
+	}
+	sql += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %d", q.sort.Column, dir, dir, q.limit+1)
+	return sql, args
+}
+
+// Result turns the rows fetched with Page into a page of at most the limit.
+func (q *Query[T]) Result(items []T, total int) *Page[T] {
+	page := &Page[T]{Data: items, Total: total}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(items) > q.limit {
+		page.Data = items[:q.limit]
+		last := page.Data[q.limit-1]
+		next := encodeCursor(cursor{
+			Sort:  q.sortParam(),
+			Value: q.sort.Value(last),
+			ID:    q.spec.ID(last).String(),
+		})
+		page.NextCursor = &next
+	}
+	return page
+}
+
+func (q *Query[T]) sortParam() string {
+	if q.desc {
+		return "-" + q.sortName
+	}
+	return q.sortName
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func sortNames[T any](spec Spec[T]) []string {
+	names := make([]string, 0, len(spec.Sorts))
+	for name := range spec.Sorts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func filterNames[T any](spec Spec[T]) []string {
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Time formats a timestamp sort value.
+func Time(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// Number formats a numeric sort value.
+func Number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -2,30 +2,59 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/dubai/platform/backend/internal/httperr"
+	"github.com/dubai/platform/backend/internal/service"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var jwtKey = []byte("super-secret-jwt-key") // Same as service - ideally centralized
 
 type Claims struct {
-	UserID   string  `json:"user_id"`
-	Role     string  `json:"role"`
-	ClientID *string `json:"client_id"`
+	UserID             string  `json:"user_id"`
+	Role               string  `json:"role"`
+	ClientID           *string `json:"client_id"`
+	MustChangePassword bool    `json:"must_change_password"`
+	MFASetupRequired   bool    `json:"mfa_setup_required"`
 	jwt.RegisteredClaims
 }
 
+// passwordChangeRoutes are all a token with MustChangePassword may call.
+var passwordChangeRoutes = map[string]bool{
+	"GET /api/auth/me":        true,
+	"POST /api/auth/password": true,
+}
+
+// mfaSetupRoutes are all a token with MFASetupRequired may call.
+var mfaSetupRoutes = map[string]bool{
+	"GET /api/auth/me":          true,
+	"POST /api/auth/mfa/setup":  true,
+	"POST /api/auth/mfa/enable": true,
+}
+
+// userActive looks up whether a token's user may still sign in. Tests swap it
+// out.
+var userActive = service.UserActive
+
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
+		if key := r.Header.Get("X-API-Key"); key != "" && authHeader == "" {
+			authHeader = "Bearer " + key
+		}
 		if authHeader == "" {
-			http.Error(w, "Missing Authorization Header", http.StatusUnauthorized)
+			httperr.WriteStatus(w, http.StatusUnauthorized, "Missing Authorization Header")
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if service.IsAPIKey(tokenString) {
+			apiKeyAuth(w, r, next, tokenString)
+			return
+		}
 		claims := &Claims{}
 
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -33,7 +62,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			http.Error(w, "Invalid Token", http.StatusUnauthorized)
+			httperr.WriteStatus(w, http.StatusUnauthorized, "Invalid Token")
+			return
+		}
+
+		// Tokens outlive a disabled or deleted account, so check it's still there
+		active, err := userActive(r.Context(), claims.UserID)
+		if err != nil {
+			httperr.Write(w, err)
+			return
+		}
+		if !active {
+			httperr.WriteStatus(w, http.StatusUnauthorized, "Account is disabled")
+			return
+		}
+
+		route := r.Method + " " + r.URL.Path
+		if claims.MustChangePassword && !passwordChangeRoutes[route] {
+			httperr.WriteStatus(w, http.StatusForbidden, "Password change required")
+			return
+		}
+		if claims.MFASetupRequired && !claims.MustChangePassword && !mfaSetupRoutes[route] {
+			httperr.WriteStatus(w, http.StatusForbidden, "Two-factor authentication setup required")
 			return
 		}
 
@@ -46,3 +96,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// apiKeyAuth serves a request from a service account, within the key's
+// scopes.
+func apiKeyAuth(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	principal, err := service.AuthenticateAPIKey(r.Context(), key, ip)
+	if err != nil {
+		httperr.Write(w, err)
+		return
+	}
+	if !principal.Allows(r.Method, r.URL.Path) {
+		httperr.WriteStatus(w, http.StatusForbidden, "Forbidden: API key scopes don't cover this request")
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", principal.UserID.String())
+	ctx = context.WithValue(ctx, "role", principal.Role)
+	if principal.ClientID != nil {
+		ctx = context.WithValue(ctx, "client_id", principal.ClientID.String())
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signToken(t *testing.T, claims Claims) string {
+	t.Helper()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareDisabledUser(t *testing.T) {
+	const userID = "2b7e1516-28ae-4d2a-a6f7-15880923c4d1"
+
+	tests := []struct {
+		name       string
+		active     bool
+		lookupErr  error
+		wantStatus int
+	}{
+		{name: "active user", active: true, wantStatus: http.StatusOK},
+		{name: "disabled or deleted user", active: false, wantStatus: http.StatusUnauthorized},
+		{name: "lookup fails", lookupErr: errors.New("connection refused"), wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := userActive
+			t.Cleanup(func() { userActive = prev })
+			var looked string
+			userActive = func(_ context.Context, id string) (bool, error) {
+				looked = id
+				return tt.active, tt.lookupErr
+			}
+
+			var gotRole interface{}
+			h := AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRole = r.Context().Value("role")
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/clients", nil)
+			r.Header.Set("Authorization", "Bearer "+signToken(t, Claims{UserID: userID, Role: "HR"}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if looked != userID {
+				t.Errorf("looked up user %q, want %q", looked, userID)
+			}
+			if (gotRole != nil) != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v, want %v", gotRole != nil, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareInvalidToken(t *testing.T) {
+	prev := userActive
+	t.Cleanup(func() { userActive = prev })
+	userActive = func(context.Context, string) (bool, error) {
+		t.Fatal("user looked up for an invalid token")
+		return false, nil
+	}
+
+	other, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "x"}).SignedString([]byte("another-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, header := range map[string]string{
+		"missing":    "",
+		"garbage":    "Bearer not-a-jwt",
+		"wrong key":  "Bearer " + other,
+		"wrong type": "Basic dXNlcjpwYXNz",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/clients", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		AuthMiddleware(http.NotFoundHandler()).ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", name, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", trusted: true, remoteAddr: "203.0.113.5:4000", want: "203.0.113.5"},
+		{name: "untrusted peer forwarding", trusted: true, remoteAddr: "203.0.113.5:4000", forwarded: []string{"1.2.3.4"}, want: "203.0.113.5"},
+		{name: "untrusted peer real ip", trusted: true, remoteAddr: "203.0.113.5:4000", realIP: "1.2.3.4", want: "203.0.113.5"},
+		{name: "no trusted proxies", remoteAddr: "10.0.0.2:4000", forwarded: []string{"1.2.3.4"}, want: "10.0.0.2"},
+		{name: "trusted proxy", trusted: true, remoteAddr: "10.0.0.2:4000", forwarded: []string{"1.2.3.4"}, want: "1.2.3.4"},
+		{name: "spoofed left hop", trusted: true, remoteAddr: "10.0.0.2:4000", forwarded: []string{"6.6.6.6, 1.2.3.4"}, want: "1.2.3.4"},
+		{name: "chain of proxies", trusted: true, remoteAddr: "10.0.0.2:4000", forwarded: []string{"6.6.6.6, 1.2.3.4", "192.168.1.1, 10.1.1.1"}, want: "1.2.3.4"},
+		{name: "only proxies", trusted: true, remoteAddr: "10.0.0.2:4000", forwarded: []string{"10.1.1.1"}, want: "10.1.1.1"},
+		{name: "unreadable hop", trusted: true, remoteAddr: "10.0.0.2:4000", forwarded: []string{"1.2.3.4, garbage"}, want: "10.0.0.2"},
+		{name: "mapped ipv4 peer", trusted: true, remoteAddr: "[::ffff:10.0.0.2]:4000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			var got string
+			handler := RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "10.0.0.0/8", want: 1},
+		{in: "10.0.0.1, ::1 ,fd00::/8", want: 3},
+		{in: "10.0.0.0/8,proxy.local", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrustedProxies(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTrustedProxies(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if len(got) != tt.want {
+			t.Errorf("ParseTrustedProxies(%q) = %v, want %d prefixes", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dubai/platform/backend/internal/httperr"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// EchoRequestID returns the ID chi's RequestID middleware gave the request
+// in the X-Request-Id response header, where error bodies pick it up. It
+// must come after RequestID.
+func EchoRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := chimiddleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(httperr.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
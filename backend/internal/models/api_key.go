@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey lets a service account call the API. The key itself is only
+// returned when it's created.
+type APIKey struct {
+	ID               uuid.UUID  `json:"id"`
+	ServiceAccountID uuid.UUID  `json:"service_account_id"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"`
+	Scopes           []string   `json:"scopes"`
+	ClientID         *uuid.UUID `json:"client_id"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	LastUsedIP       *string    `json:"last_used_ip"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedBy        *uuid.UUID `json:"created_by"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	// Name identifies the account, e.g. "hris-sync". It becomes the username.
+	Name     string     `json:"name" validate:"required"`
+	Role     UserRole   `json:"role" validate:"required,enum=user_role"`
+	ClientID *uuid.UUID `json:"client_id"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes are resource:read or resource:write, where the resource is the
+	// path segment after /api/ (e.g. "talent:read") and * means all of them.
+	// write includes read.
+	Scopes []string `json:"scopes" validate:"required"`
+	// ClientID limits the key to one client's data. It must match the
+	// account's client when the account has one.
+	ClientID  *uuid.UUID `json:"client_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse is the only time the key is shown.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
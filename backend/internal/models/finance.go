@@ -8,40 +8,42 @@ import (
 
 type Capital struct {
 	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
+	Name      string    `json:"name" validate:"required,max=200"`
 	Balance   float64   `json:"balance"`
-	Currency  string    `json:"currency"`
+	Currency  string    `json:"currency" validate:"currency"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Budget struct {
 	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	TotalAmount float64   `json:"total_amount"`
-	SpentAmount float64   `json:"spent_amount,omitempty"` // Computed
-	StartDate   string    `json:"start_date"`             // YYYY-MM-DD
-	EndDate     string    `json:"end_date"`               // YYYY-MM-DD
-	Status      string    `json:"status"`
+	Name        string    `json:"name" validate:"required,max=200"`
+	TotalAmount float64   `json:"total_amount" validate:"min=0"`
+	SpentAmount float64   `json:"spent_amount,omitempty"`                               // Computed
+	StartDate   string    `json:"start_date" validate:"required,date"`                  // YYYY-MM-DD
+	EndDate     string    `json:"end_date" validate:"required,date,gtefield=StartDate"` // YYYY-MM-DD
+	Status      string    `json:"status" validate:"oneof=ACTIVE ARCHIVED"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
 type Expense struct {
 	ID          uuid.UUID  `json:"id"`
-	Description string     `json:"description"`
-	Amount      float64    `json:"amount"`
-	Category    string     `json:"category"`
-	Date        string     `json:"date"` // YYYY-MM-DD
+	Description string     `json:"description" validate:"required"`
+	Amount      float64    `json:"amount" validate:"min=0"`
+	Category    string     `json:"category" validate:"required,max=100"`
+	Date        string     `json:"date" validate:"required,date"` // YYYY-MM-DD
+	Currency    string     `json:"currency" validate:"currency"`  // USD when not given
 	BudgetID    *uuid.UUID `json:"budget_id,omitempty"`
+	DocumentID  *uuid.UUID `json:"document_id,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
 
 type Investment struct {
 	ID            uuid.UUID `json:"id"`
-	Name          string    `json:"name"`
-	Investor      string    `json:"investor"`
-	InitialAmount float64   `json:"initial_amount"`
-	CurrentValue  float64   `json:"current_value"`
-	StartDate     string    `json:"start_date"` // YYYY-MM-DD
-	Status        string    `json:"status"`
+	Name          string    `json:"name" validate:"required,max=200"`
+	Investor      string    `json:"investor" validate:"required,max=200"`
+	InitialAmount float64   `json:"initial_amount" validate:"min=0"`
+	CurrentValue  float64   `json:"current_value" validate:"min=0"`
+	StartDate     string    `json:"start_date" validate:"required,date"` // YYYY-MM-DD
+	Status        string    `json:"status" validate:"oneof=ACTIVE LIQUIDATED"`
 	CreatedAt     time.Time `json:"created_at"`
 }
@@ -3,26 +3,34 @@ package models
 import (
 	"time"
 
+	"github.com/dubai/platform/backend/internal/extract"
+	"github.com/dubai/platform/backend/internal/textdiff"
 	"github.com/google/uuid"
 )
 
 type Talent struct {
-	ID           uuid.UUID       `json:"id"`
-	FirstName    string          `json:"first_name"`
-	LastName     string          `json:"last_name"`
-	Email        string          `json:"email"`
-	LinkedinURL  *string         `json:"linkedin_url"`
+	ID           uuid.UUID       `json:"id" validate:"readonly"`
+	FirstName    string          `json:"first_name" validate:"required,max=100"`
+	LastName     string          `json:"last_name" validate:"required,max=100"`
+	Email        string          `json:"email" validate:"required,email"`
+	LinkedinURL  *string         `json:"linkedin_url" validate:"url"`
 	Country      *string         `json:"country"`
 	Timezone     *string         `json:"timezone"`
-	Role         string          `json:"role"`
+	Role         string          `json:"role" validate:"required,max=100"`
 	Seniority    *string         `json:"seniority"`
 	EnglishLevel *string         `json:"english_level"`
 	Source       *string         `json:"source"`
 	Notes        *string         `json:"notes"`
 	Skills       []string        `json:"skills"`
-	Status       *string         `json:"status"`
-	History      []StatusHistory `json:"history"`
-	CreatedAt    time.Time       `json:"created_at"`
+	Status       *string         `json:"status" validate:"enum=talent_status"`
+	History      []StatusHistory `json:"history" validate:"readonly"`
+	CreatedAt    time.Time       `json:"created_at" validate:"readonly"`
+	Version      int             `json:"version" validate:"readonly"` // Bumped by every update, sent as the ETag
+	UpdatedAt    time.Time       `json:"updated_at" validate:"readonly"`
+
+	// Related records, only sent when asked for with ?expand=
+	Assignments []ProjectAssignment `json:"assignments,omitzero" validate:"readonly"`
+	Documents   []Document          `json:"documents,omitzero" validate:"readonly"`
 }
 
 type StatusHistory struct {
@@ -33,74 +41,122 @@ type StatusHistory struct {
 
 type Skill struct {
 	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Category string `json:"category"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Category string `json:"category" validate:"max=100"`
 }
 
 type TalentCommercial struct {
 	TalentID            uuid.UUID  `json:"talent_id"`
-	ExpectedMonthlyRate *float64   `json:"expected_monthly_rate_usd"`
+	ExpectedMonthlyRate *float64   `json:"expected_monthly_rate_usd" validate:"min=0"`
 	AvailabilityStatus  *string    `json:"availability_status"`
 	AvailableFromDate   *time.Time `json:"available_from_date"`
 	PaymentMethod       *string    `json:"payment_method"`
 }
 
 type Client struct {
-	ID              uuid.UUID `json:"id"`
-	CompanyName     string    `json:"company_name"`
+	ID              uuid.UUID `json:"id" validate:"readonly"`
+	CompanyName     string    `json:"company_name" validate:"required,max=200"`
 	Country         *string   `json:"country"`
 	Timezone        *string   `json:"timezone"`
-	BillingCurrency *string   `json:"billing_currency"`
-	Status          string    `json:"status"`
+	BillingCurrency *string   `json:"billing_currency" validate:"currency"`
+	BillingAddress  *string   `json:"billing_address"`
+	TaxID           *string   `json:"tax_id"`
+	Status          string    `json:"status" validate:"required,enum=client_status"`
 	Notes           *string   `json:"notes"`
-	CreatedAt       time.Time `json:"created_at"`
+	CreatedAt       time.Time `json:"created_at" validate:"readonly"`
+	Version         int       `json:"version" validate:"readonly"` // Bumped by every update, sent as the ETag
+	UpdatedAt       time.Time `json:"updated_at" validate:"readonly"`
+
+	// Related records, only sent when asked for with ?expand=
+	Projects    []Project           `json:"projects,omitzero" validate:"readonly"`
+	Assignments []ProjectAssignment `json:"assignments,omitzero" validate:"readonly"`
+	Contracts   []Contract          `json:"contracts,omitzero" validate:"readonly"`
+	Documents   []Document          `json:"documents,omitzero" validate:"readonly"`
 }
 
 type ClientContact struct {
 	ID        uuid.UUID `json:"id"`
 	ClientID  uuid.UUID `json:"client_id"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Email     string    `json:"email"`
+	FirstName string    `json:"first_name" validate:"required,max=100"`
+	LastName  string    `json:"last_name" validate:"max=100"`
+	Email     string    `json:"email" validate:"email"`
 	Role      string    `json:"role"`
 	IsPrimary bool      `json:"is_primary"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type Contract struct {
-	ID           uuid.UUID  `json:"id"`
-	ClientID     uuid.UUID  `json:"client_id"`
-	TalentID     uuid.UUID  `json:"talent_id"`
-	ProjectID    uuid.UUID  `json:"project_id"`
-	Type         string     `json:"type"`   // MSA, SOW, NDA
-	Status       string     `json:"status"` // DRAFT, SENT, SIGNED
-	StartDate    time.Time  `json:"start_date"`
-	EndDate      *time.Time `json:"end_date"`
-	NoticePeriod int        `json:"notice_period_days"`
-	FileURL      *string    `json:"file_url"`
-	FileKey      *string    `json:"file_key"`
-	SignedAt     *time.Time `json:"signed_at"`
-	CreatedAt    time.Time  `json:"created_at"`
+	ID                uuid.UUID  `json:"id"`
+	ClientID          *uuid.UUID `json:"client_id"`
+	TalentID          *uuid.UUID `json:"talent_id"`
+	ProjectID         *uuid.UUID `json:"project_id"`
+	MSAID             *uuid.UUID `json:"msa_id"`                                      // Required for SOWs: the client's governing MSA
+	Type              string     `json:"type" validate:"required,enum=contract_type"` // CLIENT, CONTRACTOR, MSA, SOW, NDA
+	Status            string     `json:"status"`                                      // DRAFT, SENT, SIGNED, TERMINATED, EXPIRED
+	Signed            bool       `json:"signed"`
+	StartDate         *time.Time `json:"start_date"`
+	EndDate           *time.Time `json:"end_date" validate:"gtefield=StartDate"`
+	NoticePeriod      int        `json:"notice_period_days" validate:"min=0"`
+	Rate              *float64   `json:"rate" validate:"min=0"`
+	RatePeriod        *string    `json:"rate_period" validate:"oneof=HOUR DAY MONTH YEAR"` // HOUR, DAY, MONTH, YEAR
+	Currency          *string    `json:"currency" validate:"currency"`
+	DocumentID        *uuid.UUID `json:"document_id"` // Source or generated document
+	TemplateID        *uuid.UUID `json:"template_id"` // Template it was generated from
+	FileURL           *string    `json:"file_url"`
+	FileKey           *string    `json:"file_key"`
+	SentAt            *time.Time `json:"sent_at"`
+	SignedAt          *time.Time `json:"signed_at"`
+	TerminatedAt      *time.Time `json:"terminated_at"`
+	TerminationReason *string    `json:"termination_reason"`
+	RenewedFromID     *uuid.UUID `json:"renewed_from_id"`
+	ExpiryFlaggedAt   *time.Time `json:"expiry_flagged_at"` // Set when the notice window opens
+	CreatedAt         time.Time  `json:"created_at"`
+
+	// Related records, only sent when asked for with ?expand=
+	Client    *Client    `json:"client,omitzero" validate:"readonly"`
+	Talent    *Talent    `json:"talent,omitzero" validate:"readonly"`
+	Project   *Project   `json:"project,omitzero" validate:"readonly"`
+	Documents []Document `json:"documents,omitzero" validate:"readonly"`
+}
+
+type ContractTemplate struct {
+	ID        *uuid.UUID `json:"id"` // Nil for built-in templates
+	Type      string     `json:"type" validate:"required,enum=contract_type"`
+	Name      string     `json:"name" validate:"required,max=200"`
+	Body      string     `json:"body" validate:"required"`
+	IsDefault bool       `json:"is_default"`
+	BuiltIn   bool       `json:"builtin"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 type Project struct {
-	ID                     uuid.UUID     `json:"id"`
-	ClientID               uuid.UUID     `json:"client_id"`
-	Name                   string        `json:"name"`
+	ID                     uuid.UUID     `json:"id" validate:"readonly"`
+	ClientID               uuid.UUID     `json:"client_id" validate:"required"`
+	Name                   string        `json:"name" validate:"required,max=200"`
 	Description            *string       `json:"description"`
-	Status                 string        `json:"status"`
-	EngagementType         string        `json:"engagement_type"`
-	MonthlyBudget          *float64      `json:"monthly_budget"`
-	TargetHoursPerWeek     *int          `json:"target_hours_per_week"`
-	BillableDaysPerMonth   *int          `json:"billable_days_per_month"`
-	ActiveAssignmentsCount int           `json:"active_assignments_count"`
-	CurrentWeeklyHours     *float64      `json:"current_weekly_hours"`
-	ActualMonthlyRevenue   *float64      `json:"actual_monthly_revenue"`
-	ActualMonthlyCost      *float64      `json:"actual_monthly_cost"`
-	PlannedMonthlyRevenue  *float64      `json:"planned_monthly_revenue"`
-	TeamMembers            []TeamMember  `json:"team_members"`
+	Status                 string        `json:"status" validate:"required,enum=project_status"`
+	EngagementType         string        `json:"engagement_type" validate:"oneof=TIME_AND_MATERIALS FIXED"`
+	MonthlyBudget          *float64      `json:"monthly_budget" validate:"min=0"`
+	TargetHoursPerWeek     *int          `json:"target_hours_per_week" validate:"min=0,max=168"`
+	BillableDaysPerMonth   *int          `json:"billable_days_per_month" validate:"min=0,max=31"`
+	ActiveAssignmentsCount int           `json:"active_assignments_count" validate:"readonly"`
+	CurrentWeeklyHours     *float64      `json:"current_weekly_hours" validate:"readonly"`
+	ActualMonthlyRevenue   *float64      `json:"actual_monthly_revenue" validate:"readonly"`
+	ActualMonthlyCost      *float64      `json:"actual_monthly_cost" validate:"readonly"`
+	PlannedMonthlyRevenue  *float64      `json:"planned_monthly_revenue" validate:"readonly"`
+	TeamMembers            []TeamMember  `json:"team_members" validate:"readonly"`
 	PlannedRoles           []PlannedRole `json:"planned_roles"`
-	CreatedAt              time.Time     `json:"created_at"`
+	CreatedAt              time.Time     `json:"created_at" validate:"readonly"`
+	Version                int           `json:"version" validate:"readonly"` // Bumped by every update, sent as the ETag
+	UpdatedAt              time.Time     `json:"updated_at" validate:"readonly"`
+
+	// Related records, only sent when asked for with ?expand=
+	Client      *Client             `json:"client,omitzero" validate:"readonly"`
+	Assignments []ProjectAssignment `json:"assignments,omitzero" validate:"readonly"`
+	Contracts   []Contract          `json:"contracts,omitzero" validate:"readonly"`
+	Documents   []Document          `json:"documents,omitzero" validate:"readonly"`
 }
 
 type TeamMember struct {
@@ -113,39 +169,51 @@ type TeamMember struct {
 type PlannedRole struct {
 	ID        uuid.UUID `json:"id"`
 	ProjectID uuid.UUID `json:"project_id"`
-	RoleName  string    `json:"role_name"`
-	Count     int       `json:"count"`
-	BillRate  float64   `json:"bill_rate"`
+	RoleName  string    `json:"role_name" validate:"required,max=100"`
+	Count     int       `json:"count" validate:"min=1"`
+	BillRate  float64   `json:"bill_rate" validate:"min=0"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type ProjectAssignment struct {
-	ID                    uuid.UUID  `json:"id"`
-	ProjectID             uuid.UUID  `json:"project_id"`
-	ClientID              uuid.UUID  `json:"client_id"` // Legacy/Redundant but kept for now
-	TalentID              uuid.UUID  `json:"talent_id"`
-	Role                  string     `json:"role"`
-	StartDate             time.Time  `json:"start_date"`
-	TrialEndDate          *time.Time `json:"trial_end_date"`
-	MonthlyClientRate     *float64   `json:"monthly_client_rate"`
-	MonthlyContractorCost float64    `json:"monthly_contractor_cost"`
-	DailyPayoutRate       *float64   `json:"daily_payout_rate"`
-	DailyBillRate         *float64   `json:"daily_bill_rate"`
-	HoursPerWeek          *int       `json:"hours_per_week"`
-	Status                string     `json:"status"`
-	CreatedAt             time.Time  `json:"created_at"`
+	ID                    uuid.UUID  `json:"id" validate:"readonly"`
+	ProjectID             uuid.UUID  `json:"project_id" validate:"required"`
+	ClientID              uuid.UUID  `json:"client_id" validate:"readonly"` // Legacy/Redundant but kept for now
+	TalentID              uuid.UUID  `json:"talent_id" validate:"required"`
+	Role                  string     `json:"role" validate:"required,max=100"`
+	StartDate             time.Time  `json:"start_date" validate:"required"`
+	TrialEndDate          *time.Time `json:"trial_end_date" validate:"gtefield=StartDate"`
+	MonthlyClientRate     *float64   `json:"monthly_client_rate" validate:"min=0"`
+	MonthlyContractorCost float64    `json:"monthly_contractor_cost" validate:"min=0"`
+	DailyPayoutRate       *float64   `json:"daily_payout_rate" validate:"min=0"`
+	DailyBillRate         *float64   `json:"daily_bill_rate" validate:"min=0"`
+	HoursPerWeek          *int       `json:"hours_per_week" validate:"min=0,max=168"`
+	Status                string     `json:"status" validate:"enum=project_status"`
+	CreatedAt             time.Time  `json:"created_at" validate:"readonly"`
+	Version               int        `json:"version" validate:"readonly"` // Bumped by every update, sent as the ETag
+	UpdatedAt             time.Time  `json:"updated_at" validate:"readonly"`
+
+	// Related records, only sent when asked for with ?expand=
+	Client  *Client  `json:"client,omitzero" validate:"readonly"`
+	Project *Project `json:"project,omitzero" validate:"readonly"`
+	Talent  *Talent  `json:"talent,omitzero" validate:"readonly"`
 }
 
 type Invoice struct {
 	ID            uuid.UUID         `json:"id"`
-	ClientID      uuid.UUID         `json:"client_id"`
-	BillingMonth  string            `json:"billing_month"`
-	TotalAmount   float64           `json:"total_amount"`
-	Currency      string            `json:"currency"`
-	Status        string            `json:"status"`
+	InvoiceNumber string            `json:"invoice_number"`
+	ClientID      uuid.UUID         `json:"client_id" validate:"required"`
+	BillingMonth  string            `json:"billing_month" validate:"required,month"`
+	DueDate       *time.Time        `json:"due_date"`
+	TotalAmount   float64           `json:"total_amount" validate:"min=0"`
+	Currency      string            `json:"currency" validate:"required,currency"`
+	Status        string            `json:"status" validate:"enum=invoice_status"`
 	XeroInvoiceID *string           `json:"xero_invoice_id"`
+	PaidAt        *time.Time        `json:"paid_at"`
 	LineItems     []InvoiceLineItem `json:"line_items"`
 	CreatedAt     time.Time         `json:"created_at"`
+
+	Client *Client `json:"client,omitzero" validate:"readonly"` // Only sent with ?expand=client
 }
 
 type InvoiceLineItem struct {
@@ -155,30 +223,112 @@ type InvoiceLineItem struct {
 	// Schema: project_id UUID REFERENCES projects(id) ON DELETE RESTRICT
 	// It is nullable in SQL (default).
 	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
+	Amount      float64 `json:"amount" validate:"min=0"`
+	ProjectName *string `json:"project_name,omitempty"` // Read-only
 }
 
 type ContractorPayment struct {
-	ID           uuid.UUID `json:"id"`
-	TalentID     uuid.UUID `json:"talent_id"`
-	ProjectID    uuid.UUID `json:"project_id"`
-	BillingMonth string    `json:"billing_month"`
-	Amount       float64   `json:"amount"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           uuid.UUID  `json:"id"`
+	TalentID     uuid.UUID  `json:"talent_id" validate:"required"`
+	ProjectID    uuid.UUID  `json:"project_id" validate:"required"`
+	BillingMonth string     `json:"billing_month" validate:"required,month"`
+	Amount       float64    `json:"amount" validate:"min=0"`
+	Status       string     `json:"status" validate:"enum=payment_status"` // PENDING, APPROVED, PAID
+	ApprovedAt   *time.Time `json:"approved_at"`
+	ApprovedBy   *uuid.UUID `json:"approved_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Related records, only sent when asked for with ?expand=
+	Talent  *Talent  `json:"talent,omitzero" validate:"readonly"`
+	Project *Project `json:"project,omitzero" validate:"readonly"`
 }
 
 type Document struct {
-	ID         uuid.UUID `json:"id"`
-	EntityType string    `json:"entity_type"`
-	EntityID   uuid.UUID `json:"entity_id"`
-	FileName   string    `json:"file_name"`
-	FileType   string    `json:"file_type"`
-	FileSize   int64     `json:"file_size"`
-	Status     string    `json:"status"`
-	FileURL    string    `json:"file_url"`
-	FileKey    string    `json:"file_key"`
-	OCRStatus  *string   `json:"ocr_status"`
-	Content    *string   `json:"content"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	ID          uuid.UUID `json:"id"`
+	EntityType  string    `json:"entity_type" validate:"required"`
+	EntityID    uuid.UUID `json:"entity_id" validate:"required"`
+	FileName    string    `json:"file_name" validate:"required,max=255"`
+	FileType    string    `json:"file_type"`
+	FileSize    int64     `json:"file_size"`
+	Status      string    `json:"status"`
+	FileURL     string    `json:"file_url,omitempty"` // Write-only: never returned, use DownloadURL
+	FileKey     string    `json:"file_key,omitempty"` // Write-only: never returned, use DownloadURL
+	OCRStatus   *string   `json:"ocr_status"`         // PENDING, PROCESSING, COMPLETED, FAILED
+	OCRError    *string   `json:"ocr_error"`
+	Content     *string   `json:"content"`
+	DownloadURL string    `json:"download_url"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+
+	LogicalID    uuid.UUID  `json:"logical_id"` // Shared by every version of the document
+	Version      int        `json:"version"`
+	SupersededBy *uuid.UUID `json:"superseded_by"` // Nil for the latest version
+	SupersededAt *time.Time `json:"superseded_at"`
+	UploadedBy   *uuid.UUID `json:"uploaded_by"`
+}
+
+type DocumentSearchResult struct {
+	Document
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"` // HTML-escaped text with matches wrapped in <mark></mark>
+}
+
+type DocumentDiff struct {
+	LogicalID   uuid.UUID       `json:"logical_id"`
+	FromVersion int             `json:"from_version"`
+	ToVersion   int             `json:"to_version"`
+	Added       int             `json:"added"`
+	Removed     int             `json:"removed"`
+	Lines       []textdiff.Line `json:"lines"`
+}
+
+type DocumentAccess struct {
+	ID         uuid.UUID  `json:"id"`
+	DocumentID uuid.UUID  `json:"document_id"`
+	UserID     *uuid.UUID `json:"user_id"`
+	Mode       string     `json:"mode"` // REDIRECT, STREAM, URL
+	IPAddress  *string    `json:"ip_address"`
+	UserAgent  *string    `json:"user_agent"`
+	AccessedAt time.Time  `json:"accessed_at"`
+}
+
+type TrashItem struct {
+	EntityType string     `json:"entity_type"` // client, project, talent, contract, document
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	DeletedAt  time.Time  `json:"deleted_at"`
+	DeletedBy  *uuid.UUID `json:"deleted_by"`
+}
+
+type DocumentExtraction struct {
+	ID           uuid.UUID       `json:"id"`
+	DocumentID   uuid.UUID       `json:"document_id"`
+	DocumentType string          `json:"document_type"` // contract, invoice
+	Fields       []extract.Field `json:"fields"`
+	Status       string          `json:"status"`      // PROPOSED, ACCEPTED, REJECTED
+	TargetType   *string         `json:"target_type"` // CONTRACT, EXPENSE
+	TargetID     *uuid.UUID      `json:"target_id"`
+	CreatedBy    *uuid.UUID      `json:"created_by"`
+	CreatedAt    time.Time       `json:"created_at"`
+	ReviewedBy   *uuid.UUID      `json:"reviewed_by"`
+	ReviewedAt   *time.Time      `json:"reviewed_at"`
+}
+
+type OutboxEmail struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	UserID    *uuid.UUID `json:"user_id"`
+	To        string     `json:"to"`
+	Subject   string     `json:"subject"`
+	Status    string     `json:"status"` // PENDING, SENT, FAILED
+	Attempts  int        `json:"attempts"`
+	LastError *string    `json:"last_error"`
+	SentAt    *time.Time `json:"sent_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type NotificationPreference struct {
+	Kind         string `json:"kind"`
+	Description  string `json:"description"`
+	EmailEnabled bool   `json:"email_enabled"`
+	Mandatory    bool   `json:"mandatory"` // Can't be switched off
 }
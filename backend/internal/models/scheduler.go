@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ScheduledTask is a recurring background task and its most recent run.
+type ScheduledTask struct {
+	Name           string            `json:"name"`
+	Schedule       string            `json:"schedule"` // Cron expression, in SCHEDULER_TIMEZONE
+	Description    *string           `json:"description"`
+	NextRunAt      *time.Time        `json:"next_run_at"`
+	PausedAt       *time.Time        `json:"paused_at"`
+	PausedBy       *uuid.UUID        `json:"paused_by"`
+	RunRequestedAt *time.Time        `json:"run_requested_at"` // A manual run waiting to be picked up
+	LastRun        *ScheduledTaskRun `json:"last_run"`
+}
+
+type ScheduledTaskRun struct {
+	ID          uuid.UUID  `json:"id"`
+	TaskName    string     `json:"task_name"`
+	Status      string     `json:"status"`       // RUNNING, SUCCEEDED, FAILED
+	TriggeredBy *uuid.UUID `json:"triggered_by"` // Set for manual runs
+	Instance    *string    `json:"instance"`
+	Result      *string    `json:"result"`
+	Error       *string    `json:"error"`
+	DurationMS  *int64     `json:"duration_ms"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
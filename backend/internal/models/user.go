@@ -25,23 +25,115 @@ type User struct {
 	Role         string     `json:"role"`
 	CompanyName  *string    `json:"company_name"`
 	ClientID     *uuid.UUID `json:"client_id"`
-	CreatedAt    time.Time  `json:"created_at"`
+	// ActivatedAt is nil until an invited user sets their password
+	ActivatedAt *time.Time `json:"activated_at"`
+	// DisabledAt is set when an administrator has blocked the account
+	DisabledAt         *time.Time `json:"disabled_at"`
+	MustChangePassword bool       `json:"must_change_password"`
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	FailedLoginCount   int        `json:"failed_login_count"`
+	LockedUntil        *time.Time `json:"locked_until"`
+	MFAEnabled         bool       `json:"mfa_enabled"`
+	// SSO is set once the user has signed in through the identity provider
+	SSO bool `json:"sso"`
+	// ServiceAccount users can't sign in; they act through API keys
+	ServiceAccount bool      `json:"service_account"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse carries either a session token and user, or, for accounts
+// with two-factor auth, MFARequired and the MFAToken to complete sign-in with.
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token       string `json:"token,omitempty"`
+	User        *User  `json:"user,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// MFALoginRequest completes a sign-in with an authenticator code or one of the
+// recovery codes.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// MFARecoveryCodesResponse is the only time recovery codes are shown. Token
+// replaces the caller's session when enabling 2FA lifted a restriction on it.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Token         string   `json:"token,omitempty"`
 }
 
 type RegisterRequest struct {
-	Username string     `json:"username"`
-	Email    string     `json:"email"`
+	Username string `json:"username"`
+	Email    string `json:"email" validate:"required,email"`
+	// Password is optional. Without one the user is emailed an invitation to
+	// choose their own; with one they must change it at first sign-in.
 	Password string     `json:"password"`
-	Role     UserRole   `json:"role"`
+	Role     UserRole   `json:"role" validate:"required,enum=user_role"`
 	ClientID *uuid.UUID `json:"client_id"`
 }
+
+type ForgotPasswordRequest struct {
+	// Email is the user's email address or username
+	Email string `json:"email" validate:"required"`
+}
+
+// SetPasswordRequest redeems an invitation or password reset token.
+type SetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// UpdateProfileRequest changes a user's own details. Fields left out are
+// kept.
+type UpdateProfileRequest struct {
+	CompanyName *string `json:"company_name" validate:"max=200"`
+	Email       *string `json:"email" validate:"email"`
+	Username    *string `json:"username" validate:"max=100"`
+	Password    *string `json:"password"`
+}
+
+// UpdateUserRequest is how admins change another user. An empty ClientID
+// unlinks the user from their client.
+type UpdateUserRequest struct {
+	UpdateProfileRequest
+	Role     *UserRole `json:"role" validate:"enum=user_role"`
+	ClientID *string   `json:"client_id" validate:"uuid"`
+}
+
+type LoginAttempt struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    *uuid.UUID `json:"user_id"`
+	Login     string     `json:"login"`
+	IP        string     `json:"ip"`
+	UserAgent *string    `json:"user_agent"`
+	Success   bool       `json:"success"`
+	Reason    *string    `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEndpoint receives platform events. Secret is only returned when the
+// endpoint is created or its secret is rotated.
+type WebhookEndpoint struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Description         *string    `json:"description"`
+	Secret              string     `json:"secret,omitempty"`
+	Events              []string   `json:"events"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DisabledReason      *string    `json:"disabled_reason"`
+	LastSuccessAt       *time.Time `json:"last_success_at"`
+	CreatedBy           *uuid.UUID `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events" validate:"required"`
+	// Enabled re-enables an endpoint that was disabled, or disables one.
+	// Ignored when creating.
+	Enabled *bool `json:"enabled"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   *string         `json:"response_body"`
+	LastError      *string         `json:"last_error"`
+	DurationMS     *int            `json:"duration_ms"`
+	ReplayOf       *uuid.UUID      `json:"replay_of"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
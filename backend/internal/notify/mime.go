@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Bytes encodes the message as RFC 5322 with a text/html alternative and
+// any attachments.
+func (m *Message) Bytes(now time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+
+	header("From", encodeAddress(m.From))
+	to := make([]string, len(m.To))
+	for i, addr := range m.To {
+		to[i] = encodeAddress(addr)
+	}
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(m.From))
+	header("MIME-Version", "1.0")
+
+	var alt bytes.Buffer
+	altWriter := multipart.NewWriter(&alt)
+	writeQuotedPrintable(altWriter, "text/plain; charset=utf-8", m.Text)
+	if m.HTML != "" {
+		writeQuotedPrintable(altWriter, "text/html; charset=utf-8", m.HTML)
+	}
+	altWriter.Close()
+	altType := `multipart/alternative; boundary="` + altWriter.Boundary() + `"`
+
+	if len(m.Attachments) == 0 {
+		header("Content-Type", altType)
+		buf.WriteString("\r\n")
+		buf.Write(alt.Bytes())
+		return buf.Bytes()
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/mixed; boundary="`+mixed.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	part, _ := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {altType}})
+	part.Write(alt.Bytes())
+
+	for _, a := range m.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, _ := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+	mixed.Close()
+	return buf.Bytes()
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType, s string) {
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n")))
+	qp.Close()
+}
+
+// encodeAddress encodes a display name if needed, leaving bare addresses
+// and unparseable input as they are.
+func encodeAddress(s string) string {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return s
+	}
+	return addr.String()
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,74 @@
+// Package notify renders and delivers transactional email. Messages are
+// queued through the email outbox in the service layer; this package only
+// knows how to turn a template into a Message and hand it to a Transport.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+type Message struct {
+	From        string
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Transport delivers a message. Implementations must be safe for
+// concurrent use.
+type Transport interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// NewFromEnv selects the transport from MAIL_TRANSPORT (smtp, file or log).
+// The log transport is used when unset so development never sends mail by
+// accident.
+func NewFromEnv() (Transport, error) {
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "log":
+		return LogTransport{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_FILE_DIR")
+		if dir == "" {
+			dir = "./data/mail"
+		}
+		return NewFileTransport(dir)
+	case "smtp":
+		port := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT: %q", v)
+			}
+			port = n
+		}
+		return NewSMTPTransport(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			TLS:      os.Getenv("SMTP_TLS"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT: %s", transport)
+	}
+}
+
+// From returns the sender address from MAIL_FROM.
+func From() string {
+	if from := os.Getenv("MAIL_FROM"); from != "" {
+		return from
+	}
+	return "Hirefel <no-reply@localhost>"
+}
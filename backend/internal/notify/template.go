@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Each template defines a "subject" and a plain-text "text" block. The HTML
+// part is derived from the text so the two can't drift apart.
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templates maps a file name without extension to its parsed blocks. Each
+// file is parsed on its own because they all define the same block names.
+var templates = map[string]*template.Template{}
+
+func init() {
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		templates[name] = template.Must(template.New(name).ParseFS(templateFS, "templates/"+e.Name()))
+	}
+}
+
+// Render executes the named template.
+func Render(name string, data interface{}) (subject, text, htmlBody string, err error) {
+	t, ok := templates[name]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown email template %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err := t.ExecuteTemplate(&buf, "text", data); err != nil {
+		return "", "", "", err
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+
+	htmlBody, err = textToHTML(subject, text)
+	return subject, text, htmlBody, err
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+var layout = htmltemplate.Must(htmltemplate.New("layout").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="margin:0;padding:24px;background:#f5f6f8;font-family:Helvetica,Arial,sans-serif;font-size:14px;line-height:1.5;color:#1f2933">
+<div style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:6px;padding:32px">
+{{range .Paragraphs}}<p style="margin:0 0 16px">{{.}}</p>
+{{end}}</div>
+</body></html>
+`))
+
+// textToHTML escapes text, turns URLs into links and blank-line separated
+// blocks into paragraphs.
+func textToHTML(title, text string) (string, error) {
+	var paragraphs []htmltemplate.HTML
+	for _, block := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		for i, line := range lines {
+			line = html.EscapeString(line)
+			lines[i] = urlPattern.ReplaceAllStringFunc(line, func(u string) string {
+				return `<a href="` + u + `" style="color:#1f3a5f">` + u + `</a>`
+			})
+		}
+		paragraphs = append(paragraphs, htmltemplate.HTML(strings.Join(lines, "<br>\n")))
+	}
+
+	var buf bytes.Buffer
+	err := layout.Execute(&buf, struct {
+		Title      string
+		Paragraphs []htmltemplate.HTML
+	}{title, paragraphs})
+	return buf.String(), err
+}
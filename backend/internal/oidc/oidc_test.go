@@ -0,0 +1,236 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/oidc"
+	"github.com/dubai/platform/backend/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	clientID     = "platform"
+	clientSecret = "s3cret"
+	redirectURL  = "http://app.test/api/auth/oidc/callback"
+)
+
+func newProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	t.Helper()
+	idp, srv, err := oidctest.NewServer(clientID, clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	p, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       idp.Issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email"},
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	return idp, p
+}
+
+// authorize follows authURL to the provider and returns the query it
+// redirects back to our callback with.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(loc.String(), redirectURL) {
+		t.Fatalf("redirected to %s, want %s", loc, redirectURL)
+	}
+	return loc.Query()
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	idp, p := newProvider(t)
+	idp.SetUser(oidctest.User{
+		Subject:       "u-123",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Name:          "Jane",
+		Groups:        []string{"platform-hr", "everyone"},
+	})
+
+	verifier, _ := oidc.RandomString()
+	authURL := p.AuthCodeURL("the-state", "the-nonce", oidc.Challenge(verifier))
+	u, _ := url.Parse(authURL)
+	for key, want := range map[string]string{
+		"client_id":             clientID,
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email",
+		"state":                 "the-state",
+		"nonce":                 "the-nonce",
+		"code_challenge":        oidc.Challenge(verifier),
+		"code_challenge_method": "S256",
+	} {
+		if got := u.Query().Get(key); got != want {
+			t.Errorf("auth URL %s = %q, want %q", key, got, want)
+		}
+	}
+
+	back := authorize(t, authURL)
+	if back.Get("state") != "the-state" {
+		t.Fatalf("state = %q, want the-state", back.Get("state"))
+	}
+	rawIDToken, err := p.Exchange(context.Background(), back.Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := p.Verify(context.Background(), rawIDToken, "the-nonce")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "u-123" || claims.Email != "jane@example.com" || !claims.EmailVerified || claims.Name != "Jane" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if got, want := claims.StringsClaim("groups"), []string{"platform-hr", "everyone"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(code, verifier *string)
+	}{
+		{"wrong PKCE verifier", func(_, verifier *string) { *verifier = "not-the-verifier" }},
+		{"empty PKCE verifier", func(_, verifier *string) { *verifier = "" }},
+		{"unknown code", func(code, _ *string) { *code = "forged" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p := newProvider(t)
+			verifier, _ := oidc.RandomString()
+			code := authorize(t, p.AuthCodeURL("s", "n", oidc.Challenge(verifier))).Get("code")
+			tt.mutate(&code, &verifier)
+			if _, err := p.Exchange(context.Background(), code, verifier); err == nil {
+				t.Fatal("Exchange succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestExchangeCodeIsSingleUse(t *testing.T) {
+	_, p := newProvider(t)
+	verifier, _ := oidc.RandomString()
+	code := authorize(t, p.AuthCodeURL("s", "n", oidc.Challenge(verifier))).Get("code")
+	if _, err := p.Exchange(context.Background(), code, verifier); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err := p.Exchange(context.Background(), code, verifier); err == nil {
+		t.Fatal("second Exchange succeeded, want error")
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	tests := []struct {
+		name    string
+		nonce   string
+		wantErr bool
+	}{
+		{"matching nonce", "n-1", false},
+		{"other nonce", "n-2", true},
+		{"empty nonce", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, p := newProvider(t)
+			verifier, _ := oidc.RandomString()
+			code := authorize(t, p.AuthCodeURL("s", "n-1", oidc.Challenge(verifier))).Get("code")
+			rawIDToken, err := p.Exchange(context.Background(), code, verifier)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			_, err = p.Verify(context.Background(), rawIDToken, tt.nonce)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsOtherSigner(t *testing.T) {
+	_, p := newProvider(t)
+	// A token from another provider instance is signed with a key ours
+	// doesn't publish
+	other, otherSrv, err := oidctest.NewServer(clientID, clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherSrv.Close()
+	op, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer: other.Issuer, ClientID: clientID, ClientSecret: clientSecret, RedirectURL: redirectURL,
+	}, otherSrv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, _ := oidc.RandomString()
+	code := authorize(t, op.AuthCodeURL("s", "n", oidc.Challenge(verifier))).Get("code")
+	rawIDToken, err := op.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(context.Background(), rawIDToken, "n"); err == nil {
+		t.Fatal("Verify accepted a token from another issuer")
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	idp, srv, err := oidctest.NewServer(clientID, clientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	_, err = oidc.Discover(context.Background(), oidc.Config{Issuer: idp.Issuer + "/"}, srv.Client())
+	if err == nil {
+		t.Fatal("Discover accepted a different issuer")
+	}
+}
+
+func TestStringsClaim(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  interface{}
+		want []string
+	}{
+		{"list", []interface{}{"a", "b"}, []string{"a", "b"}},
+		{"single string", "a", []string{"a"}},
+		{"non-strings skipped", []interface{}{"a", 1.0, true}, []string{"a"}},
+		{"missing", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &oidc.Claims{Raw: jwt.MapClaims{}}
+			if tt.raw != nil {
+				c.Raw["groups"] = tt.raw
+			}
+			if got := c.StringsClaim("groups"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StringsClaim = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
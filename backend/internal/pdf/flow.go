@@ -0,0 +1,61 @@
+package pdf
+
+// Flow lays out content top to bottom between margins, starting new pages
+// as needed. Y is the top of the free space on the current page.
+type Flow struct {
+	Doc    *Document
+	Margin float64
+	Y      float64
+
+	// Footer, if set, is drawn on every page when it is started.
+	Footer func(d *Document, page int)
+}
+
+func NewFlow(d *Document, margin float64) *Flow {
+	f := &Flow{Doc: d, Margin: margin}
+	f.NewPage()
+	return f
+}
+
+// Width is the usable width between the margins.
+func (f *Flow) Width() float64 {
+	return PageWidth - 2*f.Margin
+}
+
+func (f *Flow) NewPage() {
+	f.Doc.AddPage()
+	f.Y = f.Margin
+	if f.Footer != nil {
+		f.Footer(f.Doc, f.Doc.PageCount())
+	}
+}
+
+// Ensure starts a new page unless h points of space remain.
+func (f *Flow) Ensure(h float64) {
+	if f.Y+h > PageHeight-f.Margin {
+		f.NewPage()
+	}
+}
+
+// Space moves down by h points.
+func (f *Flow) Space(h float64) {
+	f.Y += h
+}
+
+// Paragraph writes wrapped text indented by indent points.
+func (f *Flow) Paragraph(font Font, size float64, color Color, indent float64, text string) {
+	leading := size * 1.4
+	for _, line := range Wrap(font, size, text, f.Width()-indent) {
+		f.Ensure(leading)
+		f.Y += leading
+		f.Doc.Text(f.Margin+indent, f.Y-size*0.3, font, size, color, line)
+	}
+}
+
+// Rule draws a horizontal line across the usable width.
+func (f *Flow) Rule(color Color) {
+	f.Ensure(8)
+	f.Y += 4
+	f.Doc.Line(f.Margin, f.Y, PageWidth-f.Margin, f.Y, 0.5, color)
+	f.Y += 4
+}
@@ -0,0 +1,110 @@
+package pdf
+
+import "strings"
+
+// Glyph widths (per 1000 units of font size) for WinAnsi codes 32-126, from
+// the Adobe font metrics of the standard fonts. The oblique face shares the
+// regular widths.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// WinAnsi codes for characters outside Latin-1 that commonly appear in
+// business documents.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '•': 0x95, '–': 0x96, '—': 0x97,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '™': 0x99,
+}
+
+// encode converts s to WinAnsi bytes, replacing unsupported characters with '?'.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		case r == '\n' || r == '\t':
+			out = append(out, ' ')
+		default:
+			if b, ok := winAnsiExtras[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+func glyphWidth(font Font, c byte) int {
+	if c < 32 || c > 126 {
+		// Close enough for accented letters and symbols outside ASCII.
+		return 556
+	}
+	if font == HelveticaBold {
+		return helveticaBoldWidths[c-32]
+	}
+	return helveticaWidths[c-32]
+}
+
+// TextWidth returns the width of s in points.
+func TextWidth(font Font, size float64, s string) float64 {
+	total := 0
+	for _, c := range encode(s) {
+		total += glyphWidth(font, c)
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width, splitting on spaces and,
+// for words longer than a line, inside the word.
+func Wrap(font Font, size float64, s string, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := ""
+		for _, w := range words {
+			candidate := w
+			if line != "" {
+				candidate = line + " " + w
+			}
+			if TextWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Hard-break words that can't fit on a line of their own
+			for TextWidth(font, size, w) > width {
+				cut := len([]rune(w)) - 1
+				for cut > 1 && TextWidth(font, size, string([]rune(w)[:cut])) > width {
+					cut--
+				}
+				lines = append(lines, string([]rune(w)[:cut]))
+				w = string([]rune(w)[cut:])
+			}
+			line = w
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,51 @@
+package pdf
+
+import "strings"
+
+// Markup renders a small markdown subset: "# " title, "## " heading,
+// "- " bullets, "---" rules, and paragraphs separated by blank lines.
+// Consecutive text lines are joined into one paragraph.
+func (f *Flow) Markup(text string) {
+	var para []string
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		f.Paragraph(Helvetica, 10, Black, 0, strings.Join(para, " "))
+		f.Space(6)
+		para = nil
+	}
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		// Inline emphasis isn't supported; drop the markers rather than print them
+		line := strings.TrimSpace(strings.ReplaceAll(raw, "**", ""))
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "# "):
+			flush()
+			f.Ensure(60) // Keep headings with what follows
+			f.Paragraph(HelveticaBold, 16, Black, 0, line[2:])
+			f.Space(10)
+		case strings.HasPrefix(line, "## "):
+			flush()
+			f.Ensure(50)
+			f.Space(4)
+			f.Paragraph(HelveticaBold, 11.5, Black, 0, line[3:])
+			f.Space(4)
+		case strings.HasPrefix(line, "- "):
+			flush()
+			// Reserve the first line so the bullet and its text share a page
+			f.Ensure(14)
+			f.Doc.Text(f.Margin+4, f.Y+14-3, Helvetica, 10, Black, "•")
+			f.Paragraph(Helvetica, 10, Black, 14, line[2:])
+			f.Space(2)
+		case line == "---":
+			flush()
+			f.Rule(LightGray)
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+}
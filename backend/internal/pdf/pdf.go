@@ -0,0 +1,195 @@
+// Package pdf writes simple PDF documents without external dependencies. It
+// supports text in the standard Helvetica fonts (WinAnsi encoding), lines and
+// filled rectangles, which is all our contracts and invoices need.
+//
+// Coordinates are in points with the origin at the top-left of the page.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A4 portrait, in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	HelveticaOblique
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// Color is an RGB colour with components from 0 to 1.
+type Color struct{ R, G, B float64 }
+
+var (
+	Black     = Color{0, 0, 0}
+	White     = Color{1, 1, 1}
+	Gray      = Color{0.45, 0.45, 0.45}
+	LightGray = Color{0.93, 0.93, 0.93}
+)
+
+// ParseHex parses "#rrggbb" (the "#" is optional).
+func ParseHex(s string) (Color, error) {
+	s = strings.TrimPrefix(s, "#")
+	var r, g, b uint8
+	if len(s) != 6 {
+		return Color{}, fmt.Errorf("invalid colour %q", s)
+	}
+	if _, err := fmt.Sscanf(s, "%02x%02x%02x", &r, &g, &b); err != nil {
+		return Color{}, fmt.Errorf("invalid colour %q", s)
+	}
+	return Color{float64(r) / 255, float64(g) / 255, float64(b) / 255}, nil
+}
+
+type Document struct {
+	Title   string
+	Author  string
+	Created time.Time
+
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+}
+
+func New() *Document {
+	return &Document{Created: time.Now()}
+}
+
+// AddPage starts a new page; drawing always goes to the last page.
+func (d *Document) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// PageCount returns the number of pages so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) current() *bytes.Buffer {
+	if d.page == nil {
+		d.AddPage()
+	}
+	return d.page
+}
+
+// Text draws s with its baseline at y.
+func (d *Document) Text(x, y float64, font Font, size float64, color Color, s string) {
+	fmt.Fprintf(d.current(), "BT %s rg /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		rgb(color), int(font)+1, num(size), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// TextRight draws s so that it ends at x.
+func (d *Document) TextRight(x, y float64, font Font, size float64, color Color, s string) {
+	d.Text(x-TextWidth(font, size, s), y, font, size, color, s)
+}
+
+// Line draws a straight line.
+func (d *Document) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(d.current(), "%s RG %s w %s %s m %s %s l S\n",
+		rgb(color), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Rect fills a rectangle whose top-left corner is at x, y.
+func (d *Document) Rect(x, y, w, h float64, color Color) {
+	fmt.Fprintf(d.current(), "%s rg %s %s %s %s re f\n",
+		rgb(color), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// WriteTo serialises the document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Object layout: 1 catalog, 2 page tree, 3 info, fonts, then a page and
+	// its content stream for each page.
+	fontBase := 4
+	pageBase := fontBase + len(fontNames)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageBase+2*i)
+	}
+	var fonts strings.Builder
+	for i := range fontNames {
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", i+1, fontBase+i)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj(fmt.Sprintf("<< /Title (%s) /Author (%s) /Producer (platform) /CreationDate (D:%s) >>",
+		escape(encode(d.Title)), escape(encode(d.Author)), d.Created.UTC().Format("20060102150405Z")))
+	for _, name := range fontNames {
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	for i, content := range d.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), fonts.String(), pageBase+2*i+1))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Bytes returns the serialised document.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
+
+func rgb(c Color) string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n', '\r', '\t':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
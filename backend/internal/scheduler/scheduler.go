@@ -0,0 +1,325 @@
+// Package scheduler runs recurring tasks on cron schedules. Every instance
+// runs the same loop; a Postgres advisory lock per task makes sure only one
+// of them runs a task at a time, and the next run time is kept in the
+// database so an occurrence runs once however many instances are up.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/google/uuid"
+)
+
+const (
+	RunRunning   = "RUNNING"
+	RunSucceeded = "SUCCEEDED"
+	RunFailed    = "FAILED"
+)
+
+// Scheduler settings. Location is the time zone cron expressions are read
+// in.
+var (
+	PollInterval   = 30 * time.Second
+	DefaultTimeout = 30 * time.Minute
+	Location       = time.UTC
+)
+
+// RunFunc does a task's work and returns a short summary for the run
+// history, e.g. "purged 3 items".
+type RunFunc func(ctx context.Context) (string, error)
+
+// Task is a recurring task. A task that runs longer than Timeout (default
+// DefaultTimeout) is cancelled.
+type Task struct {
+	Name        string
+	Schedule    string
+	Description string
+	Timeout     time.Duration
+	Run         RunFunc
+
+	cron *Schedule
+}
+
+var (
+	mu      sync.RWMutex
+	tasks   = map[string]*Task{}
+	order   []string
+	running = map[string]bool{}
+
+	instance = func() string {
+		if host, err := os.Hostname(); err == nil {
+			return host
+		}
+		return "unknown"
+	}()
+)
+
+// Register adds a task. It must be called before Start.
+func Register(t Task) error {
+	cron, err := ParseCron(t.Schedule)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", t.Name, err)
+	}
+	if t.Timeout == 0 {
+		t.Timeout = DefaultTimeout
+	}
+	t.cron = cron
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := tasks[t.Name]; ok {
+		return fmt.Errorf("task %s is already registered", t.Name)
+	}
+	tasks[t.Name] = &t
+	order = append(order, t.Name)
+	return nil
+}
+
+// Names returns the registered task names in registration order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string(nil), order...)
+}
+
+// NextRun returns when a registered task is next due after t, or nil if it
+// isn't registered or its schedule never matches again.
+func NextRun(name string, t time.Time) *time.Time {
+	mu.RLock()
+	task, ok := tasks[name]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return task.next(t)
+}
+
+func (t *Task) next(after time.Time) *time.Time {
+	next := t.cron.Next(after.In(Location))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// Start records the registered tasks and runs them when due until ctx is
+// cancelled. A task whose schedule changed is rescheduled from now; one that
+// was due while no instance was up runs once straight away.
+func Start(ctx context.Context) error {
+	now := time.Now()
+	for _, name := range Names() {
+		mu.RLock()
+		t := tasks[name]
+		mu.RUnlock()
+		_, err := db.Pool.Exec(ctx, `
+			INSERT INTO scheduled_tasks (name, schedule, description, next_run_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO UPDATE SET
+				description = EXCLUDED.description,
+				schedule = EXCLUDED.schedule,
+				next_run_at = CASE WHEN scheduled_tasks.schedule = EXCLUDED.schedule THEN scheduled_tasks.next_run_at ELSE EXCLUDED.next_run_at END,
+				updated_at = now()
+		`, t.Name, t.Schedule, t.Description, t.next(now))
+		if err != nil {
+			return fmt.Errorf("register task %s: %w", t.Name, err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		for {
+			if err := runDue(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Scheduler: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// runDue starts every task that is due or was triggered by hand and isn't
+// already running here.
+func runDue(ctx context.Context) error {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT name FROM scheduled_tasks
+		WHERE name = ANY($1)
+		  AND ((paused_at IS NULL AND next_run_at <= $2) OR run_requested_at IS NOT NULL)
+	`, Names(), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	var due []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		due = append(due, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range due {
+		mu.Lock()
+		t := tasks[name]
+		if running[name] {
+			mu.Unlock()
+			continue
+		}
+		running[name] = true
+		mu.Unlock()
+
+		go func() {
+			defer func() {
+				mu.Lock()
+				delete(running, t.Name)
+				mu.Unlock()
+			}()
+			if err := runLocked(ctx, t); err != nil && ctx.Err() == nil {
+				log.Printf("Scheduler: task %s: %v", t.Name, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// runLocked runs t if this instance gets its advisory lock and it is still
+// due once the lock is held. The lock belongs to the connection, so it is
+// released if the instance dies mid-run.
+func runLocked(ctx context.Context, t *Task) error {
+	conn, err := db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	lockKey := "scheduler:" + t.Name
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, lockKey).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, lockKey); err != nil {
+			// Closing the connection releases the lock
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	// Another instance may have run it between our check and the lock
+	now := time.Now()
+	var paused bool
+	var nextRunAt, requestedAt *time.Time
+	var requestedBy *uuid.UUID
+	err = conn.QueryRow(ctx, `
+		SELECT paused_at IS NOT NULL, next_run_at, run_requested_at, run_requested_by
+		FROM scheduled_tasks WHERE name = $1
+	`, t.Name).Scan(&paused, &nextRunAt, &requestedAt, &requestedBy)
+	if err != nil {
+		return err
+	}
+	scheduled := !paused && nextRunAt != nil && !nextRunAt.After(now.UTC())
+	if !scheduled && requestedAt == nil {
+		return nil
+	}
+
+	// Move the schedule on before running, so a task that crashes the
+	// instance isn't retried in a loop. A manual run leaves it alone.
+	next := nextRunAt
+	if scheduled {
+		next = t.next(now)
+	}
+	_, err = conn.Exec(ctx, `
+		UPDATE scheduled_tasks SET next_run_at = $2, run_requested_at = NULL, run_requested_by = NULL, updated_at = now()
+		WHERE name = $1
+	`, t.Name, next)
+	if err != nil {
+		return err
+	}
+
+	// Nobody else can be running it while we hold the lock
+	_, err = conn.Exec(ctx, `
+		UPDATE scheduled_task_runs SET status = $2, error = 'interrupted: the instance running it stopped', finished_at = now()
+		WHERE task_name = $1 AND status = $3
+	`, t.Name, RunFailed, RunRunning)
+	if err != nil {
+		return err
+	}
+
+	var runID uuid.UUID
+	err = conn.QueryRow(ctx, `
+		INSERT INTO scheduled_task_runs (task_name, status, triggered_by, instance)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, t.Name, RunRunning, requestedBy, instance).Scan(&runID)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	runCtx, cancel := context.WithTimeout(ctx, t.Timeout)
+	result, runErr := safeRun(runCtx, t)
+	cancel()
+	duration := time.Since(started)
+
+	status, errText := RunSucceeded, (*string)(nil)
+	if runErr != nil {
+		status = RunFailed
+		msg := runErr.Error()
+		errText = &msg
+		log.Printf("Scheduler: task %s failed after %s: %v", t.Name, duration.Round(time.Millisecond), runErr)
+	} else {
+		log.Printf("Scheduler: task %s finished in %s: %s", t.Name, duration.Round(time.Millisecond), result)
+	}
+
+	// Record the outcome even if the run used up ctx
+	_, err = conn.Exec(context.Background(), `
+		UPDATE scheduled_task_runs SET status = $2, result = $3, error = $4, finished_at = now(), duration_ms = $5
+		WHERE id = $1
+	`, runID, status, result, errText, duration.Milliseconds())
+	return err
+}
+
+// safeRun turns a task panic into a failed run instead of killing the
+// scheduler.
+func safeRun(ctx context.Context, t *Task) (result string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	result, err = t.Run(ctx)
+	if err == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", t.Timeout)
+	}
+	return result, err
+}
+
+// PruneRuns deletes finished runs older than retention.
+func PruneRuns(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM scheduled_task_runs WHERE started_at < $1 AND status <> $2`, time.Now().Add(-retention), RunRunning)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/db"
+)
+
+var ErrForbidden = ForbiddenError("forbidden")
+
+// staffRoles can see every client's data unless they are tied to a client.
+var staffRoles = map[string]bool{
+	"ADMIN":   true,
+	"HR":      true,
+	"SALES":   true,
+	"FINANCE": true,
+}
+
+// CanAccessEntity reports whether the caller in ctx may see the given
+// client, project, assignment, talent, contract or invoice. Callers tied to a
+// client (client portal users) only see entities belonging to that client.
+func CanAccessEntity(ctx context.Context, entityType string, entityID string) (bool, error) {
+	role, _ := ctx.Value("role").(string)
+	clientID, _ := ctx.Value("client_id").(string)
+
+	if clientID == "" {
+		return staffRoles[role], nil
+	}
+
+	var query string
+	switch strings.ToUpper(entityType) {
+	case "CLIENT":
+		return entityID == clientID, nil
+	case "PROJECT":
+		query = `SELECT EXISTS (SELECT 1 FROM projects WHERE id = $1 AND client_id = $2 AND deleted_at IS NULL)`
+	case "CONTRACT":
+		query = `SELECT EXISTS (SELECT 1 FROM contracts WHERE id = $1 AND client_id = $2 AND deleted_at IS NULL)`
+	case "ASSIGNMENT":
+		query = `SELECT EXISTS (SELECT 1 FROM project_assignments WHERE id = $1 AND client_id = $2)`
+	case "INVOICE":
+		query = `SELECT EXISTS (SELECT 1 FROM invoices WHERE id = $1 AND client_id = $2)`
+	case "TALENT":
+		// Clients may see talent currently or previously placed on their projects.
+		query = `SELECT EXISTS (SELECT 1 FROM project_assignments WHERE talent_id = $1 AND client_id = $2)`
+	default:
+		return false, nil
+	}
+
+	var ok bool
+	err := db.Pool.QueryRow(ctx, query, entityID, clientID).Scan(&ok)
+	return ok, err
+}
+
+// authorizeEntity is CanAccessEntity as an error: ErrForbidden when the
+// caller may not see the entity.
+func authorizeEntity(ctx context.Context, entityType string, entityID string) error {
+	ok, err := CanAccessEntity(ctx, entityType, entityID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// documentScope returns a SQL condition limiting documents (aliased as d) to
+// those the caller may see, using placeholder $argPos for any argument.
+func documentScope(ctx context.Context, argPos int) (string, []interface{}, error) {
+	role, _ := ctx.Value("role").(string)
+	clientID, _ := ctx.Value("client_id").(string)
+
+	if clientID == "" {
+		if staffRoles[role] {
+			return "TRUE", nil, nil
+		}
+		return "", nil, ErrForbidden
+	}
+
+	clause := fmt.Sprintf(`(
+		(upper(d.entity_type) = 'CLIENT' AND d.entity_id = $%[1]d)
+		OR (upper(d.entity_type) = 'PROJECT' AND d.entity_id IN (SELECT id FROM projects WHERE client_id = $%[1]d AND deleted_at IS NULL))
+		OR (upper(d.entity_type) = 'CONTRACT' AND d.entity_id IN (SELECT id FROM contracts WHERE client_id = $%[1]d AND deleted_at IS NULL))
+		OR (upper(d.entity_type) = 'TALENT' AND d.entity_id IN (SELECT talent_id FROM project_assignments WHERE client_id = $%[1]d))
+	)`, argPos)
+	return clause, []interface{}{clientID}, nil
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// apiKeyPrefix marks API keys, so they can be told apart from JWTs and
+	// found by secret scanners
+	apiKeyPrefix        = "plk_"
+	loginServiceAccount = "SERVICE_ACCOUNT"
+	// apiKeyUseInterval limits how often last-used details are written, so
+	// a busy script doesn't update the row on every request
+	apiKeyUseInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey      = UnauthorizedError("invalid API key")
+	ErrAPIKeyNotFound     = NotFoundError("API key not found")
+	ErrNotServiceAccount  = ValidationError("API keys can only belong to service accounts")
+	ErrInvalidAPIKeyInput = ValidationError("invalid API key request")
+)
+
+var (
+	serviceAccountName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,62}$`)
+	apiKeyScope        = regexp.MustCompile(`^(\*|[a-z][a-z0-9-]*):(read|write)$`)
+)
+
+// apiKeyBlockedResources can't be reached with an API key whatever its
+// scopes, so a leaked key can't mint more keys or change credentials: users
+// covers setting passwords and turning off two-factor auth.
+var apiKeyBlockedResources = map[string]bool{
+	"auth":             true,
+	"service-accounts": true,
+	"users":            true,
+}
+
+type APIKeyService struct{}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{}
+}
+
+// IsAPIKey reports whether a bearer credential is an API key rather than a
+// JWT.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// APIKeyPrincipal is who a request authenticated with an API key acts as.
+type APIKeyPrincipal struct {
+	KeyID    uuid.UUID
+	UserID   uuid.UUID
+	Role     string
+	ClientID *uuid.UUID
+	Scopes   []string
+}
+
+// Allows reports whether the key's scopes cover the request. The role of the
+// service account still applies on top, in the handlers.
+func (p *APIKeyPrincipal) Allows(method, path string) bool {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return false
+	}
+	resource, _, _ := strings.Cut(rest, "/")
+	if resource == "" || apiKeyBlockedResources[resource] {
+		return false
+	}
+	write := method != http.MethodGet && method != http.MethodHead
+	for _, scope := range p.Scopes {
+		res, access, _ := strings.Cut(scope, ":")
+		if res != "*" && res != resource {
+			continue
+		}
+		if access == "write" || !write {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthenticateAPIKey looks up an active key of an enabled service account and
+// records that it was used from ip.
+func AuthenticateAPIKey(ctx context.Context, key, ip string) (*APIKeyPrincipal, error) {
+	if !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+	var p APIKeyPrincipal
+	var lastUsed *time.Time
+	err := db.Pool.QueryRow(ctx, `
+		SELECT k.id, u.id, u.role::text, COALESCE(k.client_id, u.client_id), k.scopes, k.last_used_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > now())
+		  AND u.service_account AND u.disabled_at IS NULL
+		  -- A client limit on a staff account wouldn't be enforced, so refuse the key
+		  AND (u.role IN ('CLIENT_ADMIN', 'CLIENT_USER') OR COALESCE(k.client_id, u.client_id) IS NULL)
+	`, hashToken(key)).Scan(&p.KeyID, &p.UserID, &p.Role, &p.ClientID, &p.Scopes, &lastUsed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsed == nil || time.Since(*lastUsed) > apiKeyUseInterval {
+		_, err := db.Pool.Exec(ctx,
+			`UPDATE api_keys SET last_used_at = now(), last_used_ip = $2 WHERE id = $1`, p.KeyID, optString(ip))
+		if err != nil {
+			// Not worth failing the request over
+			log.Printf("Failed to record use of API key %s: %v", p.KeyID, err)
+		}
+	}
+	return &p, nil
+}
+
+// CreateServiceAccount adds a user that can't sign in and acts only through
+// API keys. Its role caps what its keys can do, as for any user.
+func (s *APIKeyService) CreateServiceAccount(ctx context.Context, req models.CreateServiceAccountRequest) (*models.User, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !serviceAccountName.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be 2-63 lowercase letters, digits, dots, dashes or underscores", ErrInvalidAPIKeyInput)
+	}
+	role := string(req.Role)
+	clientRole := req.Role == models.RoleClientAdmin || req.Role == models.RoleClientUser
+	if !staffRoles[role] && !clientRole {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidAPIKeyInput, role)
+	}
+	if clientRole && req.ClientID == nil {
+		return nil, fmt.Errorf("%w: client roles need a client_id", ErrInvalidAPIKeyInput)
+	}
+	// Staff roles see every client's records whatever client_id says
+	if !clientRole && req.ClientID != nil {
+		return nil, fmt.Errorf("%w: only client roles can be limited to a client", ErrInvalidAPIKeyInput)
+	}
+
+	// Nobody knows this password and Login refuses service accounts anyway
+	hash, err := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	// .invalid can never receive mail
+	email := name + "@service-accounts.invalid"
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1) OR username = $2)`, email, name,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUserExists
+	}
+
+	user := models.User{Username: &name, Email: email, Role: role, ClientID: req.ClientID, ServiceAccount: true}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (email, username, password_hash, role, client_id, activated_at, service_account)
+		VALUES ($1, $2, $3, $4, $5, now(), true)
+		RETURNING id, activated_at, created_at
+	`, email, name, string(hash), role, req.ClientID).Scan(&user.ID, &user.ActivatedAt, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	log.Printf("Created service account %s (%s, role %s)", name, user.ID, role)
+	return &user, nil
+}
+
+// ListServiceAccounts returns all service accounts, newest first.
+func (s *APIKeyService) ListServiceAccounts(ctx context.Context) ([]models.User, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, username, email, role::text, client_id, activated_at, disabled_at, created_at
+		FROM users WHERE service_account
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []models.User{}
+	for rows.Next() {
+		u := models.User{ServiceAccount: true}
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.ClientID, &u.ActivatedAt, &u.DisabledAt, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, u)
+	}
+	return accounts, rows.Err()
+}
+
+// CreateKey issues a key for a service account. The key is in the response
+// and nowhere else; only its hash is stored.
+func (s *APIKeyService) CreateKey(ctx context.Context, accountID string, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKeyInput)
+	}
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyInput)
+	}
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !apiKeyScope.MatchString(scope) {
+			return nil, fmt.Errorf("%w: scope %q must look like resource:read or resource:write", ErrInvalidAPIKeyInput, scope)
+		}
+		scopes = append(scopes, scope)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyInput)
+	}
+
+	var serviceAccount bool
+	var role string
+	var accountClientID *uuid.UUID
+	err := db.Pool.QueryRow(ctx,
+		`SELECT service_account, role::text, client_id FROM users WHERE id = $1`, accountID,
+	).Scan(&serviceAccount, &role, &accountClientID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !serviceAccount {
+		return nil, ErrNotServiceAccount
+	}
+	// Only client roles are scoped by client_id, so a staff account's key can't
+	// be narrowed to one client: it would still see everything
+	if req.ClientID != nil && staffRoles[role] {
+		return nil, fmt.Errorf("%w: keys of staff accounts can't be limited to a client; use a service account with a client role", ErrInvalidAPIKeyInput)
+	}
+	// A key can narrow the account to one client but never widen it
+	if accountClientID != nil && req.ClientID != nil && *req.ClientID != *accountClientID {
+		return nil, fmt.Errorf("%w: the account is limited to another client", ErrInvalidAPIKeyInput)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	resp := &models.CreateAPIKeyResponse{Key: key}
+	k := &resp.APIKey
+	err = db.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes, client_id, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, user_id, name, key_prefix, scopes, client_id, expires_at, created_by, created_at
+	`, accountID, name, key[:len(apiKeyPrefix)+8], hashToken(key), scopes, req.ClientID, req.ExpiresAt, contextUserID(ctx),
+	).Scan(&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &k.Scopes, &k.ClientID, &k.ExpiresAt, &k.CreatedBy, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created API key %s (%s) for service account %s", k.ID, k.Prefix, accountID)
+	return resp, nil
+}
+
+// ListKeys returns the service account's keys, including revoked and expired
+// ones, newest first.
+func (s *APIKeyService) ListKeys(ctx context.Context, accountID string) ([]models.APIKey, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, user_id, name, key_prefix, scopes, client_id, expires_at, last_used_at, last_used_ip, revoked_at, created_by, created_at
+		FROM api_keys WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.ServiceAccountID, &k.Name, &k.Prefix, &k.Scopes, &k.ClientID, &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP, &k.RevokedAt, &k.CreatedBy, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeKey stops a key working straight away.
+func (s *APIKeyService) RevokeKey(ctx context.Context, accountID, keyID string) error {
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1 AND user_id = $2`, keyID, accountID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	log.Printf("Revoked API key %s of service account %s", keyID, accountID)
+	return nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAPIKeyPrincipalAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		method string
+		path   string
+		want   bool
+	}{
+		{name: "read scope, GET", scopes: []string{"clients:read"}, method: http.MethodGet, path: "/api/clients", want: true},
+		{name: "read scope, HEAD", scopes: []string{"clients:read"}, method: http.MethodHead, path: "/api/clients/42", want: true},
+		{name: "read scope, POST", scopes: []string{"clients:read"}, method: http.MethodPost, path: "/api/clients"},
+		{name: "read scope, DELETE", scopes: []string{"clients:read"}, method: http.MethodDelete, path: "/api/clients/42"},
+		{name: "write scope, PATCH", scopes: []string{"clients:write"}, method: http.MethodPatch, path: "/api/clients/42", want: true},
+		{name: "write scope, GET", scopes: []string{"clients:write"}, method: http.MethodGet, path: "/api/clients", want: true},
+		{name: "other resource", scopes: []string{"clients:write"}, method: http.MethodGet, path: "/api/talent"},
+		{name: "resource prefix isn't a match", scopes: []string{"client:read"}, method: http.MethodGet, path: "/api/clients"},
+		{name: "second scope matches", scopes: []string{"talent:read", "clients:write"}, method: http.MethodPut, path: "/api/clients/42", want: true},
+		{name: "wildcard read", scopes: []string{"*:read"}, method: http.MethodGet, path: "/api/invoices", want: true},
+		{name: "wildcard read, POST", scopes: []string{"*:read"}, method: http.MethodPost, path: "/api/invoices"},
+		{name: "wildcard write", scopes: []string{"*:write"}, method: http.MethodPost, path: "/api/invoices", want: true},
+		{name: "no scopes", method: http.MethodGet, path: "/api/clients"},
+		{name: "blocked: auth", scopes: []string{"*:write"}, method: http.MethodPost, path: "/api/auth/mfa/disable"},
+		{name: "blocked: service accounts", scopes: []string{"*:write"}, method: http.MethodPost, path: "/api/service-accounts/1/keys"},
+		{name: "blocked: users", scopes: []string{"*:write"}, method: http.MethodPut, path: "/api/users/1/password"},
+		{name: "blocked even when named", scopes: []string{"users:read"}, method: http.MethodGet, path: "/api/users"},
+		{name: "empty resource", scopes: []string{"*:write"}, method: http.MethodGet, path: "/api/"},
+		{name: "outside the API", scopes: []string{"*:write"}, method: http.MethodGet, path: "/health"},
+	}
+	for _, tt := range tests {
+		p := &APIKeyPrincipal{Scopes: tt.scopes}
+		if got := p.Allows(tt.method, tt.path); got != tt.want {
+			t.Errorf("%s: Allows(%s %s) = %v, want %v", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -2,20 +2,71 @@ package service
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrAssignmentNotFound = NotFoundError("assignment not found")
+
 type AssignmentService struct{}
 
 func NewAssignmentService() *AssignmentService {
 	return &AssignmentService{}
 }
 
-func (s *AssignmentService) List(ctx context.Context) ([]models.ProjectAssignment, error) {
-	query := `SELECT id, project_id, client_id, talent_id, role, start_date, trial_end_date, monthly_client_rate, monthly_contractor_cost, daily_payout_rate, daily_bill_rate, hours_per_week, status, created_at FROM project_assignments`
-	rows, err := db.Pool.Query(ctx, query)
+// assignmentColumns and scanAssignment keep every assignment query in step.
+const assignmentColumns = `id, project_id, client_id, talent_id, role, start_date, trial_end_date, monthly_client_rate, monthly_contractor_cost, daily_payout_rate, daily_bill_rate, hours_per_week, status, created_at, version, updated_at`
+
+func scanAssignment(row pgx.Row) (*models.ProjectAssignment, error) {
+	var a models.ProjectAssignment
+	err := row.Scan(
+		&a.ID, &a.ProjectID, &a.ClientID, &a.TalentID, &a.Role, &a.StartDate, &a.TrialEndDate,
+		&a.MonthlyClientRate, &a.MonthlyContractorCost, &a.DailyPayoutRate, &a.DailyBillRate, &a.HoursPerWeek, &a.Status, &a.CreatedAt, &a.Version, &a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// AssignmentList is how GET /api/assignments may be sorted and filtered.
+var AssignmentList = listquery.Spec[models.ProjectAssignment]{
+	Sorts: map[string]listquery.Sort[models.ProjectAssignment]{
+		"created_at": {Column: "created_at", Type: "timestamp", Value: func(a models.ProjectAssignment) string { return listquery.Time(a.CreatedAt) }},
+		"start_date": {Column: "start_date", Type: "date", Value: func(a models.ProjectAssignment) string { return a.StartDate.Format(time.DateOnly) }},
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]listquery.Filter{
+		"project_id": {Column: "project_id", Rules: "uuid"},
+		"talent_id":  {Column: "talent_id", Rules: "uuid"},
+		"client_id":  {Column: "client_id", Rules: "uuid"},
+		"status":     {Column: "status", Rules: "enum=project_status"},
+	},
+	ID: func(a models.ProjectAssignment) uuid.UUID { return a.ID },
+}
+
+// List returns a page of assignments on live projects. Client users only
+// see their own client's.
+func (s *AssignmentService) List(ctx context.Context, q *listquery.Query[models.ProjectAssignment]) (*listquery.Page[models.ProjectAssignment], error) {
+	if clientID, _ := ctx.Value("client_id").(string); clientID != "" {
+		q.Where("client_id", clientID)
+	}
+	where, args := q.Filter(" WHERE project_id IN (SELECT id FROM projects WHERE deleted_at IS NULL)", nil)
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM project_assignments`+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query, args := q.Page(`SELECT `+assignmentColumns+` FROM project_assignments`+where, args)
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -23,34 +74,28 @@ func (s *AssignmentService) List(ctx context.Context) ([]models.ProjectAssignmen
 
 	var assignments []models.ProjectAssignment
 	for rows.Next() {
-		var a models.ProjectAssignment
-		err := rows.Scan(
-			&a.ID, &a.ProjectID, &a.ClientID, &a.TalentID, &a.Role, &a.StartDate, &a.TrialEndDate,
-			&a.MonthlyClientRate, &a.MonthlyContractorCost, &a.DailyPayoutRate, &a.DailyBillRate, &a.HoursPerWeek, &a.Status, &a.CreatedAt,
-		)
+		a, err := scanAssignment(rows)
 		if err != nil {
 			return nil, err
 		}
-		assignments = append(assignments, a)
+		assignments = append(assignments, *a)
 	}
-	return assignments, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.Result(assignments, total), nil
 }
 
 func (s *AssignmentService) Get(ctx context.Context, id string) (*models.ProjectAssignment, error) {
-	query := `SELECT id, project_id, client_id, talent_id, role, start_date, trial_end_date, monthly_client_rate, monthly_contractor_cost, daily_payout_rate, daily_bill_rate, hours_per_week, status, created_at FROM project_assignments WHERE id = $1`
-	var a models.ProjectAssignment
-	err := db.Pool.QueryRow(ctx, query, id).Scan(
-		&a.ID, &a.ProjectID, &a.ClientID, &a.TalentID, &a.Role, &a.StartDate, &a.TrialEndDate,
-		&a.MonthlyClientRate, &a.MonthlyContractorCost, &a.DailyPayoutRate, &a.DailyBillRate, &a.HoursPerWeek, &a.Status, &a.CreatedAt,
-	)
-	if err != nil {
-		return nil, err
+	a, err := scanAssignment(db.Pool.QueryRow(ctx, `SELECT `+assignmentColumns+` FROM project_assignments WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAssignmentNotFound
 	}
-	return &a, nil
+	return a, err
 }
 
 func (s *AssignmentService) ListByProject(ctx context.Context, projectID string) ([]models.ProjectAssignment, error) {
-	query := `SELECT id, project_id, client_id, talent_id, role, start_date, trial_end_date, monthly_client_rate, monthly_contractor_cost, daily_payout_rate, daily_bill_rate, hours_per_week, status, created_at FROM project_assignments WHERE project_id = $1`
+	query := `SELECT ` + assignmentColumns + ` FROM project_assignments WHERE project_id = $1`
 	rows, err := db.Pool.Query(ctx, query, projectID)
 	if err != nil {
 		return nil, err
@@ -59,23 +104,19 @@ func (s *AssignmentService) ListByProject(ctx context.Context, projectID string)
 
 	var assignments []models.ProjectAssignment
 	for rows.Next() {
-		var a models.ProjectAssignment
-		err := rows.Scan(
-			&a.ID, &a.ProjectID, &a.ClientID, &a.TalentID, &a.Role, &a.StartDate, &a.TrialEndDate,
-			&a.MonthlyClientRate, &a.MonthlyContractorCost, &a.DailyPayoutRate, &a.DailyBillRate, &a.HoursPerWeek, &a.Status, &a.CreatedAt,
-		)
+		a, err := scanAssignment(rows)
 		if err != nil {
 			return nil, err
 		}
-		assignments = append(assignments, a)
+		assignments = append(assignments, *a)
 	}
 	return assignments, nil
 }
 
 func (s *AssignmentService) Create(ctx context.Context, a *models.ProjectAssignment) error {
 	// 1. Fetch Client ID from the parent Project
-	var clientID string
-	err := db.Pool.QueryRow(ctx, "SELECT client_id FROM projects WHERE id = $1", a.ProjectID).Scan(&clientID)
+	var clientID uuid.UUID
+	err := db.Pool.QueryRow(ctx, "SELECT client_id FROM projects WHERE id = $1 AND deleted_at IS NULL", a.ProjectID).Scan(&clientID)
 	if err != nil {
 		return err // Handle if project doesn't exist
 	}
@@ -93,19 +134,45 @@ func (s *AssignmentService) Create(ctx context.Context, a *models.ProjectAssignm
 	query := `
 		INSERT INTO project_assignments (project_id, client_id, talent_id, role, start_date, trial_end_date, monthly_client_rate, monthly_contractor_cost, daily_payout_rate, daily_bill_rate, hours_per_week, status)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, created_at
+		RETURNING id, created_at, version, updated_at
 	`
 	status := "TRIAL"
 	if a.Status != "" {
 		status = a.Status
 	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	// Use the fetched clientID
-	return db.Pool.QueryRow(ctx, query,
+	err = tx.QueryRow(ctx, query,
 		a.ProjectID, clientID, a.TalentID, a.Role, a.StartDate, a.TrialEndDate,
 		a.MonthlyClientRate, a.MonthlyContractorCost, a.DailyPayoutRate, a.DailyBillRate, a.HoursPerWeek, status,
-	).Scan(&a.ID, &a.CreatedAt)
+	).Scan(&a.ID, &a.CreatedAt, &a.Version, &a.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	a.ClientID = clientID
+	a.Status = status
+	err = events.Publish(ctx, tx, events.AssignmentCreated{
+		AssignmentID: a.ID,
+		ProjectID:    a.ProjectID,
+		ClientID:     clientID,
+		TalentID:     a.TalentID,
+		Role:         a.Role,
+		StartDate:    a.StartDate,
+		Status:       status,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
+// Update replaces the assignment's terms. A non-zero a.Version must match
+// the stored version.
 func (s *AssignmentService) Update(ctx context.Context, id string, a *models.ProjectAssignment) error {
 	// Calculate Monthly values from Daily if provided (21.73 days avg)
 	avgDays := 21.73
@@ -117,22 +184,92 @@ func (s *AssignmentService) Update(ctx context.Context, id string, a *models.Pro
 		a.MonthlyClientRate = &monthly
 	}
 
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var version int
+	err = tx.QueryRow(ctx, `SELECT version FROM project_assignments WHERE id = $1 FOR UPDATE`, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAssignmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(a.Version, version); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE project_assignments 
-		SET role = $1, start_date = $2, monthly_client_rate = $3, monthly_contractor_cost = $4, daily_payout_rate = $5, daily_bill_rate = $6, hours_per_week = $7, status = $8, talent_id = $9
-		WHERE id = $10
-		RETURNING created_at
+		SET role = $1, start_date = $2, trial_end_date = $3, monthly_client_rate = $4, monthly_contractor_cost = $5, daily_payout_rate = $6, daily_bill_rate = $7, hours_per_week = $8, status = $9, talent_id = $10,
+			version = version + 1, updated_at = now()
+		WHERE id = $11
+		RETURNING project_id, client_id, created_at, version, updated_at
 	`
 	status := "TRIAL"
 	if a.Status != "" {
 		status = a.Status
 	}
-	return db.Pool.QueryRow(ctx, query,
-		a.Role, a.StartDate, a.MonthlyClientRate, a.MonthlyContractorCost, a.DailyPayoutRate, a.DailyBillRate, a.HoursPerWeek, status, a.TalentID, id,
-	).Scan(&a.CreatedAt)
+	err = tx.QueryRow(ctx, query,
+		a.Role, a.StartDate, a.TrialEndDate, a.MonthlyClientRate, a.MonthlyContractorCost, a.DailyPayoutRate, a.DailyBillRate, a.HoursPerWeek, status, a.TalentID, id,
+	).Scan(&a.ProjectID, &a.ClientID, &a.CreatedAt, &a.Version, &a.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	a.Status = status
+	return tx.Commit(ctx)
 }
+
 func (s *AssignmentService) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM project_assignments WHERE id = $1`
 	_, err := db.Pool.Exec(ctx, query, id)
 	return err
 }
+
+// ExpireTrials makes assignments whose trial ended before today ACTIVE and
+// returns how many changed. An assignment that shouldn't continue is moved
+// to ENDING or ENDED by hand before its trial runs out.
+func (s *AssignmentService) ExpireTrials(ctx context.Context) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE project_assignments a SET status = 'ACTIVE', version = a.version + 1, updated_at = now()
+		FROM projects p
+		WHERE p.id = a.project_id AND p.deleted_at IS NULL
+		  AND a.status = 'TRIAL' AND a.trial_end_date < CURRENT_DATE
+		RETURNING a.id, a.project_id, COALESCE(a.client_id, p.client_id), a.talent_id, a.trial_end_date
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var ended []events.AssignmentTrialEnded
+	for rows.Next() {
+		e := events.AssignmentTrialEnded{Status: "ACTIVE"}
+		if err := rows.Scan(&e.AssignmentID, &e.ProjectID, &e.ClientID, &e.TalentID, &e.TrialEndDate); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ended = append(ended, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range ended {
+		if err := events.Publish(ctx, tx, e); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(ended), nil
+}
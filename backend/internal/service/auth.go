@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"log"
@@ -11,12 +12,24 @@ import (
 	"github.com/dubai/platform/backend/internal/db"
 	"github.com/dubai/platform/backend/internal/models"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // JWT Secret Key - In production, this should be an env var
 var jwtKey = []byte("super-secret-jwt-key")
 
+var (
+	ErrUserNotFound     = NotFoundError("user not found")
+	ErrAlreadyActivated = ConflictError("user has already activated their account")
+	ErrWrongPassword    = ForbiddenError("current password is incorrect")
+)
+
+// timingHash is compared against when a login matches no user. Any bcrypt
+// hash of the default cost will do.
+const timingHash = "$2a$10$bpHeIIQnJ93ra8L2h.3L3OSH4BrfK7EclDdS.t.qjDMean/uY/rWK"
+
 type AuthService struct{}
 
 func NewAuthService() *AuthService {
@@ -24,8 +37,16 @@ func NewAuthService() *AuthService {
 }
 
 func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest) (*models.User, error) {
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	// Without a password the user is invited to choose one. The stored hash is
+	// of random bytes nobody knows, so the account can't be signed into yet.
+	password := req.Password
+	invite := password == ""
+	if invite {
+		password = rand.Text()
+	} else if err := ValidatePassword(password, req.Email, req.Username); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
@@ -36,41 +57,131 @@ func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest)
 		PasswordHash: string(hashedPassword),
 		Role:         string(req.Role),
 		ClientID:     req.ClientID,
+		// A password chosen by someone else is only good for the first sign-in
+		MustChangePassword: !invite,
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
 	query := `
-		INSERT INTO users (username, email, password_hash, role, client_id)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at
+		INSERT INTO users (username, email, password_hash, role, client_id, must_change_password, activated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $7 THEN NULL ELSE now() END)
+		RETURNING id, activated_at, created_at
 	`
-	err = db.Pool.QueryRow(ctx, query, user.Username, user.Email, user.PasswordHash, user.Role, user.ClientID).Scan(&user.ID, &user.CreatedAt)
+	err = tx.QueryRow(ctx, query, user.Username, user.Email, user.PasswordHash, user.Role, user.ClientID, user.MustChangePassword, invite).Scan(&user.ID, &user.ActivatedAt, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := sendAccountEmail(ctx, tx, user, invite); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, req models.LoginRequest) (*models.LoginResponse, error) {
-	query := `SELECT id, username, email, password_hash, role::text, company_name, client_id, created_at FROM users WHERE username = $1 OR email = $1`
-	// Middleware
-	var user models.User
-	err := db.Pool.QueryRow(ctx, query, req.Username).Scan(
-		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role, &user.CompanyName, &user.ClientID, &user.CreatedAt,
-	)
+// Login checks credentials from ip. Repeated failures against an account or
+// from an address make further attempts wait, and enough consecutive failures
+// lock the account for a while; either way a *LoginThrottledError is returned.
+// Accounts with two-factor auth get an MFA challenge instead of a session,
+// to be completed with VerifyMFA.
+func (s *AuthService) Login(ctx context.Context, req models.LoginRequest, ip, userAgent string) (*models.LoginResponse, error) {
+	policy := loadLoginPolicy()
+	wait, err := ipRetryAfter(ctx, policy, ip)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		return nil, &LoginThrottledError{RetryAfter: wait}
+	}
+
+	user, lastFailed, err := loadLoginUser(ctx, "username = $1 OR email = $1", req.Username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Spend the same time as a real check so response times don't reveal
+		// which logins exist
+		bcrypt.CompareHashAndPassword([]byte(timingHash), []byte(req.Password))
+		recordLoginAttempt(ctx, nil, req.Username, ip, userAgent, false, loginUnknownUser)
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		log.Printf("Login DB Scan Error for %s: %v", req.Username, err)
-		return nil, errors.New("invalid credentials")
+		return nil, err
+	}
+
+	if wait := accountRetryAfter(policy, user, lastFailed); wait > 0 {
+		recordLoginAttempt(ctx, &user.ID, req.Username, ip, userAgent, false, loginLocked)
+		return nil, &LoginThrottledError{RetryAfter: wait}
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		log.Printf("Login Password Error for %s: %v", req.Username, err)
-		return nil, errors.New("invalid credentials")
+		recordLoginAttempt(ctx, &user.ID, req.Username, ip, userAgent, false, loginBadPassword)
+		if err := recordLoginFailure(ctx, policy, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if user.DisabledAt != nil {
+		recordLoginAttempt(ctx, &user.ID, req.Username, ip, userAgent, false, loginDisabled)
+		return nil, ErrInvalidCredentials
+	}
+	if user.ServiceAccount {
+		recordLoginAttempt(ctx, &user.ID, req.Username, ip, userAgent, false, loginServiceAccount)
+		return nil, ErrInvalidCredentials
 	}
+	if passwordLoginDisabled(user.Role) {
+		recordLoginAttempt(ctx, &user.ID, req.Username, ip, userAgent, false, loginPasswordDisabled)
+		return nil, ErrPasswordLoginDisabled
+	}
+
+	// The failure count carries over to the code check, so knowing the
+	// password doesn't buy unlimited guesses at the code
+	if user.MFAEnabled {
+		token, err := generateMFAToken(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &models.LoginResponse{MFARequired: true, MFAToken: token}, nil
+	}
+	return completeLogin(ctx, user, req.Username, ip, userAgent)
+}
+
+// loadLoginUser fetches a user with the fields sign-in needs, and when their
+// last failure was.
+func loadLoginUser(ctx context.Context, where string, arg interface{}) (*models.User, *time.Time, error) {
+	query := `SELECT id, username, email, password_hash, role::text, company_name, client_id, activated_at, disabled_at, must_change_password, last_login_at, failed_login_count, locked_until, totp_enabled_at IS NOT NULL, oidc_subject IS NOT NULL, service_account, last_failed_login_at, created_at FROM users WHERE ` + where
+	var user models.User
+	var lastFailed *time.Time
+	err := db.Pool.QueryRow(ctx, query, arg).Scan(
+		&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role, &user.CompanyName, &user.ClientID, &user.ActivatedAt, &user.DisabledAt, &user.MustChangePassword, &user.LastLoginAt, &user.FailedLoginCount, &user.LockedUntil, &user.MFAEnabled, &user.SSO, &user.ServiceAccount, &lastFailed, &user.CreatedAt,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, lastFailed, nil
+}
+
+// completeLogin records a successful sign-in and issues the session token.
+func completeLogin(ctx context.Context, user *models.User, login, ip, userAgent string) (*models.LoginResponse, error) {
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE users SET last_login_at = now(), failed_login_count = 0, locked_until = NULL
+		WHERE id = $1
+		RETURNING last_login_at
+	`, user.ID).Scan(&user.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	user.FailedLoginCount, user.LockedUntil = 0, nil
+	recordLoginAttempt(ctx, &user.ID, login, ip, userAgent, true, "")
 
 	// Generate JWT
-	tokenString, err := GenerateJWT(&user)
+	tokenString, err := GenerateJWT(user)
 	if err != nil {
 		return nil, err
 	}
@@ -81,101 +192,351 @@ func (s *AuthService) Login(ctx context.Context, req models.LoginRequest) (*mode
 	}, nil
 }
 
+// sendAccountEmail lets a new user know their account exists, with a link to
+// choose their password when they were invited.
+func sendAccountEmail(ctx context.Context, tx pgx.Tx, user *models.User, invite bool) error {
+	data := map[string]string{
+		"Company":   LoadCompanyProfile().Name,
+		"Username":  deref(user.Username),
+		"Email":     user.Email,
+		"LoginURL":  appURL("/login"),
+		"InviteURL": "",
+		"ExpiresIn": "7 days",
+	}
+	if invite {
+		token, err := issueUserToken(ctx, tx, user.ID, TokenInvite, inviteTokenTTL)
+		if err != nil {
+			return err
+		}
+		data["InviteURL"] = appURL("/accept-invite?token=" + token)
+	}
+	_, err := EnqueueEmail(ctx, tx, Email{Kind: NotifyUserInvited, To: user.Email, UserID: &user.ID, Data: data})
+	return err
+}
+
+// ResendInvite issues a new invitation to a user who hasn't activated their
+// account yet. Earlier invitation links stop working.
+func (s *AuthService) ResendInvite(ctx context.Context, userID string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var user models.User
+	err = tx.QueryRow(ctx,
+		`SELECT id, username, email, activated_at FROM users WHERE id = $1 FOR UPDATE`, userID,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.ActivatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.ActivatedAt != nil {
+		return ErrAlreadyActivated
+	}
+
+	if err := sendAccountEmail(ctx, tx, &user, true); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AcceptInvite sets the password of an invited user and activates the account.
+func (s *AuthService) AcceptInvite(ctx context.Context, req models.SetPasswordRequest) error {
+	return setPasswordWithToken(ctx, TokenInvite, req)
+}
+
+// ForgotPassword emails a reset link if login matches a user's email or
+// username. It doesn't report whether one did, so callers can't probe for
+// accounts.
+func (s *AuthService) ForgotPassword(ctx context.Context, login string) error {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	var email, role string
+	err = tx.QueryRow(ctx,
+		`SELECT id, email, role::text FROM users WHERE (lower(email) = lower($1) OR username = $1) AND disabled_at IS NULL AND NOT service_account`, login,
+	).Scan(&userID, &email, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Password reset requested for unknown login %q", login)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if passwordLoginDisabled(role) {
+		// A password they can't sign in with is no use to them
+		log.Printf("Password reset requested for SSO-only user %s", userID)
+		return nil
+	}
+
+	token, err := issueUserToken(ctx, tx, userID, TokenReset, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+	_, err = EnqueueEmail(ctx, tx, Email{
+		Kind:   NotifyPasswordReset,
+		To:     email,
+		UserID: &userID,
+		Data: map[string]string{
+			"Company":   LoadCompanyProfile().Name,
+			"Email":     email,
+			"ResetURL":  appURL("/reset-password?token=" + token),
+			"ExpiresIn": "1 hour",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword.
+func (s *AuthService) ResetPassword(ctx context.Context, req models.SetPasswordRequest) error {
+	return setPasswordWithToken(ctx, TokenReset, req)
+}
+
+// setPasswordWithToken redeems an invitation or reset token. Proving control
+// of the mailbox also activates an invited account, and any other outstanding
+// tokens of the user are revoked.
+func setPasswordWithToken(ctx context.Context, purpose string, req models.SetPasswordRequest) error {
+	if req.Token == "" {
+		return ErrInvalidToken
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// A weak password rolls back, leaving the token usable for another try
+	userID, err := redeemUserToken(ctx, tx, req.Token, purpose)
+	if err != nil {
+		return err
+	}
+	var username *string
+	var email string
+	err = tx.QueryRow(ctx, `SELECT username, email FROM users WHERE id = $1 AND disabled_at IS NULL`, userID).Scan(&username, &email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := ValidatePassword(req.Password, email, deref(username)); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, must_change_password = false, activated_at = COALESCE(activated_at, now()),
+			failed_login_count = 0, locked_until = NULL
+		WHERE id = $1
+	`, userID, string(hashedPassword))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ChangePassword replaces the signed-in user's password after checking the
+// current one. It returns a fresh token since the old one may still carry the
+// must-change flag.
+func (s *AuthService) ChangePassword(ctx context.Context, userID string, req models.ChangePasswordRequest) (*models.LoginResponse, error) {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	var currentHash string
+	if err := db.Pool.QueryRow(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&currentHash); err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(req.CurrentPassword)); err != nil {
+		return nil, ErrWrongPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return nil, fmt.Errorf("%w: must differ from the current password", ErrWeakPassword)
+	}
+	if err := ValidatePassword(req.NewPassword, user.Email, deref(user.Username)); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE users SET password_hash = $2, must_change_password = false WHERE id = $1`, userID, string(hashedPassword))
+	if err != nil {
+		return nil, err
+	}
+	user.MustChangePassword = false
+
+	tokenString, err := GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{Token: tokenString, User: user}, nil
+}
+
 func (s *AuthService) GetProfile(ctx context.Context, userID string) (*models.User, error) {
-	query := `SELECT id, username, email, role, company_name, client_id, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, email, role, company_name, client_id, activated_at, disabled_at, must_change_password, last_login_at, failed_login_count, locked_until, totp_enabled_at IS NOT NULL, oidc_subject IS NOT NULL, service_account, created_at FROM users WHERE id = $1`
 	var user models.User
 	err := db.Pool.QueryRow(ctx, query, userID).Scan(
-		&user.ID, &user.Username, &user.Email, &user.Role, &user.CompanyName, &user.ClientID, &user.CreatedAt,
+		&user.ID, &user.Username, &user.Email, &user.Role, &user.CompanyName, &user.ClientID, &user.ActivatedAt, &user.DisabledAt, &user.MustChangePassword, &user.LastLoginAt, &user.FailedLoginCount, &user.LockedUntil, &user.MFAEnabled, &user.SSO, &user.ServiceAccount, &user.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (s *AuthService) UpdateProfile(ctx context.Context, userID string, updatePayload map[string]interface{}) (*models.User, error) {
+// UpdateProfile applies the fields set in req to a user. Callers decide
+// which fields the caller may change: users changing themselves only send
+// the embedded UpdateProfileRequest.
+func (s *AuthService) UpdateProfile(ctx context.Context, userID string, req *models.UpdateUserRequest) (*models.User, error) {
 	// Build dynamic query
 	query := "UPDATE users SET "
 	var args []interface{}
 	argID := 1
 
-	if val, ok := updatePayload["company_name"].(string); ok {
+	if req.CompanyName != nil {
 		query += fmt.Sprintf("company_name = $%d, ", argID)
-		args = append(args, val)
+		args = append(args, *req.CompanyName)
 		argID++
 	}
 
-	if val, ok := updatePayload["email"].(string); ok && val != "" {
+	if req.Email != nil && *req.Email != "" {
 		query += fmt.Sprintf("email = $%d, ", argID)
-		args = append(args, val)
+		args = append(args, *req.Email)
 		argID++
 	}
 
-	// Also allow updating username? (Not requested but good practice)
-	if val, ok := updatePayload["username"].(string); ok && val != "" {
+	if req.Username != nil && *req.Username != "" {
 		query += fmt.Sprintf("username = $%d, ", argID)
-		args = append(args, val)
+		args = append(args, *req.Username)
 		argID++
 	}
 
-	if val, ok := updatePayload["password"].(string); ok && val != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(val), bcrypt.DefaultCost)
+	if req.Password != nil && *req.Password != "" {
+		current, err := s.GetProfile(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := ValidatePassword(*req.Password, current.Email, deref(current.Username), deref(req.Email), deref(req.Username)); err != nil {
+			return nil, err
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return nil, err
 		}
 		query += fmt.Sprintf("password_hash = $%d, ", argID)
 		args = append(args, string(hashedPassword))
 		argID++
+
+		// A password set for someone else has to be changed at their next sign-in
+		caller := contextUserID(ctx)
+		query += fmt.Sprintf("must_change_password = $%d, ", argID)
+		args = append(args, caller == nil || caller.String() != userID)
+		argID++
 	}
 
-	if val, ok := updatePayload["role"].(string); ok && val != "" {
+	if req.Role != nil && *req.Role != "" {
 		query += fmt.Sprintf("role = $%d, ", argID)
-		args = append(args, val)
+		args = append(args, *req.Role)
 		argID++
 	}
 
-	if val, ok := updatePayload["client_id"].(string); ok {
-		// client_id can be UUID string or "" to nullify (if we want to support unlinking)
-		// For now assume standard valid UUID string if present
-		if val != "" {
+	if req.ClientID != nil {
+		if *req.ClientID != "" {
 			query += fmt.Sprintf("client_id = $%d, ", argID)
-			args = append(args, val)
+			args = append(args, *req.ClientID)
 			argID++
 		} else {
-			// Handle explicit clearing if needed, but for now let's just ignore empty string or handle it as NULL?
-			// Simplest: if sent, update it.
 			query += "client_id = NULL, "
 		}
 	}
 
+	if len(args) == 0 && req.ClientID == nil {
+		return s.GetProfile(ctx, userID)
+	}
+
 	// Remove trailing comma and space
 	query = strings.TrimSuffix(query, ", ")
-	query += fmt.Sprintf(" WHERE id = $%d RETURNING id, username, email, role, company_name, client_id, created_at", argID)
+	query += fmt.Sprintf(" WHERE id = $%d RETURNING id, username, email, role, company_name, client_id, activated_at, disabled_at, must_change_password, last_login_at, failed_login_count, locked_until, totp_enabled_at IS NOT NULL, oidc_subject IS NOT NULL, service_account, created_at", argID)
 	args = append(args, userID)
 
 	var user models.User
 	err := db.Pool.QueryRow(ctx, query, args...).Scan(
-		&user.ID, &user.Username, &user.Email, &user.Role, &user.CompanyName, &user.ClientID, &user.CreatedAt,
+		&user.ID, &user.Username, &user.Email, &user.Role, &user.CompanyName, &user.ClientID, &user.ActivatedAt, &user.DisabledAt, &user.MustChangePassword, &user.LastLoginAt, &user.FailedLoginCount, &user.LockedUntil, &user.MFAEnabled, &user.SSO, &user.ServiceAccount, &user.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
+// UserActive reports whether the user a session token was issued to still
+// exists and isn't disabled. AuthMiddleware checks it on every request, so
+// disabling or deleting a user ends their sessions straight away.
+func UserActive(ctx context.Context, userID string) (bool, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return false, nil
+	}
+	var active bool
+	err = db.Pool.QueryRow(ctx, `SELECT disabled_at IS NULL FROM users WHERE id = $1`, id).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return active, err
+}
+
 func GenerateJWT(user *models.User) (string, error) {
+	// Users who can only sign in through SSO have no password to change, and
+	// their provider is in charge of second factors
+	ssoOnly := user.SSO && passwordLoginDisabled(user.Role)
 	claims := jwt.MapClaims{
 		"user_id":   user.ID,
 		"role":      user.Role,
 		"client_id": user.ClientID, // Nullable, but jwt handles it (or usually we should check if nil?)
 		"exp":       time.Now().Add(24 * time.Hour).Unix(),
+		// Restrict the token to changing the password or enrolling in 2FA
+		// (see AuthMiddleware)
+		"must_change_password": user.MustChangePassword && !ssoOnly,
+		"mfa_setup_required":   !user.MFAEnabled && mfaRequiredFor(user.Role) && !ssoOnly,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtKey)
 }
 
 func (s *AuthService) ListUsers(ctx context.Context, clientID string) ([]models.User, error) {
-	query := `SELECT id, username, email, role, company_name, client_id, created_at FROM users WHERE 1=1`
+	query := `SELECT id, username, email, role, company_name, client_id, activated_at, disabled_at, must_change_password, last_login_at, failed_login_count, locked_until, totp_enabled_at IS NOT NULL, oidc_subject IS NOT NULL, service_account, created_at FROM users WHERE 1=1`
 	var args []interface{}
 	argID := 1
 
@@ -196,7 +557,7 @@ func (s *AuthService) ListUsers(ctx context.Context, clientID string) ([]models.
 	var users []models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.CompanyName, &u.ClientID, &u.CreatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.CompanyName, &u.ClientID, &u.ActivatedAt, &u.DisabledAt, &u.MustChangePassword, &u.LastLoginAt, &u.FailedLoginCount, &u.LockedUntil, &u.MFAEnabled, &u.SSO, &u.ServiceAccount, &u.CreatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -211,7 +572,7 @@ func (s *AuthService) DeleteUser(ctx context.Context, userID string) error {
 		return err
 	}
 	if result.RowsAffected() == 0 {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 	return nil
 }
@@ -2,20 +2,66 @@ package service
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrClientNotFound = NotFoundError("client not found")
+
 type ClientService struct{}
 
 func NewClientService() *ClientService {
 	return &ClientService{}
 }
 
-func (s *ClientService) List(ctx context.Context) ([]models.Client, error) {
-	query := `SELECT id, company_name, country, timezone, billing_currency, status::text, notes, created_at FROM clients`
-	rows, err := db.Pool.Query(ctx, query)
+// clientColumns and scanClient keep every client query in step.
+const clientColumns = `id, company_name, country, timezone, billing_currency, billing_address, tax_id, status::text, notes, created_at, version, updated_at`
+
+func scanClient(row pgx.Row) (*models.Client, error) {
+	var c models.Client
+	err := row.Scan(
+		&c.ID, &c.CompanyName, &c.Country, &c.Timezone, &c.BillingCurrency, &c.BillingAddress, &c.TaxID, &c.Status, &c.Notes, &c.CreatedAt, &c.Version, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ClientList is how GET /api/clients may be sorted and filtered.
+var ClientList = listquery.Spec[models.Client]{
+	Sorts: map[string]listquery.Sort[models.Client]{
+		"company_name": {Column: "company_name", Type: "text", Value: func(c models.Client) string { return c.CompanyName }},
+		"created_at":   {Column: "created_at", Type: "timestamp", Value: func(c models.Client) string { return listquery.Time(c.CreatedAt) }},
+	},
+	DefaultSort: "company_name",
+	Filters: map[string]listquery.Filter{
+		"status":  {Column: "status", Rules: "enum=client_status"},
+		"country": {Column: "country", Rules: "max=100"},
+	},
+	ID: func(c models.Client) uuid.UUID { return c.ID },
+}
+
+// List returns a page of clients. Client users only see their own.
+func (s *ClientService) List(ctx context.Context, q *listquery.Query[models.Client]) (*listquery.Page[models.Client], error) {
+	if clientID, _ := ctx.Value("client_id").(string); clientID != "" {
+		q.Where("id", clientID)
+	}
+	where, args := q.Filter(" WHERE deleted_at IS NULL", nil)
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM clients`+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query, args := q.Page(`SELECT `+clientColumns+` FROM clients`+where, args)
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -23,39 +69,72 @@ func (s *ClientService) List(ctx context.Context) ([]models.Client, error) {
 
 	var clients []models.Client
 	for rows.Next() {
-		var c models.Client
-		err := rows.Scan(
-			&c.ID, &c.CompanyName, &c.Country, &c.Timezone, &c.BillingCurrency, &c.Status, &c.Notes, &c.CreatedAt,
-		)
+		c, err := scanClient(rows)
 		if err != nil {
 			return nil, err
 		}
-		clients = append(clients, c)
+		clients = append(clients, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.Result(clients, total), nil
+}
+
+func (s *ClientService) Get(ctx context.Context, id string) (*models.Client, error) {
+	c, err := scanClient(db.Pool.QueryRow(ctx, `SELECT `+clientColumns+` FROM clients WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrClientNotFound
 	}
-	return clients, nil
+	return c, err
 }
 
 func (s *ClientService) Create(ctx context.Context, c *models.Client) error {
 	query := `
-		INSERT INTO clients (company_name, country, timezone, billing_currency, status, notes)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at
+		INSERT INTO clients (company_name, country, timezone, billing_currency, billing_address, tax_id, status, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, version, updated_at
 	`
 	return db.Pool.QueryRow(ctx, query,
-		c.CompanyName, c.Country, c.Timezone, c.BillingCurrency, c.Status, c.Notes,
-	).Scan(&c.ID, &c.CreatedAt)
+		c.CompanyName, c.Country, c.Timezone, c.BillingCurrency, c.BillingAddress, c.TaxID, c.Status, c.Notes,
+	).Scan(&c.ID, &c.CreatedAt, &c.Version, &c.UpdatedAt)
 }
 
+// Update replaces the client's fields. A non-zero c.Version must match the
+// stored version.
 func (s *ClientService) Update(ctx context.Context, id string, c *models.Client) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var version int
+	err = tx.QueryRow(ctx, `SELECT version FROM clients WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrClientNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(c.Version, version); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE clients 
-		SET company_name = $1, country = $2, timezone = $3, billing_currency = $4, status = $5, notes = $6
-		WHERE id = $7
-		RETURNING created_at
+		SET company_name = $1, country = $2, timezone = $3, billing_currency = $4, billing_address = $5, tax_id = $6, status = $7, notes = $8,
+			version = version + 1, updated_at = now()
+		WHERE id = $9
+		RETURNING created_at, version, updated_at
 	`
-	return db.Pool.QueryRow(ctx, query,
-		c.CompanyName, c.Country, c.Timezone, c.BillingCurrency, c.Status, c.Notes, id,
-	).Scan(&c.CreatedAt)
+	err = tx.QueryRow(ctx, query,
+		c.CompanyName, c.Country, c.Timezone, c.BillingCurrency, c.BillingAddress, c.TaxID, c.Status, c.Notes, id,
+	).Scan(&c.CreatedAt, &c.Version, &c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (s *ClientService) AddContact(ctx context.Context, c *models.ClientContact) error {
@@ -107,7 +186,35 @@ func (s *ClientService) DeleteContact(ctx context.Context, id string) error {
 }
 
 func (s *ClientService) Archive(ctx context.Context, id string) error {
-	query := `UPDATE clients SET status = 'ARCHIVED' WHERE id = $1`
+	query := `UPDATE clients SET status = 'ARCHIVED', version = version + 1, updated_at = now() WHERE id = $1`
 	_, err := db.Pool.Exec(ctx, query, id)
 	return err
 }
+
+// Delete moves the client and its live projects to the trash with a shared
+// timestamp so Restore can bring them back together.
+func (s *ClientService) Delete(ctx context.Context, id string) error {
+	var deletedBy *string
+	if userID, ok := ctx.Value("user_id").(string); ok && userID != "" {
+		deletedBy = &userID
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	query := `UPDATE clients SET deleted_at = now(), deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING deleted_at`
+	if err := tx.QueryRow(ctx, query, id, deletedBy).Scan(&deletedAt); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE projects SET deleted_at = $2, deleted_by = $3 WHERE client_id = $1 AND deleted_at IS NULL`, id, deletedAt, deletedBy)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
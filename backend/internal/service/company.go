@@ -0,0 +1,57 @@
+package service
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CompanyProfile is our own legal entity as it appears on generated
+// contracts and invoices. It is configured through COMPANY_* variables.
+type CompanyProfile struct {
+	Name               string
+	Address            []string // One entry per line; COMPANY_ADDRESS separates lines with "|"
+	Email              string
+	Phone              string
+	Website            string
+	RegistrationNumber string
+	TaxID              string
+	BankDetails        []string // Payment instructions on invoices, "|"-separated like the address
+	PaymentTermsDays   int      // Default invoice due date, days after issue
+	BrandColor         string   // Hex accent colour on invoices, e.g. "#1f3a5f"
+}
+
+func LoadCompanyProfile() CompanyProfile {
+	p := CompanyProfile{
+		Name:               os.Getenv("COMPANY_NAME"),
+		Email:              os.Getenv("COMPANY_EMAIL"),
+		Phone:              os.Getenv("COMPANY_PHONE"),
+		Website:            os.Getenv("COMPANY_WEBSITE"),
+		RegistrationNumber: os.Getenv("COMPANY_REGISTRATION_NUMBER"),
+		TaxID:              os.Getenv("COMPANY_TAX_ID"),
+		Address:            splitLines(os.Getenv("COMPANY_ADDRESS")),
+		BankDetails:        splitLines(os.Getenv("COMPANY_BANK_DETAILS")),
+		PaymentTermsDays:   30,
+		BrandColor:         os.Getenv("COMPANY_BRAND_COLOR"),
+	}
+	if p.Name == "" {
+		p.Name = "Hirefel"
+	}
+	if days, err := strconv.Atoi(os.Getenv("COMPANY_PAYMENT_TERMS_DAYS")); err == nil && days >= 0 {
+		p.PaymentTermsDays = days
+	}
+	if p.BrandColor == "" {
+		p.BrandColor = "#1f3a5f"
+	}
+	return p
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -2,10 +2,37 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/validate"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Contract status values stored in contracts.status.
+const (
+	ContractDraft      = "DRAFT"
+	ContractSent       = "SENT"
+	ContractSigned     = "SIGNED"
+	ContractTerminated = "TERMINATED"
+	ContractExpired    = "EXPIRED"
+)
+
+var contractTypes = map[string]bool{"CLIENT": true, "CONTRACTOR": true, "MSA": true, "SOW": true, "NDA": true}
+
+var (
+	ErrContractNotFound  = NotFoundError("contract not found")
+	ErrInvalidTransition = ConflictError("invalid contract status transition")
+	ErrContractLocked    = ConflictError("only draft or sent contracts can be edited")
+	ErrInvalidContract   = ValidationError("invalid contract")
+	ErrAlreadyRenewed    = ConflictError("contract has already been renewed")
 )
 
 type ContractService struct{}
@@ -14,9 +41,60 @@ func NewContractService() *ContractService {
 	return &ContractService{}
 }
 
-func (s *ContractService) List(ctx context.Context) ([]models.Contract, error) {
-	query := `SELECT id, client_id, talent_id, project_id, contract_type, status, start_date, end_date, file_url, file_key, created_at FROM contracts`
-	rows, err := db.Pool.Query(ctx, query)
+const contractColumns = `id, client_id, talent_id, project_id, msa_id, contract_type, status, signed, start_date, end_date, notice_period_days,
+	rate, rate_period, currency, document_id, template_id, file_url, file_key, sent_at, signed_at, terminated_at, termination_reason, renewed_from_id,
+	expiry_flagged_at, created_at`
+
+func scanContract(row pgx.Row) (*models.Contract, error) {
+	var c models.Contract
+	var noticePeriod *int
+	var signed *bool
+	err := row.Scan(
+		&c.ID, &c.ClientID, &c.TalentID, &c.ProjectID, &c.MSAID, &c.Type, &c.Status, &signed, &c.StartDate, &c.EndDate, &noticePeriod,
+		&c.Rate, &c.RatePeriod, &c.Currency, &c.DocumentID, &c.TemplateID, &c.FileURL, &c.FileKey, &c.SentAt, &c.SignedAt, &c.TerminatedAt, &c.TerminationReason, &c.RenewedFromID,
+		&c.ExpiryFlaggedAt, &c.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if noticePeriod != nil {
+		c.NoticePeriod = *noticePeriod
+	}
+	c.Signed = signed != nil && *signed
+	return &c, nil
+}
+
+// ContractList is how GET /api/contracts may be sorted and filtered.
+var ContractList = listquery.Spec[models.Contract]{
+	Sorts: map[string]listquery.Sort[models.Contract]{
+		"created_at": {Column: "created_at", Type: "timestamp", Value: func(c models.Contract) string { return listquery.Time(c.CreatedAt) }},
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]listquery.Filter{
+		"client_id":  {Column: "client_id", Rules: "uuid"},
+		"talent_id":  {Column: "talent_id", Rules: "uuid"},
+		"project_id": {Column: "project_id", Rules: "uuid"},
+		"status":     {Column: "status", Rules: "oneof=DRAFT SENT SIGNED TERMINATED EXPIRED"},
+		"type":       {Column: "contract_type::text", Rules: "enum=contract_type"},
+	},
+	ID: func(c models.Contract) uuid.UUID { return c.ID },
+}
+
+// List returns a page of contracts. Client users only ever see their own
+// client's.
+func (s *ContractService) List(ctx context.Context, q *listquery.Query[models.Contract]) (*listquery.Page[models.Contract], error) {
+	if clientID, _ := ctx.Value("client_id").(string); clientID != "" {
+		q.Where("client_id", clientID)
+	}
+	where, args := q.Filter(" WHERE deleted_at IS NULL", nil)
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM contracts`+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query, args := q.Page(`SELECT `+contractColumns+` FROM contracts`+where, args)
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -24,52 +102,488 @@ func (s *ContractService) List(ctx context.Context) ([]models.Contract, error) {
 
 	var contracts []models.Contract
 	for rows.Next() {
-		var c models.Contract
-		err := rows.Scan(
-			&c.ID, &c.ClientID, &c.TalentID, &c.ProjectID, &c.Type, &c.Status, &c.StartDate, &c.EndDate, &c.FileURL, &c.FileKey, &c.CreatedAt,
-		)
+		c, err := scanContract(rows)
 		if err != nil {
 			return nil, err
 		}
-		contracts = append(contracts, c)
+		contracts = append(contracts, *c)
 	}
-	return contracts, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.Result(contracts, total), nil
+}
+
+func (s *ContractService) Get(ctx context.Context, id string) (*models.Contract, error) {
+	c, err := scanContract(db.Pool.QueryRow(ctx, `SELECT `+contractColumns+` FROM contracts WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrContractNotFound
+	}
+	return c, err
 }
 
 func (s *ContractService) Create(ctx context.Context, c *models.Contract) error {
+	return s.create(ctx, db.Pool, c)
+}
+
+// create inserts a draft contract through q, which may be the caller's
+// transaction.
+func (s *ContractService) create(ctx context.Context, q querier, c *models.Contract) error {
+	c.Type = strings.ToUpper(c.Type)
+	// Lifecycle fields are only set through transitions
+	c.Status = ContractDraft
+	c.Signed = false
+	c.SentAt, c.SignedAt, c.TerminatedAt, c.TerminationReason, c.ExpiryFlaggedAt = nil, nil, nil, nil, nil
+
+	if errs := validate.Struct(c); len(errs) > 0 {
+		return ValidationError(ErrInvalidContract.Message, errs...)
+	}
+	if err := validateContract(ctx, q, c); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO contracts (client_id, talent_id, project_id, contract_type, status, start_date, end_date, notice_period_days, file_url, file_key)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO contracts (client_id, talent_id, project_id, msa_id, contract_type, status, start_date, end_date, notice_period_days, rate, rate_period, currency, document_id, file_url, file_key, renewed_from_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 		RETURNING id, created_at
 	`
-	// Status defaults to DRAFT
-	if c.Status == "" {
-		c.Status = "DRAFT"
+	return q.QueryRow(ctx, query,
+		c.ClientID, c.TalentID, c.ProjectID, c.MSAID, c.Type, c.Status, c.StartDate, c.EndDate, c.NoticePeriod, c.Rate, c.RatePeriod, c.Currency, c.DocumentID, c.FileURL, c.FileKey, c.RenewedFromID,
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+// querier is satisfied by the pool and by transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// validateContract checks the type, the date range and that a SOW hangs off
+// a live MSA belonging to the same client.
+func validateContract(ctx context.Context, q querier, c *models.Contract) error {
+	if !contractTypes[c.Type] {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidContract, c.Type)
+	}
+	if c.StartDate != nil && c.EndDate != nil && c.EndDate.Before(*c.StartDate) {
+		return fmt.Errorf("%w: end_date is before start_date", ErrInvalidContract)
+	}
+	if c.NoticePeriod < 0 {
+		return fmt.Errorf("%w: notice_period_days cannot be negative", ErrInvalidContract)
 	}
 
-	return db.Pool.QueryRow(ctx, query,
-		c.ClientID, c.TalentID, c.ProjectID, c.Type, c.Status, c.StartDate, c.EndDate, c.NoticePeriod, c.FileURL, c.FileKey,
-	).Scan(&c.ID, &c.CreatedAt)
+	if c.Type != "SOW" {
+		if c.MSAID != nil {
+			return fmt.Errorf("%w: only a SOW can reference an MSA", ErrInvalidContract)
+		}
+		return nil
+	}
+	if c.MSAID == nil {
+		return fmt.Errorf("%w: a SOW must reference an MSA", ErrInvalidContract)
+	}
+	if c.ClientID == nil {
+		return fmt.Errorf("%w: a SOW must have a client", ErrInvalidContract)
+	}
+
+	var msaType string
+	var msaClient *uuid.UUID
+	err := q.QueryRow(ctx, `SELECT contract_type::text, client_id FROM contracts WHERE id = $1 AND deleted_at IS NULL`, *c.MSAID).Scan(&msaType, &msaClient)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: referenced MSA does not exist", ErrInvalidContract)
+	}
+	if err != nil {
+		return err
+	}
+	if msaType != "MSA" {
+		return fmt.Errorf("%w: msa_id references a %s, not an MSA", ErrInvalidContract, msaType)
+	}
+	if msaClient == nil || *msaClient != *c.ClientID {
+		return fmt.Errorf("%w: the MSA belongs to a different client", ErrInvalidContract)
+	}
+	return nil
+}
+
+type ContractUpdateInput struct {
+	ClientID     *uuid.UUID `json:"client_id"`
+	TalentID     *uuid.UUID `json:"talent_id"`
+	ProjectID    *uuid.UUID `json:"project_id"`
+	MSAID        *uuid.UUID `json:"msa_id"`
+	Type         *string    `json:"type"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	NoticePeriod *int       `json:"notice_period_days"`
+	Rate         *float64   `json:"rate"`
+	RatePeriod   *string    `json:"rate_period"`
+	Currency     *string    `json:"currency"`
+	FileURL      *string    `json:"file_url"`
+	FileKey      *string    `json:"file_key"`
 }
 
+// Update edits the terms of a contract that hasn't been signed yet.
+func (s *ContractService) Update(ctx context.Context, id string, input ContractUpdateInput) (*models.Contract, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := s.update(ctx, tx, id, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// update applies input to a contract within tx.
+func (s *ContractService) update(ctx context.Context, tx pgx.Tx, id string, input ContractUpdateInput) (*models.Contract, error) {
+	c, err := lockContract(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != ContractDraft && c.Status != ContractSent {
+		return nil, ErrContractLocked
+	}
+
+	if input.ClientID != nil {
+		c.ClientID = input.ClientID
+	}
+	if input.TalentID != nil {
+		c.TalentID = input.TalentID
+	}
+	if input.ProjectID != nil {
+		c.ProjectID = input.ProjectID
+	}
+	if input.MSAID != nil {
+		c.MSAID = input.MSAID
+	}
+	if input.Type != nil {
+		c.Type = strings.ToUpper(*input.Type)
+	}
+	if input.StartDate != nil {
+		c.StartDate = input.StartDate
+	}
+	if input.EndDate != nil {
+		c.EndDate = input.EndDate
+	}
+	if input.NoticePeriod != nil {
+		c.NoticePeriod = *input.NoticePeriod
+	}
+	if input.Rate != nil {
+		c.Rate = input.Rate
+	}
+	if input.RatePeriod != nil {
+		c.RatePeriod = input.RatePeriod
+	}
+	if input.Currency != nil {
+		c.Currency = input.Currency
+	}
+	if input.FileURL != nil {
+		c.FileURL = input.FileURL
+	}
+	if input.FileKey != nil {
+		c.FileKey = input.FileKey
+	}
+
+	if errs := validate.Struct(c); len(errs) > 0 {
+		return nil, ValidationError(ErrInvalidContract.Message, errs...)
+	}
+	if err := validateContract(ctx, tx, c); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE contracts SET client_id = $2, talent_id = $3, project_id = $4, msa_id = $5, contract_type = $6, start_date = $7, end_date = $8,
+			notice_period_days = $9, rate = $10, rate_period = $11, currency = $12, file_url = $13, file_key = $14
+		WHERE id = $1
+	`, c.ID, c.ClientID, c.TalentID, c.ProjectID, c.MSAID, c.Type, c.StartDate, c.EndDate, c.NoticePeriod, c.Rate, c.RatePeriod, c.Currency, c.FileURL, c.FileKey)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func lockContract(ctx context.Context, tx pgx.Tx, id string) (*models.Contract, error) {
+	c, err := scanContract(tx.QueryRow(ctx, `SELECT `+contractColumns+` FROM contracts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrContractNotFound
+	}
+	return c, err
+}
+
+// Send marks a draft as sent to the counterparty.
+func (s *ContractService) Send(ctx context.Context, id string) (*models.Contract, error) {
+	return s.transition(ctx, id, []string{ContractDraft}, func(ctx context.Context, tx pgx.Tx, c *models.Contract) error {
+		return tx.QueryRow(ctx,
+			`UPDATE contracts SET status = $2, sent_at = now() WHERE id = $1 RETURNING status, sent_at`,
+			c.ID, ContractSent,
+		).Scan(&c.Status, &c.SentAt)
+	})
+}
+
+// Sign records the counterparty's signature and stamps signed_at. signedAt
+// defaults to now; pass it when recording a signature made earlier.
+func (s *ContractService) Sign(ctx context.Context, id string, signedAt *time.Time) (*models.Contract, error) {
+	return s.transition(ctx, id, []string{ContractDraft, ContractSent}, func(ctx context.Context, tx pgx.Tx, c *models.Contract) error {
+		if err := validateContract(ctx, tx, c); err != nil {
+			return err
+		}
+		if c.Type == "SOW" {
+			// A SOW can't be in force without its MSA
+			var msaStatus string
+			if err := tx.QueryRow(ctx, `SELECT status FROM contracts WHERE id = $1`, *c.MSAID).Scan(&msaStatus); err != nil {
+				return err
+			}
+			if msaStatus != ContractSigned {
+				return fmt.Errorf("%w: the MSA must be signed first", ErrInvalidContract)
+			}
+		}
+		err := tx.QueryRow(ctx,
+			`UPDATE contracts SET status = $2, signed = true, signed_at = COALESCE($3, now()) WHERE id = $1 RETURNING status, signed, signed_at`,
+			c.ID, ContractSigned, signedAt,
+		).Scan(&c.Status, &c.Signed, &c.SignedAt)
+		if err != nil {
+			return err
+		}
+		return events.Publish(ctx, tx, events.ContractSigned{
+			ContractID:   c.ID,
+			ContractType: c.Type,
+			ClientID:     c.ClientID,
+			TalentID:     c.TalentID,
+			ProjectID:    c.ProjectID,
+			StartDate:    c.StartDate,
+			EndDate:      c.EndDate,
+			SignedAt:     c.SignedAt,
+		})
+	})
+}
+
+type ContractTerminateInput struct {
+	Reason        string     `json:"reason"`
+	EffectiveDate *time.Time `json:"effective_date"` // Defaults to today plus the notice period
+}
+
+// Terminate ends a signed contract. The end date moves to the effective date
+// of the termination if that is earlier.
+func (s *ContractService) Terminate(ctx context.Context, id string, input ContractTerminateInput) (*models.Contract, error) {
+	return s.transition(ctx, id, []string{ContractSigned}, func(ctx context.Context, tx pgx.Tx, c *models.Contract) error {
+		effective := input.EffectiveDate
+		if effective == nil {
+			d := time.Now().Truncate(24*time.Hour).AddDate(0, 0, c.NoticePeriod)
+			effective = &d
+		}
+		var reason *string
+		if r := strings.TrimSpace(input.Reason); r != "" {
+			reason = &r
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE contracts
+			SET status = $2, terminated_at = now(), termination_reason = $3,
+				end_date = CASE WHEN end_date IS NULL OR end_date > $4 THEN $4 ELSE end_date END
+			WHERE id = $1
+			RETURNING status, terminated_at, termination_reason, end_date
+		`, c.ID, ContractTerminated, reason, *effective).Scan(&c.Status, &c.TerminatedAt, &c.TerminationReason, &c.EndDate)
+	})
+}
+
+type ContractRenewInput struct {
+	StartDate *time.Time `json:"start_date"` // Defaults to the day after the current end date
+	EndDate   *time.Time `json:"end_date"`   // Defaults to the same term length as the current contract
+}
+
+// Renew creates a draft successor with the same parties and terms. The
+// current contract stays in force until its end date.
+func (s *ContractService) Renew(ctx context.Context, id string, input ContractRenewInput) (*models.Contract, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := lockContract(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != ContractSigned && c.Status != ContractExpired {
+		return nil, fmt.Errorf("%w: only signed or expired contracts can be renewed (status is %s)", ErrInvalidTransition, c.Status)
+	}
+
+	var renewed bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM contracts WHERE renewed_from_id = $1 AND deleted_at IS NULL)`, c.ID).Scan(&renewed)
+	if err != nil {
+		return nil, err
+	}
+	if renewed {
+		return nil, ErrAlreadyRenewed
+	}
+
+	next := *c
+	next.ID = uuid.Nil
+	next.RenewedFromID = &c.ID
+	next.Status = ContractDraft
+	next.Signed = false
+	next.SentAt, next.SignedAt, next.TerminatedAt, next.TerminationReason, next.ExpiryFlaggedAt = nil, nil, nil, nil, nil
+	next.DocumentID, next.FileURL, next.FileKey = nil, nil, nil
+
+	next.StartDate = input.StartDate
+	if next.StartDate == nil && c.EndDate != nil {
+		d := c.EndDate.AddDate(0, 0, 1)
+		next.StartDate = &d
+	}
+	next.EndDate = input.EndDate
+	if next.EndDate == nil && next.StartDate != nil && c.StartDate != nil && c.EndDate != nil {
+		d := next.StartDate.Add(c.EndDate.Sub(*c.StartDate))
+		next.EndDate = &d
+	}
+
+	if err := validateContract(ctx, tx, &next); err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO contracts (client_id, talent_id, project_id, msa_id, contract_type, status, start_date, end_date, notice_period_days, rate, rate_period, currency, renewed_from_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at
+	`, next.ClientID, next.TalentID, next.ProjectID, next.MSAID, next.Type, next.Status, next.StartDate, next.EndDate, next.NoticePeriod, next.Rate, next.RatePeriod, next.Currency, next.RenewedFromID,
+	).Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// transition locks the contract, checks its current status is one of from
+// and applies the change.
+func (s *ContractService) transition(ctx context.Context, id string, from []string, apply func(context.Context, pgx.Tx, *models.Contract) error) (*models.Contract, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := lockContract(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, st := range from {
+		if c.Status == st {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: contract is %s", ErrInvalidTransition, c.Status)
+	}
+
+	if err := apply(ctx, tx, c); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Delete moves the contract to the trash. The stored file is only removed
+// from storage when the trash is purged.
 func (s *ContractService) Delete(ctx context.Context, id string) error {
-	// 1. Get file_key
-	var fileKey *string
-	query := `SELECT file_key FROM contracts WHERE id = $1`
-	err := db.Pool.QueryRow(ctx, query, id).Scan(&fileKey)
+	return softDelete(ctx, "contracts", id)
+}
+
+// Expiring lists signed contracts whose notice window has opened, i.e. the
+// end date is no more than notice_period_days (plus withinDays) away.
+func (s *ContractService) Expiring(ctx context.Context, withinDays int) ([]models.Contract, error) {
+	query := `SELECT ` + contractColumns + ` FROM contracts
+		WHERE deleted_at IS NULL AND status = $1 AND end_date IS NOT NULL
+		  AND end_date - (COALESCE(notice_period_days, 0) + $2) <= CURRENT_DATE`
+	args := []interface{}{ContractSigned, withinDays}
+	if clientID, _ := ctx.Value("client_id").(string); clientID != "" {
+		query += " AND client_id = $3"
+		args = append(args, clientID)
+	}
+	query += " ORDER BY end_date"
+
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	// 2. Delete from Uploadthing
-	if fileKey != nil && *fileKey != "" {
-		if err := DeleteFromUploadthing(*fileKey); err != nil {
-			fmt.Printf("Warning: Failed to delete contract file from Uploadthing: %v\n", err)
+	var contracts []models.Contract
+	for rows.Next() {
+		c, err := scanContract(rows)
+		if err != nil {
+			return nil, err
 		}
+		contracts = append(contracts, *c)
+	}
+	return contracts, nil
+}
+
+// ScanExpiry flags signed contracts that have entered their notice window,
+// emailing admin and sales staff, and marks those past their end date as
+// EXPIRED. It returns the contracts flagged in this run.
+func (s *ContractService) ScanExpiry(ctx context.Context) ([]models.Contract, error) {
+	_, err := db.Pool.Exec(ctx,
+		`UPDATE contracts SET status = $1 WHERE deleted_at IS NULL AND status = $2 AND end_date < CURRENT_DATE`,
+		ContractExpired, ContractSigned,
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	// 3. Delete from DB
-	query = `DELETE FROM contracts WHERE id = $1`
-	_, err = db.Pool.Exec(ctx, query, id)
-	return err
+	// Flag and notify in one transaction so each contract is announced once
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE contracts SET expiry_flagged_at = now()
+		WHERE deleted_at IS NULL AND status = $1 AND expiry_flagged_at IS NULL AND end_date IS NOT NULL
+		  AND end_date - COALESCE(notice_period_days, 0) <= CURRENT_DATE
+		RETURNING `+contractColumns, ContractSigned)
+	if err != nil {
+		return nil, err
+	}
+	var flagged []models.Contract
+	for rows.Next() {
+		c, err := scanContract(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		flagged = append(flagged, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range flagged {
+		var party string
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE((SELECT company_name FROM clients WHERE id = $1), (SELECT first_name || ' ' || last_name FROM talent WHERE id = $2), '')
+		`, c.ClientID, c.TalentID).Scan(&party)
+		if err != nil {
+			return nil, err
+		}
+		_, err = notifyStaff(ctx, tx, NotifyContractExpiring, []string{"ADMIN", "SALES"}, map[string]interface{}{
+			"ContractType":     c.Type,
+			"Party":            party,
+			"EndDate":          c.EndDate.Format("2 January 2006"),
+			"NoticePeriodDays": c.NoticePeriod,
+			"URL":              appURL("/contracts/" + c.ID.String()),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return flagged, nil
 }
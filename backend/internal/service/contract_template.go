@@ -0,0 +1,783 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/pdf"
+	"github.com/dubai/platform/backend/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Built-in templates are used for any contract type without a default
+// template in the database.
+//
+//go:embed templates/contracts/*.tmpl
+var builtinTemplates embed.FS
+
+var builtinTemplateFiles = map[string]string{
+	"CLIENT":     "templates/contracts/client.tmpl",
+	"CONTRACTOR": "templates/contracts/contractor.tmpl",
+	"MSA":        "templates/contracts/msa.tmpl",
+	"SOW":        "templates/contracts/sow.tmpl",
+	"NDA":        "templates/contracts/nda.tmpl",
+}
+
+// maxTemplateOutput caps rendered contract text so a runaway template
+// (e.g. a huge range) can't exhaust memory.
+const maxTemplateOutput = 1 << 20
+
+var (
+	ErrTemplateNotFound = NotFoundError("contract template not found")
+	ErrInvalidTemplate  = ValidationError("invalid contract template")
+)
+
+// Templates only get these functions; text/template itself has no access to
+// the filesystem, network or environment.
+var templateFuncs = template.FuncMap{
+	"date":    formatTemplateDate,
+	"money":   formatMoney,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"join":    strings.Join,
+	"default": templateDefault,
+}
+
+// ContractTemplateData is what a template sees. It is deliberately flat and
+// free of internal IDs; optional parties are nil when not involved.
+type ContractTemplateData struct {
+	Company    CompanyProfile
+	Contract   TemplateContract
+	Client     *TemplateClient
+	Contact    *TemplateContact
+	Talent     *TemplateTalent
+	Project    *TemplateProject
+	Assignment *TemplateAssignment
+	MSA        *TemplateMSA
+	Today      time.Time
+}
+
+type TemplateContract struct {
+	Type             string
+	StartDate        *time.Time
+	EndDate          *time.Time
+	NoticePeriodDays int
+	Rate             float64
+	RatePeriod       string
+	Currency         string
+}
+
+type TemplateClient struct {
+	CompanyName string
+	Country     string
+}
+
+type TemplateContact struct {
+	Name  string
+	Email string
+	Role  string
+}
+
+type TemplateTalent struct {
+	Name    string
+	Email   string
+	Country string
+	Role    string
+}
+
+type TemplateProject struct {
+	Name           string
+	Description    string
+	EngagementType string
+}
+
+type TemplateAssignment struct {
+	Role         string
+	StartDate    time.Time
+	HoursPerWeek int
+}
+
+type TemplateMSA struct {
+	StartDate *time.Time
+	SignedAt  *time.Time
+}
+
+type ContractTemplateService struct{}
+
+func NewContractTemplateService() *ContractTemplateService {
+	return &ContractTemplateService{}
+}
+
+const contractTemplateColumns = `id, contract_type::text, name, body, is_default, created_by, created_at, updated_at`
+
+func scanContractTemplate(row pgx.Row) (*models.ContractTemplate, error) {
+	var t models.ContractTemplate
+	var id uuid.UUID
+	err := row.Scan(&id, &t.Type, &t.Name, &t.Body, &t.IsDefault, &t.CreatedBy, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.ID = &id
+	return &t, nil
+}
+
+// List returns stored templates, optionally of one type, followed by the
+// built-in template of each type. A built-in is the default only when no
+// stored template of its type is.
+func (s *ContractTemplateService) List(ctx context.Context, contractType string) ([]models.ContractTemplate, error) {
+	contractType = strings.ToUpper(contractType)
+	query := `SELECT ` + contractTemplateColumns + ` FROM contract_templates`
+	var args []interface{}
+	if contractType != "" {
+		query += ` WHERE contract_type::text = $1`
+		args = append(args, contractType)
+	}
+	query += ` ORDER BY contract_type, is_default DESC, name`
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.ContractTemplate
+	hasDefault := map[string]bool{}
+	for rows.Next() {
+		t, err := scanContractTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		if t.IsDefault {
+			hasDefault[t.Type] = true
+		}
+		templates = append(templates, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, typ := range []string{"CLIENT", "CONTRACTOR", "MSA", "SOW", "NDA"} {
+		if contractType != "" && typ != contractType {
+			continue
+		}
+		body, err := builtinTemplateBody(typ)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, models.ContractTemplate{
+			Type:      typ,
+			Name:      "Standard " + typ,
+			Body:      body,
+			IsDefault: !hasDefault[typ],
+			BuiltIn:   true,
+		})
+	}
+	return templates, nil
+}
+
+func (s *ContractTemplateService) Get(ctx context.Context, id string) (*models.ContractTemplate, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrTemplateNotFound
+	}
+	return scanContractTemplate(db.Pool.QueryRow(ctx, `SELECT `+contractTemplateColumns+` FROM contract_templates WHERE id = $1`, id))
+}
+
+func (s *ContractTemplateService) Create(ctx context.Context, t *models.ContractTemplate) error {
+	t.Type = strings.ToUpper(t.Type)
+	t.BuiltIn = false
+	if err := validateTemplate(t.Type, t.Name, t.Body); err != nil {
+		return err
+	}
+	t.CreatedBy = contextUserID(ctx)
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if t.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE contract_templates SET is_default = false WHERE contract_type = $1 AND is_default`, t.Type); err != nil {
+			return err
+		}
+	}
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO contract_templates (contract_type, name, body, is_default, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, t.Type, t.Name, t.Body, t.IsDefault, t.CreatedBy).Scan(&id, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	t.ID = &id
+	return tx.Commit(ctx)
+}
+
+type ContractTemplateUpdateInput struct {
+	Name      *string `json:"name"`
+	Body      *string `json:"body"`
+	IsDefault *bool   `json:"is_default"`
+}
+
+func (s *ContractTemplateService) Update(ctx context.Context, id string, input ContractTemplateUpdateInput) (*models.ContractTemplate, error) {
+	t, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		t.Name = *input.Name
+	}
+	if input.Body != nil {
+		t.Body = *input.Body
+	}
+	if input.IsDefault != nil {
+		t.IsDefault = *input.IsDefault
+	}
+	if err := validateTemplate(t.Type, t.Name, t.Body); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if t.IsDefault {
+		if _, err := tx.Exec(ctx, `UPDATE contract_templates SET is_default = false WHERE contract_type = $1 AND is_default AND id <> $2`, t.Type, id); err != nil {
+			return nil, err
+		}
+	}
+	err = tx.QueryRow(ctx, `
+		UPDATE contract_templates SET name = $1, body = $2, is_default = $3, updated_at = now()
+		WHERE id = $4
+		RETURNING updated_at
+	`, t.Name, t.Body, t.IsDefault, id).Scan(&t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Delete removes a template. Contracts generated from it keep their document
+// and lose only the template reference.
+func (s *ContractTemplateService) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrTemplateNotFound
+	}
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM contract_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// Preview renders a template body against sample data, so admins can check a
+// template before saving it.
+func (s *ContractTemplateService) Preview(contractType, body string) ([]byte, error) {
+	contractType = strings.ToUpper(contractType)
+	if err := validateTemplate(contractType, "preview", body); err != nil {
+		return nil, err
+	}
+	data := sampleTemplateData(contractType)
+	text, err := renderTemplate(body, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return renderContractPDF(contractTitle(data), data.Company.Name, text), nil
+}
+
+type ContractGenerateInput struct {
+	TemplateID   *uuid.UUID `json:"template_id"` // Defaults to the type's default template
+	Type         string     `json:"type"`
+	ClientID     *uuid.UUID `json:"client_id"`
+	TalentID     *uuid.UUID `json:"talent_id"`
+	ProjectID    *uuid.UUID `json:"project_id"`
+	AssignmentID *uuid.UUID `json:"assignment_id"` // Implies project, talent, client and rate
+	MSAID        *uuid.UUID `json:"msa_id"`        // SOWs default to the client's latest MSA
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	NoticePeriod *int       `json:"notice_period_days"` // Defaults to 30
+	Rate         *float64   `json:"rate"`               // Overrides the assignment rate
+	RatePeriod   *string    `json:"rate_period"`
+	Currency     *string    `json:"currency"` // Defaults to the client's billing currency
+}
+
+// Generate renders a contract from a template, stores the PDF and creates a
+// DRAFT contract linked to it. The rendered text becomes the document's
+// content, so generated contracts are searchable without OCR.
+func (s *ContractTemplateService) Generate(ctx context.Context, input ContractGenerateInput) (*models.Contract, error) {
+	input.Type = strings.ToUpper(input.Type)
+	if !contractTypes[input.Type] {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidContract, input.Type)
+	}
+
+	c, data, err := loadTemplateData(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateContract(ctx, db.Pool, c); err != nil {
+		return nil, err
+	}
+
+	body, templateID, err := resolveTemplate(ctx, input.TemplateID, c.Type)
+	if err != nil {
+		return nil, err
+	}
+	c.TemplateID = templateID
+	text, err := renderTemplate(body, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	title := contractTitle(data)
+	content := renderContractPDF(title, data.Company.Name, text)
+	fileName := title + " - " + data.Today.Format("2006-01-02") + ".pdf"
+	obj, err := storage.Default.Put(ctx, storage.NewKey("contracts", fileName), bytes.NewReader(content), int64(len(content)), "application/pdf")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.insertGenerated(ctx, c, fileName, obj, text); err != nil {
+		// The row never existed, so the stored file has no owner
+		if delErr := storage.Default.Delete(context.Background(), obj.Key); delErr != nil {
+			fmt.Printf("Failed to delete orphaned contract file %s: %v\n", obj.Key, delErr)
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *ContractTemplateService) insertGenerated(ctx context.Context, c *models.Contract, fileName string, obj *storage.Object, text string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	c.FileURL, c.FileKey = &obj.URL, &obj.Key
+	err = tx.QueryRow(ctx, `
+		INSERT INTO contracts (client_id, talent_id, project_id, msa_id, contract_type, status, start_date, end_date, notice_period_days, rate, rate_period, currency, template_id, file_url, file_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id, created_at
+	`, c.ClientID, c.TalentID, c.ProjectID, c.MSAID, c.Type, c.Status, c.StartDate, c.EndDate, c.NoticePeriod, c.Rate, c.RatePeriod, c.Currency, c.TemplateID, c.FileURL, c.FileKey,
+	).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	d := &models.Document{
+		EntityType: "CONTRACT",
+		EntityID:   c.ID,
+		FileName:   fileName,
+		FileType:   "application/pdf",
+		FileSize:   obj.Size,
+		FileURL:    obj.URL,
+		FileKey:    obj.Key,
+		Content:    &text,
+	}
+	if err := insertDocument(ctx, tx, d, false); err != nil {
+		return err
+	}
+	c.DocumentID = &d.ID
+	if _, err := tx.Exec(ctx, `UPDATE contracts SET document_id = $1 WHERE id = $2`, d.ID, c.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// loadTemplateData builds the draft contract and the data its template sees
+// from the referenced records.
+func loadTemplateData(ctx context.Context, input ContractGenerateInput) (*models.Contract, *ContractTemplateData, error) {
+	c := &models.Contract{
+		Type:         input.Type,
+		Status:       ContractDraft,
+		ClientID:     input.ClientID,
+		TalentID:     input.TalentID,
+		ProjectID:    input.ProjectID,
+		MSAID:        input.MSAID,
+		StartDate:    input.StartDate,
+		EndDate:      input.EndDate,
+		NoticePeriod: 30,
+		Rate:         input.Rate,
+		RatePeriod:   input.RatePeriod,
+		Currency:     input.Currency,
+	}
+	if input.NoticePeriod != nil {
+		c.NoticePeriod = *input.NoticePeriod
+	}
+	data := &ContractTemplateData{Company: LoadCompanyProfile(), Today: time.Now()}
+
+	if input.AssignmentID != nil {
+		var a TemplateAssignment
+		var projectID, talentID uuid.UUID
+		var contractorCost float64
+		var clientRate, dailyBill, dailyPayout *float64
+		var hours *int
+		err := db.Pool.QueryRow(ctx, `
+			SELECT project_id, talent_id, role, start_date, monthly_client_rate, monthly_contractor_cost, daily_bill_rate, daily_payout_rate, hours_per_week
+			FROM project_assignments WHERE id = $1
+		`, *input.AssignmentID).Scan(&projectID, &talentID, &a.Role, &a.StartDate, &clientRate, &contractorCost, &dailyBill, &dailyPayout, &hours)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: assignment not found", ErrInvalidContract)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if hours != nil {
+			a.HoursPerWeek = *hours
+		}
+		data.Assignment = &a
+		c.ProjectID, c.TalentID = &projectID, &talentID
+		if c.StartDate == nil {
+			c.StartDate = &a.StartDate
+		}
+
+		// Contractor agreements carry what we pay; everything else what we bill
+		if c.Rate == nil {
+			var rate float64
+			period := "MONTH"
+			if clientRate != nil {
+				rate = *clientRate
+			}
+			if dailyBill != nil {
+				rate, period = *dailyBill, "DAY"
+			}
+			if c.Type == "CONTRACTOR" {
+				rate, period = contractorCost, "MONTH"
+				if dailyPayout != nil {
+					rate, period = *dailyPayout, "DAY"
+				}
+			}
+			if rate > 0 {
+				c.Rate, c.RatePeriod = &rate, &period
+			}
+		}
+	}
+
+	if c.ProjectID != nil {
+		var p TemplateProject
+		var clientID uuid.UUID
+		var description, engagement *string
+		err := db.Pool.QueryRow(ctx, `
+			SELECT client_id, name, description, engagement_type FROM projects WHERE id = $1 AND deleted_at IS NULL
+		`, *c.ProjectID).Scan(&clientID, &p.Name, &description, &engagement)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: project not found", ErrInvalidContract)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		p.Description, p.EngagementType = deref(description), deref(engagement)
+		data.Project = &p
+		if c.ClientID == nil {
+			c.ClientID = &clientID
+		} else if *c.ClientID != clientID {
+			return nil, nil, fmt.Errorf("%w: the project belongs to a different client", ErrInvalidContract)
+		}
+	}
+
+	if c.ClientID != nil {
+		var cl TemplateClient
+		var country, currency *string
+		err := db.Pool.QueryRow(ctx, `
+			SELECT company_name, country, billing_currency FROM clients WHERE id = $1 AND deleted_at IS NULL
+		`, *c.ClientID).Scan(&cl.CompanyName, &country, &currency)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: client not found", ErrInvalidContract)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		cl.Country = deref(country)
+		data.Client = &cl
+		if c.Currency == nil && currency != nil {
+			c.Currency = currency
+		}
+
+		var ct TemplateContact
+		var first, last string
+		var email, role *string
+		err = db.Pool.QueryRow(ctx, `
+			SELECT first_name, last_name, email, role FROM client_contacts
+			WHERE client_id = $1 ORDER BY is_primary DESC, created_at LIMIT 1
+		`, *c.ClientID).Scan(&first, &last, &email, &role)
+		if err == nil {
+			ct.Name = strings.TrimSpace(first + " " + last)
+			ct.Email, ct.Role = deref(email), deref(role)
+			data.Contact = &ct
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, err
+		}
+
+		if c.Type == "SOW" && c.MSAID == nil {
+			var msaID uuid.UUID
+			err := db.Pool.QueryRow(ctx, `
+				SELECT id FROM contracts
+				WHERE client_id = $1 AND contract_type = 'MSA' AND deleted_at IS NULL AND status IN ('SIGNED', 'SENT', 'DRAFT')
+				ORDER BY (status = 'SIGNED') DESC, created_at DESC LIMIT 1
+			`, *c.ClientID).Scan(&msaID)
+			if err == nil {
+				c.MSAID = &msaID
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if c.TalentID != nil {
+		var t TemplateTalent
+		var first, last string
+		var country *string
+		err := db.Pool.QueryRow(ctx, `
+			SELECT first_name, last_name, email, country, role FROM talent WHERE id = $1 AND deleted_at IS NULL
+		`, *c.TalentID).Scan(&first, &last, &t.Email, &country, &t.Role)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, fmt.Errorf("%w: talent not found", ErrInvalidContract)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Name = strings.TrimSpace(first + " " + last)
+		t.Country = deref(country)
+		data.Talent = &t
+	}
+
+	if c.MSAID != nil {
+		var m TemplateMSA
+		err := db.Pool.QueryRow(ctx, `SELECT start_date, signed_at FROM contracts WHERE id = $1 AND deleted_at IS NULL`, *c.MSAID).Scan(&m.StartDate, &m.SignedAt)
+		if err == nil {
+			data.MSA = &m
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, err
+		}
+	}
+
+	data.Contract = TemplateContract{
+		Type:             c.Type,
+		StartDate:        c.StartDate,
+		EndDate:          c.EndDate,
+		NoticePeriodDays: c.NoticePeriod,
+		RatePeriod:       deref(c.RatePeriod),
+		Currency:         deref(c.Currency),
+	}
+	if c.Rate != nil {
+		data.Contract.Rate = *c.Rate
+	}
+	return c, data, nil
+}
+
+// resolveTemplate picks the requested template, else the type's stored
+// default, else the built-in. The returned ID is nil for built-ins.
+func resolveTemplate(ctx context.Context, templateID *uuid.UUID, contractType string) (string, *uuid.UUID, error) {
+	if templateID != nil {
+		var typ, body string
+		err := db.Pool.QueryRow(ctx, `SELECT contract_type::text, body FROM contract_templates WHERE id = $1`, *templateID).Scan(&typ, &body)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, ErrTemplateNotFound
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if typ != contractType {
+			return "", nil, fmt.Errorf("%w: template is for %s contracts", ErrInvalidTemplate, typ)
+		}
+		return body, templateID, nil
+	}
+
+	var id uuid.UUID
+	var body string
+	err := db.Pool.QueryRow(ctx, `SELECT id, body FROM contract_templates WHERE contract_type = $1 AND is_default`, contractType).Scan(&id, &body)
+	if err == nil {
+		return body, &id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, err
+	}
+	body, err = builtinTemplateBody(contractType)
+	return body, nil, err
+}
+
+func builtinTemplateBody(contractType string) (string, error) {
+	b, err := builtinTemplates.ReadFile(builtinTemplateFiles[contractType])
+	if err != nil {
+		return "", fmt.Errorf("no built-in template for %s: %w", contractType, err)
+	}
+	return string(b), nil
+}
+
+// validateTemplate checks a template parses and renders against both empty
+// and fully populated data, catching references to fields that don't exist.
+func validateTemplate(contractType, name, body string) error {
+	if !contractTypes[contractType] {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidTemplate, contractType)
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidTemplate)
+	}
+	for _, data := range []*ContractTemplateData{{}, sampleTemplateData(contractType)} {
+		if _, err := renderTemplate(body, data); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	return nil
+}
+
+func renderTemplate(body string, data *ContractTemplateData) (string, error) {
+	tmpl, err := template.New("contract").Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return "", err
+	}
+	out := &limitedBuffer{max: maxTemplateOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
+
+func renderContractPDF(title, author, text string) []byte {
+	doc := pdf.New()
+	doc.Title, doc.Author = title, author
+	f := pdf.NewFlow(doc, 56)
+	f.Footer = func(d *pdf.Document, page int) {
+		d.Text(56, pdf.PageHeight-28, pdf.Helvetica, 8, pdf.Gray, title)
+		d.TextRight(pdf.PageWidth-56, pdf.PageHeight-28, pdf.Helvetica, 8, pdf.Gray, fmt.Sprintf("Page %d", page))
+	}
+	f.Markup(text)
+	return doc.Bytes()
+}
+
+func contractTitle(data *ContractTemplateData) string {
+	title := data.Contract.Type
+	switch {
+	case data.Talent != nil && data.Contract.Type == "CONTRACTOR":
+		title += " - " + data.Talent.Name
+	case data.Client != nil:
+		title += " - " + data.Client.CompanyName
+	case data.Talent != nil:
+		title += " - " + data.Talent.Name
+	}
+	return title
+}
+
+func sampleTemplateData(contractType string) *ContractTemplateData {
+	start := time.Now().AddDate(0, 0, 7)
+	end := start.AddDate(1, 0, 0)
+	return &ContractTemplateData{
+		Company: LoadCompanyProfile(),
+		Contract: TemplateContract{
+			Type: contractType, StartDate: &start, EndDate: &end, NoticePeriodDays: 30,
+			Rate: 8500, RatePeriod: "MONTH", Currency: "USD",
+		},
+		Client:     &TemplateClient{CompanyName: "Example Client Ltd", Country: "United Kingdom"},
+		Contact:    &TemplateContact{Name: "Jane Doe", Email: "jane@example.com", Role: "CTO"},
+		Talent:     &TemplateTalent{Name: "John Smith", Email: "john@example.com", Country: "Portugal", Role: "Senior Engineer"},
+		Project:    &TemplateProject{Name: "Platform Rebuild", Description: "Rebuild of the customer platform.", EngagementType: "Dedicated team"},
+		Assignment: &TemplateAssignment{Role: "Senior Engineer", StartDate: start, HoursPerWeek: 40},
+		MSA:        &TemplateMSA{StartDate: &start, SignedAt: &start},
+		Today:      time.Now(),
+	}
+}
+
+// formatTemplateDate accepts time.Time or *time.Time; nil and zero render
+// as an empty string so templates can fall back with default.
+func formatTemplateDate(v interface{}) string {
+	var t time.Time
+	switch d := v.(type) {
+	case time.Time:
+		t = d
+	case *time.Time:
+		if d == nil {
+			return ""
+		}
+		t = *d
+	default:
+		return ""
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2 January 2006")
+}
+
+// formatMoney renders 12345.5 as "USD 12,345.50".
+func formatMoney(amount float64, currency string) string {
+	s := fmt.Sprintf("%.2f", amount)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac := s[:len(s)-3], s[len(s)-3:]
+	var sb strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(r)
+	}
+	s = sb.String() + frac
+	if neg {
+		s = "-" + s
+	}
+	if currency != "" {
+		s = currency + " " + s
+	}
+	return s
+}
+
+// templateDefault returns v unless it is empty, in which case def.
+func templateDefault(def interface{}, v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return def
+	case string:
+		if x == "" {
+			return def
+		}
+	case *string:
+		if x == nil || *x == "" {
+			return def
+		}
+		return *x
+	}
+	return v
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
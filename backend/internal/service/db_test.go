@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/db"
+)
+
+var (
+	dbOnce sync.Once
+	dbErr  error
+)
+
+// requireDB connects db.Pool to TEST_DATABASE_URL and loads the schema, or
+// skips the test when no database is configured.
+func requireDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	dbOnce.Do(func() {
+		if dbErr = db.Connect(url); dbErr != nil {
+			return
+		}
+		var schema []byte
+		if schema, dbErr = os.ReadFile("../db/schema.sql"); dbErr != nil {
+			return
+		}
+		_, dbErr = db.Pool.Exec(context.Background(), string(schema))
+	})
+	if dbErr != nil {
+		t.Fatalf("test database: %v", dbErr)
+	}
+}
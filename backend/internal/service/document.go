@@ -1,31 +1,55 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"html"
 	"io"
-	"mime/multipart"
-	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/storage"
+	"github.com/dubai/platform/backend/internal/textdiff"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrVersionNotFound = NotFoundError("document version not found")
+
 type DocumentService struct{}
 
 func NewDocumentService() *DocumentService {
 	return &DocumentService{}
 }
 
-func (s *DocumentService) List(ctx context.Context, entityType string, entityID string) ([]models.Document, error) {
-	query := `
-		SELECT id, entity_type, entity_id, file_name, file_type, file_size, status, file_url, file_key, ocr_status, content, uploaded_at 
-		FROM documents
-		WHERE 1=1
-	`
+// documentColumns and scanDocument keep every document query in step.
+const documentColumns = `id, entity_type, entity_id, file_name, file_type, file_size, status, file_url, file_key, ocr_status, ocr_error, content, uploaded_at,
+	logical_id, version, superseded_by, superseded_at, uploaded_by`
+
+func scanDocument(row pgx.Row) (*models.Document, error) {
+	var d models.Document
+	err := row.Scan(
+		&d.ID, &d.EntityType, &d.EntityID, &d.FileName, &d.FileType, &d.FileSize, &d.Status, &d.FileURL, &d.FileKey, &d.OCRStatus, &d.OCRError, &d.Content, &d.UploadedAt,
+		&d.LogicalID, &d.Version, &d.SupersededBy, &d.SupersededAt, &d.UploadedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// List returns the latest version of each document unless history is set,
+// in which case superseded versions are included too.
+func (s *DocumentService) List(ctx context.Context, entityType string, entityID string, history bool) ([]models.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE deleted_at IS NULL`
+	if !history {
+		query += " AND superseded_by IS NULL"
+	}
 	args := []interface{}{}
 	argId := 1
 
@@ -40,7 +64,7 @@ func (s *DocumentService) List(ctx context.Context, entityType string, entityID
 		argId++
 	}
 
-	query += " ORDER BY uploaded_at DESC"
+	query += " ORDER BY uploaded_at DESC, version DESC"
 
 	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
@@ -51,71 +75,356 @@ func (s *DocumentService) List(ctx context.Context, entityType string, entityID
 
 	var docs []models.Document
 	for rows.Next() {
-		var d models.Document
-		err := rows.Scan(
-			&d.ID, &d.EntityType, &d.EntityID, &d.FileName, &d.FileType, &d.FileSize, &d.Status, &d.FileURL, &d.FileKey, &d.OCRStatus, &d.Content, &d.UploadedAt,
-		)
+		d, err := scanDocument(rows)
 		if err != nil {
 			fmt.Printf("DocumentService List Scan Error: %v\n", err)
 			return nil, err
 		}
-		docs = append(docs, d)
+		docs = append(docs, *d)
 	}
 	return docs, nil
 }
 
+type DocumentSearchInput struct {
+	Query      string
+	EntityType string
+	Status     string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	History    bool // Include superseded versions
+}
+
+// Search runs a ranked full-text query over file names and OCR content,
+// restricted to documents the caller can see.
+func (s *DocumentService) Search(ctx context.Context, input DocumentSearchInput) ([]models.DocumentSearchResult, error) {
+	scope, args, err := documentScope(ctx, 2)
+	if err != nil {
+		return nil, err
+	}
+	args = append([]interface{}{input.Query}, args...)
+	argId := len(args) + 1
+
+	query := `
+		SELECT d.id, d.entity_type, d.entity_id, d.file_name, d.file_type, d.file_size, d.status, d.ocr_status, d.uploaded_at,
+			d.logical_id, d.version, d.superseded_by, d.superseded_at, d.uploaded_by,
+			ts_rank(d.search_vector, q) AS rank,
+			ts_headline('english', translate(coalesce(d.content, d.file_name), chr(2) || chr(3), ''), q,
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MaxWords=30, MinWords=10') AS snippet
+		FROM documents d, websearch_to_tsquery('english', $1) q
+		WHERE d.deleted_at IS NULL AND d.search_vector @@ q AND ` + scope
+
+	if !input.History {
+		query += " AND d.superseded_by IS NULL"
+	}
+	if input.EntityType != "" {
+		query += fmt.Sprintf(" AND upper(d.entity_type) = upper($%d)", argId)
+		args = append(args, input.EntityType)
+		argId++
+	}
+	if input.Status != "" {
+		query += fmt.Sprintf(" AND d.status = $%d", argId)
+		args = append(args, input.Status)
+		argId++
+	}
+	if input.From != nil {
+		query += fmt.Sprintf(" AND d.uploaded_at >= $%d", argId)
+		args = append(args, *input.From)
+		argId++
+	}
+	if input.To != nil {
+		query += fmt.Sprintf(" AND d.uploaded_at < $%d", argId)
+		args = append(args, *input.To)
+		argId++
+	}
+
+	limit := input.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query += fmt.Sprintf(" ORDER BY rank DESC, d.uploaded_at DESC LIMIT $%d", argId)
+	args = append(args, limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.DocumentSearchResult
+	for rows.Next() {
+		var r models.DocumentSearchResult
+		err := rows.Scan(
+			&r.ID, &r.EntityType, &r.EntityID, &r.FileName, &r.FileType, &r.FileSize, &r.Status, &r.OCRStatus, &r.UploadedAt,
+			&r.LogicalID, &r.Version, &r.SupersededBy, &r.SupersededAt, &r.UploadedBy,
+			&r.Rank, &r.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.Snippet = highlightSnippet(r.Snippet)
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// highlightSnippet turns a headline into safe HTML. OCR text is whatever was
+// in the uploaded file, so it is escaped first and only then are the control
+// characters ts_headline put around matches replaced with <mark> tags.
+func highlightSnippet(headline string) string {
+	return snippetMarks.Replace(html.EscapeString(headline))
+}
+
+var snippetMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// Get loads one document version by its id.
+func (s *DocumentService) Get(ctx context.Context, id string) (*models.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE id = $1 AND deleted_at IS NULL`
+	return scanDocument(db.Pool.QueryRow(ctx, query, id))
+}
+
+// ErrFileKeyInUse stops a document from pointing at a stored file that
+// already belongs to another record, whose access rules it would bypass.
+var ErrFileKeyInUse = ConflictError("file is already attached to another record")
+
+// Create records a new logical document for a file the caller has already put
+// in storage; use UploadVersion to revise one. The file key comes from the
+// caller, so it must not be in use by any other document or contract.
 func (s *DocumentService) Create(ctx context.Context, d *models.Document) error {
+	if d.FileKey == "" {
+		return ValidationError("file_key is required")
+	}
+	if err := authorizeEntity(ctx, d.EntityType, d.EntityID.String()); err != nil {
+		return err
+	}
+	var inUse bool
+	err := db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM documents WHERE file_key = $1)
+		    OR EXISTS (SELECT 1 FROM contracts WHERE file_key = $1)
+	`, d.FileKey).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrFileKeyInUse
+	}
+	return s.create(ctx, d)
+}
+
+// create records a new logical document.
+func (s *DocumentService) create(ctx context.Context, d *models.Document) error {
+	d.LogicalID = uuid.Nil
+	d.SupersededBy = nil
+	d.SupersededAt = nil
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertDocument(ctx, tx, d, true); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// insertDocument writes a document version and publishes DocumentCreated in
+// the same transaction. With ocr set the ocr subscriber queues its text
+// extraction; without it the caller supplies Content itself (e.g. for
+// generated files). A zero LogicalID starts a new logical document.
+func insertDocument(ctx context.Context, tx pgx.Tx, d *models.Document, ocr bool) error {
 	if d.Status == "" {
 		d.Status = "DRAFT"
 	}
-	ocrStatus := "PENDING"
+	ocrStatus := OCRPending
+	if !ocr {
+		ocrStatus = OCRCompleted
+	}
 	d.OCRStatus = &ocrStatus
+	d.ID = uuid.New()
+	if d.LogicalID == uuid.Nil {
+		d.LogicalID = d.ID
+		d.Version = 1
+	}
+	d.UploadedBy = contextUserID(ctx)
 
 	query := `
-		INSERT INTO documents (entity_type, entity_id, file_name, file_type, file_size, status, file_url, file_key, ocr_status, content)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id, uploaded_at
+		INSERT INTO documents (id, logical_id, version, entity_type, entity_id, file_name, file_type, file_size, status, file_url, file_key, ocr_status, content, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING uploaded_at
 	`
-	err := db.Pool.QueryRow(ctx, query,
-		d.EntityType, d.EntityID, d.FileName, d.FileType, d.FileSize, d.Status, d.FileURL, d.FileKey, d.OCRStatus, d.Content,
-	).Scan(&d.ID, &d.UploadedAt)
-
-	if err == nil {
-		// Trigger Async OCR
-		// go func(docID string, fileURL string, fileName string) {
-		// 	// Basic retry logic could be added here
-		// 	if err := processOCR(docID, fileURL, fileName); err != nil {
-		// 		fmt.Printf("OCR Processing Failed for %s: %v\n", docID, err)
-		// 		// Update status to FAILED
-		// 		db.Pool.Exec(context.Background(), "UPDATE documents SET ocr_status = 'FAILED' WHERE id = $1", docID)
-		// 	}
-		// }(d.ID.String(), d.FileURL, d.FileName)
+	err := tx.QueryRow(ctx, query,
+		d.ID, d.LogicalID, d.Version, d.EntityType, d.EntityID, d.FileName, d.FileType, d.FileSize, d.Status, d.FileURL, d.FileKey, d.OCRStatus, d.Content, d.UploadedBy,
+	).Scan(&d.UploadedAt)
+	if err != nil {
+		return err
 	}
 
-	return err
+	return events.Publish(ctx, tx, events.DocumentCreated{
+		DocumentID: d.ID,
+		LogicalID:  d.LogicalID,
+		Version:    d.Version,
+		EntityType: d.EntityType,
+		EntityID:   d.EntityID,
+		FileName:   d.FileName,
+		NeedsOCR:   ocr,
+	})
 }
 
-func (s *DocumentService) Delete(ctx context.Context, id string) error {
-	// 1. Get file_key
-	var fileKey string
-	query := `SELECT file_key FROM documents WHERE id = $1`
-	err := db.Pool.QueryRow(ctx, query, id).Scan(&fileKey)
+// Upload stores the file through the configured storage backend and records
+// it as a new document.
+func (s *DocumentService) Upload(ctx context.Context, d *models.Document, r io.Reader) error {
+	if err := authorizeEntity(ctx, d.EntityType, d.EntityID.String()); err != nil {
+		return err
+	}
+	return s.store(ctx, d, r, s.create)
+}
+
+// UploadVersion stores a revised file as the next version of the logical
+// document that id belongs to. The new version inherits the document's
+// entity (and status, unless one is given) and supersedes the current latest.
+func (s *DocumentService) UploadVersion(ctx context.Context, id string, d *models.Document, r io.Reader) error {
+	return s.store(ctx, d, r, func(ctx context.Context, d *models.Document) error {
+		tx, err := db.Pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		// Locking the latest version serialises concurrent uploads.
+		var latestID uuid.UUID
+		var status string
+		err = tx.QueryRow(ctx, `
+			SELECT id, logical_id, version, entity_type, entity_id, status FROM documents
+			WHERE logical_id = (SELECT logical_id FROM documents WHERE id = $1)
+			  AND superseded_by IS NULL AND deleted_at IS NULL
+			FOR UPDATE
+		`, id).Scan(&latestID, &d.LogicalID, &d.Version, &d.EntityType, &d.EntityID, &status)
+		if err != nil {
+			return err
+		}
+		d.Version++
+		if d.Status == "" {
+			d.Status = status
+		}
+
+		if err := insertDocument(ctx, tx, d, true); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE documents SET superseded_by = $2, superseded_at = now() WHERE id = $1`, latestID, d.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+// store puts the file in storage and then records it with create, removing
+// the stored file again if that fails.
+func (s *DocumentService) store(ctx context.Context, d *models.Document, r io.Reader, create func(context.Context, *models.Document) error) error {
+	obj, err := storage.Default.Put(ctx, storage.NewKey("documents", d.FileName), r, d.FileSize, d.FileType)
 	if err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+	d.FileKey = obj.Key
+	d.FileURL = obj.URL
+
+	if err := create(ctx, d); err != nil {
+		// Don't leave an orphaned file behind
+		if delErr := storage.Default.Delete(ctx, obj.Key); delErr != nil {
+			fmt.Printf("Warning: Failed to remove orphaned upload %s: %v\n", obj.Key, delErr)
+		}
 		return err
 	}
+	return nil
+}
+
+// Versions lists every version of the logical document id belongs to, oldest first.
+func (s *DocumentService) Versions(ctx context.Context, id string) ([]models.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents
+		WHERE logical_id = (SELECT logical_id FROM documents WHERE id = $1) AND deleted_at IS NULL
+		ORDER BY version`
+	rows, err := db.Pool.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// 2. Delete from Uploadthing
-	if fileKey != "" {
-		if err := DeleteFromUploadthing(fileKey); err != nil {
-			// Log error but continue to delete from DB to keep it clean
-			fmt.Printf("Warning: Failed to delete from Uploadthing: %v\n", err)
+	var docs []models.Document
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
 		}
+		docs = append(docs, *d)
 	}
+	return docs, nil
+}
 
-	// 3. Delete from DB
-	query = `DELETE FROM documents WHERE id = $1`
-	_, err = db.Pool.Exec(ctx, query, id)
-	return err
+// Diff compares the OCR content of two versions of the same logical document.
+// A zero from or to means the version before to, or the latest, respectively.
+func (s *DocumentService) Diff(ctx context.Context, id string, from int, to int) (*models.DocumentDiff, error) {
+	versions, err := s.Versions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	if to == 0 {
+		to = versions[len(versions)-1].Version
+	}
+	if from == 0 {
+		from = to - 1
+	}
+
+	var a, b *models.Document
+	for i := range versions {
+		switch versions[i].Version {
+		case from:
+			a = &versions[i]
+		case to:
+			b = &versions[i]
+		}
+	}
+	if a == nil || b == nil {
+		return nil, ErrVersionNotFound
+	}
+
+	var before, after string
+	if a.Content != nil {
+		before = *a.Content
+	}
+	if b.Content != nil {
+		after = *b.Content
+	}
+	lines := textdiff.Lines(before, after)
+	added, removed := textdiff.Stats(lines)
+	return &models.DocumentDiff{
+		LogicalID:   b.LogicalID,
+		FromVersion: from,
+		ToVersion:   to,
+		Added:       added,
+		Removed:     removed,
+		Lines:       lines,
+	}, nil
+}
+
+// Delete moves the document, with all its versions, to the trash. The stored
+// files are only removed from storage when the trash is purged.
+func (s *DocumentService) Delete(ctx context.Context, id string) error {
+	result, err := db.Pool.Exec(ctx, `
+		UPDATE documents SET deleted_at = now(), deleted_by = $2
+		WHERE logical_id = (SELECT logical_id FROM documents WHERE id = $1) AND deleted_at IS NULL
+	`, id, contextUserID(ctx))
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return NotFoundError("document not found")
+	}
+	return nil
 }
 
 type DocumentUpdateInput struct {
@@ -125,30 +434,64 @@ type DocumentUpdateInput struct {
 	EntityID   *string
 }
 
+// Update changes a document version. File name and status belong to the
+// version; a new entity_type/entity_id is applied to every version so the
+// document's history stays filed together.
 func (s *DocumentService) Update(ctx context.Context, id string, input DocumentUpdateInput) error {
+	if input.EntityType != nil || input.EntityID != nil {
+		// The caller must be able to see where the document is moved to
+		d, err := s.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		entityType, entityID := d.EntityType, d.EntityID.String()
+		if input.EntityType != nil {
+			entityType = *input.EntityType
+		}
+		if input.EntityID != nil {
+			entityID = *input.EntityID
+		}
+		if err := authorizeEntity(ctx, entityType, entityID); err != nil {
+			return err
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateDocument(ctx, tx, "id = $%d", id, map[string]*string{
+		"file_name": input.FileName,
+		"status":    input.Status,
+	}); err != nil {
+		return err
+	}
+	if err := updateDocument(ctx, tx, "logical_id = (SELECT logical_id FROM documents WHERE id = $%d)", id, map[string]*string{
+		"entity_type": input.EntityType,
+		"entity_id":   input.EntityID,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// updateDocument sets the non-nil values on the rows matched by where, whose
+// single placeholder is filled with id.
+func updateDocument(ctx context.Context, tx pgx.Tx, where string, id string, values map[string]*string) error {
 	// Build dynamic query
 	query := "UPDATE documents SET "
 	args := []interface{}{}
 	argId := 1
 
-	if input.FileName != nil {
-		query += fmt.Sprintf("file_name = $%d, ", argId)
-		args = append(args, *input.FileName)
-		argId++
-	}
-	if input.Status != nil {
-		query += fmt.Sprintf("status = $%d, ", argId)
-		args = append(args, *input.Status)
-		argId++
-	}
-	if input.EntityType != nil {
-		query += fmt.Sprintf("entity_type = $%d, ", argId)
-		args = append(args, *input.EntityType)
-		argId++
-	}
-	if input.EntityID != nil {
-		query += fmt.Sprintf("entity_id = $%d, ", argId)
-		args = append(args, *input.EntityID)
+	for _, column := range []string{"file_name", "status", "entity_type", "entity_id"} {
+		v, ok := values[column]
+		if !ok || v == nil {
+			continue
+		}
+		query += fmt.Sprintf("%s = $%d, ", column, argId)
+		args = append(args, *v)
 		argId++
 	}
 
@@ -158,77 +501,76 @@ func (s *DocumentService) Update(ctx context.Context, id string, input DocumentU
 	}
 	query = query[:len(query)-2]
 
-	query += fmt.Sprintf(" WHERE id = $%d", argId)
+	query += " WHERE " + fmt.Sprintf(where, argId) + " AND deleted_at IS NULL"
 	args = append(args, id)
 
-	_, err := db.Pool.Exec(ctx, query, args...)
+	_, err := tx.Exec(ctx, query, args...)
 	return err
 }
 
-func processOCR(docID string, fileURL string, fileName string) error {
-	fmt.Printf("Starting OCR for document %s (%s)\n", docID, fileName)
-
-	// 1. Download file
-	resp, err := http.Get(fileURL)
+// Authorize loads a document and checks the caller may see its owning entity.
+func (s *DocumentService) Authorize(ctx context.Context, id string) (*models.Document, error) {
+	d, err := s.Get(ctx, id)
 	if err != nil {
-		return fmt.Errorf("failed to download file: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status code downloading file: %d", resp.StatusCode)
+	if err := authorizeEntity(ctx, d.EntityType, d.EntityID.String()); err != nil {
+		return nil, err
 	}
+	return d, nil
+}
 
-	// 2. Prepare multipart request to OCR service
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+// DownloadURLTTL is how long signed download links stay valid
+// (DOWNLOAD_URL_TTL_SECONDS, default 5 minutes).
+func DownloadURLTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("DOWNLOAD_URL_TTL_SECONDS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
 	}
+	return 5 * time.Minute
+}
 
-	_, err = io.Copy(part, resp.Body)
+func (s *DocumentService) SignedURL(ctx context.Context, d *models.Document) (string, time.Time, error) {
+	ttl := DownloadURLTTL()
+	url, err := storage.Default.SignedURL(ctx, d.FileKey, ttl)
 	if err != nil {
-		return fmt.Errorf("failed to copy file content: %w", err)
+		return "", time.Time{}, err
 	}
-	writer.Close()
+	return url, time.Now().Add(ttl), nil
+}
 
-	// 3. Send to OCR service
-	ocrURL := "http://localhost:8000/ocr" // Assuming running locally or reachable
-	// In Docker, you might need "http://ocr-service:8000/ocr"
+func (s *DocumentService) Open(ctx context.Context, d *models.Document) (io.ReadCloser, error) {
+	return storage.Default.Get(ctx, d.FileKey)
+}
 
-	req, err := http.NewRequest("POST", ocrURL, body)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+func (s *DocumentService) LogAccess(ctx context.Context, a *models.DocumentAccess) error {
+	query := `
+		INSERT INTO document_access_log (document_id, user_id, mode, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, accessed_at
+	`
+	return db.Pool.QueryRow(ctx, query, a.DocumentID, a.UserID, a.Mode, a.IPAddress, a.UserAgent).Scan(&a.ID, &a.AccessedAt)
+}
 
-	client := &http.Client{Timeout: 300 * time.Second} // Long timeout for OCR (PDFs can take time)
-	ocrResp, err := client.Do(req)
+func (s *DocumentService) ListAccessLog(ctx context.Context, documentID string) ([]models.DocumentAccess, error) {
+	query := `
+		SELECT id, document_id, user_id, mode, ip_address, user_agent, accessed_at
+		FROM document_access_log
+		WHERE document_id = $1
+		ORDER BY accessed_at DESC
+	`
+	rows, err := db.Pool.Query(ctx, query, documentID)
 	if err != nil {
-		return fmt.Errorf("failed to send request to OCR service: %w", err)
-	}
-	defer ocrResp.Body.Close()
-
-	if ocrResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("OCR service returned bad status: %d", ocrResp.StatusCode)
+		return nil, err
 	}
+	defer rows.Close()
 
-	// 4. Parse Response
-	var result struct {
-		Markdown       string  `json:"markdown"`
-		ProcessingTime float64 `json:"processing_time"`
-	}
-	if err := json.NewDecoder(ocrResp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("failed to decode OCR response: %w", err)
+	var entries []models.DocumentAccess
+	for rows.Next() {
+		var a models.DocumentAccess
+		if err := rows.Scan(&a.ID, &a.DocumentID, &a.UserID, &a.Mode, &a.IPAddress, &a.UserAgent, &a.AccessedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, a)
 	}
-
-	fmt.Printf("OCR Completed in %.2fs. Saving results...\n", result.ProcessingTime)
-
-	// 5. Update DB
-	_, err = db.Pool.Exec(context.Background(),
-		"UPDATE documents SET ocr_status = 'COMPLETED', content = $1 WHERE id = $2",
-		result.Markdown, docID)
-
-	return err
+	return entries, nil
 }
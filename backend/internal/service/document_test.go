@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// clientContext is a client portal user's request context.
+func clientContext(clientID string) context.Context {
+	ctx := context.WithValue(context.Background(), "role", "CLIENT_USER")
+	return context.WithValue(ctx, "client_id", clientID)
+}
+
+func TestDocumentCreateChecksEntity(t *testing.T) {
+	own, other := uuid.New(), uuid.New()
+	ctx := clientContext(own.String())
+	s := NewDocumentService()
+
+	tests := []struct {
+		name    string
+		create  func(d *models.Document) error
+		doc     models.Document
+		wantErr error
+	}{
+		{
+			name:    "create on another client",
+			create:  func(d *models.Document) error { return s.Create(ctx, d) },
+			doc:     models.Document{EntityType: "CLIENT", EntityID: other, FileName: "a.pdf", FileKey: "documents/a.pdf"},
+			wantErr: ErrForbidden,
+		},
+		{
+			name:    "upload to another client",
+			create:  func(d *models.Document) error { return s.Upload(ctx, d, strings.NewReader("%PDF")) },
+			doc:     models.Document{EntityType: "CLIENT", EntityID: other, FileName: "a.pdf"},
+			wantErr: ErrForbidden,
+		},
+		{
+			name:    "unknown entity type",
+			create:  func(d *models.Document) error { return s.Upload(ctx, d, strings.NewReader("%PDF")) },
+			doc:     models.Document{EntityType: "INVOICE_RUN", EntityID: own, FileName: "a.pdf"},
+			wantErr: ErrForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.doc
+			if err := tt.create(&d); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	d := models.Document{EntityType: "CLIENT", EntityID: own, FileName: "a.pdf"}
+	if e := AsError(s.Create(ctx, &d)); e == nil || e.Kind != KindValidation {
+		t.Errorf("create without file_key: error = %v, want a validation error", e)
+	}
+}
+
+func TestDocumentCreateRejectsKeyInUse(t *testing.T) {
+	requireDB(t)
+	ctx := context.WithValue(context.Background(), "role", "ADMIN")
+	s := NewDocumentService()
+	key := "documents/" + uuid.NewString() + ".pdf"
+
+	first := models.Document{EntityType: "CLIENT", EntityID: uuid.New(), FileName: "a.pdf", FileURL: "https://files.test/a.pdf", FileKey: key}
+	if err := s.Create(ctx, &first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second := models.Document{EntityType: "CLIENT", EntityID: uuid.New(), FileName: "b.pdf", FileURL: "https://files.test/a.pdf", FileKey: key}
+	if err := s.Create(ctx, &second); !errors.Is(err, ErrFileKeyInUse) {
+		t.Fatalf("second Create error = %v, want %v", err, ErrFileKeyInUse)
+	}
+}
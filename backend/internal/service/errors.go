@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/validate"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorKind classifies a service error for callers. The API turns it into
+// the response status and the code clients branch on, so kinds must not be
+// renamed.
+type ErrorKind string
+
+const (
+	KindValidation   ErrorKind = "validation_failed"
+	KindNotFound     ErrorKind = "not_found"
+	KindConflict     ErrorKind = "conflict"
+	KindForbidden    ErrorKind = "forbidden"
+	KindUnauthorized ErrorKind = "unauthorized"
+	// KindPreconditionFailed means a conditional request's condition, such
+	// as the record version it expected, no longer holds.
+	KindPreconditionFailed ErrorKind = "precondition_failed"
+)
+
+// FieldError is a problem with one input field.
+type FieldError = validate.FieldError
+
+// Error is an error callers can act on. Its message is shown to users, so it
+// must not carry internal details. Sentinels are *Error values, which keeps
+// errors.Is working, and are wrapped with fmt.Errorf("%w: ...") to add
+// detail to the message.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Fields  []FieldError
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func NotFoundError(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func ConflictError(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func ValidationError(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+func ForbiddenError(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func UnauthorizedError(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+func PreconditionFailedError(message string) *Error {
+	return &Error{Kind: KindPreconditionFailed, Message: message}
+}
+
+// AsError returns err as an *Error, or nil if it's an internal failure.
+// Wrapped sentinels take the message of the outermost error, and database
+// errors a caller can fix (missing rows, unique and foreign key violations,
+// bad input) are translated without exposing the values involved.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return &Error{Kind: e.Kind, Message: err.Error(), Fields: e.Fields}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NotFoundError("not found")
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fromPgError(pgErr)
+	}
+	return nil
+}
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation       = "23505"
+	pgForeignKeyViolation   = "23503"
+	pgNotNullViolation      = "23502"
+	pgCheckViolation        = "23514"
+	pgInvalidTextRepr       = "22P02"
+	pgInvalidDatetimeFormat = "22007"
+	pgDatetimeOutOfRange    = "22008"
+	pgStringTooLong         = "22001"
+)
+
+// pgKeyDetail matches the column list of a constraint violation's detail,
+// e.g. `Key (email)=(a@b.c) already exists.`
+var pgKeyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+func fromPgError(pgErr *pgconn.PgError) *Error {
+	field := pgErr.ColumnName
+	if m := pgKeyDetail.FindStringSubmatch(pgErr.Detail); m != nil {
+		field = m[1]
+	}
+	var fields []FieldError
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if field == "" {
+			return ConflictError("a record with the same values already exists")
+		}
+		return &Error{
+			Kind:    KindConflict,
+			Message: "a record with this " + field + " already exists",
+			Fields:  []FieldError{{Field: field, Message: "already exists"}},
+		}
+	case pgForeignKeyViolation:
+		// Inserting or updating a row that points to a missing record is the
+		// caller's mistake; deleting a record others still point to is a
+		// conflict with that data.
+		if strings.Contains(pgErr.Detail, "is still referenced") {
+			return ConflictError("the record is still in use by " + strings.ReplaceAll(pgErr.TableName, "_", " "))
+		}
+		if field != "" {
+			fields = []FieldError{{Field: field, Message: "does not exist"}}
+		}
+		return ValidationError("a referenced record does not exist", fields...)
+	case pgNotNullViolation:
+		if field != "" {
+			fields = []FieldError{{Field: field, Message: "is required"}}
+		}
+		return ValidationError("a required field is missing", fields...)
+	case pgCheckViolation:
+		return ValidationError("a value is not allowed")
+	case pgInvalidTextRepr, pgInvalidDatetimeFormat, pgDatetimeOutOfRange:
+		return ValidationError("a value has the wrong format")
+	case pgStringTooLong:
+		return ValidationError("a value is too long")
+	}
+	return nil
+}
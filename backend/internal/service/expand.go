@@ -0,0 +1,583 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Expand is a parsed ?expand= parameter: the related records to embed in a
+// response, each with the relations to expand under it in turn. For example
+// "client,assignments.talent" is {client: {}, assignments: {talent: {}}}.
+//
+// Related records are loaded with one query per relation and level, however
+// many records are being expanded, and only include what the caller may see:
+// client users get nothing that belongs to another client.
+type Expand map[string]Expand
+
+// The relations each resource can expand.
+var (
+	ProjectRelations    = Expand{"client": {}, "assignments": {"talent": {}}, "contracts": {}, "documents": {}}
+	ClientRelations     = Expand{"projects": {}, "assignments": {"project": {}, "talent": {}}, "contracts": {}, "documents": {}}
+	AssignmentRelations = Expand{"client": {}, "project": {}, "talent": {}}
+	TalentRelations     = Expand{"assignments": {"client": {}, "project": {}}, "documents": {}}
+	ContractRelations   = Expand{"client": {}, "project": {}, "talent": {}, "documents": {}}
+	InvoiceRelations    = Expand{"client": {}}
+	PaymentRelations    = Expand{"project": {}, "talent": {}}
+)
+
+// ParseExpand reads a comma-separated list of dotted relation paths, which
+// must all be in allowed.
+func ParseExpand(param string, allowed Expand) (Expand, error) {
+	e := Expand{}
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		node, allow := e, allowed
+		for _, name := range strings.Split(path, ".") {
+			next, ok := allow[name]
+			if !ok {
+				return nil, ValidationError("invalid expand", FieldError{
+					Field:   "expand",
+					Message: fmt.Sprintf("%q is not one of %s", path, strings.Join(allowed.paths(""), ", ")),
+				})
+			}
+			if node[name] == nil {
+				node[name] = Expand{}
+			}
+			node, allow = node[name], next
+		}
+	}
+	return e, nil
+}
+
+// paths lists every relation path under e, sorted.
+func (e Expand) paths(prefix string) []string {
+	var paths []string
+	for name, sub := range e {
+		paths = append(paths, prefix+name)
+		paths = append(paths, sub.paths(prefix+name+".")...)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// ExpandProjects embeds the relations in e into projects.
+func ExpandProjects(ctx context.Context, e Expand, projects ...*models.Project) error {
+	if len(e) == 0 || len(projects) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(projects))
+	clientIDs := make([]uuid.UUID, len(projects))
+	for i, p := range projects {
+		ids[i], clientIDs[i] = p.ID, p.ClientID
+	}
+
+	if _, ok := e["client"]; ok {
+		clients, err := loadClients(ctx, clientIDs)
+		if err != nil {
+			return err
+		}
+		for _, p := range projects {
+			p.Client = clients[p.ClientID]
+		}
+	}
+	if sub, ok := e["assignments"]; ok {
+		byProject, err := loadAssignments(ctx, "project_id", ids)
+		if err != nil {
+			return err
+		}
+		var all []*models.ProjectAssignment
+		for _, p := range projects {
+			p.Assignments = listOf(byProject[p.ID])
+			all = append(all, Pointers(p.Assignments)...)
+		}
+		if err := ExpandAssignments(ctx, sub, all...); err != nil {
+			return err
+		}
+	}
+	if _, ok := e["contracts"]; ok {
+		byProject, err := loadContracts(ctx, "project_id", ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range projects {
+			p.Contracts = listOf(byProject[p.ID])
+		}
+	}
+	if _, ok := e["documents"]; ok {
+		byProject, err := loadDocuments(ctx, "PROJECT", ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range projects {
+			p.Documents = listOf(byProject[p.ID])
+		}
+	}
+	return nil
+}
+
+// ExpandClients embeds the relations in e into clients.
+func ExpandClients(ctx context.Context, e Expand, clients ...*models.Client) error {
+	if len(e) == 0 || len(clients) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+
+	if _, ok := e["projects"]; ok {
+		byClient, err := loadProjects(ctx, "client_id", ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range clients {
+			c.Projects = listOf(byClient[c.ID])
+		}
+	}
+	if sub, ok := e["assignments"]; ok {
+		byClient, err := loadAssignments(ctx, "client_id", ids)
+		if err != nil {
+			return err
+		}
+		var all []*models.ProjectAssignment
+		for _, c := range clients {
+			c.Assignments = listOf(byClient[c.ID])
+			all = append(all, Pointers(c.Assignments)...)
+		}
+		if err := ExpandAssignments(ctx, sub, all...); err != nil {
+			return err
+		}
+	}
+	if _, ok := e["contracts"]; ok {
+		byClient, err := loadContracts(ctx, "client_id", ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range clients {
+			c.Contracts = listOf(byClient[c.ID])
+		}
+	}
+	if _, ok := e["documents"]; ok {
+		byClient, err := loadDocuments(ctx, "CLIENT", ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range clients {
+			c.Documents = listOf(byClient[c.ID])
+		}
+	}
+	return nil
+}
+
+// ExpandAssignments embeds the relations in e into assignments.
+func ExpandAssignments(ctx context.Context, e Expand, assignments ...*models.ProjectAssignment) error {
+	if len(e) == 0 || len(assignments) == 0 {
+		return nil
+	}
+
+	if _, ok := e["client"]; ok {
+		ids := make([]uuid.UUID, len(assignments))
+		for i, a := range assignments {
+			ids[i] = a.ClientID
+		}
+		clients, err := loadClients(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, a := range assignments {
+			a.Client = clients[a.ClientID]
+		}
+	}
+	if _, ok := e["project"]; ok {
+		ids := make([]uuid.UUID, len(assignments))
+		for i, a := range assignments {
+			ids[i] = a.ProjectID
+		}
+		projects, err := loadProjects(ctx, "id", ids)
+		if err != nil {
+			return err
+		}
+		for _, a := range assignments {
+			a.Project = only(projects[a.ProjectID])
+		}
+	}
+	if _, ok := e["talent"]; ok {
+		ids := make([]uuid.UUID, len(assignments))
+		for i, a := range assignments {
+			ids[i] = a.TalentID
+		}
+		talent, err := loadTalent(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, a := range assignments {
+			a.Talent = talent[a.TalentID]
+		}
+	}
+	return nil
+}
+
+// ExpandTalent embeds the relations in e into talent.
+func ExpandTalent(ctx context.Context, e Expand, talent ...*models.Talent) error {
+	if len(e) == 0 || len(talent) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(talent))
+	for i, t := range talent {
+		ids[i] = t.ID
+	}
+
+	if sub, ok := e["assignments"]; ok {
+		byTalent, err := loadAssignments(ctx, "talent_id", ids)
+		if err != nil {
+			return err
+		}
+		var all []*models.ProjectAssignment
+		for _, t := range talent {
+			t.Assignments = listOf(byTalent[t.ID])
+			all = append(all, Pointers(t.Assignments)...)
+		}
+		if err := ExpandAssignments(ctx, sub, all...); err != nil {
+			return err
+		}
+	}
+	if _, ok := e["documents"]; ok {
+		byTalent, err := loadDocuments(ctx, "TALENT", ids)
+		if err != nil {
+			return err
+		}
+		for _, t := range talent {
+			t.Documents = listOf(byTalent[t.ID])
+		}
+	}
+	return nil
+}
+
+// ExpandContracts embeds the relations in e into contracts. Contracts
+// without a client, talent or project get none.
+func ExpandContracts(ctx context.Context, e Expand, contracts ...*models.Contract) error {
+	if len(e) == 0 || len(contracts) == 0 {
+		return nil
+	}
+
+	if _, ok := e["client"]; ok {
+		clients, err := loadClients(ctx, optionalIDs(contracts, func(c *models.Contract) *uuid.UUID { return c.ClientID }))
+		if err != nil {
+			return err
+		}
+		for _, c := range contracts {
+			if c.ClientID != nil {
+				c.Client = clients[*c.ClientID]
+			}
+		}
+	}
+	if _, ok := e["project"]; ok {
+		projects, err := loadProjects(ctx, "id", optionalIDs(contracts, func(c *models.Contract) *uuid.UUID { return c.ProjectID }))
+		if err != nil {
+			return err
+		}
+		for _, c := range contracts {
+			if c.ProjectID != nil {
+				c.Project = only(projects[*c.ProjectID])
+			}
+		}
+	}
+	if _, ok := e["talent"]; ok {
+		talent, err := loadTalent(ctx, optionalIDs(contracts, func(c *models.Contract) *uuid.UUID { return c.TalentID }))
+		if err != nil {
+			return err
+		}
+		for _, c := range contracts {
+			if c.TalentID != nil {
+				c.Talent = talent[*c.TalentID]
+			}
+		}
+	}
+	if _, ok := e["documents"]; ok {
+		ids := make([]uuid.UUID, len(contracts))
+		for i, c := range contracts {
+			ids[i] = c.ID
+		}
+		byContract, err := loadDocuments(ctx, "CONTRACT", ids)
+		if err != nil {
+			return err
+		}
+		for _, c := range contracts {
+			c.Documents = listOf(byContract[c.ID])
+		}
+	}
+	return nil
+}
+
+// ExpandInvoices embeds the relations in e into invoices.
+func ExpandInvoices(ctx context.Context, e Expand, invoices ...*models.Invoice) error {
+	if _, ok := e["client"]; !ok || len(invoices) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.ClientID
+	}
+	clients, err := loadClients(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, inv := range invoices {
+		inv.Client = clients[inv.ClientID]
+	}
+	return nil
+}
+
+// ExpandPayments embeds the relations in e into contractor payments.
+func ExpandPayments(ctx context.Context, e Expand, payments ...*models.ContractorPayment) error {
+	if len(e) == 0 || len(payments) == 0 {
+		return nil
+	}
+
+	if _, ok := e["project"]; ok {
+		ids := make([]uuid.UUID, len(payments))
+		for i, p := range payments {
+			ids[i] = p.ProjectID
+		}
+		projects, err := loadProjects(ctx, "id", ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range payments {
+			p.Project = only(projects[p.ProjectID])
+		}
+	}
+	if _, ok := e["talent"]; ok {
+		ids := make([]uuid.UUID, len(payments))
+		for i, p := range payments {
+			ids[i] = p.TalentID
+		}
+		talent, err := loadTalent(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, p := range payments {
+			p.Talent = talent[p.TalentID]
+		}
+	}
+	return nil
+}
+
+// expandScope returns the client the caller is tied to, whose records are
+// the only ones they may see expanded, or "" for staff who see everything.
+func expandScope(ctx context.Context) (string, error) {
+	role, _ := ctx.Value("role").(string)
+	clientID, _ := ctx.Value("client_id").(string)
+	if clientID == "" && !staffRoles[role] {
+		return "", ErrForbidden
+	}
+	return clientID, nil
+}
+
+// loadRows runs query with ids as $1, adding scope (with the caller's client
+// as $2) for client users, then order, and scans each row.
+func loadRows[T any](ctx context.Context, query, scope, order string, ids []uuid.UUID, scan func(pgx.Row) (*T, error)) ([]T, error) {
+	clientID, err := expandScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{ids}
+	if clientID != "" {
+		query += " AND " + scope
+		args = append(args, clientID)
+	}
+	query += " " + order
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+func loadClients(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.Client, error) {
+	clients, err := loadRows(ctx, `SELECT `+clientColumns+` FROM clients WHERE id = ANY($1) AND deleted_at IS NULL`,
+		"id = $2", "", ids, scanClient)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Client, len(clients))
+	for i := range clients {
+		byID[clients[i].ID] = &clients[i]
+	}
+	return byID, nil
+}
+
+// loadTalent loads talent without their skills or history. Client users only
+// get talent placed with their client.
+func loadTalent(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.Talent, error) {
+	talent, err := loadRows(ctx, `SELECT `+talentColumns+` FROM talent WHERE id = ANY($1) AND deleted_at IS NULL`,
+		"id IN (SELECT talent_id FROM project_assignments WHERE client_id = $2)", "", ids, scanTalent)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Talent, len(talent))
+	for i := range talent {
+		byID[talent[i].ID] = &talent[i]
+	}
+	return byID, nil
+}
+
+// loadProjects loads the projects whose column is one of ids, grouped by it.
+// Planned roles are left out.
+func loadProjects(ctx context.Context, column string, ids []uuid.UUID) (map[uuid.UUID][]models.Project, error) {
+	projects, err := loadRows(ctx, `SELECT `+projectColumns+` FROM projects p WHERE p.`+column+` = ANY($1) AND p.deleted_at IS NULL`,
+		"p.client_id = $2", "ORDER BY p.created_at DESC", ids, scanProject)
+	if err != nil {
+		return nil, err
+	}
+	return groupBy(projects, func(p models.Project) uuid.UUID {
+		if column == "client_id" {
+			return p.ClientID
+		}
+		return p.ID
+	}), nil
+}
+
+// loadAssignments loads the assignments on live projects whose column is one
+// of ids, grouped by it.
+func loadAssignments(ctx context.Context, column string, ids []uuid.UUID) (map[uuid.UUID][]models.ProjectAssignment, error) {
+	assignments, err := loadRows(ctx, `SELECT `+assignmentColumns+` FROM project_assignments WHERE `+column+` = ANY($1)
+		AND project_id IN (SELECT id FROM projects WHERE deleted_at IS NULL)`,
+		"client_id = $2", "ORDER BY start_date DESC", ids, scanAssignment)
+	if err != nil {
+		return nil, err
+	}
+	return groupBy(assignments, func(a models.ProjectAssignment) uuid.UUID {
+		switch column {
+		case "project_id":
+			return a.ProjectID
+		case "client_id":
+			return a.ClientID
+		}
+		return a.TalentID
+	}), nil
+}
+
+// loadContracts loads the contracts whose column is one of ids, grouped by it.
+func loadContracts(ctx context.Context, column string, ids []uuid.UUID) (map[uuid.UUID][]models.Contract, error) {
+	contracts, err := loadRows(ctx, `SELECT `+contractColumns+` FROM contracts WHERE `+column+` = ANY($1) AND deleted_at IS NULL`,
+		"client_id = $2", "ORDER BY created_at DESC", ids, scanContract)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID][]models.Contract)
+	for _, c := range contracts {
+		var key *uuid.UUID
+		switch column {
+		case "project_id":
+			key = c.ProjectID
+		case "client_id":
+			key = c.ClientID
+		default:
+			key = c.TalentID
+		}
+		if key != nil {
+			byID[*key] = append(byID[*key], c)
+		}
+	}
+	return byID, nil
+}
+
+// loadDocuments loads the latest version of the documents attached to the
+// given entities, grouped by entity, redacted the way the documents API
+// returns them.
+func loadDocuments(ctx context.Context, entityType string, ids []uuid.UUID) (map[uuid.UUID][]models.Document, error) {
+	scope, scopeArgs, err := documentScope(ctx, 3)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + documentColumns + ` FROM documents d
+		WHERE upper(d.entity_type) = $1 AND d.entity_id = ANY($2)
+		AND d.deleted_at IS NULL AND d.superseded_by IS NULL AND ` + scope + `
+		ORDER BY d.uploaded_at DESC`
+	args := append([]interface{}{entityType, ids}, scopeArgs...)
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byEntity := make(map[uuid.UUID][]models.Document)
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		d.FileURL = ""
+		d.FileKey = ""
+		d.DownloadURL = fmt.Sprintf("/api/documents/%s/download", d.ID)
+		byEntity[d.EntityID] = append(byEntity[d.EntityID], *d)
+	}
+	return byEntity, rows.Err()
+}
+
+func groupBy[T any](items []T, key func(T) uuid.UUID) map[uuid.UUID][]T {
+	groups := make(map[uuid.UUID][]T)
+	for _, item := range items {
+		k := key(item)
+		groups[k] = append(groups[k], item)
+	}
+	return groups
+}
+
+// listOf returns items, or an empty list so an expanded relation with no
+// records is sent as [] rather than left out.
+func listOf[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
+
+// only returns the single record looked up by ID, or nil if the caller may
+// not see it.
+func only[T any](items []T) *T {
+	if len(items) == 0 {
+		return nil
+	}
+	return &items[0]
+}
+
+// Pointers returns pointers into items, for expanding a list in place.
+func Pointers[T any](items []T) []*T {
+	ptrs := make([]*T, len(items))
+	for i := range items {
+		ptrs[i] = &items[i]
+	}
+	return ptrs
+}
+
+func optionalIDs[T any](items []*T, id func(*T) *uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, item := range items {
+		if v := id(item); v != nil {
+			ids = append(ids, *v)
+		}
+	}
+	return ids
+}
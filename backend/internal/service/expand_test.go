@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestParseExpand(t *testing.T) {
+	e, err := ParseExpand(" client, assignments.talent ,,", ProjectRelations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.paths(""); !slices.Equal(got, []string{"assignments", "assignments.talent", "client"}) {
+		t.Errorf("paths = %v", got)
+	}
+	for _, param := range []string{"owner", "client.projects", "assignments.talent.skills"} {
+		if _, err := ParseExpand(param, ProjectRelations); AsError(err) == nil || AsError(err).Kind != KindValidation {
+			t.Errorf("ParseExpand(%q) error = %v, want a validation error", param, err)
+		}
+	}
+}
+
+func TestExpandScope(t *testing.T) {
+	clientID := uuid.NewString()
+	tests := []struct {
+		name     string
+		role     string
+		clientID string
+		want     string
+		wantErr  error
+	}{
+		{name: "staff see everything", role: "FINANCE"},
+		{name: "client user", role: "CLIENT_USER", clientID: clientID, want: clientID},
+		{name: "client admin", role: "CLIENT_ADMIN", clientID: clientID, want: clientID},
+		{name: "client user without a client", role: "CLIENT_USER", wantErr: ErrForbidden},
+		{name: "unknown role", role: "INTERN", wantErr: ErrForbidden},
+		{name: "no role", wantErr: ErrForbidden},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.role != "" {
+			ctx = context.WithValue(ctx, "role", tt.role)
+		}
+		if tt.clientID != "" {
+			ctx = context.WithValue(ctx, "client_id", tt.clientID)
+		}
+		got, err := expandScope(ctx)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expandScope = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// Refused before any query is run
+	p := &models.Project{ID: uuid.New(), ClientID: uuid.New()}
+	if err := ExpandProjects(context.Background(), Expand{"client": {}}, p); !errors.Is(err, ErrForbidden) {
+		t.Errorf("ExpandProjects without a role: error = %v, want %v", err, ErrForbidden)
+	}
+}
+
+func TestExpandClientScoping(t *testing.T) {
+	f := newTrashFixture(t)
+	assign := func(projectID, clientID, talentID string) {
+		f.exec(`
+			INSERT INTO project_assignments (project_id, client_id, talent_id, role, start_date, monthly_contractor_cost, status)
+			VALUES ($1, $2, $3, 'Engineer', '2026-01-01', 1000, 'ACTIVE')
+		`, projectID, clientID, talentID)
+	}
+	own, other := f.client(), f.client()
+	ownProject, otherProject := f.project(own), f.project(other)
+	placed, elsewhere := f.talent(), f.talent()
+	assign(ownProject, own, placed)
+	assign(otherProject, other, elsewhere)
+	ids := func(s ...string) []uuid.UUID {
+		out := make([]uuid.UUID, len(s))
+		for i, id := range s {
+			out[i] = uuid.MustParse(id)
+		}
+		return out
+	}
+	staff := context.WithValue(context.Background(), "role", "ADMIN")
+	client := clientContext(own)
+
+	t.Run("clients", func(t *testing.T) {
+		got, err := loadClients(client, ids(own, other))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[uuid.MustParse(own)] == nil {
+			t.Errorf("client user got %d clients, want only their own", len(got))
+		}
+		if got, _ := loadClients(staff, ids(own, other)); len(got) != 2 {
+			t.Errorf("staff got %d clients, want 2", len(got))
+		}
+	})
+
+	t.Run("talent", func(t *testing.T) {
+		got, err := loadTalent(client, ids(placed, elsewhere))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[uuid.MustParse(placed)] == nil {
+			t.Errorf("client user got %d talent, want only the one placed with them", len(got))
+		}
+		if got, _ := loadTalent(staff, ids(placed, elsewhere)); len(got) != 2 {
+			t.Errorf("staff got %d talent, want 2", len(got))
+		}
+	})
+
+	t.Run("projects by client", func(t *testing.T) {
+		got, err := loadProjects(client, "client_id", ids(own, other))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got[uuid.MustParse(other)]) != 0 || len(got[uuid.MustParse(own)]) != 1 {
+			t.Errorf("client user got projects %v, want only their own", got)
+		}
+	})
+
+	t.Run("nested under another record", func(t *testing.T) {
+		// A contract naming talent who was never placed with the client
+		// doesn't reveal them
+		talentID := uuid.MustParse(elsewhere)
+		c := &models.Contract{ID: uuid.New(), TalentID: &talentID}
+		if err := ExpandContracts(client, Expand{"talent": {}}, c); err != nil {
+			t.Fatal(err)
+		}
+		if c.Talent != nil {
+			t.Error("client user sees talent placed with another client")
+		}
+
+		p := &models.Project{ID: uuid.MustParse(otherProject), ClientID: uuid.MustParse(other)}
+		if err := ExpandProjects(client, Expand{"client": {}, "assignments": {"talent": {}}}, p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Client != nil || len(p.Assignments) != 0 {
+			t.Errorf("client user sees another client's project: client %v, %d assignments", p.Client, len(p.Assignments))
+		}
+	})
+}
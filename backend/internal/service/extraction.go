@@ -0,0 +1,500 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/extract"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/validate"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Extraction review states stored in document_extractions.status.
+const (
+	ExtractionProposed = "PROPOSED"
+	ExtractionAccepted = "ACCEPTED"
+	ExtractionRejected = "REJECTED"
+)
+
+var (
+	ErrOCRNotReady         = ConflictError("document has no completed OCR content")
+	ErrUnknownDocumentType = ValidationError("no extractor for this document type")
+	ErrExtractionReviewed  = ConflictError("extraction has already been reviewed")
+	ErrInvalidField        = ValidationError("invalid extracted field")
+	ErrTargetNotFound      = NotFoundError("target record not found")
+)
+
+type ExtractionService struct{}
+
+func NewExtractionService() *ExtractionService {
+	return &ExtractionService{}
+}
+
+// Propose runs the extractor for documentType over a document's OCR content
+// and stores the proposed fields for review. For contracts it also tries to
+// match a party against existing clients.
+func (s *ExtractionService) Propose(ctx context.Context, d *models.Document, documentType string) (*models.DocumentExtraction, error) {
+	if documentType == "" && strings.EqualFold(d.EntityType, "CONTRACT") {
+		documentType = "contract"
+	}
+	extractor, ok := extract.For(documentType)
+	if !ok {
+		return nil, ErrUnknownDocumentType
+	}
+	if d.OCRStatus == nil || *d.OCRStatus != OCRCompleted || d.Content == nil {
+		return nil, ErrOCRNotReady
+	}
+
+	fields := extractor.Extract(*d.Content)
+	if extractor.DocumentType() == "contract" {
+		if f, ok, err := matchClient(ctx, fields); err != nil {
+			return nil, err
+		} else if ok {
+			fields = append(fields, f)
+		}
+	}
+	if fields == nil {
+		fields = []extract.Field{}
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	e := models.DocumentExtraction{
+		DocumentID:   d.ID,
+		DocumentType: extractor.DocumentType(),
+		Fields:       fields,
+		Status:       ExtractionProposed,
+		CreatedBy:    contextUserID(ctx),
+	}
+
+	query := `
+		INSERT INTO document_extractions (document_id, document_type, fields, status, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err = db.Pool.QueryRow(ctx, query, e.DocumentID, e.DocumentType, body, e.Status, e.CreatedBy).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// matchClient looks up the contract parties in clients by company name and
+// proposes a client_id for the first one found.
+func matchClient(ctx context.Context, fields []extract.Field) (extract.Field, bool, error) {
+	for _, f := range fields {
+		if f.Name != "party_a" && f.Name != "party_b" {
+			continue
+		}
+		var id uuid.UUID
+		var exact bool
+		err := db.Pool.QueryRow(ctx, `
+			SELECT id, lower(company_name) = lower($1) FROM clients
+			WHERE deleted_at IS NULL
+			  AND (company_name ILIKE $1 OR $1 ILIKE '%' || company_name || '%')
+			ORDER BY lower(company_name) = lower($1) DESC, length(company_name) DESC
+			LIMIT 1
+		`, f.Value).Scan(&id, &exact)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return extract.Field{}, false, err
+		}
+		confidence := 0.6
+		if exact {
+			confidence = 0.9
+		}
+		return extract.Field{Name: "client_id", Value: id.String(), Confidence: confidence, Source: f.Value}, true, nil
+	}
+	return extract.Field{}, false, nil
+}
+
+func (s *ExtractionService) ListByDocument(ctx context.Context, documentID string) ([]models.DocumentExtraction, error) {
+	query := `
+		SELECT id, document_id, document_type, fields, status, target_type, target_id, created_by, created_at, reviewed_by, reviewed_at
+		FROM document_extractions
+		WHERE document_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := db.Pool.Query(ctx, query, documentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var extractions []models.DocumentExtraction
+	for rows.Next() {
+		e, err := scanExtraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		extractions = append(extractions, *e)
+	}
+	return extractions, nil
+}
+
+func (s *ExtractionService) Get(ctx context.Context, id string) (*models.DocumentExtraction, error) {
+	query := `
+		SELECT id, document_id, document_type, fields, status, target_type, target_id, created_by, created_at, reviewed_by, reviewed_at
+		FROM document_extractions
+		WHERE id = $1
+	`
+	return scanExtraction(db.Pool.QueryRow(ctx, query, id))
+}
+
+func scanExtraction(row pgx.Row) (*models.DocumentExtraction, error) {
+	var e models.DocumentExtraction
+	var fields []byte
+	err := row.Scan(&e.ID, &e.DocumentID, &e.DocumentType, &fields, &e.Status, &e.TargetType, &e.TargetID, &e.CreatedBy, &e.CreatedAt, &e.ReviewedBy, &e.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(fields, &e.Fields); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+type ExtractionAcceptInput struct {
+	TargetID *string           `json:"target_id"` // Existing contract/expense to update instead of creating one
+	Fields   map[string]string `json:"fields"`    // Reviewer corrections, by field name
+}
+
+// Accept applies an extraction (with any reviewer corrections) to a contract
+// or expense linked to the document, creating one unless a target is given.
+func (s *ExtractionService) Accept(ctx context.Context, id string, input ExtractionAcceptInput) (*models.DocumentExtraction, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	e, err := lockExtraction(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, f := range e.Fields {
+		values[f.Name] = f.Value
+	}
+	for name, v := range input.Fields {
+		values[name] = v
+	}
+
+	var entityType string
+	var entityID uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT upper(entity_type), entity_id FROM documents WHERE id = $1`, e.DocumentID).Scan(&entityType, &entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetType string
+	var targetID uuid.UUID
+	switch e.DocumentType {
+	case "contract":
+		targetType = "CONTRACT"
+		// A document filed against a contract describes that contract.
+		if input.TargetID == nil && entityType == "CONTRACT" {
+			id := entityID.String()
+			input.TargetID = &id
+		}
+		targetID, err = applyContract(ctx, tx, e.DocumentID, input.TargetID, values, entityType, entityID)
+	case "invoice":
+		targetType = "EXPENSE"
+		targetID, err = applyExpense(ctx, tx, e.DocumentID, input.TargetID, values)
+	default:
+		return nil, ErrUnknownDocumentType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := markReviewed(ctx, tx, e, ExtractionAccepted, &targetType, &targetID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reject marks an extraction as reviewed without applying it.
+func (s *ExtractionService) Reject(ctx context.Context, id string) (*models.DocumentExtraction, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	e, err := lockExtraction(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := markReviewed(ctx, tx, e, ExtractionRejected, nil, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func lockExtraction(ctx context.Context, tx pgx.Tx, id string) (*models.DocumentExtraction, error) {
+	query := `
+		SELECT id, document_id, document_type, fields, status, target_type, target_id, created_by, created_at, reviewed_by, reviewed_at
+		FROM document_extractions
+		WHERE id = $1
+		FOR UPDATE
+	`
+	e, err := scanExtraction(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+	if e.Status != ExtractionProposed {
+		return nil, ErrExtractionReviewed
+	}
+	return e, nil
+}
+
+func markReviewed(ctx context.Context, tx pgx.Tx, e *models.DocumentExtraction, status string, targetType *string, targetID *uuid.UUID) error {
+	reviewer := contextUserID(ctx)
+	err := tx.QueryRow(ctx, `
+		UPDATE document_extractions
+		SET status = $2, target_type = $3, target_id = $4, reviewed_by = $5, reviewed_at = now()
+		WHERE id = $1
+		RETURNING reviewed_at
+	`, e.ID, status, targetType, targetID, reviewer).Scan(&e.ReviewedAt)
+	if err != nil {
+		return err
+	}
+	e.Status = status
+	e.TargetType = targetType
+	e.TargetID = targetID
+	e.ReviewedBy = reviewer
+	return nil
+}
+
+// applyContract writes extracted values to a contract. Only fields that were
+// extracted (or supplied by the reviewer) are changed on an existing one.
+func applyContract(ctx context.Context, tx pgx.Tx, documentID uuid.UUID, targetID *string, v map[string]string, entityType string, entityID uuid.UUID) (uuid.UUID, error) {
+	contractType := optString(strings.ToUpper(v["contract_type"]))
+	startDate, err := optDate(v["start_date"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: start_date: %v", ErrInvalidField, err)
+	}
+	endDate, err := optDate(v["end_date"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: end_date: %v", ErrInvalidField, err)
+	}
+	notice, err := optInt(v["notice_period_days"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: notice_period_days: %v", ErrInvalidField, err)
+	}
+	rate, err := optFloat(v["rate"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: rate: %v", ErrInvalidField, err)
+	}
+	ratePeriod := optString(strings.ToUpper(v["rate_period"]))
+	currency := optString(strings.ToUpper(v["currency"]))
+
+	// Go through the contract service so extracted terms get the same checks
+	// as ones typed in, and signed contracts stay untouched.
+	contracts := NewContractService()
+	if targetID != nil {
+		c, err := contracts.update(ctx, tx, *targetID, ContractUpdateInput{
+			Type:         contractType,
+			StartDate:    startDate,
+			EndDate:      endDate,
+			NoticePeriod: notice,
+			Rate:         rate,
+			RatePeriod:   ratePeriod,
+			Currency:     currency,
+		})
+		if errors.Is(err, ErrContractNotFound) {
+			return uuid.Nil, fmt.Errorf("contract: %w", ErrTargetNotFound)
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+		_, err = tx.Exec(ctx, `UPDATE contracts SET document_id = $2 WHERE id = $1`, c.ID, documentID)
+		return c.ID, err
+	}
+
+	if contractType == nil {
+		return uuid.Nil, fmt.Errorf("%w: contract_type is required", ErrInvalidField)
+	}
+
+	c := &models.Contract{
+		Type:       *contractType,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Rate:       rate,
+		RatePeriod: ratePeriod,
+		Currency:   currency,
+		DocumentID: &documentID,
+	}
+	if notice != nil {
+		c.NoticePeriod = *notice
+	}
+	if clientID := optString(v["client_id"]); clientID != nil {
+		id, err := uuid.Parse(*clientID)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%w: client_id: %v", ErrInvalidField, err)
+		}
+		c.ClientID = &id
+	}
+
+	// Link the new contract to whatever the document is filed against.
+	switch entityType {
+	case "CLIENT":
+		c.ClientID = &entityID
+	case "PROJECT":
+		c.ProjectID = &entityID
+		if c.ClientID == nil {
+			var clientID uuid.UUID
+			if err := tx.QueryRow(ctx, `SELECT client_id FROM projects WHERE id = $1`, entityID).Scan(&clientID); err == nil {
+				c.ClientID = &clientID
+			}
+		}
+	case "TALENT":
+		c.TalentID = &entityID
+	}
+
+	if err := contracts.create(ctx, tx, c); err != nil {
+		return uuid.Nil, err
+	}
+	return c.ID, nil
+}
+
+// applyExpense books a vendor invoice as an expense.
+func applyExpense(ctx context.Context, tx pgx.Tx, documentID uuid.UUID, targetID *string, v map[string]string) (uuid.UUID, error) {
+	amount, err := optFloat(v["amount"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: amount: %v", ErrInvalidField, err)
+	}
+	date, err := optDate(v["invoice_date"])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invoice_date: %v", ErrInvalidField, err)
+	}
+	currency := optString(strings.ToUpper(v["currency"]))
+	if currency != nil {
+		if msg := validate.Value(*currency, "currency"); msg != "" {
+			return uuid.Nil, fmt.Errorf("%w: currency %s", ErrInvalidField, msg)
+		}
+	}
+
+	description := v["description"]
+	if description == "" {
+		description = "Invoice"
+		if n := v["invoice_number"]; n != "" {
+			description += " " + n
+		}
+		if vendor := v["vendor"]; vendor != "" {
+			description += " from " + vendor
+		}
+	}
+	category := v["category"]
+	if category == "" {
+		category = "Vendor Invoice"
+	}
+
+	if targetID != nil {
+		var id uuid.UUID
+		err := tx.QueryRow(ctx, `
+			UPDATE expenses SET
+				amount = COALESCE($2, amount),
+				date = COALESCE($3, date),
+				document_id = $4,
+				currency = COALESCE($5, currency)
+			WHERE id = $1
+			RETURNING id
+		`, *targetID, amount, date, documentID, currency).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("expense: %w", ErrTargetNotFound)
+		}
+		return id, err
+	}
+
+	if amount == nil {
+		return uuid.Nil, fmt.Errorf("%w: amount is required", ErrInvalidField)
+	}
+	if date == nil {
+		today := time.Now()
+		date = &today
+	}
+
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO expenses (description, amount, currency, category, date, document_id)
+		VALUES ($1, $2, COALESCE($3, 'USD'), $4, $5, $6)
+		RETURNING id
+	`, description, *amount, currency, category, *date, documentID).Scan(&id)
+	return id, err
+}
+
+// contextUserID returns the authenticated user from the request context, if any.
+func contextUserID(ctx context.Context) *uuid.UUID {
+	userID, ok := ctx.Value("user_id").(string)
+	if !ok {
+		return nil
+	}
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+func optString(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optDate(s string) (*time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func optInt(s string) (*int, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func optFloat(s string) (*float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
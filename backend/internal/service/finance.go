@@ -87,7 +87,7 @@ func (s *FinanceService) CreateBudget(ctx context.Context, b models.Budget) (*mo
 // EXPENSES
 
 func (s *FinanceService) ListExpenses(ctx context.Context) ([]models.Expense, error) {
-	rows, err := db.Pool.Query(ctx, "SELECT id, description, amount, category, date, budget_id, created_at FROM expenses ORDER BY date DESC")
+	rows, err := db.Pool.Query(ctx, "SELECT id, description, amount, currency, category, date, budget_id, document_id, created_at FROM expenses ORDER BY date DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +97,7 @@ func (s *FinanceService) ListExpenses(ctx context.Context) ([]models.Expense, er
 	for rows.Next() {
 		var e models.Expense
 		var date time.Time
-		if err := rows.Scan(&e.ID, &e.Description, &e.Amount, &e.Category, &date, &e.BudgetID, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.Description, &e.Amount, &e.Currency, &e.Category, &date, &e.BudgetID, &e.DocumentID, &e.CreatedAt); err != nil {
 			return nil, err
 		}
 		e.Date = date.Format("2006-01-02")
@@ -107,8 +107,11 @@ func (s *FinanceService) ListExpenses(ctx context.Context) ([]models.Expense, er
 }
 
 func (s *FinanceService) CreateExpense(ctx context.Context, e models.Expense) (*models.Expense, error) {
-	query := `INSERT INTO expenses (description, amount, category, date, budget_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
-	err := db.Pool.QueryRow(ctx, query, e.Description, e.Amount, e.Category, e.Date, e.BudgetID).Scan(&e.ID, &e.CreatedAt)
+	if e.Currency == "" {
+		e.Currency = "USD"
+	}
+	query := `INSERT INTO expenses (description, amount, currency, category, date, budget_id, document_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	err := db.Pool.QueryRow(ctx, query, e.Description, e.Amount, e.Currency, e.Category, e.Date, e.BudgetID, e.DocumentID).Scan(&e.ID, &e.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
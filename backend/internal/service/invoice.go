@@ -2,20 +2,59 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/notify"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrInvoiceNotFound = NotFoundError("invoice not found")
+
 type InvoiceService struct{}
 
 func NewInvoiceService() *InvoiceService {
 	return &InvoiceService{}
 }
 
-func (s *InvoiceService) List(ctx context.Context) ([]models.Invoice, error) {
-	query := `SELECT id, client_id, billing_month, total_amount, currency, status::text, xero_invoice_id, created_at FROM invoices`
-	rows, err := db.Pool.Query(ctx, query)
+// InvoiceList is how GET /api/invoices may be sorted and filtered.
+var InvoiceList = listquery.Spec[models.Invoice]{
+	Sorts: map[string]listquery.Sort[models.Invoice]{
+		"created_at":    {Column: "created_at", Type: "timestamp", Value: func(i models.Invoice) string { return listquery.Time(i.CreatedAt) }},
+		"billing_month": {Column: "billing_month", Type: "text", Value: func(i models.Invoice) string { return i.BillingMonth }},
+		"total_amount":  {Column: "total_amount", Type: "numeric", Value: func(i models.Invoice) string { return listquery.Number(i.TotalAmount) }},
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]listquery.Filter{
+		"client_id":          {Column: "client_id", Rules: "uuid"},
+		"status":             {Column: "status", Rules: "enum=invoice_status"},
+		"billing_month_from": {Column: "billing_month", Op: ">=", Rules: "month"},
+		"billing_month_to":   {Column: "billing_month", Op: "<=", Rules: "month"},
+	},
+	ID: func(i models.Invoice) uuid.UUID { return i.ID },
+}
+
+// List returns a page of invoices, without line items. Client users only
+// see their own client's.
+func (s *InvoiceService) List(ctx context.Context, q *listquery.Query[models.Invoice]) (*listquery.Page[models.Invoice], error) {
+	if clientID, _ := ctx.Value("client_id").(string); clientID != "" {
+		q.Where("client_id", clientID)
+	}
+	where, args := q.Filter(" WHERE TRUE", nil)
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM invoices`+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query, args := q.Page(`SELECT id, invoice_number, client_id, billing_month, due_date, total_amount, currency, status::text, xero_invoice_id, paid_at, created_at FROM invoices`+where, args)
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -25,16 +64,58 @@ func (s *InvoiceService) List(ctx context.Context) ([]models.Invoice, error) {
 	for rows.Next() {
 		var i models.Invoice
 		err := rows.Scan(
-			&i.ID, &i.ClientID, &i.BillingMonth, &i.TotalAmount, &i.Currency, &i.Status, &i.XeroInvoiceID, &i.CreatedAt,
+			&i.ID, &i.InvoiceNumber, &i.ClientID, &i.BillingMonth, &i.DueDate, &i.TotalAmount, &i.Currency, &i.Status, &i.XeroInvoiceID, &i.PaidAt, &i.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		invoices = append(invoices, i)
 	}
-	// MVP: Not fetching line items in list for performance/simplicity
-	// Could implement Get method for details
-	return invoices, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.Result(invoices, total), nil
+}
+
+// Get returns an invoice with its line items.
+func (s *InvoiceService) Get(ctx context.Context, id string) (*models.Invoice, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+
+	var i models.Invoice
+	err := db.Pool.QueryRow(ctx, `
+		SELECT id, invoice_number, client_id, billing_month, due_date, total_amount, currency, status::text, xero_invoice_id, paid_at, created_at
+		FROM invoices WHERE id = $1
+	`, id).Scan(&i.ID, &i.InvoiceNumber, &i.ClientID, &i.BillingMonth, &i.DueDate, &i.TotalAmount, &i.Currency, &i.Status, &i.XeroInvoiceID, &i.PaidAt, &i.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT li.id, li.invoice_id, li.project_id, p.name, COALESCE(li.description, ''), li.amount
+		FROM invoice_line_items li
+		LEFT JOIN projects p ON p.id = li.project_id
+		WHERE li.invoice_id = $1
+		ORDER BY p.name NULLS LAST, li.description
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	i.LineItems = []models.InvoiceLineItem{}
+	for rows.Next() {
+		var item models.InvoiceLineItem
+		if err := rows.Scan(&item.ID, &item.InvoiceID, &item.ProjectID, &item.ProjectName, &item.Description, &item.Amount); err != nil {
+			return nil, err
+		}
+		i.LineItems = append(i.LineItems, item)
+	}
+	return &i, rows.Err()
 }
 
 func (s *InvoiceService) Create(ctx context.Context, i *models.Invoice) error {
@@ -45,10 +126,11 @@ func (s *InvoiceService) Create(ctx context.Context, i *models.Invoice) error {
 	}
 	defer tx.Rollback(ctx)
 
+	// Without an explicit due date the invoice falls due after our payment terms
 	query := `
-		INSERT INTO invoices (client_id, billing_month, total_amount, currency, status)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at
+		INSERT INTO invoices (client_id, billing_month, total_amount, currency, status, due_date)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, CURRENT_DATE + $7::int))
+		RETURNING id, invoice_number, due_date, created_at
 	`
 	// defaults
 	if i.Status == "" {
@@ -56,8 +138,8 @@ func (s *InvoiceService) Create(ctx context.Context, i *models.Invoice) error {
 	}
 
 	err = tx.QueryRow(ctx, query,
-		i.ClientID, i.BillingMonth, i.TotalAmount, i.Currency, i.Status,
-	).Scan(&i.ID, &i.CreatedAt)
+		i.ClientID, i.BillingMonth, i.TotalAmount, i.Currency, i.Status, i.DueDate, LoadCompanyProfile().PaymentTermsDays,
+	).Scan(&i.ID, &i.InvoiceNumber, &i.DueDate, &i.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -81,3 +163,283 @@ func (s *InvoiceService) Create(ctx context.Context, i *models.Invoice) error {
 
 	return tx.Commit(ctx)
 }
+
+var (
+	ErrInvoiceNotSendable = ConflictError("only draft, sent or overdue invoices can be sent")
+	ErrInvoiceNotPayable  = ConflictError("only sent or overdue invoices can be marked paid")
+	ErrNoRecipients       = ValidationError("the client has no contact or portal user with an email address")
+)
+
+// Send emails the invoice PDF to the client's primary contact and to its
+// portal users who haven't opted out, and marks a draft as SENT. Sending a
+// sent invoice again re-sends the email.
+func (s *InvoiceService) Send(ctx context.Context, id string) (*models.Invoice, error) {
+	content, inv, err := s.RenderPDF(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch inv.Status {
+	case "DRAFT", "SENT", "OVERDUE":
+	default:
+		return nil, ErrInvoiceNotSendable
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE invoices SET status = CASE WHEN status = 'DRAFT' THEN 'SENT'::invoice_status ELSE status END, sent_at = now()
+		WHERE id = $1
+		RETURNING status::text
+	`, id).Scan(&inv.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	company := LoadCompanyProfile()
+	due := inv.CreatedAt.AddDate(0, 0, company.PaymentTermsDays)
+	if inv.DueDate != nil {
+		due = *inv.DueDate
+	}
+	data := func(contactName string) map[string]string {
+		return map[string]string{
+			"Company":       company.Name,
+			"ContactName":   contactName,
+			"InvoiceNumber": inv.InvoiceNumber,
+			"BillingPeriod": billingPeriod(inv.BillingMonth),
+			"Amount":        formatMoney(inv.TotalAmount, inv.Currency),
+			"DueDate":       due.Format("2 January 2006"),
+		}
+	}
+	attachment := []notify.Attachment{{Name: inv.InvoiceNumber + ".pdf", ContentType: "application/pdf", Data: content}}
+
+	sent := 0
+	var contactName, contactEmail string
+	err = tx.QueryRow(ctx, `
+		SELECT first_name, email FROM client_contacts
+		WHERE client_id = $1 AND COALESCE(email, '') <> ''
+		ORDER BY is_primary DESC, created_at LIMIT 1
+	`, inv.ClientID).Scan(&contactName, &contactEmail)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if contactEmail != "" {
+		if _, err := EnqueueEmail(ctx, tx, Email{Kind: NotifyInvoiceSent, To: contactEmail, Data: data(contactName), Attachments: attachment}); err != nil {
+			return nil, err
+		}
+		sent++
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id, email FROM users WHERE client_id = $1 AND lower(email) <> lower($2) AND disabled_at IS NULL AND NOT service_account`, inv.ClientID, contactEmail)
+	if err != nil {
+		return nil, err
+	}
+	type recipient struct {
+		id    uuid.UUID
+		email string
+	}
+	var users []recipient
+	for rows.Next() {
+		var u recipient
+		if err := rows.Scan(&u.id, &u.email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		ok, err := EnqueueEmail(ctx, tx, Email{Kind: NotifyInvoiceSent, To: u.email, UserID: &u.id, Data: data(""), Attachments: attachment})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	if sent == 0 {
+		return nil, ErrNoRecipients
+	}
+	if err := events.Publish(ctx, tx, events.InvoiceSent{Invoice: invoiceEvent(inv)}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// MarkPaid records that a sent invoice was paid. paidAt defaults to now; pass
+// it when recording a payment that arrived earlier.
+func (s *InvoiceService) MarkPaid(ctx context.Context, id string, paidAt *time.Time) (*models.Invoice, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status::text FROM invoices WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != "SENT" && status != "OVERDUE" {
+		return nil, ErrInvoiceNotPayable
+	}
+	if _, err := tx.Exec(ctx, `UPDATE invoices SET status = 'PAID', paid_at = COALESCE($2, now()) WHERE id = $1`, id, paidAt); err != nil {
+		return nil, err
+	}
+
+	var inv models.Invoice
+	err = tx.QueryRow(ctx, `
+		SELECT id, invoice_number, client_id, billing_month, due_date, total_amount, currency, status::text, xero_invoice_id, paid_at, created_at
+		FROM invoices WHERE id = $1
+	`, id).Scan(&inv.ID, &inv.InvoiceNumber, &inv.ClientID, &inv.BillingMonth, &inv.DueDate, &inv.TotalAmount, &inv.Currency, &inv.Status, &inv.XeroInvoiceID, &inv.PaidAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Publish(ctx, tx, events.InvoicePaid{Invoice: invoiceEvent(&inv)}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// MarkOverdue moves sent invoices past their due date to OVERDUE and
+// returns how many changed. Invoices from before due dates were stored fall
+// due after the payment terms.
+func (s *InvoiceService) MarkOverdue(ctx context.Context) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE invoices SET status = 'OVERDUE'
+		WHERE status = 'SENT' AND COALESCE(due_date, created_at::date + $1::int) < CURRENT_DATE
+		RETURNING id, invoice_number, client_id, billing_month, due_date, total_amount, currency, status::text, xero_invoice_id, paid_at, created_at
+	`, LoadCompanyProfile().PaymentTermsDays)
+	if err != nil {
+		return 0, err
+	}
+	var overdue []models.Invoice
+	for rows.Next() {
+		var i models.Invoice
+		if err := rows.Scan(&i.ID, &i.InvoiceNumber, &i.ClientID, &i.BillingMonth, &i.DueDate, &i.TotalAmount, &i.Currency, &i.Status, &i.XeroInvoiceID, &i.PaidAt, &i.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		overdue = append(overdue, i)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for idx := range overdue {
+		if err := events.Publish(ctx, tx, events.InvoiceOverdue{Invoice: invoiceEvent(&overdue[idx])}); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(overdue), nil
+}
+
+// DraftMonthly creates a DRAFT invoice for billingMonth ("2006-01") for each
+// client with running assignments that has no invoice for that month yet,
+// and returns the invoices created. Each assignment bills its full monthly
+// client rate; finance adjusts part months before sending.
+func (s *InvoiceService) DraftMonthly(ctx context.Context, billingMonth string) ([]models.Invoice, error) {
+	start, err := time.Parse("2006-01", billingMonth)
+	if err != nil {
+		return nil, ValidationError(fmt.Sprintf("invalid billing month %q", billingMonth), FieldError{Field: "billing_month", Message: "must be YYYY-MM"})
+	}
+	end := start.AddDate(0, 1, -1)
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT p.client_id, COALESCE(c.billing_currency, 'USD'), p.id,
+			p.name || ' - ' || a.role || COALESCE(' (' || t.first_name || ' ' || t.last_name || ')', ''),
+			a.monthly_client_rate
+		FROM project_assignments a
+		JOIN projects p ON p.id = a.project_id AND p.deleted_at IS NULL
+		JOIN clients c ON c.id = p.client_id AND c.deleted_at IS NULL
+		LEFT JOIN talent t ON t.id = a.talent_id
+		WHERE a.status IN ('TRIAL', 'ACTIVE', 'ENDING')
+		  AND a.start_date <= $2 AND a.monthly_client_rate > 0
+		  AND NOT EXISTS (SELECT 1 FROM invoices i WHERE i.client_id = p.client_id AND i.billing_month = $1)
+		ORDER BY c.company_name, p.name, a.start_date
+	`, billingMonth, end)
+	if err != nil {
+		return nil, err
+	}
+	var drafts []*models.Invoice
+	byClient := map[uuid.UUID]*models.Invoice{}
+	for rows.Next() {
+		var clientID uuid.UUID
+		var currency, description string
+		var item models.InvoiceLineItem
+		var projectID uuid.UUID
+		if err := rows.Scan(&clientID, &currency, &projectID, &description, &item.Amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.ProjectID = &projectID
+		item.Description = description
+
+		inv, ok := byClient[clientID]
+		if !ok {
+			inv = &models.Invoice{ClientID: clientID, BillingMonth: billingMonth, Currency: currency, Status: "DRAFT"}
+			byClient[clientID] = inv
+			drafts = append(drafts, inv)
+		}
+		inv.LineItems = append(inv.LineItems, item)
+		inv.TotalAmount += item.Amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	created := []models.Invoice{}
+	for _, inv := range drafts {
+		if err := s.Create(ctx, inv); err != nil {
+			return created, fmt.Errorf("draft invoice for client %s: %w", inv.ClientID, err)
+		}
+		created = append(created, *inv)
+	}
+	return created, nil
+}
+
+// invoiceEvent is the invoice as carried by invoice events.
+func invoiceEvent(inv *models.Invoice) events.Invoice {
+	return events.Invoice{
+		InvoiceID:     inv.ID,
+		InvoiceNumber: inv.InvoiceNumber,
+		ClientID:      inv.ClientID,
+		BillingMonth:  inv.BillingMonth,
+		TotalAmount:   inv.TotalAmount,
+		Currency:      inv.Currency,
+		Status:        inv.Status,
+		DueDate:       inv.DueDate,
+		PaidAt:        inv.PaidAt,
+	}
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/pdf"
+	"github.com/jackc/pgx/v5"
+)
+
+const invoiceMargin = 50
+
+// invoiceBillTo is the client side of an invoice.
+type invoiceBillTo struct {
+	CompanyName  string
+	Address      []string
+	Country      string
+	TaxID        string
+	ContactName  string
+	ContactEmail string
+}
+
+// RenderPDF renders an invoice as a PDF, returning it with the invoice.
+func (s *InvoiceService) RenderPDF(ctx context.Context, id string) ([]byte, *models.Invoice, error) {
+	inv, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	billTo, err := loadBillTo(ctx, inv.ClientID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return renderInvoicePDF(LoadCompanyProfile(), inv, billTo, time.Now()), inv, nil
+}
+
+func loadBillTo(ctx context.Context, clientID string) (*invoiceBillTo, error) {
+	var b invoiceBillTo
+	var address, country, taxID *string
+	err := db.Pool.QueryRow(ctx, `SELECT company_name, billing_address, country, tax_id FROM clients WHERE id = $1`, clientID).
+		Scan(&b.CompanyName, &address, &country, &taxID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// client_id is nullable; render with an empty billing block
+		return &b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if address != nil {
+		for _, line := range strings.Split(*address, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				b.Address = append(b.Address, line)
+			}
+		}
+	}
+	b.Country, b.TaxID = deref(country), deref(taxID)
+
+	var first, last string
+	var email *string
+	err = db.Pool.QueryRow(ctx, `
+		SELECT first_name, last_name, email FROM client_contacts
+		WHERE client_id = $1 ORDER BY is_primary DESC, created_at LIMIT 1
+	`, clientID).Scan(&first, &last, &email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	b.ContactName = strings.TrimSpace(first + " " + last)
+	b.ContactEmail = deref(email)
+	return &b, nil
+}
+
+func renderInvoicePDF(company CompanyProfile, inv *models.Invoice, billTo *invoiceBillTo, now time.Time) []byte {
+	brand, err := pdf.ParseHex(company.BrandColor)
+	if err != nil {
+		brand = pdf.Black
+	}
+	right := pdf.PageWidth - invoiceMargin
+
+	doc := pdf.New()
+	doc.Title = "Invoice " + inv.InvoiceNumber
+	doc.Author = company.Name
+	f := &pdf.Flow{Doc: doc, Margin: invoiceMargin}
+	// Every page gets the brand band along the top and a footer
+	f.Footer = func(d *pdf.Document, page int) {
+		d.Rect(0, 0, pdf.PageWidth, 8, brand)
+		footer := company.Name
+		if company.RegistrationNumber != "" {
+			footer += " · Registration " + company.RegistrationNumber
+		}
+		d.Text(invoiceMargin, pdf.PageHeight-28, pdf.Helvetica, 8, pdf.Gray, footer)
+		d.TextRight(right, pdf.PageHeight-28, pdf.Helvetica, 8, pdf.Gray, fmt.Sprintf("%s · Page %d", inv.InvoiceNumber, page))
+	}
+	f.NewPage()
+
+	// Header: our details on the left, invoice details on the right
+	y := f.Y + 18
+	doc.Text(invoiceMargin, y, pdf.HelveticaBold, 18, brand, company.Name)
+	doc.TextRight(right, y, pdf.HelveticaBold, 24, brand, "INVOICE")
+	y += 8
+	var ours []string
+	ours = append(ours, company.Address...)
+	for _, v := range []string{company.Email, company.Phone, company.Website} {
+		if v != "" {
+			ours = append(ours, v)
+		}
+	}
+	if company.TaxID != "" {
+		ours = append(ours, "Tax ID: "+company.TaxID)
+	}
+	leftY := y
+	for _, line := range ours {
+		leftY += 12
+		doc.Text(invoiceMargin, leftY, pdf.Helvetica, 9, pdf.Gray, line)
+	}
+
+	issued := inv.CreatedAt
+	due := issued.AddDate(0, 0, company.PaymentTermsDays)
+	if inv.DueDate != nil {
+		due = *inv.DueDate
+	}
+	details := [][2]string{
+		{"Invoice number", inv.InvoiceNumber},
+		{"Issue date", issued.Format("2 January 2006")},
+		{"Due date", due.Format("2 January 2006")},
+		{"Billing period", billingPeriod(inv.BillingMonth)},
+	}
+	if inv.Status == "PAID" {
+		details = append(details, [2]string{"Status", "Paid"})
+	} else if inv.Status != "DRAFT" && now.After(due.AddDate(0, 0, 1)) {
+		details = append(details, [2]string{"Status", "Overdue"})
+	}
+	rightY := y
+	for _, d := range details {
+		rightY += 13
+		doc.TextRight(right-110, rightY, pdf.Helvetica, 9, pdf.Gray, d[0])
+		doc.TextRight(right, rightY, pdf.HelveticaBold, 9, pdf.Black, d[1])
+	}
+	f.Y = math.Max(leftY, rightY) + 30
+
+	// Bill to
+	doc.Text(invoiceMargin, f.Y, pdf.HelveticaBold, 8, pdf.Gray, "BILL TO")
+	f.Y += 4
+	f.Paragraph(pdf.HelveticaBold, 11, pdf.Black, 0, billTo.CompanyName)
+	var theirs []string
+	theirs = append(theirs, billTo.Address...)
+	if billTo.Country != "" {
+		theirs = append(theirs, billTo.Country)
+	}
+	if billTo.TaxID != "" {
+		theirs = append(theirs, "Tax ID: "+billTo.TaxID)
+	}
+	if billTo.ContactName != "" {
+		attn := "Attn: " + billTo.ContactName
+		if billTo.ContactEmail != "" {
+			attn += " <" + billTo.ContactEmail + ">"
+		}
+		theirs = append(theirs, attn)
+	}
+	for _, line := range theirs {
+		f.Paragraph(pdf.Helvetica, 9, pdf.Black, 0, line)
+	}
+	f.Space(24)
+
+	// Line items
+	amountX := right - 8
+	projectX := invoiceMargin + 8 + f.Width()*0.55
+	descWidth := projectX - invoiceMargin - 24
+	projectWidth := amountX - 90 - projectX
+	header := func() {
+		doc.Rect(invoiceMargin, f.Y, f.Width(), 20, brand)
+		doc.Text(invoiceMargin+8, f.Y+13.5, pdf.HelveticaBold, 9, pdf.White, "Description")
+		doc.Text(projectX, f.Y+13.5, pdf.HelveticaBold, 9, pdf.White, "Project")
+		doc.TextRight(amountX, f.Y+13.5, pdf.HelveticaBold, 9, pdf.White, "Amount ("+inv.Currency+")")
+		f.Y += 20
+	}
+	header()
+
+	var subtotal float64
+	for n, item := range inv.LineItems {
+		desc := pdf.Wrap(pdf.Helvetica, 9, item.Description, descWidth)
+		project := pdf.Wrap(pdf.Helvetica, 9, deref(item.ProjectName), projectWidth)
+		lines := len(desc)
+		if len(project) > lines {
+			lines = len(project)
+		}
+		h := float64(lines)*12 + 10
+		if f.Y+h > pdf.PageHeight-invoiceMargin {
+			f.NewPage()
+			header()
+		}
+		if n%2 == 1 {
+			doc.Rect(invoiceMargin, f.Y, f.Width(), h, pdf.LightGray)
+		}
+		for i, line := range desc {
+			doc.Text(invoiceMargin+8, f.Y+17+float64(i)*12, pdf.Helvetica, 9, pdf.Black, line)
+		}
+		for i, line := range project {
+			doc.Text(projectX, f.Y+17+float64(i)*12, pdf.Helvetica, 9, pdf.Gray, line)
+		}
+		doc.TextRight(amountX, f.Y+17, pdf.Helvetica, 9, pdf.Black, formatMoney(item.Amount, ""))
+		f.Y += h
+		subtotal += item.Amount
+	}
+	if len(inv.LineItems) == 0 {
+		subtotal = inv.TotalAmount
+		doc.Text(invoiceMargin+8, f.Y+17, pdf.Helvetica, 9, pdf.Black, "Services for "+billingPeriod(inv.BillingMonth))
+		doc.TextRight(amountX, f.Y+17, pdf.Helvetica, 9, pdf.Black, formatMoney(inv.TotalAmount, ""))
+		f.Y += 22
+	}
+	doc.Line(invoiceMargin, f.Y, right, f.Y, 0.5, pdf.Gray)
+
+	// Totals. The stored total is authoritative; any difference from the
+	// line items (discounts, manual corrections) is shown as an adjustment.
+	totals := [][2]string{{"Subtotal", formatMoney(subtotal, inv.Currency)}}
+	if diff := inv.TotalAmount - subtotal; math.Abs(diff) >= 0.005 {
+		totals = append(totals, [2]string{"Adjustments", formatMoney(diff, inv.Currency)})
+	}
+	f.Ensure(float64(len(totals))*16 + 40)
+	f.Y += 6
+	for _, t := range totals {
+		f.Y += 16
+		doc.TextRight(amountX-120, f.Y, pdf.Helvetica, 9, pdf.Gray, t[0])
+		doc.TextRight(amountX, f.Y, pdf.Helvetica, 9, pdf.Black, t[1])
+	}
+	f.Y += 10
+	doc.Rect(right-230, f.Y, 230, 26, pdf.LightGray)
+	doc.TextRight(amountX-120, f.Y+17, pdf.HelveticaBold, 11, pdf.Black, "Total due")
+	doc.TextRight(amountX, f.Y+17, pdf.HelveticaBold, 11, brand, formatMoney(inv.TotalAmount, inv.Currency))
+	f.Y += 50
+
+	// Payment instructions
+	f.Ensure(60)
+	f.Paragraph(pdf.HelveticaBold, 10, pdf.Black, 0, "Payment instructions")
+	f.Space(2)
+	f.Paragraph(pdf.Helvetica, 9, pdf.Black, 0, fmt.Sprintf("Please pay %s by %s, quoting %s as the payment reference.",
+		formatMoney(inv.TotalAmount, inv.Currency), due.Format("2 January 2006"), inv.InvoiceNumber))
+	if len(company.BankDetails) > 0 {
+		f.Space(4)
+		for _, line := range company.BankDetails {
+			f.Paragraph(pdf.Helvetica, 9, pdf.Black, 0, line)
+		}
+	}
+	if company.Email != "" {
+		f.Space(8)
+		f.Paragraph(pdf.HelveticaOblique, 8, pdf.Gray, 0, "Questions about this invoice? Contact "+company.Email+".")
+	}
+
+	return doc.Bytes()
+}
+
+// billingPeriod renders "2024-03" as "March 2024", leaving other formats as is.
+func billingPeriod(month string) string {
+	if t, err := time.Parse("2006-01", month); err == nil {
+		return t.Format("January 2006")
+	}
+	return month
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// Reasons recorded for failed sign-ins
+const (
+	loginUnknownUser = "UNKNOWN_USER"
+	loginBadPassword = "BAD_PASSWORD"
+	loginLocked      = "LOCKED"
+	loginDisabled    = "DISABLED"
+)
+
+var ErrInvalidCredentials = UnauthorizedError("invalid credentials")
+
+// LoginThrottledError is returned while an account or address has to wait
+// before its next sign-in attempt.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed sign-in attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// loginPolicy decides how failed sign-ins slow down further attempts. Each
+// failure past a threshold doubles the wait before the next try, up to a cap.
+type loginPolicy struct {
+	// Consecutive failures of one account before it's locked
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// Failures of one account before waits start, and the longest wait
+	AccountDelayAfter int
+	AccountMaxDelay   time.Duration
+	// Failures from one address within IPWindow before waits start. Waits
+	// are capped at the window.
+	IPDelayAfter int
+	IPWindow     time.Duration
+}
+
+// loadLoginPolicy reads LOGIN_MAX_FAILURES and LOGIN_LOCKOUT_MINUTES.
+func loadLoginPolicy() loginPolicy {
+	p := loginPolicy{
+		MaxFailures:       10,
+		LockoutDuration:   15 * time.Minute,
+		AccountDelayAfter: 3,
+		AccountMaxDelay:   time.Minute,
+		IPDelayAfter:      20,
+		IPWindow:          15 * time.Minute,
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && n > 0 {
+		p.MaxFailures = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTES")); err == nil && n > 0 {
+		p.LockoutDuration = time.Duration(n) * time.Minute
+	}
+	return p
+}
+
+// progressiveDelay is the wait after failures, doubling from one second once
+// there are more than after.
+func progressiveDelay(failures, after int, max time.Duration) time.Duration {
+	if failures <= after {
+		return 0
+	}
+	shift := failures - after - 1
+	if shift > 20 {
+		return max
+	}
+	return min(time.Second<<shift, max)
+}
+
+// ipRetryAfter is how long ip has to wait because of its recent failures,
+// whichever accounts they were against.
+func ipRetryAfter(ctx context.Context, p loginPolicy, ip string) (time.Duration, error) {
+	var failures int
+	var last *time.Time
+	err := db.Pool.QueryRow(ctx, `
+		SELECT count(*), max(created_at) FROM login_attempts
+		WHERE ip = $1 AND NOT success AND created_at > now() - make_interval(secs => $2)
+	`, ip, p.IPWindow.Seconds()).Scan(&failures, &last)
+	if err != nil || last == nil {
+		return 0, err
+	}
+	return progressiveDelay(failures, p.IPDelayAfter, p.IPWindow) - time.Since(*last), nil
+}
+
+// accountRetryAfter is how long the user has to wait because of a lockout or
+// their recent failures.
+func accountRetryAfter(p loginPolicy, user *models.User, lastFailed *time.Time) time.Duration {
+	if user.LockedUntil != nil && time.Until(*user.LockedUntil) > 0 {
+		return time.Until(*user.LockedUntil)
+	}
+	if lastFailed == nil {
+		return 0
+	}
+	return progressiveDelay(user.FailedLoginCount, p.AccountDelayAfter, p.AccountMaxDelay) - time.Since(*lastFailed)
+}
+
+// recordLoginFailure counts a wrong password against the user, locking the
+// account once it reaches MaxFailures.
+func recordLoginFailure(ctx context.Context, p loginPolicy, userID uuid.UUID) error {
+	var failures int
+	var lockedUntil *time.Time
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE users SET
+			failed_login_count = failed_login_count + 1,
+			last_failed_login_at = now(),
+			locked_until = CASE WHEN failed_login_count + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE locked_until END
+		WHERE id = $1
+		RETURNING failed_login_count, locked_until
+	`, userID, p.MaxFailures, p.LockoutDuration.Seconds()).Scan(&failures, &lockedUntil)
+	if err != nil {
+		return err
+	}
+	if failures >= p.MaxFailures {
+		log.Printf("Locked user %s until %s after %d failed sign-ins", userID, lockedUntil.Format(time.RFC3339), failures)
+	}
+	return nil
+}
+
+func recordLoginAttempt(ctx context.Context, userID *uuid.UUID, login, ip, userAgent string, success bool, reason string) {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO login_attempts (user_id, login, ip, user_agent, success, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, userID, login, ip, optString(userAgent), success, optString(reason))
+	if err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+}
+
+// UnlockUser clears a lockout and the failure count behind it.
+func (s *AuthService) UnlockUser(ctx context.Context, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrUserNotFound
+	}
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE users SET failed_login_count = 0, locked_until = NULL WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+type LoginHistoryFilter struct {
+	UserID  string
+	IP      string
+	Success *bool
+	Limit   int
+}
+
+// LoginHistory returns sign-in attempts, newest first.
+func (s *AuthService) LoginHistory(ctx context.Context, f LoginHistoryFilter) ([]models.LoginAttempt, error) {
+	query := `SELECT id, user_id, login, ip, user_agent, success, reason, created_at FROM login_attempts WHERE 1=1`
+	var args []interface{}
+	argID := 1
+
+	if f.UserID != "" {
+		if _, err := uuid.Parse(f.UserID); err != nil {
+			return nil, ErrUserNotFound
+		}
+		query += fmt.Sprintf(" AND user_id = $%d", argID)
+		args = append(args, f.UserID)
+		argID++
+	}
+	if f.IP != "" {
+		query += fmt.Sprintf(" AND ip = $%d", argID)
+		args = append(args, f.IP)
+		argID++
+	}
+	if f.Success != nil {
+		query += fmt.Sprintf(" AND success = $%d", argID)
+		args = append(args, *f.Success)
+		argID++
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argID)
+	args = append(args, f.Limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.LoginAttempt{}
+	for rows.Next() {
+		var a models.LoginAttempt
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Login, &a.IP, &a.UserAgent, &a.Success, &a.Reason, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// PruneLoginHistory deletes attempts older than retention.
+func (s *AuthService) PruneLoginHistory(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM login_attempts WHERE created_at < now() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
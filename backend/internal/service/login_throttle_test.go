@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestProgressiveDelay(t *testing.T) {
+	tests := []struct {
+		failures, after int
+		want            time.Duration
+	}{
+		{0, 3, 0},
+		{3, 3, 0},
+		{4, 3, time.Second},
+		{5, 3, 2 * time.Second},
+		{8, 3, 16 * time.Second},
+		{9, 3, 32 * time.Second},
+		{10, 3, time.Minute},
+		{1000, 3, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := progressiveDelay(tt.failures, tt.after, time.Minute); got != tt.want {
+			t.Errorf("progressiveDelay(%d, %d) = %v, want %v", tt.failures, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestAccountRetryAfter(t *testing.T) {
+	p := loginPolicy{AccountDelayAfter: 3, AccountMaxDelay: time.Minute}
+	at := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d)
+		return &t
+	}
+
+	tests := []struct {
+		name       string
+		failures   int
+		locked     *time.Time
+		lastFailed *time.Time
+		wantMin    time.Duration // both zero when the login may go ahead
+		wantMax    time.Duration
+	}{
+		{name: "never failed"},
+		{name: "few failures", failures: 3, lastFailed: at(0)},
+		{name: "locked", failures: 10, locked: at(10 * time.Minute), lastFailed: at(0), wantMin: 9 * time.Minute, wantMax: 10 * time.Minute},
+		{name: "lock expired, delay served", failures: 10, locked: at(-time.Minute), lastFailed: at(-16 * time.Minute)},
+		{name: "delay running", failures: 6, lastFailed: at(-time.Second), wantMin: 2 * time.Second, wantMax: 3 * time.Second},
+		{name: "delay served", failures: 6, lastFailed: at(-5 * time.Second)},
+	}
+	for _, tt := range tests {
+		user := &models.User{FailedLoginCount: tt.failures, LockedUntil: tt.locked}
+		got := accountRetryAfter(p, user, tt.lastFailed)
+		if tt.wantMax == 0 {
+			if got > 0 {
+				t.Errorf("%s: accountRetryAfter = %v, want no wait", tt.name, got)
+			}
+			continue
+		}
+		if got < tt.wantMin || got > tt.wantMax {
+			t.Errorf("%s: accountRetryAfter = %v, want between %v and %v", tt.name, got, tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestLoadLoginPolicy(t *testing.T) {
+	tests := []struct {
+		maxFailures, lockout string
+		wantMax              int
+		wantLockout          time.Duration
+	}{
+		{"", "", 10, 15 * time.Minute},
+		{"5", "60", 5, time.Hour},
+		{"0", "-1", 10, 15 * time.Minute},
+		{"five", "1h", 10, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv("LOGIN_MAX_FAILURES", tt.maxFailures)
+		t.Setenv("LOGIN_LOCKOUT_MINUTES", tt.lockout)
+		p := loadLoginPolicy()
+		if p.MaxFailures != tt.wantMax || p.LockoutDuration != tt.wantLockout {
+			t.Errorf("LOGIN_MAX_FAILURES=%q LOGIN_LOCKOUT_MINUTES=%q: got %d, %v; want %d, %v",
+				tt.maxFailures, tt.lockout, p.MaxFailures, p.LockoutDuration, tt.wantMax, tt.wantLockout)
+		}
+	}
+}
+
+// createTestUser inserts an active user with a unique email and returns it.
+func createTestUser(t *testing.T, role, password string) (uuid.UUID, string) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := "test-" + uuid.NewString() + "@example.com"
+	var id uuid.UUID
+	err = db.Pool.QueryRow(context.Background(),
+		`INSERT INTO users (email, password_hash, role, activated_at) VALUES ($1, $2, $3, now()) RETURNING id`,
+		email, string(hash), role,
+	).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, email
+}
+
+func TestLoginLockout(t *testing.T) {
+	requireDB(t)
+	t.Setenv("LOGIN_MAX_FAILURES", "3")
+	ctx := context.Background()
+	s := NewAuthService()
+	id, email := createTestUser(t, "HR", "correct horse")
+	// A fresh address per run keeps the per-IP limit out of the way
+	ip := "test-" + uuid.NewString()
+
+	for i := 1; i <= 3; i++ {
+		_, err := s.Login(ctx, models.LoginRequest{Username: email, Password: "wrong"}, ip, "test")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: error = %v, want %v", i, err, ErrInvalidCredentials)
+		}
+	}
+
+	// Locked: even the right password has to wait
+	_, err := s.Login(ctx, models.LoginRequest{Username: email, Password: "correct horse"}, ip, "test")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("after lockout: error = %v, want a LoginThrottledError", err)
+	}
+	if throttled.RetryAfter < 14*time.Minute || throttled.RetryAfter > 15*time.Minute {
+		t.Errorf("RetryAfter = %v, want about the 15 minute lockout", throttled.RetryAfter)
+	}
+
+	if err := s.UnlockUser(ctx, id.String()); err != nil {
+		t.Fatalf("UnlockUser: %v", err)
+	}
+	resp, err := s.Login(ctx, models.LoginRequest{Username: email, Password: "correct horse"}, ip, "test")
+	if err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+	if resp.Token == "" {
+		t.Error("no session token after unlock")
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/totp"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	loginBadMFACode   = "BAD_MFA_CODE"
+)
+
+var (
+	ErrInvalidMFACode    = ValidationError("invalid authentication code")
+	ErrInvalidMFAToken   = UnauthorizedError("sign-in expired, start again")
+	ErrMFAAlreadyEnabled = ConflictError("two-factor authentication is already enabled")
+	ErrMFANotEnrolled    = ConflictError("two-factor authentication is not set up")
+	ErrMFARequired       = ForbiddenError("two-factor authentication is required for your role")
+)
+
+// mfaKey signs MFA challenge tokens. It differs from jwtKey so a challenge
+// can never pass as a session token.
+var mfaKey = func() []byte {
+	sum := sha256.Sum256(append([]byte("mfa-challenge:"), jwtKey...))
+	return sum[:]
+}()
+
+// mfaRequiredFor reports whether MFA_REQUIRED_ROLES (comma separated, e.g.
+// "ADMIN,FINANCE,HR") includes role. Users with such a role can only enroll
+// in 2FA until they have.
+func mfaRequiredFor(role string) bool {
+	for _, r := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if strings.EqualFold(strings.TrimSpace(r), role) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateMFAToken(userID uuid.UUID) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{"mfa"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(mfaKey)
+}
+
+func parseMFAToken(tokenString string) (uuid.UUID, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return mfaKey, nil
+	}, jwt.WithAudience("mfa"), jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return uuid.Nil, ErrInvalidMFAToken
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, ErrInvalidMFAToken
+	}
+	return id, nil
+}
+
+// VerifyMFA completes a sign-in started by Login with an authenticator code
+// or a recovery code. Wrong codes count as failed sign-ins.
+func (s *AuthService) VerifyMFA(ctx context.Context, req models.MFALoginRequest, ip, userAgent string) (*models.LoginResponse, error) {
+	userID, err := parseMFAToken(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := loadLoginPolicy()
+	wait, err := ipRetryAfter(ctx, policy, ip)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		return nil, &LoginThrottledError{RetryAfter: wait}
+	}
+
+	user, lastFailed, err := loadLoginUser(ctx, "id = $1", userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if wait := accountRetryAfter(policy, user, lastFailed); wait > 0 {
+		recordLoginAttempt(ctx, &user.ID, user.Email, ip, userAgent, false, loginLocked)
+		return nil, &LoginThrottledError{RetryAfter: wait}
+	}
+	if user.DisabledAt != nil || !user.MFAEnabled {
+		return nil, ErrInvalidMFAToken
+	}
+
+	if req.RecoveryCode != "" {
+		err = useRecoveryCode(ctx, user.ID, req.RecoveryCode)
+	} else {
+		err = checkTOTP(ctx, db.Pool, user.ID, req.Code)
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		recordLoginAttempt(ctx, &user.ID, user.Email, ip, userAgent, false, loginBadMFACode)
+		if err := recordLoginFailure(ctx, policy, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return completeLogin(ctx, user, user.Email, ip, userAgent)
+}
+
+// checkTOTP accepts code if it matches the user's enrolled secret and is
+// newer than the last code accepted.
+func checkTOTP(ctx context.Context, q dbtx, userID uuid.UUID, code string) error {
+	var secret *string
+	if err := q.QueryRow(ctx, `SELECT totp_secret FROM users WHERE id = $1`, userID).Scan(&secret); err != nil {
+		return err
+	}
+	if secret == nil {
+		return ErrMFANotEnrolled
+	}
+	step, ok := totp.Validate(*secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	tag, err := q.Exec(ctx, `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		// Already used
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes so codes can be typed
+// however they were written down.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newRecoveryCodes replaces the user's recovery codes. The codes are returned
+// formatted for display; only their hashes are kept.
+func newRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID) ([]string, error) {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		_, err := tx.Exec(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hashToken(raw))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// SetupMFA starts enrollment with a new secret. 2FA isn't on until EnableMFA
+// confirms the user's app produces matching codes.
+func (s *AuthService) SetupMFA(ctx context.Context, userID string) (*models.MFASetupResponse, error) {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled_at IS NULL`, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFASetupResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(LoadCompanyProfile().Name, user.Email, secret),
+	}, nil
+}
+
+// EnableMFA turns 2FA on once code proves enrollment worked, and returns the
+// recovery codes with a session token that reflects the change.
+func (s *AuthService) EnableMFA(ctx context.Context, userID, code string) (*models.MFARecoveryCodesResponse, error) {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkTOTP(ctx, tx, user.ID, code); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE users SET totp_enabled_at = now() WHERE id = $1`, user.ID); err != nil {
+		return nil, err
+	}
+	codes, err := newRecoveryCodes(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	user.MFAEnabled = true
+	token, err := GenerateJWT(user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes, Token: token}, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, e.g. after some were
+// used or the list was lost. It needs a current authenticator code.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var enabled bool
+	err = tx.QueryRow(ctx, `SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, id).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrMFANotEnrolled
+	}
+	if err := checkTOTP(ctx, tx, id, code); err != nil {
+		return nil, err
+	}
+	codes, err := newRecoveryCodes(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit(ctx)
+}
+
+// DisableMFA turns 2FA off for a user who can prove both factors, unless
+// their role requires it.
+func (s *AuthService) DisableMFA(ctx context.Context, userID string, req models.MFADisableRequest) error {
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+	if mfaRequiredFor(user.Role) {
+		return ErrMFARequired
+	}
+
+	var hash string
+	if err := db.Pool.QueryRow(ctx, `SELECT password_hash FROM users WHERE id = $1`, userID).Scan(&hash); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)); err != nil {
+		return ErrWrongPassword
+	}
+	if err := checkTOTP(ctx, db.Pool, user.ID, req.Code); err != nil {
+		return err
+	}
+	return resetMFA(ctx, user.ID)
+}
+
+// ResetMFA removes a user's 2FA so they can enroll again, for when they've
+// lost their device and recovery codes. If their role requires 2FA they'll
+// be asked to enroll at next sign-in.
+func (s *AuthService) ResetMFA(ctx context.Context, userID string) error {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return resetMFA(ctx, id)
+}
+
+func resetMFA(ctx context.Context, userID uuid.UUID) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/totp"
+)
+
+func TestCheckTOTPRefusesReplay(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	id, _ := createTestUser(t, "HR", "correct horse")
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Pool.Exec(ctx, `UPDATE users SET totp_secret = $2 WHERE id = $1`, id, secret); err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code := func(step int64) string {
+		c, err := totp.Code(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	if err := checkTOTP(ctx, db.Pool, id, code(step)); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := checkTOTP(ctx, db.Pool, id, code(step)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("same code again: error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	// Still inside the skew window, but older than the code just accepted
+	if err := checkTOTP(ctx, db.Pool, id, code(step-1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("earlier code: error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := checkTOTP(ctx, db.Pool, id, code(step+1)); err != nil {
+		t.Errorf("next code: %v", err)
+	}
+}
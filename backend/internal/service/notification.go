@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/jobs"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/notify"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const EmailJobKind = "email.send"
+
+// Notification kinds. Each has a template in internal/notify/templates with
+// the dot replaced by an underscore.
+const (
+	NotifyUserInvited      = "user.invited"
+	NotifyInvoiceSent      = "invoice.sent"
+	NotifyContractExpiring = "contract.expiring"
+	NotifyPayoutApproved   = "payout.approved"
+	NotifyPasswordReset    = "password.reset"
+	NotifyWebhookDisabled  = "webhook.disabled"
+)
+
+type notificationKind struct {
+	Description string
+	Mandatory   bool
+}
+
+// notificationKinds are the kinds a user can be sent. Preferences only apply
+// to recipients who are users; contacts and talent always get their mail.
+var notificationKinds = map[string]notificationKind{
+	NotifyUserInvited:      {Description: "Your account was created", Mandatory: true},
+	NotifyInvoiceSent:      {Description: "An invoice was issued to your company"},
+	NotifyContractExpiring: {Description: "A contract entered its notice period"},
+	NotifyPayoutApproved:   {Description: "A contractor payout was approved"},
+	NotifyPasswordReset:    {Description: "A password reset was requested", Mandatory: true},
+	NotifyWebhookDisabled:  {Description: "A webhook endpoint was disabled after repeated failures"},
+}
+
+var (
+	ErrUnknownNotification = ValidationError("unknown notification kind")
+	ErrInvalidPreference   = ValidationError("invalid notification preference")
+	ErrOutboxNotFound      = NotFoundError("email not found")
+)
+
+// dbtx is satisfied by the pool and by transactions.
+type dbtx interface {
+	querier
+	jobs.Execer
+}
+
+// Email is one message to queue. Set UserID when the recipient is a user so
+// their preferences are honoured.
+type Email struct {
+	Kind        string
+	To          string
+	UserID      *uuid.UUID
+	Data        interface{}
+	Attachments []notify.Attachment
+}
+
+type emailPayload struct {
+	OutboxID string `json:"outbox_id"`
+}
+
+// EnqueueEmail renders an email into the outbox and queues its delivery.
+// Pass the transaction of the change that triggers it so the email only
+// exists if the change does. It reports false if the recipient opted out.
+func EnqueueEmail(ctx context.Context, q dbtx, e Email) (bool, error) {
+	kind, ok := notificationKinds[e.Kind]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownNotification, e.Kind)
+	}
+	if e.UserID != nil && !kind.Mandatory {
+		var enabled bool
+		err := q.QueryRow(ctx,
+			`SELECT COALESCE((SELECT email_enabled FROM notification_preferences WHERE user_id = $1 AND kind = $2), true)`,
+			*e.UserID, e.Kind,
+		).Scan(&enabled)
+		if err != nil {
+			return false, err
+		}
+		if !enabled {
+			return false, nil
+		}
+	}
+
+	subject, text, html, err := notify.Render(strings.ReplaceAll(e.Kind, ".", "_"), e.Data)
+	if err != nil {
+		return false, err
+	}
+	attachments, err := json.Marshal(e.Attachments)
+	if err != nil {
+		return false, err
+	}
+	if e.Attachments == nil {
+		attachments = []byte("[]")
+	}
+
+	var id uuid.UUID
+	err = q.QueryRow(ctx, `
+		INSERT INTO email_outbox (kind, user_id, to_address, subject, text_body, html_body, attachments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, e.Kind, e.UserID, e.To, subject, text, html, attachments).Scan(&id)
+	if err != nil {
+		return false, err
+	}
+	return true, jobs.Enqueue(ctx, q, EmailJobKind, emailPayload{OutboxID: id.String()})
+}
+
+// notifyStaff emails every staff user with one of roles who hasn't opted out
+// of the kind. It returns how many emails were queued.
+func notifyStaff(ctx context.Context, tx pgx.Tx, kind string, roles []string, data interface{}) (int, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT id, email FROM users WHERE role::text = ANY($1) AND client_id IS NULL AND disabled_at IS NULL AND NOT service_account`, roles)
+	if err != nil {
+		return 0, err
+	}
+	type recipient struct {
+		id    uuid.UUID
+		email string
+	}
+	var recipients []recipient
+	for rows.Next() {
+		var r recipient
+		if err := rows.Scan(&r.id, &r.email); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range recipients {
+		ok, err := EnqueueEmail(ctx, tx, Email{Kind: kind, To: r.email, UserID: &r.id, Data: data})
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// appURL links to a page of the frontend configured by APP_BASE_URL.
+func appURL(path string) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+type NotificationService struct {
+	Transport notify.Transport
+	From      string
+}
+
+func NewNotificationService(t notify.Transport) *NotificationService {
+	return &NotificationService{Transport: t, From: notify.From()}
+}
+
+// HandleJob is the jobs.HandlerFunc for EmailJobKind. The outbox row keeps
+// the attempt count and last error; on the final attempt it is marked FAILED.
+func (s *NotificationService) HandleJob(ctx context.Context, job *jobs.Job) error {
+	var p emailPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid email payload: %w", err)
+	}
+
+	m := &notify.Message{From: s.From}
+	var to string
+	var attachments []byte
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE email_outbox SET attempts = attempts + 1
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING to_address, subject, text_body, COALESCE(html_body, ''), attachments
+	`, p.OutboxID).Scan(&to, &m.Subject, &m.Text, &m.HTML, &attachments)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Already sent, or given up on and not retried
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m.To = []string{to}
+	if err := json.Unmarshal(attachments, &m.Attachments); err != nil {
+		return fmt.Errorf("invalid attachments: %w", err)
+	}
+
+	if err := s.Transport.Send(ctx, m); err != nil {
+		status := "PENDING"
+		if job.FinalAttempt() {
+			status = "FAILED"
+		}
+		// Use a fresh context: ctx may be the one that just timed out.
+		_, dbErr := db.Pool.Exec(context.Background(),
+			`UPDATE email_outbox SET status = $2, last_error = $3 WHERE id = $1`,
+			p.OutboxID, status, err.Error(),
+		)
+		if dbErr != nil {
+			fmt.Printf("Failed to record email error for %s: %v\n", p.OutboxID, dbErr)
+		}
+		return err
+	}
+
+	_, err = db.Pool.Exec(ctx,
+		`UPDATE email_outbox SET status = 'SENT', last_error = NULL, sent_at = now() WHERE id = $1`, p.OutboxID)
+	return err
+}
+
+// ListOutbox returns the most recent outbox entries, optionally by status.
+func (s *NotificationService) ListOutbox(ctx context.Context, status string, limit int) ([]models.OutboxEmail, error) {
+	query := `SELECT id, kind, user_id, to_address, subject, status, attempts, last_error, sent_at, created_at FROM email_outbox`
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, strings.ToUpper(status))
+		query += ` WHERE status = $1`
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC LIMIT $%d`, len(args))
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.OutboxEmail
+	for rows.Next() {
+		var e models.OutboxEmail
+		if err := rows.Scan(&e.ID, &e.Kind, &e.UserID, &e.To, &e.Subject, &e.Status, &e.Attempts, &e.LastError, &e.SentAt, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+// Retry queues a FAILED email again with a fresh set of attempts.
+func (s *NotificationService) Retry(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrOutboxNotFound
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE email_outbox SET status = 'PENDING', attempts = 0 WHERE id = $1 AND status = 'FAILED'`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOutboxNotFound
+	}
+	if err := jobs.Enqueue(ctx, tx, EmailJobKind, emailPayload{OutboxID: id}); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Preferences lists every notification kind with the user's setting.
+func (s *NotificationService) Preferences(ctx context.Context, userID string) ([]models.NotificationPreference, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT kind, email_enabled FROM notification_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := map[string]bool{}
+	for rows.Next() {
+		var kind string
+		var enabled bool
+		if err := rows.Scan(&kind, &enabled); err != nil {
+			return nil, err
+		}
+		set[kind] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	prefs := []models.NotificationPreference{}
+	for _, kind := range []string{NotifyUserInvited, NotifyPasswordReset, NotifyInvoiceSent, NotifyContractExpiring, NotifyPayoutApproved} {
+		k := notificationKinds[kind]
+		enabled, ok := set[kind]
+		prefs = append(prefs, models.NotificationPreference{
+			Kind:         kind,
+			Description:  k.Description,
+			EmailEnabled: k.Mandatory || !ok || enabled,
+			Mandatory:    k.Mandatory,
+		})
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences sets email on or off per kind. Mandatory kinds can't be
+// switched off.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, userID string, settings map[string]bool) ([]models.NotificationPreference, error) {
+	for kind, enabled := range settings {
+		k, ok := notificationKinds[kind]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown kind %s", ErrInvalidPreference, kind)
+		}
+		if k.Mandatory && !enabled {
+			return nil, fmt.Errorf("%w: %s can't be switched off", ErrInvalidPreference, kind)
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	for kind, enabled := range settings {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO notification_preferences (user_id, kind, email_enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, kind) DO UPDATE SET email_enabled = EXCLUDED.email_enabled, updated_at = now()
+		`, userID, kind, enabled)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, userID)
+}
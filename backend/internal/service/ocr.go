@@ -0,0 +1,209 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/jobs"
+	"github.com/dubai/platform/backend/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const OCRJobKind = "document.ocr"
+
+// OCR status values stored in documents.ocr_status.
+const (
+	OCRPending    = "PENDING"
+	OCRProcessing = "PROCESSING"
+	OCRCompleted  = "COMPLETED"
+	OCRFailed     = "FAILED"
+)
+
+var ErrOCRInProgress = ConflictError("OCR is already queued or running for this document")
+
+type ocrPayload struct {
+	DocumentID string `json:"document_id"`
+}
+
+// EnqueueOCR queues OCR for a document. Pass the transaction that created the
+// document so the job only exists if the document does.
+func EnqueueOCR(ctx context.Context, q jobs.Execer, documentID string) error {
+	return jobs.Enqueue(ctx, q, OCRJobKind, ocrPayload{DocumentID: documentID})
+}
+
+type OCRService struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewOCRService reads the OCR endpoint from OCR_SERVICE_URL.
+func NewOCRService() *OCRService {
+	endpoint := os.Getenv("OCR_SERVICE_URL")
+	if endpoint == "" {
+		endpoint = "http://localhost:8000/ocr"
+	}
+	// Per-request deadlines come from the job timeout, not the client.
+	return &OCRService{Endpoint: endpoint, Client: &http.Client{}}
+}
+
+// Rerun resets a document's OCR state and queues it again.
+func (s *OCRService) Rerun(ctx context.Context, documentID string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var inFlight bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM jobs WHERE kind = $1 AND payload->>'document_id' = $2 AND status IN ('QUEUED', 'RUNNING'))`,
+		OCRJobKind, documentID,
+	).Scan(&inFlight)
+	if err != nil {
+		return err
+	}
+	if inFlight {
+		return ErrOCRInProgress
+	}
+
+	result, err := tx.Exec(ctx,
+		`UPDATE documents SET ocr_status = $2, ocr_error = NULL WHERE id = $1 AND deleted_at IS NULL`,
+		documentID, OCRPending,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return NotFoundError("document not found")
+	}
+
+	if err := EnqueueOCR(ctx, tx, documentID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// HandleJob is the jobs.HandlerFunc for OCRJobKind. While retries remain a
+// failed document goes back to PENDING with the error recorded; on the final
+// attempt it is marked FAILED.
+func (s *OCRService) HandleJob(ctx context.Context, job *jobs.Job) error {
+	var p ocrPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid OCR payload: %w", err)
+	}
+
+	var documentID uuid.UUID
+	var fileKey, fileName string
+	err := db.Pool.QueryRow(ctx,
+		`UPDATE documents SET ocr_status = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING id, file_key, file_name`,
+		p.DocumentID, OCRProcessing,
+	).Scan(&documentID, &fileKey, &fileName)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Document was deleted after the job was queued; nothing to do.
+		log.Printf("OCR skipped for document %s: document no longer exists", p.DocumentID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	markdown, err := s.process(ctx, fileKey, fileName)
+	if err != nil {
+		status := OCRPending
+		if job.FinalAttempt() {
+			status = OCRFailed
+		}
+		// Use a fresh context: ctx may be the one that just timed out.
+		_, dbErr := db.Pool.Exec(context.Background(),
+			`UPDATE documents SET ocr_status = $2, ocr_error = $3 WHERE id = $1`,
+			p.DocumentID, status, err.Error(),
+		)
+		if dbErr != nil {
+			log.Printf("Failed to record OCR error for %s: %v", p.DocumentID, dbErr)
+		}
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx,
+		`UPDATE documents SET ocr_status = $2, ocr_error = NULL, content = $3 WHERE id = $1`,
+		p.DocumentID, OCRCompleted, markdown,
+	)
+	if err != nil {
+		return err
+	}
+	err = events.Publish(ctx, tx, events.DocumentOCRCompleted{DocumentID: documentID, FileName: fileName})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// process sends the stored file to the OCR service and returns its markdown.
+func (s *OCRService) process(ctx context.Context, fileKey string, fileName string) (string, error) {
+	log.Printf("Starting OCR for %s", fileName)
+
+	// 1. Download file
+	file, err := storage.Default.Get(ctx, fileKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer file.Close()
+
+	// 2. Prepare multipart request to OCR service
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", fmt.Errorf("failed to copy file content: %w", err)
+	}
+	writer.Close()
+
+	// 3. Send to OCR service
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Endpoint, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request to OCR service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OCR service returned bad status: %d", resp.StatusCode)
+	}
+
+	// 4. Parse Response
+	var result struct {
+		Markdown       string  `json:"markdown"`
+		ProcessingTime float64 `json:"processing_time"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode OCR response: %w", err)
+	}
+
+	log.Printf("OCR completed in %.2fs for %s", result.ProcessingTime, fileName)
+	return result.Markdown, nil
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/oidc"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	oidcStateTTL          = 10 * time.Minute
+	loginPasswordDisabled = "PASSWORD_LOGIN_DISABLED"
+	loginSSODenied        = "SSO_DENIED"
+)
+
+var (
+	ErrPasswordLoginDisabled = ForbiddenError("password sign-in is disabled for your role, sign in with SSO")
+	ErrSSODenied             = ForbiddenError("your identity provider account is not allowed to sign in here")
+	ErrInvalidSSOState       = ValidationError("sign-in expired or was started elsewhere, start again")
+)
+
+// passwordLoginDisabled reports whether OIDC_DISABLE_STAFF_PASSWORDS turns off
+// password sign-in for role. Client portal users always keep their passwords.
+func passwordLoginDisabled(role string) bool {
+	return staffRoles[role] && os.Getenv("OIDC_ISSUER") != "" && os.Getenv("OIDC_DISABLE_STAFF_PASSWORDS") == "true"
+}
+
+type groupRole struct {
+	Group string
+	Role  string
+}
+
+// OIDCService signs staff in through the corporate identity provider,
+// creating their account on first sign-in.
+type OIDCService struct {
+	cfg         oidc.Config
+	groupsClaim string
+	// roleMapping is in priority order: a user in several groups gets the
+	// role of the first that matches
+	roleMapping []groupRole
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCServiceFromEnv configures SSO from OIDC_ISSUER, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET, OIDC_REDIRECT_URL, OIDC_SCOPES, OIDC_GROUPS_CLAIM and
+// OIDC_ROLE_MAPPING. It returns nil when OIDC_ISSUER isn't set.
+func NewOIDCServiceFromEnv() (*OIDCService, error) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	cfg := oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required with OIDC_ISSUER")
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	groupsClaim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	mapping, err := parseRoleMapping(os.Getenv("OIDC_ROLE_MAPPING"))
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCService{cfg: cfg, groupsClaim: groupsClaim, roleMapping: mapping}, nil
+}
+
+// parseRoleMapping reads "group=ROLE,other-group=ROLE". Only staff roles can
+// be granted; client portal users are never provisioned through SSO.
+func parseRoleMapping(s string) ([]groupRole, error) {
+	var mapping []groupRole
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(entry, "=")
+		role = strings.ToUpper(strings.TrimSpace(role))
+		if !ok || strings.TrimSpace(group) == "" || !staffRoles[role] {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q: want group=ROLE with a staff role", entry)
+		}
+		mapping = append(mapping, groupRole{Group: strings.TrimSpace(group), Role: role})
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("OIDC_ROLE_MAPPING is required with OIDC_ISSUER")
+	}
+	return mapping, nil
+}
+
+// getProvider discovers the provider on first use, so the server starts even
+// while the provider is unreachable.
+func (s *OIDCService) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider != nil {
+		return s.provider, nil
+	}
+	p, err := oidc.Discover(ctx, s.cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.provider = p
+	return p, nil
+}
+
+// Begin starts a sign-in and returns the provider URL to send the browser to
+// and the state to bind to the browser. redirectPath is where the frontend
+// should go afterwards.
+func (s *OIDCService) Begin(ctx context.Context, redirectPath string) (authURL, state string, err error) {
+	p, err := s.getProvider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	state, err = oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	// Clear out sign-ins that were abandoned at the provider
+	if _, err := db.Pool.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < now()`); err != nil {
+		return "", "", err
+	}
+	_, err = db.Pool.Exec(ctx, `
+		INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, redirect_path, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, hashToken(state), nonce, verifier, optString(safeRedirectPath(redirectPath)), time.Now().Add(oidcStateTTL))
+	if err != nil {
+		return "", "", err
+	}
+	return p.AuthCodeURL(state, nonce, oidc.Challenge(verifier)), state, nil
+}
+
+// safeRedirectPath only allows paths on the frontend, so the sign-in can't be
+// used to send people to another site.
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return ""
+	}
+	return path
+}
+
+// Complete finishes a sign-in when the provider redirects back with code. It
+// returns the session and where the frontend should go next.
+func (s *OIDCService) Complete(ctx context.Context, state, code, ip, userAgent string) (*models.LoginResponse, string, error) {
+	var nonce, verifier string
+	var redirectPath *string
+	err := db.Pool.QueryRow(ctx, `
+		DELETE FROM oidc_login_states WHERE state_hash = $1 AND expires_at > now()
+		RETURNING nonce, code_verifier, redirect_path
+	`, hashToken(state)).Scan(&nonce, &verifier, &redirectPath)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrInvalidSSOState
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	p, err := s.getProvider(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	rawIDToken, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := p.Verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := s.provisionUser(ctx, claims)
+	if errors.Is(err, ErrSSODenied) {
+		var userID *uuid.UUID
+		if user != nil {
+			userID = &user.ID
+		}
+		recordLoginAttempt(ctx, userID, claims.Email, ip, userAgent, false, loginSSODenied)
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The provider handles second factors, so there's no MFA challenge here
+	resp, err := completeLogin(ctx, user, user.Email, ip, userAgent)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, deref(redirectPath), nil
+}
+
+// mapRole picks the role for the user's groups, or "" if none match.
+func (s *OIDCService) mapRole(groups []string) string {
+	for _, m := range s.roleMapping {
+		for _, g := range groups {
+			if g == m.Group {
+				return m.Role
+			}
+		}
+	}
+	return ""
+}
+
+// provisionUser finds the user for the provider's subject, links an existing
+// staff account with the same verified email, or creates one. Their role
+// follows their groups at the provider on every sign-in.
+func (s *OIDCService) provisionUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	role := s.mapRole(claims.StringsClaim(s.groupsClaim))
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id uuid.UUID
+	var clientID *uuid.UUID
+	var linked, linkedElsewhere bool
+	err = tx.QueryRow(ctx, `
+		SELECT id, client_id, COALESCE(oidc_issuer = $1 AND oidc_subject = $2, false) AS linked, oidc_subject IS NOT NULL
+		FROM users
+		WHERE (oidc_issuer = $1 AND oidc_subject = $2) OR lower(email) = lower($3)
+		ORDER BY linked DESC
+		LIMIT 1
+		FOR UPDATE
+	`, s.cfg.Issuer, claims.Subject, claims.Email).Scan(&id, &clientID, &linked, &linkedElsewhere)
+	found := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	switch {
+	case found && !linked && (linkedElsewhere || !claims.EmailVerified || clientID != nil):
+		// Only link staff accounts not yet tied to another identity, and
+		// only on an address the provider vouches for
+		log.Printf("SSO: refused to link %s to existing user %s", claims.Email, id)
+		return &models.User{ID: id}, ErrSSODenied
+	case role == "":
+		log.Printf("SSO: %s is in no group mapped to a role", claims.Email)
+		if found {
+			return &models.User{ID: id}, ErrSSODenied
+		}
+		return nil, ErrSSODenied
+	case !found:
+		if claims.Email == "" {
+			return nil, ErrSSODenied
+		}
+		// Nobody knows this password; the user signs in through SSO
+		hash, err := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO users (email, password_hash, role, company_name, activated_at, oidc_issuer, oidc_subject)
+			VALUES ($1, $2, $3, $4, now(), $5, $6)
+			RETURNING id
+		`, claims.Email, string(hash), role, optString(claims.Name), s.cfg.Issuer, claims.Subject).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("SSO: created %s user %s for %s", role, id, claims.Email)
+	default:
+		_, err = tx.Exec(ctx, `
+			UPDATE users SET role = $2, oidc_issuer = $3, oidc_subject = $4, activated_at = COALESCE(activated_at, now())
+			WHERE id = $1
+		`, id, role, s.cfg.Issuer, claims.Subject)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	user, _, err := loadLoginUser(ctx, "id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return user, ErrSSODenied
+	}
+	return user, nil
+}
+
+// SuccessURL is the frontend page that picks up the session after a sign-in.
+// The token goes in the fragment so it stays out of server logs.
+func (s *OIDCService) SuccessURL(token, redirectPath string) string {
+	v := url.Values{}
+	v.Set("token", token)
+	if redirectPath != "" {
+		v.Set("redirect", redirectPath)
+	}
+	return appURL("/sso/callback#" + v.Encode())
+}
+
+// FailureURL is the frontend sign-in page, showing why SSO failed.
+func (s *OIDCService) FailureURL(reason string) string {
+	return appURL("/login?error=" + url.QueryEscape(reason))
+}
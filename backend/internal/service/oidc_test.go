@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/oidc"
+	"github.com/dubai/platform/backend/internal/oidc/oidctest"
+	"github.com/google/uuid"
+)
+
+func TestParseRoleMapping(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []groupRole
+		wantErr bool
+	}{
+		{in: "admins=ADMIN", want: []groupRole{{"admins", "ADMIN"}}},
+		{
+			in:   " admins = admin , hr-team=HR,,",
+			want: []groupRole{{"admins", "ADMIN"}, {"hr-team", "HR"}},
+		},
+		{in: "", wantErr: true},
+		{in: "admins", wantErr: true},
+		{in: "=ADMIN", wantErr: true},
+		{in: "admins=OWNER", wantErr: true},
+		// Client portal users are never provisioned through SSO
+		{in: "customers=CLIENT", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRoleMapping(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRoleMapping(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRoleMapping(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMapRole(t *testing.T) {
+	s := &OIDCService{roleMapping: []groupRole{
+		{"platform-admins", "ADMIN"},
+		{"platform-finance", "FINANCE"},
+		{"platform-hr", "HR"},
+	}}
+	tests := []struct {
+		name   string
+		groups []string
+		want   string
+	}{
+		{"single group", []string{"platform-hr"}, "HR"},
+		{"first mapping wins", []string{"platform-hr", "platform-admins"}, "ADMIN"},
+		{"unmapped groups ignored", []string{"everyone", "platform-finance"}, "FINANCE"},
+		{"no mapped group", []string{"everyone"}, ""},
+		{"no groups", nil, ""},
+		{"case sensitive", []string{"Platform-HR"}, ""},
+	}
+	for _, tt := range tests {
+		if got := s.mapRole(tt.groups); got != tt.want {
+			t.Errorf("%s: mapRole(%v) = %q, want %q", tt.name, tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestSafeRedirectPath(t *testing.T) {
+	tests := map[string]string{
+		"/contracts?id=1":      "/contracts?id=1",
+		"":                     "",
+		"https://evil.example": "",
+		"//evil.example":       "",
+		`/\evil.example`:       "",
+		"contracts":            "",
+	}
+	for in, want := range tests {
+		if got := safeRedirectPath(in); got != want {
+			t.Errorf("safeRedirectPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// newTestOIDC returns a service signed in through a mock provider.
+func newTestOIDC(t *testing.T) (*OIDCService, *oidctest.Provider) {
+	t.Helper()
+	idp, srv, err := oidctest.NewServer("platform", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return &OIDCService{
+		cfg: oidc.Config{
+			Issuer:       idp.Issuer,
+			ClientID:     "platform",
+			ClientSecret: "s3cret",
+			RedirectURL:  "http://app.test/api/auth/oidc/callback",
+			Scopes:       []string{"openid", "email"},
+		},
+		groupsClaim: "groups",
+		roleMapping: []groupRole{{"platform-admins", "ADMIN"}, {"platform-hr", "HR"}},
+	}, idp
+}
+
+// authorizeCode follows authURL at the mock provider and returns the code and
+// state it sends back.
+func authorizeCode(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state")
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	s, idp := newTestOIDC(t)
+
+	tests := []struct {
+		name         string
+		groups       []string
+		redirect     string
+		wantRole     string
+		wantRedirect string
+		wantErr      error
+	}{
+		{name: "mapped group", groups: []string{"platform-hr"}, redirect: "/talents", wantRole: "HR", wantRedirect: "/talents"},
+		{name: "first mapping wins", groups: []string{"platform-hr", "platform-admins"}, wantRole: "ADMIN"},
+		{name: "offsite redirect dropped", groups: []string{"platform-hr"}, redirect: "//evil.example", wantRole: "HR"},
+		{name: "no mapped group", groups: []string{"everyone"}, wantErr: ErrSSODenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := "sso-" + uuid.NewString() + "@example.com"
+			idp.SetUser(oidctest.User{Subject: uuid.NewString(), Email: email, EmailVerified: true, Groups: tt.groups})
+
+			authURL, state, err := s.Begin(ctx, tt.redirect)
+			if err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			code, returned := authorizeCode(t, authURL)
+			if returned != state {
+				t.Fatalf("provider returned state %q, want %q", returned, state)
+			}
+
+			resp, redirect, err := s.Complete(ctx, state, code, "127.0.0.1", "test")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Complete error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if resp.Token == "" || resp.User.Email != email || resp.User.Role != tt.wantRole {
+				t.Errorf("signed in as %s %s (token %t), want %s %s", resp.User.Role, resp.User.Email, resp.Token != "", tt.wantRole, email)
+			}
+			if redirect != tt.wantRedirect {
+				t.Errorf("redirect = %q, want %q", redirect, tt.wantRedirect)
+			}
+		})
+	}
+}
+
+func TestOIDCState(t *testing.T) {
+	requireDB(t)
+	ctx := context.Background()
+	s, idp := newTestOIDC(t)
+	idp.SetUser(oidctest.User{
+		Subject: uuid.NewString(), Email: "sso-" + uuid.NewString() + "@example.com", EmailVerified: true,
+		Groups: []string{"platform-hr"},
+	})
+
+	if _, _, err := s.Complete(ctx, "never-issued", "code", "127.0.0.1", "test"); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("unknown state: error = %v, want %v", err, ErrInvalidSSOState)
+	}
+
+	authURL, state, err := s.Begin(ctx, "")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	code, _ := authorizeCode(t, authURL)
+	if _, _, err := s.Complete(ctx, state, code, "127.0.0.1", "test"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// A state is used up by its sign-in, so a replayed callback fails
+	if _, _, err := s.Complete(ctx, state, code, "127.0.0.1", "test"); !errors.Is(err, ErrInvalidSSOState) {
+		t.Errorf("replayed state: error = %v, want %v", err, ErrInvalidSSOState)
+	}
+
+	// The PKCE verifier stays on our side, so a code sent back with another
+	// sign-in's state is refused by the provider
+	authURL, _, err = s.Begin(ctx, "")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	code, _ = authorizeCode(t, authURL)
+	_, otherState, err := s.Begin(ctx, "")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, _, err := s.Complete(ctx, otherState, code, "127.0.0.1", "test"); err == nil {
+		t.Error("code completed a different sign-in's state")
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	minPasswordLength = 12
+	// bcrypt ignores everything past 72 bytes
+	maxPasswordBytes = 72
+)
+
+var ErrWeakPassword = ValidationError("password does not meet the requirements")
+
+// commonPasswords are rejected outright. Most are short enough to fail the
+// length rule anyway; these are the long ones that turn up in breach lists.
+var commonPasswords = map[string]bool{
+	"password1234":     true,
+	"password12345":    true,
+	"password123456":   true,
+	"passw0rd1234":     true,
+	"qwertyuiop123":    true,
+	"qwerty123456":     true,
+	"1q2w3e4r5t6y":     true,
+	"iloveyou1234":     true,
+	"welcome12345":     true,
+	"letmein12345":     true,
+	"administrator1":   true,
+	"changeme1234":     true,
+	"trustno1trustno1": true,
+	"abc123abc123":     true,
+	"football1234":     true,
+	"baseball1234":     true,
+	"superman1234":     true,
+}
+
+// ValidatePassword checks a new password against the strength rules. The
+// identifiers (email, username) must not appear in it.
+func ValidatePassword(password string, identifiers ...string) error {
+	if len([]rune(password)) < minPasswordLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: must be at most %d bytes", ErrWeakPassword, maxPasswordBytes)
+	}
+
+	var letter, other bool
+	distinct := map[rune]bool{}
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letter = true
+		} else {
+			other = true
+		}
+		distinct[r] = true
+	}
+	if !letter || !other {
+		return fmt.Errorf("%w: must mix letters with digits or symbols", ErrWeakPassword)
+	}
+	if len(distinct) < 5 {
+		return fmt.Errorf("%w: too many repeated characters", ErrWeakPassword)
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: too common", ErrWeakPassword)
+	}
+	for _, id := range identifiers {
+		id = strings.ToLower(id)
+		if local, _, ok := strings.Cut(id, "@"); ok {
+			id = local
+		}
+		if len(id) >= 3 && strings.Contains(lower, id) {
+			return fmt.Errorf("%w: must not contain your username or email", ErrWeakPassword)
+		}
+	}
+	return nil
+}
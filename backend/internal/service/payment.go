@@ -2,9 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/listquery"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPaymentNotFound      = NotFoundError("payment not found")
+	ErrPaymentNotApprovable = ConflictError("only pending payments can be approved")
 )
 
 type PaymentService struct{}
@@ -13,9 +23,34 @@ func NewPaymentService() *PaymentService {
 	return &PaymentService{}
 }
 
-func (s *PaymentService) List(ctx context.Context) ([]models.ContractorPayment, error) {
-	query := `SELECT id, talent_id, project_id, billing_month, amount, status, created_at FROM contractor_payments`
-	rows, err := db.Pool.Query(ctx, query)
+// PaymentList is how GET /api/payments may be sorted and filtered.
+var PaymentList = listquery.Spec[models.ContractorPayment]{
+	Sorts: map[string]listquery.Sort[models.ContractorPayment]{
+		"created_at":    {Column: "created_at", Type: "timestamp", Value: func(p models.ContractorPayment) string { return listquery.Time(p.CreatedAt) }},
+		"billing_month": {Column: "billing_month", Type: "text", Value: func(p models.ContractorPayment) string { return p.BillingMonth }},
+		"amount":        {Column: "amount", Type: "numeric", Value: func(p models.ContractorPayment) string { return listquery.Number(p.Amount) }},
+	},
+	DefaultSort: "-created_at",
+	Filters: map[string]listquery.Filter{
+		"talent_id":          {Column: "talent_id", Rules: "uuid"},
+		"project_id":         {Column: "project_id", Rules: "uuid"},
+		"status":             {Column: "status", Rules: "enum=payment_status"},
+		"billing_month_from": {Column: "billing_month", Op: ">=", Rules: "month"},
+		"billing_month_to":   {Column: "billing_month", Op: "<=", Rules: "month"},
+	},
+	ID: func(p models.ContractorPayment) uuid.UUID { return p.ID },
+}
+
+func (s *PaymentService) List(ctx context.Context, q *listquery.Query[models.ContractorPayment]) (*listquery.Page[models.ContractorPayment], error) {
+	where, args := q.Filter(" WHERE TRUE", nil)
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM contractor_payments`+where, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	query, args := q.Page(`SELECT id, talent_id, project_id, billing_month, amount, status, approved_at, approved_by, created_at FROM contractor_payments`+where, args)
+	rows, err := db.Pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -25,14 +60,17 @@ func (s *PaymentService) List(ctx context.Context) ([]models.ContractorPayment,
 	for rows.Next() {
 		var p models.ContractorPayment
 		err := rows.Scan(
-			&p.ID, &p.TalentID, &p.ProjectID, &p.BillingMonth, &p.Amount, &p.Status, &p.CreatedAt,
+			&p.ID, &p.TalentID, &p.ProjectID, &p.BillingMonth, &p.Amount, &p.Status, &p.ApprovedAt, &p.ApprovedBy, &p.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		payments = append(payments, p)
 	}
-	return payments, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.Result(payments, total), nil
 }
 
 func (s *PaymentService) Create(ctx context.Context, p *models.ContractorPayment) error {
@@ -49,3 +87,66 @@ func (s *PaymentService) Create(ctx context.Context, p *models.ContractorPayment
 		p.TalentID, p.ProjectID, p.BillingMonth, p.Amount, status,
 	).Scan(&p.ID, &p.CreatedAt)
 }
+
+// Approve moves a pending payout to APPROVED and emails the contractor.
+func (s *PaymentService) Approve(ctx context.Context, id string) (*models.ContractorPayment, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var p models.ContractorPayment
+	err = tx.QueryRow(ctx, `
+		UPDATE contractor_payments SET status = 'APPROVED', approved_at = now(), approved_by = $2
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING id, talent_id, project_id, billing_month, amount, status::text, approved_at, approved_by, created_at
+	`, id, contextUserID(ctx)).Scan(&p.ID, &p.TalentID, &p.ProjectID, &p.BillingMonth, &p.Amount, &p.Status, &p.ApprovedAt, &p.ApprovedBy, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM contractor_payments WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrPaymentNotApprovable
+		}
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var firstName, email string
+	var project *string
+	err = tx.QueryRow(ctx, `
+		SELECT t.first_name, t.email, (SELECT name FROM projects WHERE id = $2)
+		FROM talent t WHERE t.id = $1
+	`, p.TalentID, p.ProjectID).Scan(&firstName, &email, &project)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if strings.TrimSpace(email) != "" {
+		_, err = EnqueueEmail(ctx, tx, Email{
+			Kind: NotifyPayoutApproved,
+			To:   email,
+			Data: map[string]string{
+				"Company":       LoadCompanyProfile().Name,
+				"Name":          firstName,
+				"Amount":        formatMoney(p.Amount, ""),
+				"BillingPeriod": billingPeriod(p.BillingMonth),
+				"Project":       deref(project),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
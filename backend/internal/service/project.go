@@ -3,18 +3,55 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/dubai/platform/backend/internal/db"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrProjectNotFound = NotFoundError("project not found")
+
 type ProjectService struct{}
 
 func NewProjectService() *ProjectService {
 	return &ProjectService{}
 }
 
+// projectColumns and scanProject keep project queries in step. They select
+// from projects aliased as p, with the totals and team worked out from its
+// active assignments.
+const projectColumns = `
+	p.id, p.client_id, p.name, p.description, p.status::text, p.engagement_type, p.monthly_budget, p.target_hours_per_week, p.billable_days_per_month, p.created_at, p.version, p.updated_at,
+	(SELECT COUNT(*) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as active_assignments_count,
+	(SELECT COALESCE(SUM(pa.hours_per_week), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as current_weekly_hours,
+	(SELECT COALESCE(SUM(pa.monthly_client_rate), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as actual_monthly_revenue,
+	(SELECT COALESCE(SUM(pa.monthly_contractor_cost), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as actual_monthly_cost,
+	(SELECT COALESCE(SUM(ppr.count * ppr.bill_rate * COALESCE(p.billable_days_per_month, 21)), 0) FROM project_planned_roles ppr WHERE ppr.project_id = p.id) as planned_monthly_revenue,
+	(SELECT COALESCE(json_agg(json_build_object('id', t.id, 'first_name', t.first_name, 'last_name', t.last_name, 'role', pa.role)), '[]')
+	 FROM project_assignments pa
+	 JOIN talent t ON pa.talent_id = t.id
+	 WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as team_members`
+
+func scanProject(row pgx.Row) (*models.Project, error) {
+	var p models.Project
+	var teamMembersJSON []byte
+	err := row.Scan(
+		&p.ID, &p.ClientID, &p.Name, &p.Description, &p.Status, &p.EngagementType, &p.MonthlyBudget, &p.TargetHoursPerWeek, &p.BillableDaysPerMonth, &p.CreatedAt, &p.Version, &p.UpdatedAt,
+		&p.ActiveAssignmentsCount, &p.CurrentWeeklyHours,
+		&p.ActualMonthlyRevenue, &p.ActualMonthlyCost, &p.PlannedMonthlyRevenue,
+		&teamMembersJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(teamMembersJSON) > 0 {
+		_ = json.Unmarshal(teamMembersJSON, &p.TeamMembers)
+	}
+	return &p, nil
+}
+
 func (s *ProjectService) List(ctx context.Context) ([]models.Project, error) {
 	// RBAC: Check role and client_id from context
 	role, _ := ctx.Value("role").(string)
@@ -22,26 +59,13 @@ func (s *ProjectService) List(ctx context.Context) ([]models.Project, error) {
 
 	fmt.Printf("DEBUG: ProjectService.List - Role: %s, ClientID: %s\n", role, clientID)
 
-	baseQuery := `
-		SELECT 
-			p.id, p.client_id, p.name, p.description, p.status::text, p.engagement_type, p.monthly_budget, p.target_hours_per_week, p.billable_days_per_month, p.created_at,
-			(SELECT COUNT(*) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as active_assignments_count,
-			(SELECT COALESCE(SUM(pa.hours_per_week), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as current_weekly_hours,
-			(SELECT COALESCE(SUM(pa.monthly_client_rate), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as actual_monthly_revenue,
-			(SELECT COALESCE(SUM(pa.monthly_contractor_cost), 0) FROM project_assignments pa WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as actual_monthly_cost,
-			(SELECT COALESCE(SUM(ppr.count * ppr.bill_rate * COALESCE(p.billable_days_per_month, 21)), 0) FROM project_planned_roles ppr WHERE ppr.project_id = p.id) as planned_monthly_revenue,
-			(SELECT COALESCE(json_agg(json_build_object('id', t.id, 'first_name', t.first_name, 'last_name', t.last_name, 'role', pa.role)), '[]') 
-			 FROM project_assignments pa 
-			 JOIN talent t ON pa.talent_id = t.id 
-			 WHERE pa.project_id = p.id AND pa.status = 'ACTIVE') as team_members
-		FROM projects p 
-	`
+	baseQuery := `SELECT ` + projectColumns + ` FROM projects p WHERE p.deleted_at IS NULL`
 
 	var args []interface{}
 	// Robust RBAC: If the user belongs to a client (has clientID), strictly filter by it.
 	// This handles CLIENT_ADMIN, CLIENT_USER, and even mistakenly assigned ADMIN roles that have a client_id.
 	if clientID != "" {
-		baseQuery += " WHERE p.client_id = $1"
+		baseQuery += " AND p.client_id = $1"
 		args = append(args, clientID)
 	}
 
@@ -55,31 +79,20 @@ func (s *ProjectService) List(ctx context.Context) ([]models.Project, error) {
 
 	var projects []models.Project
 	for rows.Next() {
-		var p models.Project
-		var teamMembersJSON []byte
-		err := rows.Scan(
-			&p.ID, &p.ClientID, &p.Name, &p.Description, &p.Status, &p.EngagementType, &p.MonthlyBudget, &p.TargetHoursPerWeek, &p.BillableDaysPerMonth, &p.CreatedAt,
-			&p.ActiveAssignmentsCount, &p.CurrentWeeklyHours,
-			&p.ActualMonthlyRevenue, &p.ActualMonthlyCost, &p.PlannedMonthlyRevenue,
-			&teamMembersJSON,
-		)
+		p, err := scanProject(rows)
 		if err != nil {
 			return nil, err
 		}
-		if len(teamMembersJSON) > 0 {
-			_ = json.Unmarshal(teamMembersJSON, &p.TeamMembers)
-		}
-		projects = append(projects, p)
+		projects = append(projects, *p)
 	}
 	return projects, nil
 }
 
 func (s *ProjectService) Get(ctx context.Context, id string) (*models.Project, error) {
-	query := `SELECT id, client_id, name, description, status::text, engagement_type, monthly_budget, target_hours_per_week, billable_days_per_month, created_at FROM projects WHERE id = $1`
-	var p models.Project
-	err := db.Pool.QueryRow(ctx, query, id).Scan(
-		&p.ID, &p.ClientID, &p.Name, &p.Description, &p.Status, &p.EngagementType, &p.MonthlyBudget, &p.TargetHoursPerWeek, &p.BillableDaysPerMonth, &p.CreatedAt,
-	)
+	p, err := scanProject(db.Pool.QueryRow(ctx, `SELECT `+projectColumns+` FROM projects p WHERE p.id = $1 AND p.deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProjectNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -100,14 +113,14 @@ func (s *ProjectService) Get(ctx context.Context, id string) (*models.Project, e
 		p.PlannedRoles = append(p.PlannedRoles, pr)
 	}
 
-	return &p, nil
+	return p, nil
 }
 
 func (s *ProjectService) Create(ctx context.Context, p *models.Project) error {
 	query := `
 		INSERT INTO projects (client_id, name, description, status, engagement_type, monthly_budget, target_hours_per_week, billable_days_per_month)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at
+		RETURNING id, created_at, version, updated_at
 	`
 	// Defaults if missing
 	if p.EngagementType == "" {
@@ -120,7 +133,7 @@ func (s *ProjectService) Create(ctx context.Context, p *models.Project) error {
 
 	err := db.Pool.QueryRow(ctx, query,
 		p.ClientID, p.Name, p.Description, p.Status, p.EngagementType, p.MonthlyBudget, p.TargetHoursPerWeek, p.BillableDaysPerMonth,
-	).Scan(&p.ID, &p.CreatedAt)
+	).Scan(&p.ID, &p.CreatedAt, &p.Version, &p.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -136,6 +149,8 @@ func (s *ProjectService) Create(ctx context.Context, p *models.Project) error {
 	return nil
 }
 
+// Update replaces the project's fields. A non-zero p.Version must match the
+// stored version. Planned roles are replaced when p.PlannedRoles is non-nil.
 func (s *ProjectService) Update(ctx context.Context, id string, p *models.Project) error {
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
@@ -143,19 +158,36 @@ func (s *ProjectService) Update(ctx context.Context, id string, p *models.Projec
 	}
 	defer tx.Rollback(ctx)
 
+	var version int
+	err = tx.QueryRow(ctx, `SELECT version FROM projects WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrProjectNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(p.Version, version); err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE projects 
-		SET name = $1, description = $2, status = $3, engagement_type = $4, monthly_budget = $5, target_hours_per_week = $6, billable_days_per_month = $7
+		SET name = $1, description = $2, status = $3, engagement_type = $4, monthly_budget = $5, target_hours_per_week = $6, billable_days_per_month = $7,
+			version = version + 1, updated_at = now()
 		WHERE id = $8
-		RETURNING created_at
+		RETURNING client_id, created_at, version, updated_at
 	`
 	err = tx.QueryRow(ctx, query,
 		p.Name, p.Description, p.Status, p.EngagementType, p.MonthlyBudget, p.TargetHoursPerWeek, p.BillableDaysPerMonth, id,
-	).Scan(&p.CreatedAt)
+	).Scan(&p.ClientID, &p.CreatedAt, &p.Version, &p.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
+	if p.PlannedRoles == nil {
+		return tx.Commit(ctx)
+	}
+
 	// Update Planned Roles (Reflect blueprint changes)
 	_, err = tx.Exec(ctx, `DELETE FROM project_planned_roles WHERE project_id = $1`, id)
 	if err != nil {
@@ -173,46 +205,8 @@ func (s *ProjectService) Update(ctx context.Context, id string, p *models.Projec
 	return tx.Commit(ctx)
 }
 
+// Delete moves the project to the trash. Assignments are kept so a restore is
+// lossless; financial checks are enforced when the trash is purged.
 func (s *ProjectService) Delete(ctx context.Context, id string) error {
-	tx, err := db.Pool.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
-
-	// Check for financial records that block deletion
-	var count int
-
-	// Check invoices
-	err = tx.QueryRow(ctx, "SELECT COUNT(*) FROM invoice_line_items WHERE project_id = $1", id).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return fmt.Errorf("cannot delete project: it has %d invoice records. Please remove these records first to maintain financial integrity", count)
-	}
-
-	// Check payments
-	err = tx.QueryRow(ctx, "SELECT COUNT(*) FROM contractor_payments WHERE project_id = $1", id).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count > 0 {
-		return fmt.Errorf("cannot delete project: it has %d contractor payment records. Please remove these records first", count)
-	}
-
-	// Explicitly cleanup assignments if they cause issues (though DB has ON DELETE CASCADE on project_id)
-	// Some DB environments might have different constraints or trigger behaviors.
-	_, err = tx.Exec(ctx, `DELETE FROM project_assignments WHERE project_id = $1`, id)
-	if err != nil {
-		return err
-	}
-
-	// Delete the project
-	_, err = tx.Exec(ctx, `DELETE FROM projects WHERE id = $1`, id)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit(ctx)
+	return softDelete(ctx, "projects", id)
 }
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/scheduler"
+)
+
+// taskRunRetention is how long the scheduler's run history is kept.
+const taskRunRetention = 90 * 24 * time.Hour
+
+// RetentionConfig holds how long the housekeeping tasks keep things.
+type RetentionConfig struct {
+	Trash             time.Duration
+	LoginHistory      time.Duration
+	WebhookDeliveries time.Duration
+	Outbox            time.Duration
+}
+
+// taskSchedule returns the cron expression for a task: SCHEDULE_<NAME>
+// (e.g. SCHEDULE_TRASH_RETENTION for trash-retention) if set, else def.
+func taskSchedule(name, def string) string {
+	if v := os.Getenv("SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))); v != "" {
+		return v
+	}
+	return def
+}
+
+// RegisterScheduledTasks registers the platform's recurring tasks. It must
+// be called before scheduler.Start.
+func RegisterScheduledTasks(cfg RetentionConfig) error {
+	trash := NewTrashService()
+	contracts := NewContractService()
+	assignments := NewAssignmentService()
+	invoices := NewInvoiceService()
+	auth := NewAuthService()
+	webhooks := NewWebhookService()
+
+	tasks := []scheduler.Task{
+		{
+			Name:        "trial-expiry",
+			Schedule:    "0 1 * * *",
+			Description: "Make assignments whose trial has ended ACTIVE",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := assignments.ExpireTrials(ctx)
+				return fmt.Sprintf("%d trials ended", n), err
+			},
+		},
+		{
+			Name:        "invoice-overdue",
+			Schedule:    "0 2 * * *",
+			Description: "Mark sent invoices past their due date OVERDUE",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := invoices.MarkOverdue(ctx)
+				return fmt.Sprintf("%d invoices overdue", n), err
+			},
+		},
+		{
+			Name:        "monthly-invoice-drafts",
+			Schedule:    "0 6 1 * *",
+			Description: "Draft last month's invoices from running assignments",
+			Run: func(ctx context.Context) (string, error) {
+				now := time.Now().In(scheduler.Location)
+				month := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, scheduler.Location).Format("2006-01")
+				created, err := invoices.DraftMonthly(ctx, month)
+				return fmt.Sprintf("%d draft invoices for %s", len(created), month), err
+			},
+		},
+		{
+			Name:        "contract-expiry",
+			Schedule:    "0 7 * * *",
+			Description: "Remind staff of signed contracts entering their notice period",
+			Run: func(ctx context.Context) (string, error) {
+				flagged, err := contracts.ScanExpiry(ctx)
+				for _, c := range flagged {
+					log.Printf("Contract %s (%s) ends on %s and is within its %d day notice period", c.ID, c.Type, c.EndDate.Format("2006-01-02"), c.NoticePeriod)
+				}
+				return fmt.Sprintf("%d contracts flagged", len(flagged)), err
+			},
+		},
+		{
+			Name:        "trash-retention",
+			Schedule:    "0 * * * *",
+			Description: "Purge soft-deleted records and their files past TRASH_RETENTION_DAYS",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := trash.PurgeExpired(ctx, cfg.Trash)
+				return fmt.Sprintf("%d items purged", n), err
+			},
+		},
+		{
+			Name:        "login-history-retention",
+			Schedule:    "30 3 * * *",
+			Description: "Delete login history older than LOGIN_HISTORY_RETENTION_DAYS",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := auth.PruneLoginHistory(ctx, cfg.LoginHistory)
+				return fmt.Sprintf("%d login attempts removed", n), err
+			},
+		},
+		{
+			Name:        "webhook-delivery-retention",
+			Schedule:    "45 3 * * *",
+			Description: "Delete webhook deliveries older than WEBHOOK_DELIVERY_RETENTION_DAYS",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := webhooks.PruneDeliveries(ctx, cfg.WebhookDeliveries)
+				return fmt.Sprintf("%d deliveries removed", n), err
+			},
+		},
+		{
+			Name:        "outbox-retention",
+			Schedule:    "0 4 * * *",
+			Description: "Delete dispatched domain events older than OUTBOX_RETENTION_DAYS",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := events.Prune(ctx, cfg.Outbox)
+				return fmt.Sprintf("%d events removed", n), err
+			},
+		},
+		{
+			Name:        "scheduler-run-retention",
+			Schedule:    "15 4 * * *",
+			Description: "Delete scheduled task runs older than 90 days",
+			Run: func(ctx context.Context) (string, error) {
+				n, err := scheduler.PruneRuns(ctx, taskRunRetention)
+				return fmt.Sprintf("%d runs removed", n), err
+			},
+		},
+	}
+	for _, t := range tasks {
+		t.Schedule = taskSchedule(t.Name, t.Schedule)
+		if err := scheduler.Register(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
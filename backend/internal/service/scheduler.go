@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/scheduler"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrScheduledTaskNotFound = NotFoundError("scheduled task not found")
+
+// SchedulerService lets admins see, trigger and pause the recurring tasks
+// registered with the scheduler.
+type SchedulerService struct{}
+
+func NewSchedulerService() *SchedulerService {
+	return &SchedulerService{}
+}
+
+const scheduledTaskColumns = `t.name, t.schedule, t.description, t.next_run_at, t.paused_at, t.paused_by, t.run_requested_at`
+
+const taskRunColumns = `id, task_name, status, triggered_by, instance, result, error, duration_ms, started_at, finished_at`
+
+func scanTaskRun(row pgx.Row) (*models.ScheduledTaskRun, error) {
+	var r models.ScheduledTaskRun
+	err := row.Scan(&r.ID, &r.TaskName, &r.Status, &r.TriggeredBy, &r.Instance, &r.Result, &r.Error, &r.DurationMS, &r.StartedAt, &r.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// List returns the tasks this build runs, in the order they were
+// registered, each with its latest run.
+func (s *SchedulerService) List(ctx context.Context) ([]models.ScheduledTask, error) {
+	names := scheduler.Names()
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+scheduledTaskColumns+`,
+			r.id, r.task_name, r.status, r.triggered_by, r.instance, r.result, r.error, r.duration_ms, r.started_at, r.finished_at
+		FROM scheduled_tasks t
+		LEFT JOIN LATERAL (
+			SELECT * FROM scheduled_task_runs WHERE task_name = t.name ORDER BY started_at DESC LIMIT 1
+		) r ON true
+		WHERE t.name = ANY($1)
+		ORDER BY array_position($1, t.name)
+	`, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.ScheduledTask{}
+	for rows.Next() {
+		var t models.ScheduledTask
+		var run models.ScheduledTaskRun
+		var runID *uuid.UUID
+		var runTaskName, runStatus *string
+		var runStartedAt *time.Time
+		err := rows.Scan(&t.Name, &t.Schedule, &t.Description, &t.NextRunAt, &t.PausedAt, &t.PausedBy, &t.RunRequestedAt,
+			&runID, &runTaskName, &runStatus, &run.TriggeredBy, &run.Instance, &run.Result, &run.Error, &run.DurationMS, &runStartedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		// Tasks that never ran have no run to join
+		if runID != nil {
+			run.ID, run.TaskName, run.Status, run.StartedAt = *runID, *runTaskName, *runStatus, *runStartedAt
+			t.LastRun = &run
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// Get returns one task with its latest run.
+func (s *SchedulerService) Get(ctx context.Context, name string) (*models.ScheduledTask, error) {
+	tasks, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tasks {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+	return nil, ErrScheduledTaskNotFound
+}
+
+// Runs lists a task's most recent runs, optionally only those with status.
+func (s *SchedulerService) Runs(ctx context.Context, name, status string, limit int) ([]models.ScheduledTaskRun, error) {
+	if !registeredTask(name) {
+		return nil, ErrScheduledTaskNotFound
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := `SELECT ` + taskRunColumns + ` FROM scheduled_task_runs WHERE task_name = $1`
+	args := []interface{}{name}
+	argID := 2
+	if status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argID)
+		args = append(args, strings.ToUpper(status))
+		argID++
+	}
+	query += fmt.Sprintf(" ORDER BY started_at DESC LIMIT $%d", argID)
+	args = append(args, limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.ScheduledTaskRun{}
+	for rows.Next() {
+		r, err := scanTaskRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
+// Trigger asks for a task to run now, even if it is paused. The scheduler
+// picks the request up within its poll interval on whichever instance gets
+// the task's lock.
+func (s *SchedulerService) Trigger(ctx context.Context, name string) (*models.ScheduledTask, error) {
+	return s.update(ctx, name,
+		`UPDATE scheduled_tasks SET run_requested_at = COALESCE(run_requested_at, now()), run_requested_by = $2, updated_at = now() WHERE name = $1`,
+		contextUserID(ctx))
+}
+
+// Pause stops a task's scheduled runs until it is resumed. A run in
+// progress finishes.
+func (s *SchedulerService) Pause(ctx context.Context, name string) (*models.ScheduledTask, error) {
+	return s.update(ctx, name,
+		`UPDATE scheduled_tasks SET paused_at = COALESCE(paused_at, now()), paused_by = $2, updated_at = now() WHERE name = $1`,
+		contextUserID(ctx))
+}
+
+// Resume restarts a paused task from its next occurrence; runs missed while
+// it was paused are skipped.
+func (s *SchedulerService) Resume(ctx context.Context, name string) (*models.ScheduledTask, error) {
+	return s.update(ctx, name,
+		`UPDATE scheduled_tasks SET paused_at = NULL, paused_by = NULL, next_run_at = CASE WHEN paused_at IS NULL THEN next_run_at ELSE $2 END, updated_at = now() WHERE name = $1`,
+		scheduler.NextRun(name, time.Now()))
+}
+
+func (s *SchedulerService) update(ctx context.Context, name, query string, arg interface{}) (*models.ScheduledTask, error) {
+	if !registeredTask(name) {
+		return nil, ErrScheduledTaskNotFound
+	}
+	tag, err := db.Pool.Exec(ctx, query, name, arg)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrScheduledTaskNotFound
+	}
+	return s.Get(ctx, name)
+}
+
+func registeredTask(name string) bool {
+	for _, n := range scheduler.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterEventSubscribers wires the reactions to domain events. New
+// reactions belong here rather than in the service that publishes the event.
+// It must be called before events.StartDispatcher.
+func RegisterEventSubscribers() {
+	events.Subscribe("talent_status_history", recordInitialTalentStatus)
+	events.Subscribe("talent_status_history", recordTalentStatusChange)
+	events.Subscribe("ocr", queueDocumentOCR)
+
+	events.Subscribe("webhooks", forwardToWebhooks[events.TalentStatusChanged])
+	events.Subscribe("webhooks", forwardToWebhooks[events.AssignmentCreated])
+	events.Subscribe("webhooks", forwardToWebhooks[events.AssignmentTrialEnded])
+	events.Subscribe("webhooks", forwardToWebhooks[events.InvoiceSent])
+	events.Subscribe("webhooks", forwardToWebhooks[events.InvoicePaid])
+	events.Subscribe("webhooks", forwardToWebhooks[events.InvoiceOverdue])
+	events.Subscribe("webhooks", forwardToWebhooks[events.ContractSigned])
+	events.Subscribe("webhooks", forwardToWebhooks[events.DocumentOCRCompleted])
+}
+
+// recordInitialTalentStatus starts the status history of new talent. The
+// history is stamped with when the change happened, not when it was handled.
+func recordInitialTalentStatus(ctx context.Context, tx pgx.Tx, meta events.Meta, e events.TalentCreated) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO talent_status_history (talent_id, status, notes, changed_at)
+		VALUES ($1, $2::talent_status, $3, $4)
+	`, e.TalentID, e.Status, "Initial creation", meta.OccurredAt)
+	return err
+}
+
+func recordTalentStatusChange(ctx context.Context, tx pgx.Tx, meta events.Meta, e events.TalentStatusChanged) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO talent_status_history (talent_id, status, notes, changed_at)
+		VALUES ($1, $2::talent_status, $3, $4)
+	`, e.TalentID, e.Status, "Status updated via Talent Edit", meta.OccurredAt)
+	return err
+}
+
+// queueDocumentOCR queues text extraction for uploaded documents, unless a
+// rerun already queued it in the meantime.
+func queueDocumentOCR(ctx context.Context, tx pgx.Tx, meta events.Meta, e events.DocumentCreated) error {
+	if !e.NeedsOCR {
+		return nil
+	}
+	var queued bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM jobs WHERE kind = $1 AND payload->>'document_id' = $2 AND status IN ('QUEUED', 'RUNNING'))`,
+		OCRJobKind, e.DocumentID.String(),
+	).Scan(&queued)
+	if err != nil || queued {
+		return err
+	}
+	return EnqueueOCR(ctx, tx, e.DocumentID.String())
+}
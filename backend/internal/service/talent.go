@@ -2,20 +2,42 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
 	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var ErrTalentNotFound = NotFoundError("talent not found")
+
 type TalentService struct{}
 
 func NewTalentService() *TalentService {
 	return &TalentService{}
 }
 
+// talentColumns and scanTalent keep talent queries in step. Skills and
+// history are loaded separately.
+const talentColumns = `id, first_name, last_name, email, linkedin_url, country, timezone, role, seniority, english_level, source, notes, status::text, created_at, version, updated_at`
+
+func scanTalent(row pgx.Row) (*models.Talent, error) {
+	var t models.Talent
+	err := row.Scan(
+		&t.ID, &t.FirstName, &t.LastName, &t.Email, &t.LinkedinURL, &t.Country, &t.Timezone, &t.Role, &t.Seniority, &t.EnglishLevel, &t.Source, &t.Notes, &t.Status, &t.CreatedAt, &t.Version, &t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func (s *TalentService) ListTalent(ctx context.Context) ([]models.Talent, error) {
-	query := `SELECT id, first_name, last_name, email, role, seniority, source, notes, country, status::text, created_at FROM talent`
+	query := `SELECT ` + talentColumns + ` FROM talent WHERE deleted_at IS NULL`
 	rows, err := db.Pool.Query(ctx, query)
 	if err != nil {
 		log.Printf("ListTalent Query Error: %v", err)
@@ -25,15 +47,12 @@ func (s *TalentService) ListTalent(ctx context.Context) ([]models.Talent, error)
 
 	var talents []models.Talent
 	for rows.Next() {
-		var t models.Talent
-		err := rows.Scan(
-			&t.ID, &t.FirstName, &t.LastName, &t.Email, &t.Role, &t.Seniority, &t.Source, &t.Notes, &t.Country, &t.Status, &t.CreatedAt,
-		)
+		t, err := scanTalent(rows)
 		if err != nil {
 			log.Printf("ListTalent Scan Error: %v", err)
 			return nil, err
 		}
-		talents = append(talents, t)
+		talents = append(talents, *t)
 	}
 	return talents, nil
 }
@@ -46,11 +65,10 @@ func (s *TalentService) Get(ctx context.Context, id string) (*models.Talent, err
 	defer tx.Rollback(ctx)
 
 	// 1. Fetch Basic Info
-	query := `SELECT id, first_name, last_name, email, role, seniority, source, notes, country, status::text, created_at FROM talent WHERE id = $1`
-	var t models.Talent
-	err = tx.QueryRow(ctx, query, id).Scan(
-		&t.ID, &t.FirstName, &t.LastName, &t.Email, &t.Role, &t.Seniority, &t.Source, &t.Notes, &t.Country, &t.Status, &t.CreatedAt,
-	)
+	t, err := scanTalent(tx.QueryRow(ctx, `SELECT `+talentColumns+` FROM talent WHERE id = $1 AND deleted_at IS NULL`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTalentNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +122,7 @@ func (s *TalentService) Get(ctx context.Context, id string) (*models.Talent, err
 	}
 	t.History = history
 
-	return &t, tx.Commit(ctx)
+	return t, tx.Commit(ctx)
 }
 
 func (s *TalentService) Create(ctx context.Context, t *models.Talent) error {
@@ -115,9 +133,9 @@ func (s *TalentService) Create(ctx context.Context, t *models.Talent) error {
 	defer tx.Rollback(ctx)
 
 	query := `
-		INSERT INTO talent (first_name, last_name, email, role, seniority, source, notes, country, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, created_at
+		INSERT INTO talent (first_name, last_name, email, linkedin_url, country, timezone, role, seniority, english_level, source, notes, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, version, updated_at
 	`
 	status := "SOURCED"
 	if t.Status != nil && *t.Status != "" {
@@ -125,37 +143,29 @@ func (s *TalentService) Create(ctx context.Context, t *models.Talent) error {
 	}
 
 	err = tx.QueryRow(ctx, query,
-		t.FirstName, t.LastName, t.Email, t.Role, t.Seniority, t.Source, t.Notes, t.Country, status,
-	).Scan(&t.ID, &t.CreatedAt)
+		t.FirstName, t.LastName, t.Email, t.LinkedinURL, t.Country, t.Timezone, t.Role, t.Seniority, t.EnglishLevel, t.Source, t.Notes, status,
+	).Scan(&t.ID, &t.CreatedAt, &t.Version, &t.UpdatedAt)
 	t.Status = &status
 	if err != nil {
 		return err
 	}
 
-	// 1. Log Status History (Initial Status: SOURCED)
-	statusQuery := `
-		INSERT INTO talent_status_history (talent_id, status, notes)
-		VALUES ($1, $2::talent_status, $3)
-	`
-	_, err = tx.Exec(ctx, statusQuery, t.ID, "SOURCED", "Initial creation")
-	if err != nil {
+	// 1. Status history is recorded by the talent_status_history subscriber
+	if err := events.Publish(ctx, tx, events.TalentCreated{TalentID: t.ID, Status: status}); err != nil {
 		return err
 	}
 
 	// 2. Link Skills
-	if len(t.Skills) > 0 {
-		skillQuery := `INSERT INTO talent_skills (talent_id, skill_id) VALUES ($1, $2)`
-		for _, skillID := range t.Skills {
-			_, err = tx.Exec(ctx, skillQuery, t.ID, skillID)
-			if err != nil {
-				return err
-			}
-		}
+	if err := linkSkills(ctx, tx, t.ID.String(), t.Skills); err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
+// Update replaces the talent's fields. A non-zero t.Version must match the
+// stored version. Skills are replaced when t.Skills is non-nil, so callers
+// that don't send them keep the existing ones.
 func (s *TalentService) Update(ctx context.Context, id string, t *models.Talent) error {
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
@@ -164,17 +174,26 @@ func (s *TalentService) Update(ctx context.Context, id string, t *models.Talent)
 	defer tx.Rollback(ctx)
 
 	// 0. Get current status for history check
+	var talentID uuid.UUID
 	var oldStatus string
-	err = tx.QueryRow(ctx, "SELECT status FROM talent WHERE id = $1", id).Scan(&oldStatus)
+	var version int
+	err = tx.QueryRow(ctx, "SELECT id, status, version FROM talent WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&talentID, &oldStatus, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTalentNotFound
+	}
 	if err != nil {
 		return err
 	}
+	if err := checkVersion(t.Version, version); err != nil {
+		return err
+	}
 
 	query := `
 		UPDATE talent 
-		SET first_name = $1, last_name = $2, email = $3, role = $4, seniority = $5, source = $6, notes = $7, country = $8, status = $9
-		WHERE id = $10
-		RETURNING created_at
+		SET first_name = $1, last_name = $2, email = $3, linkedin_url = $4, country = $5, timezone = $6, role = $7, seniority = $8, english_level = $9, source = $10, notes = $11, status = $12,
+			version = version + 1, updated_at = now()
+		WHERE id = $13
+		RETURNING created_at, version, updated_at
 	`
 	newStatus := oldStatus
 	if t.Status != nil && *t.Status != "" {
@@ -182,45 +201,63 @@ func (s *TalentService) Update(ctx context.Context, id string, t *models.Talent)
 	}
 
 	err = tx.QueryRow(ctx, query,
-		t.FirstName, t.LastName, t.Email, t.Role, t.Seniority, t.Source, t.Notes, t.Country, newStatus, id,
-	).Scan(&t.CreatedAt)
+		t.FirstName, t.LastName, t.Email, t.LinkedinURL, t.Country, t.Timezone, t.Role, t.Seniority, t.EnglishLevel, t.Source, t.Notes, newStatus, id,
+	).Scan(&t.CreatedAt, &t.Version, &t.UpdatedAt)
 	if err != nil {
 		return err
 	}
+	t.ID = talentID
+	t.Status = &newStatus
 
-	// 0a. Log history if status changed
+	// 0a. Subscribers log the history and notify webhooks if status changed
 	if newStatus != oldStatus {
-		statusQuery := `
-			INSERT INTO talent_status_history (talent_id, status, notes)
-			VALUES ($1, $2::talent_status, $3)
-		`
-		_, err = tx.Exec(ctx, statusQuery, id, newStatus, "Status updated via Talent Edit")
+		err = events.Publish(ctx, tx, events.TalentStatusChanged{TalentID: talentID, PreviousStatus: oldStatus, Status: newStatus})
 		if err != nil {
 			return err
 		}
 	}
 
-	// Update Skills: Simple approach -> Delete all and re-insert
-	// A better approach would be checking diffs, but this is MVP.
-	_, err = tx.Exec(ctx, "DELETE FROM talent_skills WHERE talent_id = $1", id)
-	if err != nil {
-		return err
+	if t.Skills != nil {
+		_, err = tx.Exec(ctx, "DELETE FROM talent_skills WHERE talent_id = $1", id)
+		if err != nil {
+			return err
+		}
+		if err := linkSkills(ctx, tx, id, t.Skills); err != nil {
+			return err
+		}
 	}
 
-	if len(t.Skills) > 0 {
-		skillQuery := `INSERT INTO talent_skills (talent_id, skill_id) VALUES ($1, $2)`
-		for _, skillNameOrID := range t.Skills {
-			// Basic handling assuming IDs are passed.
-			// If Names are passed in UI, we need to lookup IDs or Insert them if they don't exist.
-			// For now, assuming UI passes IDs from the available list.
-			// Wait, the UI uses names for badges?
-			// Checking TalentForm... it toggles skill.id. So we get IDs.
-			_, err = tx.Exec(ctx, skillQuery, id, skillNameOrID)
-			if err != nil {
+	return tx.Commit(ctx)
+}
+
+// linkSkills adds skills to a talent profile. Skills are given by ID, as the
+// talent form sends them, or by name, as Get returns them, so a profile read
+// from the API can be written back unchanged.
+func linkSkills(ctx context.Context, tx pgx.Tx, talentID string, skills []string) error {
+	query := `
+		INSERT INTO talent_skills (talent_id, skill_id)
+		SELECT $1, id FROM skills WHERE id::text = $2 OR name = $2
+		ON CONFLICT DO NOTHING
+	`
+	for i, skill := range skills {
+		tag, err := tx.Exec(ctx, query, talentID, skill)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM skills WHERE id::text = $1 OR name = $1)`, skill).Scan(&exists); err != nil {
 				return err
 			}
+			if !exists {
+				return ValidationError("unknown skill", FieldError{Field: fmt.Sprintf("skills[%d]", i), Message: "is not a known skill"})
+			}
 		}
 	}
+	return nil
+}
 
-	return tx.Commit(ctx)
+// Delete moves the talent profile to the trash.
+func (s *TalentService) Delete(ctx context.Context, id string) error {
+	return softDelete(ctx, "talent", id)
 }
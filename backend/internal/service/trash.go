@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/dubai/platform/backend/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// trashTable describes how a soft-deletable entity is stored.
+type trashTable struct {
+	Table    string
+	NameExpr string
+	Filter   string // Extra condition selecting the rows listed as trash items
+}
+
+// Purge order matters: children first so foreign keys don't block their parents.
+var trashEntityOrder = []string{"document", "contract", "project", "talent", "client"}
+
+var trashTables = map[string]trashTable{
+	"client":   {Table: "clients", NameExpr: "company_name"},
+	"project":  {Table: "projects", NameExpr: "name"},
+	"talent":   {Table: "talent", NameExpr: "first_name || ' ' || last_name"},
+	"contract": {Table: "contracts", NameExpr: "contract_type::text"},
+	// A trashed document is listed once, by its latest version.
+	"document": {Table: "documents", NameExpr: "file_name", Filter: "superseded_by IS NULL"},
+}
+
+func (t trashTable) filter() string {
+	if t.Filter == "" {
+		return ""
+	}
+	return " AND " + t.Filter
+}
+
+var ErrNotInTrash = NotFoundError("item not found in trash")
+
+type TrashService struct{}
+
+func NewTrashService() *TrashService {
+	return &TrashService{}
+}
+
+// softDelete stamps deleted_at/deleted_by on a live row. The caller's user_id is
+// taken from the request context when available.
+func softDelete(ctx context.Context, table string, id string) error {
+	var deletedBy *string
+	if userID, ok := ctx.Value("user_id").(string); ok && userID != "" {
+		deletedBy = &userID
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = now(), deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL`, table)
+	result, err := db.Pool.Exec(ctx, query, id, deletedBy)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return NotFoundError(fmt.Sprintf("%s record not found", table))
+	}
+	return nil
+}
+
+func (s *TrashService) List(ctx context.Context, entityType string) ([]models.TrashItem, error) {
+	items := []models.TrashItem{}
+	for _, et := range trashEntityOrder {
+		if entityType != "" && entityType != et {
+			continue
+		}
+		t := trashTables[et]
+		query := fmt.Sprintf(`SELECT id, %s, deleted_at, deleted_by FROM %s WHERE deleted_at IS NOT NULL%s ORDER BY deleted_at DESC`, t.NameExpr, t.Table, t.filter())
+		rows, err := db.Pool.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			item := models.TrashItem{EntityType: et}
+			if err := rows.Scan(&item.ID, &item.Name, &item.DeletedAt, &item.DeletedBy); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (s *TrashService) Restore(ctx context.Context, entityType string, id string) error {
+	t, ok := trashTables[entityType]
+	if !ok {
+		return ValidationError(fmt.Sprintf("unknown entity type: %s", entityType))
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	query := fmt.Sprintf(`SELECT deleted_at FROM %s WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, t.Table)
+	if err := tx.QueryRow(ctx, query, id).Scan(&deletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotInTrash
+		}
+		return err
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`UPDATE %s SET deleted_at = NULL, deleted_by = NULL WHERE id = $1`, t.Table), id)
+	if err != nil {
+		return err
+	}
+
+	switch entityType {
+	case "client":
+		// Projects trashed together with their client come back with it.
+		_, err = tx.Exec(ctx, `UPDATE projects SET deleted_at = NULL, deleted_by = NULL WHERE client_id = $1 AND deleted_at = $2`, id, deletedAt)
+	case "document":
+		// So do the earlier versions of a document.
+		_, err = tx.Exec(ctx, `UPDATE documents SET deleted_at = NULL, deleted_by = NULL WHERE logical_id = (SELECT logical_id FROM documents WHERE id = $1) AND deleted_at = $2`, id, deletedAt)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// purgeCheck counts records that keep an entity from being purged. They
+// reference it with ON DELETE RESTRICT and matter in their own right, so they
+// have to be removed by hand first.
+type purgeCheck struct {
+	Query string
+	What  string
+}
+
+// purgeScope describes what goes with an entity when it is purged: the
+// contracts it owns (a condition on contracts using $1) and the records
+// documents may be filed against.
+type purgeScope struct {
+	Contracts string
+	Documents []string // Queries returning the entity_type and entity_id of owned records
+	Checks    []purgeCheck
+}
+
+var purgeScopes = map[string]purgeScope{
+	"project": {
+		Contracts: "project_id = $1",
+		Documents: []string{
+			`SELECT 'PROJECT', $1::uuid`,
+			`SELECT 'ASSIGNMENT', id FROM project_assignments WHERE project_id = $1`,
+		},
+		Checks: []purgeCheck{
+			{`SELECT COUNT(*) FROM invoice_line_items WHERE project_id = $1`, "invoice line items"},
+			{`SELECT COUNT(*) FROM contractor_payments WHERE project_id = $1`, "contractor payments"},
+		},
+	},
+	"client": {
+		// Its projects are purged first and take their own contracts along.
+		Contracts: "client_id = $1",
+		Documents: []string{`SELECT 'CLIENT', $1::uuid`},
+		Checks: []purgeCheck{
+			{`SELECT COUNT(*) FROM invoices WHERE client_id = $1`, "invoices"},
+			{`SELECT COUNT(*) FROM projects WHERE client_id = $1 AND deleted_at IS NULL`, "projects that are not in the trash"},
+			{`SELECT COUNT(*) FROM project_assignments WHERE client_id = $1 AND project_id NOT IN (SELECT id FROM projects WHERE client_id = $1)`, "assignments on other clients' projects"},
+		},
+	},
+	"talent": {
+		Contracts: "talent_id = $1",
+		Documents: []string{`SELECT 'TALENT', $1::uuid`},
+		Checks: []purgeCheck{
+			{`SELECT COUNT(*) FROM project_assignments WHERE talent_id = $1`, "project assignments"},
+			{`SELECT COUNT(*) FROM contractor_payments WHERE talent_id = $1`, "contractor payments"},
+		},
+	},
+	"contract": {
+		Contracts: "id = $1",
+		Documents: []string{`SELECT 'CONTRACT', $1::uuid`},
+	},
+}
+
+// checks adds to the scope's own checks the contracts of other records that
+// are filed under one of its MSAs.
+func (p purgeScope) checks() []purgeCheck {
+	owned := fmt.Sprintf(`SELECT id FROM contracts WHERE %s`, p.Contracts)
+	return append(p.Checks, purgeCheck{
+		Query: fmt.Sprintf(`SELECT COUNT(*) FROM contracts WHERE msa_id IN (%s) AND id NOT IN (%s)`, owned, owned),
+		What:  "other contracts filed under its MSA",
+	})
+}
+
+// Purge permanently removes a trashed row together with the contracts and
+// documents it owns and their files. Entities still referenced by financial
+// records or assignments are refused with a conflict and stay in the trash.
+func (s *TrashService) Purge(ctx context.Context, entityType string, id string) error {
+	t, ok := trashTables[entityType]
+	if !ok {
+		return ValidationError(fmt.Sprintf("unknown entity type: %s", entityType))
+	}
+
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1 AND deleted_at IS NOT NULL%s)`, t.Table, t.filter())
+	if err := db.Pool.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotInTrash
+	}
+
+	if entityType == "document" {
+		return purgeDocument(ctx, id)
+	}
+	return purgeEntity(ctx, entityType, id)
+}
+
+// purgeEntity removes everything an entity owns, files first, then the row
+// itself. A failure part way leaves the entity in the trash so the next
+// purge run picks up where this one stopped.
+func purgeEntity(ctx context.Context, entityType string, id string) error {
+	scope := purgeScopes[entityType]
+	if err := checkPurge(ctx, db.Pool, entityType, id, scope); err != nil {
+		return err
+	}
+
+	if entityType == "client" {
+		projectIDs, err := queryIDs(ctx, `SELECT id::text FROM projects WHERE client_id = $1`, id)
+		if err != nil {
+			return err
+		}
+		for _, projectID := range projectIDs {
+			if err := purgeEntity(ctx, "project", projectID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if entityType != "contract" {
+		// SOWs before the MSAs they are filed under.
+		contractIDs, err := queryIDs(ctx, fmt.Sprintf(`SELECT id::text FROM contracts WHERE %s ORDER BY msa_id IS NULL`, scope.Contracts), id)
+		if err != nil {
+			return err
+		}
+		for _, contractID := range contractIDs {
+			if err := purgeEntity(ctx, "contract", contractID); err != nil {
+				return err
+			}
+			log.Printf("Trash purge removed contract %s of %s %s", contractID, entityType, id)
+		}
+	}
+
+	for _, owned := range scope.Documents {
+		err := deleteDocuments(ctx, fmt.Sprintf(`
+			SELECT id::text, file_key FROM documents
+			WHERE (upper(entity_type), entity_id) IN (%s)
+			ORDER BY version
+		`, owned), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if entityType == "contract" {
+		var fileKey *string
+		if err := db.Pool.QueryRow(ctx, `SELECT file_key FROM contracts WHERE id = $1`, id).Scan(&fileKey); err != nil {
+			return err
+		}
+		if fileKey != nil && *fileKey != "" {
+			if err := storage.Default.Delete(ctx, *fileKey); err != nil {
+				// Keep the row so the next purge run can retry the remote delete.
+				return fmt.Errorf("failed to delete contract file from storage: %w", err)
+			}
+		}
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Anything filed while the files were being removed would otherwise be
+	// taken along by the cascades without its files.
+	if err := checkPurge(ctx, tx, entityType, id, scope); err != nil {
+		return err
+	}
+	var remaining int
+	err = tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT (SELECT COUNT(*) FROM contracts WHERE %s AND id <> $1)
+		     + (SELECT COUNT(*) FROM projects WHERE $2 = 'client' AND client_id = $1)
+	`, scope.Contracts), id, entityType).Scan(&remaining)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return ConflictError(fmt.Sprintf("cannot purge %s: records were added to it during the purge", entityType))
+	}
+
+	if entityType == "project" {
+		if _, err := tx.Exec(ctx, `DELETE FROM project_assignments WHERE project_id = $1`, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, trashTables[entityType].Table), id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkPurge refuses the purge while records that must be kept refer to the
+// entity.
+func checkPurge(ctx context.Context, q querier, entityType string, id string, scope purgeScope) error {
+	for _, check := range scope.checks() {
+		var count int
+		if err := q.QueryRow(ctx, check.Query, id).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return ConflictError(fmt.Sprintf("cannot purge %s: it has %d %s. Please remove these first", entityType, count, check.What))
+		}
+	}
+	return nil
+}
+
+// purgeDocument removes every trashed version of a document and its files.
+func purgeDocument(ctx context.Context, id string) error {
+	return deleteDocuments(ctx, `
+		SELECT id::text, file_key FROM documents
+		WHERE logical_id = (SELECT logical_id FROM documents WHERE id = $1) AND deleted_at IS NOT NULL
+		ORDER BY version
+	`, id)
+}
+
+// deleteDocuments removes the documents selected by query, which returns
+// their id and file key, deleting each file before its row.
+func deleteDocuments(ctx context.Context, query string, args ...any) error {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	type version struct {
+		id      string
+		fileKey *string
+	}
+	var versions []version
+	for rows.Next() {
+		var v version
+		if err := rows.Scan(&v.id, &v.fileKey); err != nil {
+			rows.Close()
+			return err
+		}
+		versions = append(versions, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Oldest first, so a failure leaves the latest version in place.
+	for _, v := range versions {
+		if v.fileKey != nil && *v.fileKey != "" {
+			if err := storage.Default.Delete(ctx, *v.fileKey); err != nil {
+				// Keep the rows so the next purge run can retry the remote delete.
+				return fmt.Errorf("failed to delete document file from storage: %w", err)
+			}
+		}
+		if _, err := db.Pool.Exec(ctx, `DELETE FROM documents WHERE id = $1`, v.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeExpired permanently removes everything that has been in the trash for
+// longer than the retention period. Failures are logged and retried next run.
+func (s *TrashService) PurgeExpired(ctx context.Context, retention time.Duration) (int, error) {
+	cutoff := time.Now().Add(-retention)
+	purged := 0
+	for _, et := range trashEntityOrder {
+		t := trashTables[et]
+		rows, err := db.Pool.Query(ctx, fmt.Sprintf(`SELECT id::text FROM %s WHERE deleted_at IS NOT NULL AND deleted_at < $1%s`, t.Table, t.filter()), cutoff)
+		if err != nil {
+			return purged, err
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return purged, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if err := s.Purge(ctx, et, id); err != nil {
+				if errors.Is(err, ErrNotInTrash) {
+					// Already purged along with something it belonged to
+					continue
+				}
+				if e := AsError(err); e != nil && e.Kind == KindConflict {
+					log.Printf("Trash purge skipped %s %s: %v", et, id, err)
+					continue
+				}
+				log.Printf("Trash purge failed for %s %s: %v", et, id, err)
+				continue
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
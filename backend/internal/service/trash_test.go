@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/storage"
+	"github.com/dubai/platform/backend/internal/storage/storagetest"
+	"github.com/google/uuid"
+)
+
+func TestPurgeScopes(t *testing.T) {
+	for et := range trashTables {
+		if _, ok := purgeScopes[et]; !ok && et != "document" {
+			t.Errorf("%s can be trashed but has no purge scope", et)
+		}
+		if !slices.Contains(trashEntityOrder, et) {
+			t.Errorf("%s is missing from trashEntityOrder", et)
+		}
+	}
+	for et, scope := range purgeScopes {
+		if _, ok := trashTables[et]; !ok {
+			t.Errorf("purge scope for %s, which can't be trashed", et)
+		}
+		n := len(scope.Checks)
+		checks := scope.checks()
+		if len(checks) != n+1 || checks[n].What != "other contracts filed under its MSA" {
+			t.Errorf("%s: checks() = %v, want its own checks and the MSA check", et, checks)
+		}
+		if len(scope.checks()) != n+1 || len(scope.Checks) != n {
+			t.Errorf("%s: checks() changed the scope", et)
+		}
+	}
+}
+
+// trashFixture inserts rows for a purge test and tracks the storage fake.
+type trashFixture struct {
+	t     *testing.T
+	ctx   context.Context
+	store *storagetest.Memory
+}
+
+func newTrashFixture(t *testing.T) *trashFixture {
+	t.Helper()
+	requireDB(t)
+	store := storagetest.NewMemory()
+	prev := storage.Default
+	storage.Default = store
+	t.Cleanup(func() { storage.Default = prev })
+	return &trashFixture{t: t, ctx: context.Background(), store: store}
+}
+
+func (f *trashFixture) insert(query string, args ...any) string {
+	f.t.Helper()
+	var id string
+	if err := db.Pool.QueryRow(f.ctx, query+" RETURNING id::text", args...).Scan(&id); err != nil {
+		f.t.Fatalf("insert: %v", err)
+	}
+	return id
+}
+
+func (f *trashFixture) client() string {
+	return f.insert(`INSERT INTO clients (company_name) VALUES ('Purge Test Ltd')`)
+}
+
+func (f *trashFixture) project(clientID string) string {
+	return f.insert(`INSERT INTO projects (client_id, name) VALUES ($1, 'Purge Test')`, clientID)
+}
+
+func (f *trashFixture) talent() string {
+	return f.insert(`INSERT INTO talent (first_name, last_name, email, role) VALUES ('Purge', 'Test', $1, 'Engineer')`,
+		"purge-"+uuid.NewString()+"@example.com")
+}
+
+// contract files a contract with a stored file. msaID may be empty.
+func (f *trashFixture) contract(kind, clientID, projectID, msaID string) (id, fileKey string) {
+	fileKey = "contracts/" + uuid.NewString() + ".pdf"
+	id = f.insert(`
+		INSERT INTO contracts (contract_type, client_id, project_id, msa_id, file_key)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, $5)
+	`, kind, clientID, projectID, msaID, fileKey)
+	return id, fileKey
+}
+
+// document files versions of a document against an entity and returns their
+// file keys, oldest first.
+func (f *trashFixture) document(entityType, entityID string, versions int) []string {
+	f.t.Helper()
+	logicalID := uuid.NewString()
+	var keys []string
+	var prev string
+	for v := 1; v <= versions; v++ {
+		key := "documents/" + uuid.NewString() + ".pdf"
+		id := f.insert(`
+			INSERT INTO documents (id, logical_id, version, entity_type, entity_id, file_name, file_url, file_key)
+			VALUES (CASE WHEN $2::int = 1 THEN $1::uuid ELSE gen_random_uuid() END, $1, $2, $3, $4, 'file.pdf', 'memory://' || $5, $5)
+		`, logicalID, v, entityType, entityID, key)
+		if prev != "" {
+			f.exec(`UPDATE documents SET superseded_by = $2, superseded_at = now() WHERE id = $1`, prev, id)
+		}
+		prev = id
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (f *trashFixture) exec(query string, args ...any) {
+	f.t.Helper()
+	if _, err := db.Pool.Exec(f.ctx, query, args...); err != nil {
+		f.t.Fatalf("exec: %v", err)
+	}
+}
+
+// trash soft-deletes rows of a table by id, all at the same moment.
+func (f *trashFixture) trash(table string, ids ...string) {
+	f.exec(`UPDATE `+table+` SET deleted_at = now() WHERE id = ANY($1::uuid[])`, ids)
+}
+
+func (f *trashFixture) exists(table, id string) bool {
+	f.t.Helper()
+	var exists bool
+	if err := db.Pool.QueryRow(f.ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists); err != nil {
+		f.t.Fatal(err)
+	}
+	return exists
+}
+
+func TestPurgeClient(t *testing.T) {
+	f := newTrashFixture(t)
+	clientID := f.client()
+	projectID := f.project(clientID)
+	msaID, msaKey := f.contract("MSA", clientID, projectID, "")
+	sowID, sowKey := f.contract("SOW", clientID, projectID, msaID)
+	clientDocs := f.document("CLIENT", clientID, 1)
+	projectDocs := f.document("PROJECT", projectID, 2)
+	contractDocs := f.document("CONTRACT", sowID, 1)
+	f.trash("clients", clientID)
+	f.trash("projects", projectID)
+
+	if err := NewTrashService().Purge(f.ctx, "client", clientID); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	for _, row := range []struct{ table, id string }{
+		{"clients", clientID}, {"projects", projectID}, {"contracts", msaID}, {"contracts", sowID},
+	} {
+		if f.exists(row.table, row.id) {
+			t.Errorf("%s %s still exists", row.table, row.id)
+		}
+	}
+
+	want := []string{msaKey, sowKey}
+	want = append(want, clientDocs...)
+	want = append(want, projectDocs...)
+	want = append(want, contractDocs...)
+	for _, key := range want {
+		if !slices.Contains(f.store.Deleted, key) {
+			t.Errorf("file %s was not deleted", key)
+		}
+	}
+	// SOWs go before the MSA they're filed under, and old document versions
+	// before the latest
+	if slices.Index(f.store.Deleted, sowKey) > slices.Index(f.store.Deleted, msaKey) {
+		t.Errorf("MSA file deleted before its SOW's: %v", f.store.Deleted)
+	}
+	if slices.Index(f.store.Deleted, projectDocs[1]) < slices.Index(f.store.Deleted, projectDocs[0]) {
+		t.Errorf("latest document version deleted first: %v", f.store.Deleted)
+	}
+}
+
+func TestPurgeRefused(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *trashFixture) (entityType, id string)
+		wantErr    error
+		wantReason string
+	}{
+		{
+			name: "not in the trash",
+			setup: func(f *trashFixture) (string, string) {
+				return "client", f.client()
+			},
+			wantErr: ErrNotInTrash,
+		},
+		{
+			name: "unknown id",
+			setup: func(f *trashFixture) (string, string) {
+				return "project", uuid.NewString()
+			},
+			wantErr: ErrNotInTrash,
+		},
+		{
+			name: "client with invoices",
+			setup: func(f *trashFixture) (string, string) {
+				id := f.client()
+				f.exec(`INSERT INTO invoices (client_id, billing_month, total_amount, currency) VALUES ($1, '2026-01', 100, 'USD')`, id)
+				f.trash("clients", id)
+				return "client", id
+			},
+			wantReason: "1 invoices",
+		},
+		{
+			name: "client with a live project",
+			setup: func(f *trashFixture) (string, string) {
+				id := f.client()
+				f.project(id)
+				f.trash("clients", id)
+				return "client", id
+			},
+			wantReason: "projects that are not in the trash",
+		},
+		{
+			name: "talent with an assignment",
+			setup: func(f *trashFixture) (string, string) {
+				clientID := f.client()
+				projectID := f.project(clientID)
+				talentID := f.talent()
+				f.exec(`
+					INSERT INTO project_assignments (project_id, client_id, talent_id, role, start_date, monthly_contractor_cost, status)
+					VALUES ($1, $2, $3, 'Engineer', '2026-01-01', 1000, 'ACTIVE')
+				`, projectID, clientID, talentID)
+				f.trash("talent", talentID)
+				return "talent", talentID
+			},
+			wantReason: "project assignments",
+		},
+		{
+			name: "project whose MSA covers another project",
+			setup: func(f *trashFixture) (string, string) {
+				clientID := f.client()
+				projectID := f.project(clientID)
+				otherID := f.project(clientID)
+				msaID, _ := f.contract("MSA", clientID, projectID, "")
+				f.contract("SOW", clientID, otherID, msaID)
+				f.trash("projects", projectID)
+				return "project", projectID
+			},
+			wantReason: "other contracts filed under its MSA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTrashFixture(t)
+			entityType, id := tt.setup(f)
+			err := NewTrashService().Purge(f.ctx, entityType, id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Purge error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			e := AsError(err)
+			if e == nil || e.Kind != KindConflict || !strings.Contains(err.Error(), tt.wantReason) {
+				t.Fatalf("Purge error = %v, want a conflict mentioning %q", err, tt.wantReason)
+			}
+			if !f.exists(trashTables[entityType].Table, id) {
+				t.Errorf("refused %s was deleted", entityType)
+			}
+		})
+	}
+}
+
+func TestPurgeKeepsRowsWhenStorageFails(t *testing.T) {
+	f := newTrashFixture(t)
+	clientID := f.client()
+	contractID, _ := f.contract("CLIENT", clientID, "", "")
+	f.document("CONTRACT", contractID, 1)
+	f.trash("contracts", contractID)
+	f.store.DeleteErr = errors.New("bucket unavailable")
+
+	if err := NewTrashService().Purge(f.ctx, "contract", contractID); err == nil {
+		t.Fatal("Purge succeeded while storage was failing")
+	}
+	if !f.exists("contracts", contractID) {
+		t.Fatal("contract was deleted although its files are still stored")
+	}
+
+	// The next run finishes the job
+	f.store.DeleteErr = nil
+	if err := NewTrashService().Purge(f.ctx, "contract", contractID); err != nil {
+		t.Fatalf("retried Purge: %v", err)
+	}
+	if f.exists("contracts", contractID) {
+		t.Error("contract still exists after the retry")
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// These back the cmd/admin CLI and the startup bootstrap. They identify users
+// by email or username since that's what an operator has at hand.
+
+var (
+	ErrUserExists = ConflictError("a user with that email or username already exists")
+	ErrLastAdmin  = ConflictError("can't disable the last active admin")
+)
+
+// bootstrapLockID serialises BootstrapAdmin across instances starting at once.
+const bootstrapLockID = 7461001
+
+// BootstrapAdminFromEnv creates the first admin from BOOTSTRAP_ADMIN_EMAIL,
+// BOOTSTRAP_ADMIN_PASSWORD and optionally BOOTSTRAP_ADMIN_USERNAME. It does
+// nothing once any admin exists, so the variables can stay set.
+func BootstrapAdminFromEnv(ctx context.Context) error {
+	email := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	password := os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	username := os.Getenv("BOOTSTRAP_ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, bootstrapLockID); err != nil {
+		return err
+	}
+	var hasAdmin bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE role = 'ADMIN' AND NOT service_account)`).Scan(&hasAdmin); err != nil {
+		return err
+	}
+	if hasAdmin {
+		return nil
+	}
+	if email == "" || password == "" {
+		log.Println("WARNING: No admin user exists. Set BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD, or run `go run ./cmd/admin create-admin`.")
+		return nil
+	}
+
+	// The password sits in the environment, so treat it as temporary
+	id, err := createAdmin(ctx, tx, email, username, password, true)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Printf("Created bootstrap admin %s (%s); the password must be changed at first sign-in", email, id)
+	return nil
+}
+
+// CreateAdmin adds an active admin account. It never touches existing users.
+func (s *AuthService) CreateAdmin(ctx context.Context, email, username, password string) (uuid.UUID, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := createAdmin(ctx, tx, email, username, password, false)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+func createAdmin(ctx context.Context, tx pgx.Tx, email, username, password string, mustChange bool) (uuid.UUID, error) {
+	if err := ValidatePassword(password, email, username); err != nil {
+		return uuid.Nil, err
+	}
+
+	var exists bool
+	err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1) OR username = $2)`, email, username,
+	).Scan(&exists)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if exists {
+		return uuid.Nil, ErrUserExists
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (email, username, password_hash, role, activated_at, must_change_password)
+		VALUES ($1, $2, $3, 'ADMIN', now(), $4)
+		RETURNING id
+	`, email, username, string(hashedPassword), mustChange).Scan(&id)
+	return id, err
+}
+
+// SetPassword replaces the password of the user with the given email or
+// username, lifts any lockout and revokes their outstanding invitation and
+// reset links.
+func (s *AuthService) SetPassword(ctx context.Context, login, password string, mustChange bool) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var id uuid.UUID
+	var email string
+	var username *string
+	err = tx.QueryRow(ctx,
+		`SELECT id, email, username FROM users WHERE lower(email) = lower($1) OR username = $1`, login,
+	).Scan(&id, &email, &username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := ValidatePassword(password, email, deref(username)); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, must_change_password = $3, activated_at = COALESCE(activated_at, now()),
+			failed_login_count = 0, locked_until = NULL
+		WHERE id = $1
+	`, id, string(hashedPassword), mustChange)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1 AND used_at IS NULL`, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetDisabled blocks or unblocks sign-in for the user with the given email or
+// username. A disabled user's existing sessions stop working too (see
+// UserActive).
+func (s *AuthService) SetDisabled(ctx context.Context, login string, disabled bool) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Same lock as the bootstrap so the last-admin check can't race
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, bootstrapLockID); err != nil {
+		return err
+	}
+	var id uuid.UUID
+	var role string
+	err = tx.QueryRow(ctx,
+		`SELECT id, role::text FROM users WHERE lower(email) = lower($1) OR username = $1`, login,
+	).Scan(&id, &role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if disabled && role == "ADMIN" {
+		var others int
+		err := tx.QueryRow(ctx,
+			`SELECT count(*) FROM users WHERE role = 'ADMIN' AND disabled_at IS NULL AND NOT service_account AND id <> $1`, id,
+		).Scan(&others)
+		if err != nil {
+			return err
+		}
+		if others == 0 {
+			return ErrLastAdmin
+		}
+	}
+
+	if disabled {
+		_, err = tx.Exec(ctx, `UPDATE users SET disabled_at = COALESCE(disabled_at, now()) WHERE id = $1`, id)
+	} else {
+		_, err = tx.Exec(ctx, `UPDATE users SET disabled_at = NULL WHERE id = $1`, id)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// LookupUserID finds the user with the given email or username.
+func (s *AuthService) LookupUserID(ctx context.Context, login string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.Pool.QueryRow(ctx,
+		`SELECT id FROM users WHERE lower(email) = lower($1) OR username = $1`, login,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrUserNotFound
+	}
+	return id, err
+}
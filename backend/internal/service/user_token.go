@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	TokenInvite = "INVITE"
+	TokenReset  = "RESET"
+
+	inviteTokenTTL = 7 * 24 * time.Hour
+	resetTokenTTL  = time.Hour
+)
+
+var ErrInvalidToken = ValidationError("invalid or expired token")
+
+// issueUserToken creates a single-use token for the user, replacing any
+// unused one with the same purpose so only the latest link works.
+func issueUserToken(ctx context.Context, tx pgx.Tx, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	_, err := tx.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		userID, purpose, hashToken(token), time.Now().Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemUserToken marks a valid token used and returns its user.
+func redeemUserToken(ctx context.Context, tx pgx.Tx, token, purpose string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := tx.QueryRow(ctx, `
+		UPDATE user_tokens SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`, hashToken(token), purpose).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return userID, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,15 @@
+package service
+
+// ErrVersionMismatch is returned by an update that expected another version
+// of the record than the stored one, i.e. someone changed it in between.
+var ErrVersionMismatch = PreconditionFailedError("the record was changed by someone else; reload it and try again")
+
+// checkVersion compares the version an update expects with the stored one.
+// Callers read current with SELECT ... FOR UPDATE so the record can't move on
+// before they write. An expected version of 0 accepts any.
+func checkVersion(expected, current int) error {
+	if expected != 0 && expected != current {
+		return ErrVersionMismatch
+	}
+	return nil
+}
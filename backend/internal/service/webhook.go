@@ -0,0 +1,537 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/events"
+	"github.com/dubai/platform/backend/internal/jobs"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const WebhookJobKind = "webhook.deliver"
+
+// webhookEvents are the domain events endpoints can subscribe to. Each one
+// is forwarded by the webhooks subscriber registered in
+// RegisterEventSubscribers.
+var webhookEvents = map[string]bool{
+	events.TypeTalentStatusChanged:  true,
+	events.TypeAssignmentCreated:    true,
+	events.TypeAssignmentTrialEnded: true,
+	events.TypeInvoiceSent:          true,
+	events.TypeInvoicePaid:          true,
+	events.TypeInvoiceOverdue:       true,
+	events.TypeContractSigned:       true,
+	events.TypeDocumentOCRCompleted: true,
+}
+
+const (
+	DeliveryPending   = "PENDING"
+	DeliverySucceeded = "SUCCEEDED"
+	DeliveryFailed    = "FAILED"
+
+	// With the jobs backoff, 8 attempts spread over about an hour
+	webhookMaxAttempts = 8
+	webhookTimeout     = 10 * time.Second
+	// webhookResponseLimit is how much of a response body is kept for the
+	// delivery log
+	webhookResponseLimit = 2048
+)
+
+var (
+	ErrWebhookNotFound   = NotFoundError("webhook not found")
+	ErrDeliveryNotFound  = NotFoundError("webhook delivery not found")
+	ErrInvalidWebhook    = ValidationError("invalid webhook")
+	ErrWebhookDisabled   = ConflictError("webhook is disabled, enable it first")
+	errWebhookStatusCode = errors.New("endpoint responded with a non-2xx status")
+)
+
+type WebhookService struct {
+	Client *http.Client
+}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{Client: &http.Client{
+		Timeout: webhookTimeout,
+		// A redirect would re-send the payload somewhere nobody registered
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// webhookDisableAfter is how many deliveries in a row may run out of retries
+// before the endpoint is disabled, from WEBHOOK_DISABLE_AFTER (default 5).
+func webhookDisableAfter() int {
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_DISABLE_AFTER")); err == nil && n > 0 {
+		return n
+	}
+	return 5
+}
+
+type webhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// webhookEnvelope is the JSON body every endpoint receives.
+type webhookEnvelope struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// forwardToWebhooks is the subscriber that queues a domain event for every
+// enabled endpoint subscribed to it. The envelope reuses the event's ID, so
+// receivers can use it to drop duplicates.
+func forwardToWebhooks[E events.Event](ctx context.Context, tx pgx.Tx, meta events.Meta, e E) error {
+	rows, err := tx.Query(ctx,
+		`SELECT id FROM webhook_endpoints WHERE disabled_at IS NULL AND ($1 = ANY(events) OR '*' = ANY(events))`, meta.Type)
+	if err != nil {
+		return err
+	}
+	var endpoints []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		endpoints = append(endpoints, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	envelope := webhookEnvelope{ID: meta.ID, Type: meta.Type, CreatedAt: meta.OccurredAt.UTC(), Data: e}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	for _, endpointID := range endpoints {
+		if _, err := queueDelivery(ctx, tx, endpointID, envelope.ID, meta.Type, body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queueDelivery(ctx context.Context, tx pgx.Tx, endpointID, eventID uuid.UUID, event string, body []byte, replayOf *uuid.UUID) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, replay_of)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, endpointID, eventID, event, body, replayOf).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, jobs.EnqueueWithAttempts(ctx, tx, WebhookJobKind, webhookPayload{DeliveryID: id.String()}, webhookMaxAttempts)
+}
+
+// SignWebhook returns the X-Webhook-Signature header value for body sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+// Receivers recompute it with the endpoint secret and reject old timestamps.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t + "."))
+	mac.Write(body)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// HandleJob is the jobs.HandlerFunc for WebhookJobKind. It makes one attempt
+// and records it; failures are retried by the jobs queue with backoff.
+func (s *WebhookService) HandleJob(ctx context.Context, job *jobs.Job) error {
+	var p webhookPayload
+	if err := json.Unmarshal(job.Payload, &p); err != nil {
+		return fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	var endpointID uuid.UUID
+	var event, status, target, secret string
+	var body []byte
+	var disabledAt *time.Time
+	err := db.Pool.QueryRow(ctx, `
+		SELECT d.endpoint_id, d.event_type, d.payload::text, d.status, e.url, e.secret, e.disabled_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.id = $1
+	`, p.DeliveryID).Scan(&endpointID, &event, &body, &status, &target, &secret, &disabledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The endpoint was deleted
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status != DeliveryPending {
+		return nil
+	}
+	if disabledAt != nil {
+		_, err := db.Pool.Exec(ctx,
+			`UPDATE webhook_deliveries SET status = $2, last_error = 'endpoint disabled' WHERE id = $1`, p.DeliveryID, DeliveryFailed)
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Platform-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", p.DeliveryID)
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Signature", SignWebhook(secret, time.Now(), body))
+
+	start := time.Now()
+	resp, sendErr := s.Client.Do(req)
+	duration := int(time.Since(start).Milliseconds())
+	var respStatus *int
+	var respBody *string
+	if sendErr == nil {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+		resp.Body.Close()
+		// Postgres text can't hold invalid UTF-8 or NUL bytes
+		text := strings.ReplaceAll(strings.ToValidUTF8(string(b), "\uFFFD"), "\x00", "")
+		respStatus, respBody = &resp.StatusCode, optString(text)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			sendErr = fmt.Errorf("%w: %d", errWebhookStatusCode, resp.StatusCode)
+		}
+	}
+
+	// Use a fresh context: ctx may be the one that just timed out.
+	recordCtx := context.Background()
+	if sendErr == nil {
+		_, err := db.Pool.Exec(recordCtx, `
+			UPDATE webhook_deliveries
+			SET status = $2, attempts = attempts + 1, response_status = $3, response_body = $4, last_error = NULL, duration_ms = $5, delivered_at = now()
+			WHERE id = $1
+		`, p.DeliveryID, DeliverySucceeded, respStatus, respBody, duration)
+		if err != nil {
+			return err
+		}
+		_, err = db.Pool.Exec(recordCtx,
+			`UPDATE webhook_endpoints SET consecutive_failures = 0, last_success_at = now() WHERE id = $1`, endpointID)
+		return err
+	}
+
+	status = DeliveryPending
+	if job.FinalAttempt() {
+		status = DeliveryFailed
+	}
+	_, err = db.Pool.Exec(recordCtx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, response_status = $3, response_body = $4, last_error = $5, duration_ms = $6
+		WHERE id = $1
+	`, p.DeliveryID, status, respStatus, respBody, sendErr.Error(), duration)
+	if err != nil {
+		log.Printf("Failed to record webhook delivery error for %s: %v", p.DeliveryID, err)
+	}
+	if status == DeliveryFailed {
+		if err := recordEndpointFailure(recordCtx, endpointID, target); err != nil {
+			log.Printf("Failed to record webhook endpoint failure for %s: %v", endpointID, err)
+		}
+	}
+	return sendErr
+}
+
+// recordEndpointFailure counts a delivery that ran out of retries, and
+// disables the endpoint and tells the admins when too many fail in a row.
+func recordEndpointFailure(ctx context.Context, endpointID uuid.UUID, target string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	limit := webhookDisableAfter()
+	var failures int
+	var disabled bool
+	err = tx.QueryRow(ctx, `
+		UPDATE webhook_endpoints
+		SET consecutive_failures = consecutive_failures + 1,
+		    disabled_at = CASE WHEN disabled_at IS NULL AND consecutive_failures + 1 >= $2 THEN now() ELSE disabled_at END,
+		    disabled_reason = CASE WHEN disabled_at IS NULL AND consecutive_failures + 1 >= $2 THEN $3 ELSE disabled_reason END,
+		    updated_at = now()
+		WHERE id = $1
+		RETURNING consecutive_failures, disabled_at IS NOT NULL AND consecutive_failures = $2
+	`, endpointID, limit, fmt.Sprintf("%d deliveries in a row failed", limit)).Scan(&failures, &disabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if disabled {
+		log.Printf("Webhook endpoint %s (%s) disabled after %d failed deliveries", endpointID, target, failures)
+		_, err := notifyStaff(ctx, tx, NotifyWebhookDisabled, []string{"ADMIN"}, map[string]interface{}{
+			"URL":      target,
+			"Failures": failures,
+			"AdminURL": appURL("/settings/webhooks/" + endpointID.String()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// validateWebhook normalises and checks an endpoint request.
+func validateWebhook(req *models.WebhookEndpointRequest) error {
+	req.URL = strings.TrimSpace(req.URL)
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(req.Events) == 0 {
+		return fmt.Errorf("%w: subscribe to at least one event", ErrInvalidWebhook)
+	}
+	for _, e := range req.Events {
+		if e != "*" && !webhookEvents[e] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+const webhookColumns = `id, url, description, events, consecutive_failures, disabled_at, disabled_reason, last_success_at, created_by, created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (*models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	err := row.Scan(&e.ID, &e.URL, &e.Description, &e.Events, &e.ConsecutiveFailures, &e.DisabledAt, &e.DisabledReason, &e.LastSuccessAt, &e.CreatedBy, &e.CreatedAt, &e.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	return &e, err
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+webhookColumns+` FROM webhook_endpoints ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []models.WebhookEndpoint{}
+	for rows.Next() {
+		e, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *e)
+	}
+	return endpoints, rows.Err()
+}
+
+func (s *WebhookService) Get(ctx context.Context, id string) (*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	return scanWebhook(db.Pool.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhook_endpoints WHERE id = $1`, id))
+}
+
+// Create registers an endpoint. The response is the only time the secret is
+// shown, apart from RotateSecret.
+func (s *WebhookService) Create(ctx context.Context, req models.WebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	if err := validateWebhook(&req); err != nil {
+		return nil, err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	e, err := scanWebhook(db.Pool.QueryRow(ctx, `
+		INSERT INTO webhook_endpoints (url, description, secret, events, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+webhookColumns,
+		req.URL, req.Description, secret, req.Events, contextUserID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	e.Secret = secret
+	return e, nil
+}
+
+// Update changes an endpoint. Enabling it clears its failure count.
+func (s *WebhookService) Update(ctx context.Context, id string, req models.WebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	if err := validateWebhook(&req); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE webhook_endpoints SET url = $2, description = $3, events = $4, updated_at = now()`
+	if req.Enabled != nil && *req.Enabled {
+		query += `, disabled_at = NULL, disabled_reason = NULL, consecutive_failures = 0`
+	} else if req.Enabled != nil {
+		query += `, disabled_at = COALESCE(disabled_at, now()), disabled_reason = COALESCE(disabled_reason, 'disabled by an admin')`
+	}
+	query += ` WHERE id = $1 RETURNING ` + webhookColumns
+	return scanWebhook(db.Pool.QueryRow(ctx, query, id, req.URL, req.Description, req.Events))
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrWebhookNotFound
+	}
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// RotateSecret replaces the signing secret. Deliveries already queued are
+// signed with the new one.
+func (s *WebhookService) RotateSecret(ctx context.Context, id string) (*models.WebhookEndpoint, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	e, err := scanWebhook(db.Pool.QueryRow(ctx,
+		`UPDATE webhook_endpoints SET secret = $2, updated_at = now() WHERE id = $1 RETURNING `+webhookColumns, id, secret))
+	if err != nil {
+		return nil, err
+	}
+	e.Secret = secret
+	return e, nil
+}
+
+const deliveryColumns = `id, endpoint_id, event_id, event_type, payload, status, attempts, response_status, response_body, last_error, duration_ms, replay_of, created_at, delivered_at`
+
+func scanDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.ResponseBody, &d.LastError, &d.DurationMS, &d.ReplayOf, &d.CreatedAt, &d.DeliveredAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	return &d, err
+}
+
+// Deliveries lists an endpoint's most recent deliveries, optionally only
+// those with status.
+func (s *WebhookService) Deliveries(ctx context.Context, endpointID, status string, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE endpoint_id = $1`
+	args := []interface{}{endpointID}
+	argID := 2
+	if status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argID)
+		args = append(args, strings.ToUpper(status))
+		argID++
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", argID)
+	args = append(args, limit)
+
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Replay sends a past delivery's event again, as a new delivery with the
+// same event id.
+func (s *WebhookService) Replay(ctx context.Context, endpointID, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := uuid.Parse(endpointID); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var disabledAt *time.Time
+	err = tx.QueryRow(ctx, `SELECT disabled_at FROM webhook_endpoints WHERE id = $1`, endpointID).Scan(&disabledAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt != nil {
+		return nil, ErrWebhookDisabled
+	}
+
+	original, err := scanDelivery(tx.QueryRow(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2`, deliveryID, endpointID))
+	if err != nil {
+		return nil, err
+	}
+	id, err := queueDelivery(ctx, tx, original.EndpointID, original.EventID, original.EventType, original.Payload, &original.ID)
+	if err != nil {
+		return nil, err
+	}
+	replay, err := scanDelivery(tx.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return replay, tx.Commit(ctx)
+}
+
+// PruneDeliveries deletes deliveries older than retention.
+func (s *WebhookService) PruneDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> $2`, time.Now().Add(-retention), DeliveryPending)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/db"
+	"github.com/dubai/platform/backend/internal/jobs"
+	"github.com/dubai/platform/backend/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestSignWebhook(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	// HMAC-SHA256 of `1700000000.{"id":1}` keyed with whsec_test
+	const want = "t=1700000000,v1=2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8"
+	if got := SignWebhook("whsec_test", at, body); got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+
+	// Each input is covered, so none can be swapped without breaking the signature
+	for name, got := range map[string]string{
+		"other secret":    SignWebhook("whsec_other", at, body),
+		"other timestamp": SignWebhook("whsec_test", at.Add(time.Second), body),
+		"other body":      SignWebhook("whsec_test", at, []byte(`{"id":2}`)),
+	} {
+		if strings.HasSuffix(got, want[len("t=1700000000"):]) {
+			t.Errorf("%s: signature unchanged", name)
+		}
+	}
+}
+
+func TestWebhookDisableAfter(t *testing.T) {
+	for value, want := range map[string]int{"": 5, "3": 3, "0": 5, "-2": 5, "many": 5} {
+		t.Setenv("WEBHOOK_DISABLE_AFTER", value)
+		if got := webhookDisableAfter(); got != want {
+			t.Errorf("WEBHOOK_DISABLE_AFTER=%q: got %d, want %d", value, got, want)
+		}
+	}
+}
+
+// webhookFixture is an endpoint pointing at a test server whose response
+// status can be changed between deliveries.
+type webhookFixture struct {
+	t        *testing.T
+	s        *WebhookService
+	endpoint *models.WebhookEndpoint
+	secret   string
+	status   atomic.Int32
+	requests atomic.Int32
+}
+
+func newWebhookFixture(t *testing.T) *webhookFixture {
+	f := &webhookFixture{t: t, s: NewWebhookService()}
+	f.status.Store(http.StatusOK)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		sig := r.Header.Get("X-Webhook-Signature")
+		ts, _, _ := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+		unix, _ := strconv.ParseInt(ts, 10, 64)
+		if sig != SignWebhook(f.secret, time.Unix(unix, 0), body) {
+			t.Errorf("signature %q doesn't match the body and endpoint secret", sig)
+		}
+		w.WriteHeader(int(f.status.Load()))
+	}))
+	t.Cleanup(srv.Close)
+
+	e, err := f.s.Create(context.Background(), models.WebhookEndpointRequest{URL: srv.URL, Events: []string{"*"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	f.endpoint, f.secret = e, e.Secret
+	return f
+}
+
+// deliver queues a delivery and runs it as the given attempt.
+func (f *webhookFixture) deliver(attempt int) uuid.UUID {
+	f.t.Helper()
+	ctx := context.Background()
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	id, err := queueDelivery(ctx, tx, f.endpoint.ID, uuid.New(), "invoice.paid", []byte(`{"invoice":1}`), nil)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		f.t.Fatal(err)
+	}
+	f.run(id, attempt)
+	return id
+}
+
+func (f *webhookFixture) run(deliveryID uuid.UUID, attempt int) {
+	f.t.Helper()
+	payload, _ := json.Marshal(webhookPayload{DeliveryID: deliveryID.String()})
+	job := &jobs.Job{Kind: WebhookJobKind, Payload: payload, Attempts: attempt, MaxAttempts: webhookMaxAttempts}
+	err := f.s.HandleJob(context.Background(), job)
+	// A failed send is returned so the queue retries it; a disabled endpoint
+	// isn't sent to at all
+	wantErr := f.status.Load() >= 300 && f.endpoint.DisabledAt == nil
+	if wantErr != (err != nil) {
+		f.t.Fatalf("HandleJob error = %v with the endpoint answering %d", err, f.status.Load())
+	}
+}
+
+func (f *webhookFixture) reload() *models.WebhookEndpoint {
+	f.t.Helper()
+	e, err := f.s.Get(context.Background(), f.endpoint.ID.String())
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.endpoint = e
+	return e
+}
+
+func (f *webhookFixture) deliveryStatus(id uuid.UUID) string {
+	f.t.Helper()
+	var status string
+	if err := db.Pool.QueryRow(context.Background(), `SELECT status FROM webhook_deliveries WHERE id = $1`, id).Scan(&status); err != nil {
+		f.t.Fatal(err)
+	}
+	return status
+}
+
+func TestWebhookAutoDisable(t *testing.T) {
+	requireDB(t)
+	t.Setenv("WEBHOOK_DISABLE_AFTER", "2")
+	f := newWebhookFixture(t)
+	f.status.Store(http.StatusInternalServerError)
+
+	// A failure with retries left doesn't count against the endpoint
+	first := f.deliver(1)
+	if got := f.deliveryStatus(first); got != DeliveryPending {
+		t.Errorf("after a retryable failure: delivery %s, want %s", got, DeliveryPending)
+	}
+	if e := f.reload(); e.ConsecutiveFailures != 0 {
+		t.Errorf("after a retryable failure: %d consecutive failures, want 0", e.ConsecutiveFailures)
+	}
+
+	// Running out of retries does
+	f.run(first, webhookMaxAttempts)
+	if got := f.deliveryStatus(first); got != DeliveryFailed {
+		t.Errorf("after the last attempt: delivery %s, want %s", got, DeliveryFailed)
+	}
+	if e := f.reload(); e.ConsecutiveFailures != 1 || e.DisabledAt != nil {
+		t.Fatalf("after one failed delivery: %d failures, disabled %v; want 1, not disabled", e.ConsecutiveFailures, e.DisabledAt)
+	}
+
+	f.deliver(webhookMaxAttempts)
+	e := f.reload()
+	if e.DisabledAt == nil {
+		t.Fatalf("after %d failed deliveries in a row the endpoint is still enabled", e.ConsecutiveFailures)
+	}
+	if e.DisabledReason == nil || *e.DisabledReason != "2 deliveries in a row failed" {
+		t.Errorf("disabled reason = %v", e.DisabledReason)
+	}
+
+	// Nothing more is sent to a disabled endpoint
+	sent := f.requests.Load()
+	f.status.Store(http.StatusOK)
+	if id := f.deliver(1); f.deliveryStatus(id) != DeliveryFailed {
+		t.Errorf("delivery to a disabled endpoint: %s, want %s", f.deliveryStatus(id), DeliveryFailed)
+	}
+	if f.requests.Load() != sent {
+		t.Error("a disabled endpoint was called")
+	}
+}
+
+func TestWebhookSuccessResetsFailures(t *testing.T) {
+	requireDB(t)
+	t.Setenv("WEBHOOK_DISABLE_AFTER", "2")
+	f := newWebhookFixture(t)
+
+	f.status.Store(http.StatusBadGateway)
+	f.deliver(webhookMaxAttempts)
+	f.status.Store(http.StatusNoContent)
+	if id := f.deliver(1); f.deliveryStatus(id) != DeliverySucceeded {
+		t.Errorf("delivery %s, want %s", f.deliveryStatus(id), DeliverySucceeded)
+	}
+	if e := f.reload(); e.ConsecutiveFailures != 0 || e.LastSuccessAt == nil {
+		t.Errorf("after a success: %d failures, last success %v; want 0 and set", e.ConsecutiveFailures, e.LastSuccessAt)
+	}
+
+	// So one more failure doesn't disable it
+	f.status.Store(http.StatusBadGateway)
+	f.deliver(webhookMaxAttempts)
+	if e := f.reload(); e.DisabledAt != nil {
+		t.Error("disabled although the failures weren't in a row")
+	}
+}
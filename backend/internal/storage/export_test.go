@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"net/http"
+	"time"
+)
+
+// NewTestUploadthing talks to a fake Uploadthing API served at baseURL.
+func NewTestUploadthing(baseURL string) *Uploadthing {
+	return &Uploadthing{apiKey: "sk_test", baseURL: baseURL, client: http.DefaultClient}
+}
+
+func (s *S3) PresignAt(key string, expiry time.Duration, now time.Time) (string, error) {
+	return s.presign(key, expiry, now)
+}
+
+func (l *Local) SignAt(key string, expires time.Time) string {
+	return l.sign(key, expires.Unix())
+}
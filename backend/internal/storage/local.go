@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalRoute is where the API serves files stored by the local backend.
+const LocalRoute = "/api/files/"
+
+// Local stores files on disk. It is meant for development and integration
+// tests; files are served back through LocalRoute with HMAC-signed URLs.
+type Local struct {
+	Root    string
+	BaseURL string
+	key     []byte
+}
+
+func NewLocal(root string, baseURL string, signingKey []byte) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %w", err)
+	}
+	if len(signingKey) == 0 {
+		log.Println("Warning: STORAGE_SIGNING_KEY not set, local file links will not survive a restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+	}
+	return &Local{Root: root, BaseURL: strings.TrimSuffix(baseURL, "/"), key: signingKey}, nil
+}
+
+// path resolves key inside Root, refusing anything that escapes it.
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("empty storage key")
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return nil, err
+	}
+
+	// The bare URL is not servable on its own; use SignedURL to grant access.
+	return &Object{Key: key, URL: l.BaseURL + LocalRoute + escapeKey(key), Size: written, ContentType: contentType}, nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.sign(key, time.Now().Add(expiry).Unix()), nil
+}
+
+func (l *Local) sign(key string, expires int64) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", l.signature(key, expires))
+	return l.BaseURL + LocalRoute + escapeKey(key) + "?" + q.Encode()
+}
+
+func (l *Local) signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, l.key)
+	fmt.Fprintf(mac, "%s\n%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by SignedURL.
+func (l *Local) Verify(key string, expiresParam string, signature string) bool {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil {
+		return false
+	}
+	if time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(l.signature(key, expires)))
+}
+
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored file.
+type Object struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// Storage is implemented by every file backend (Uploadthing, S3, local disk).
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Default is the backend selected at startup by InitFromEnv.
+var Default Storage
+
+// InitFromEnv selects the storage backend from STORAGE_BACKEND
+// (uploadthing, s3 or local). Uploadthing is used when unset.
+func InitFromEnv() error {
+	backend := strings.ToLower(os.Getenv("STORAGE_BACKEND"))
+	switch backend {
+	case "", "uploadthing":
+		s, err := NewUploadthing(os.Getenv("UPLOADTHING_SECRET"))
+		if err != nil {
+			return err
+		}
+		Default = s
+	case "s3":
+		s, err := NewS3(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       os.Getenv("S3_PATH_STYLE") != "false",
+		})
+		if err != nil {
+			return err
+		}
+		Default = s
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./data/files"
+		}
+		baseURL := os.Getenv("STORAGE_PUBLIC_BASE_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		s, err := NewLocal(dir, baseURL, []byte(os.Getenv("STORAGE_SIGNING_KEY")))
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND: %s", backend)
+	}
+	return nil
+}
+
+// NewKey builds a collision-free object key under prefix that keeps the
+// original file name readable.
+func NewKey(prefix string, fileName string) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "file"
+	}
+	return path.Join(prefix, uuid.NewString(), name)
+}
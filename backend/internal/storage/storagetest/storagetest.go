@@ -0,0 +1,156 @@
+// Package storagetest provides an in-memory storage backend for tests, and a
+// check that a backend behaves the way the rest of the code relies on.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dubai/platform/backend/internal/storage"
+)
+
+// Memory keeps objects in a map. Its signed URLs look like
+// memory://<key>?expires=<unix seconds> and are checked with Verify.
+type Memory struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+	// Deleted lists the keys passed to Delete, in order.
+	Deleted []string
+	// DeleteErr, when set, is returned by Delete for every key.
+	DeleteErr error
+	// Now is the clock used for signed URLs (time.Now when nil).
+	Now func() time.Time
+}
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]memoryObject{}}
+}
+
+func (m *Memory) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*storage.Object, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, contentType: contentType}
+	return &storage.Object{Key: key, URL: "memory://" + key, Size: int64(len(data)), ContentType: contentType}, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.DeleteErr != nil {
+		return m.DeleteErr
+	}
+	m.Deleted = append(m.Deleted, key)
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(m.now().Add(expiry).Unix(), 10))
+	return "memory://" + key + "?" + q.Encode(), nil
+}
+
+// Verify returns the key a signed URL grants access to, if it hasn't expired.
+func (m *Memory) Verify(signedURL string) (string, bool) {
+	u, err := url.Parse(signedURL)
+	if err != nil || u.Scheme != "memory" {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil || m.now().Unix() > expires {
+		return "", false
+	}
+	return u.Host + u.Path, true
+}
+
+// Has reports whether an object is stored under key.
+func (m *Memory) Has(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[key]
+	return ok
+}
+
+// Run checks the behaviour every backend must share: what is put can be read
+// back under the returned key, deleted objects are gone, deleting twice is
+// fine and missing objects report storage.ErrNotFound.
+func Run(t *testing.T, s storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+	content := []byte("%PDF-1.4 contract")
+
+	obj, err := s.Put(ctx, storage.NewKey("documents", "contract.pdf"), bytes.NewReader(content), int64(len(content)), "application/pdf")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if obj.Key == "" {
+		t.Fatal("Put returned an empty key")
+	}
+	if obj.Size != int64(len(content)) {
+		t.Errorf("Put size = %d, want %d", obj.Size, len(content))
+	}
+
+	r, err := s.Get(ctx, obj.Key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get = %q, want %q", got, content)
+	}
+
+	signed, err := s.SignedURL(ctx, obj.Key, time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	if signed == "" {
+		t.Error("SignedURL returned an empty URL")
+	}
+
+	if err := s.Delete(ctx, obj.Key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, obj.Key); err != nil {
+		t.Errorf("second Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, obj.Key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+}
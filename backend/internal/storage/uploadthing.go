@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+)
+
+const uploadthingAPI = "https://api.uploadthing.com/v6"
+
+// Uploadthing stores files with the Uploadthing REST API. Keys are assigned
+// by Uploadthing, so the key passed to Put is only used for the file name.
+type Uploadthing struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func NewUploadthing(apiKey string) (*Uploadthing, error) {
+	if apiKey == "" {
+		return nil, errors.New("UPLOADTHING_SECRET not set")
+	}
+	return &Uploadthing{apiKey: apiKey, baseURL: uploadthingAPI, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+func (u *Uploadthing) call(ctx context.Context, endpoint string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", u.baseURL+endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Uploadthing-Api-Key", u.apiKey)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("uploadthing %s failed with status: %d", endpoint, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (u *Uploadthing) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Object, error) {
+	name := path.Base(key)
+
+	// 1. Ask Uploadthing for a presigned upload target
+	var presigned struct {
+		Data []struct {
+			Key     string            `json:"key"`
+			URL     string            `json:"url"`
+			Fields  map[string]string `json:"fields"`
+			FileURL string            `json:"fileUrl"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{
+		"files": []map[string]interface{}{{"name": name, "size": size, "type": contentType}},
+	}
+	if err := u.call(ctx, "/uploadFiles", payload, &presigned); err != nil {
+		return nil, err
+	}
+	if len(presigned.Data) == 0 {
+		return nil, errors.New("uploadthing returned no upload target")
+	}
+	target := presigned.Data[0]
+
+	// 2. Upload the bytes (presigned POST with form fields, or a plain PUT)
+	var req *http.Request
+	var err error
+	if len(target.Fields) > 0 {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for k, v := range target.Fields {
+			writer.WriteField(k, v)
+		}
+		part, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(part, r); err != nil {
+			return nil, err
+		}
+		writer.Close()
+		req, err = http.NewRequestWithContext(ctx, "POST", target.URL, body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "PUT", target.URL, r)
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("uploadthing upload failed with status: %d", resp.StatusCode)
+	}
+
+	return &Object{Key: target.Key, URL: target.FileURL, Size: size, ContentType: contentType}, nil
+}
+
+func (u *Uploadthing) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	signed, err := u.SignedURL(ctx, key, 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", signed, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("uploadthing download failed with status: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes a file from Uploadthing using its file key.
+func (u *Uploadthing) Delete(ctx context.Context, key string) error {
+	payload := map[string][]string{
+		"fileKeys": {key},
+	}
+	return u.call(ctx, "/deleteFiles", payload, nil)
+}
+
+func (u *Uploadthing) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	var result struct {
+		URL string `json:"url"`
+	}
+	payload := map[string]interface{}{
+		"fileKey":   key,
+		"expiresIn": int(expiry.Seconds()),
+	}
+	if err := u.call(ctx, "/requestFileAccess", payload, &result); err != nil {
+		return "", err
+	}
+	return result.URL, nil
+}
@@ -0,0 +1,130 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed from RFC 6238 appendix B, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; these are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	// Apps show secrets in either case
+	if got, _ := Code("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", 1); got != "287082" {
+		t.Errorf("lower case secret: Code = %s, want 287082", got)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret: no error")
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	tests := []struct {
+		name   string
+		offset int64 // steps from now the code was generated for
+		wantOK bool
+	}{
+		{name: "current step", offset: 0, wantOK: true},
+		{name: "previous step", offset: -1, wantOK: true},
+		{name: "next step", offset: 1, wantOK: true},
+		{name: "two steps old", offset: -2},
+		{name: "two steps ahead", offset: 2},
+		{name: "an hour old", offset: -120},
+	}
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, step+tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := Validate(rfcSecret, code, now)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		// The matched step is what callers store to refuse replays
+		if ok && got != step+tt.offset {
+			t.Errorf("%s: step = %d, want %d", tt.name, got, step+tt.offset)
+		}
+	}
+}
+
+func TestValidateInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	tests := []struct {
+		code   string
+		wantOK bool
+	}{
+		{"050471", true},
+		{" 050 471 ", true},
+		{"50471", false},
+		{"0504710", false},
+		{"", false},
+		{"abcdef", false},
+	}
+	for _, tt := range tests {
+		if _, ok := Validate(rfcSecret, tt.code, now); ok != tt.wantOK {
+			t.Errorf("Validate(%q) ok = %v, want %v", tt.code, ok, tt.wantOK)
+		}
+	}
+	if _, ok := Validate("not base32!", "050471", now); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if len(a) != 32 || a == b {
+		t.Errorf("secrets %q and %q, want two different 160-bit secrets", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Errorf("generated secret doesn't decode: %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("Acme Talent", "jane@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Acme Talent:jane@example.com" {
+		t.Errorf("URI = %s", u)
+	}
+	q := u.Query()
+	for key, want := range map[string]string{
+		"secret": rfcSecret, "issuer": "Acme Talent", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if q.Get(key) != want {
+			t.Errorf("%s = %q, want %q", key, q.Get(key), want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Package validate checks request payloads against rules declared in
+// `validate` struct tags, e.g.
+//
+//	Email     string     `json:"email" validate:"required,email"`
+//	Status    string     `json:"status" validate:"enum=client_status"`
+//	EndDate   *time.Time `json:"end_date" validate:"gtefield=StartDate"`
+//
+// Rules are separated by commas:
+//
+//	required       the value must be set (non-zero, non-nil, non-blank)
+//	email          an email address
+//	enum=<type>    a value of the Postgres enum type (see Enums)
+//	oneof=A B C    one of the listed values
+//	min=N, max=N   bounds for numbers, or lengths for strings and slices
+//	currency       an ISO 4217 currency code
+//	date, month    a YYYY-MM-DD or YYYY-MM string
+//	url            an absolute http(s) URL
+//	uuid           a UUID string
+//	gtefield=F     not before field F (times, dates and months)
+//	readonly       set by the server; input is ignored (see Complete)
+//
+// Every rule but required only checks values that are set, so optional
+// fields can be left out. Nested structs and slices of structs are checked
+// too, with paths like "line_items[0].amount" in the errors.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError is a problem with one input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Enums holds the values of the Postgres enum types in schema.sql. Keep it
+// in step with the schema when a type gains a value.
+var Enums = map[string][]string{
+	"user_role":      {"ADMIN", "HR", "SALES", "FINANCE", "CLIENT", "CLIENT_ADMIN", "CLIENT_USER"},
+	"talent_status":  {"SOURCED", "PRE_SCREENED", "BENCH_AVAILABLE", "BENCH_UNAVAILABLE", "ACTIVE_INTERVIEWING", "PLACED", "ARCHIVED"},
+	"client_status":  {"LEAD", "QUALIFIED", "ACTIVE", "CHURNED", "ARCHIVED"},
+	"project_status": {"TRIAL", "ACTIVE", "ENDING", "ENDED"},
+	"invoice_status": {"DRAFT", "SENT", "PAID", "OVERDUE"},
+	"payment_status": {"PENDING", "APPROVED", "PAID"},
+	"contract_type":  {"CLIENT", "CONTRACTOR", "MSA", "SOW", "NDA"},
+}
+
+// Struct checks v, a struct or a pointer to one, and returns a FieldError
+// for each broken rule. It panics on a rule it doesn't know, as that is a
+// mistake in the tag.
+func Struct(v any) []FieldError {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs []FieldError
+	checkStruct(rv, "", &errs)
+	return errs
+}
+
+// Value checks a single string, such as a query parameter, against rules
+// and returns what's wrong with it, or "" if nothing is. gtefield can't be
+// used here.
+func Value(s string, rules string) string {
+	v := reflect.ValueOf(s)
+	for _, rule := range strings.Split(rules, ",") {
+		key, arg, _ := strings.Cut(rule, "=")
+		if msg := checkRule(reflect.Value{}, v, key, arg); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// Complete reports the fields of v, a struct or a pointer to one, that a
+// full replacement must send but present lacks. present holds the top-level
+// JSON keys of the request; readonly fields are not needed.
+func Complete(v any, present map[string]bool) []FieldError {
+	rt := reflect.TypeOf(v)
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	var errs []FieldError
+	for _, sf := range reflect.VisibleFields(rt) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "" || present[name] || hasRule(sf, "readonly") {
+			continue
+		}
+		errs = append(errs, FieldError{Field: name, Message: "must be sent; null clears it"})
+	}
+	return errs
+}
+
+func hasRule(sf reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(sf.Tag.Get("validate"), ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *[]FieldError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous {
+			if s, ok := structValue(fv); ok {
+				checkStruct(s, prefix, errs)
+			}
+			continue
+		}
+		name := jsonName(sf)
+		if name == "" {
+			continue
+		}
+		path := prefix + name
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				key, arg, _ := strings.Cut(rule, "=")
+				if msg := checkRule(rv, fv, key, arg); msg != "" {
+					*errs = append(*errs, FieldError{Field: path, Message: msg})
+					break
+				}
+			}
+		}
+
+		// Nested input; readonly records are ignored, so aren't checked
+		if hasRule(sf, "readonly") {
+			continue
+		}
+		if s, ok := structValue(fv); ok && s.Type() != timeType {
+			checkStruct(s, path+".", errs)
+		}
+		if fv.Kind() == reflect.Slice {
+			for j := 0; j < fv.Len(); j++ {
+				if s, ok := structValue(fv.Index(j)); ok {
+					checkStruct(s, fmt.Sprintf("%s[%d].", path, j), errs)
+				}
+			}
+		}
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+// structValue dereferences v and reports whether it is a struct.
+func structValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.Name
+	}
+	return name
+}
+
+// isSet reports whether a value was given: pointers must be non-nil and
+// point to a non-zero value, strings must not be blank.
+func isSet(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) != ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() > 0
+	}
+	return !v.IsZero()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return v
+}
+
+func checkRule(parent, fv reflect.Value, key, arg string) string {
+	if key == "readonly" {
+		return ""
+	}
+	if key == "required" {
+		if !isSet(fv) {
+			return "is required"
+		}
+		return ""
+	}
+	if !isSet(fv) {
+		return ""
+	}
+	v := indirect(fv)
+
+	switch key {
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() || !strings.Contains(addr.Address[strings.LastIndex(addr.Address, "@"):], ".") {
+			return "must be a valid email address"
+		}
+	case "enum":
+		values, ok := Enums[arg]
+		if !ok {
+			panic("validate: unknown enum " + arg)
+		}
+		return oneOf(v.String(), values)
+	case "oneof":
+		return oneOf(v.String(), strings.Fields(arg))
+	case "min", "max":
+		return checkBound(v, key, arg)
+	case "currency":
+		if !currencies[v.String()] {
+			return "must be an ISO 4217 currency code, like USD"
+		}
+	case "date":
+		if _, err := time.Parse(time.DateOnly, v.String()); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case "month":
+		if _, err := time.Parse("2006-01", v.String()); err != nil {
+			return "must be a month (YYYY-MM)"
+		}
+	case "url":
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an http or https URL"
+		}
+	case "uuid":
+		if v.Type() == uuidType {
+			return ""
+		}
+		if _, err := uuid.Parse(v.String()); err != nil {
+			return "must be a UUID"
+		}
+	case "gtefield":
+		other, ok := parent.Type().FieldByName(arg)
+		if !ok {
+			panic("validate: unknown field " + arg)
+		}
+		ov := parent.FieldByIndex(other.Index)
+		if !isSet(ov) {
+			return ""
+		}
+		a, aok := comparableTime(v)
+		b, bok := comparableTime(indirect(ov))
+		if aok && bok && a.Before(b) {
+			return "must not be before " + jsonName(other)
+		}
+	default:
+		panic("validate: unknown rule " + key)
+	}
+	return ""
+}
+
+func oneOf(s string, values []string) string {
+	for _, v := range values {
+		if s == v {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(values, ", ")
+}
+
+func checkBound(v reflect.Value, key, arg string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic("validate: bad bound " + arg)
+	}
+	var n float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	default:
+		panic("validate: " + key + " on " + v.Kind().String())
+	}
+	if key == "min" && n < limit {
+		if unit == "" {
+			return "must be at least " + arg
+		}
+		return "must have at least " + arg + unit
+	}
+	if key == "max" && n > limit {
+		if unit == "" {
+			return "must be at most " + arg
+		}
+		return "must have at most " + arg + unit
+	}
+	return ""
+}
+
+var monthPattern = regexp.MustCompile(`^\d{4}-\d{2}$`)
+
+// comparableTime reads a time.Time, or a date or month string.
+func comparableTime(v reflect.Value) (time.Time, bool) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time), true
+	}
+	if v.Kind() != reflect.String {
+		return time.Time{}, false
+	}
+	layout := time.DateOnly
+	if monthPattern.MatchString(v.String()) {
+		layout = "2006-01"
+	}
+	t, err := time.Parse(layout, v.String())
+	return t, err == nil
+}
+
+// currencies are the active ISO 4217 codes.
+var currencies = func() map[string]bool {
+	m := map[string]bool{}
+	for _, c := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL
+		BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP
+		ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR
+		IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL
+		LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR
+		NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD
+		SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX
+		USD UYU UZS VES VND VUV WST XAF XCD XCG XOF XPF YER ZAR ZMW ZWG`) {
+		m[c] = true
+	}
+	return m
+}()
@@ -0,0 +1,247 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type lineItem struct {
+	Description string  `json:"description" validate:"required"`
+	Amount      float64 `json:"amount" validate:"min=0"`
+}
+
+type address struct {
+	Country string `json:"country" validate:"required,max=2"`
+}
+
+type input struct {
+	Name      string     `json:"name" validate:"required,max=5"`
+	Email     *string    `json:"email" validate:"email"`
+	Count     int        `json:"count" validate:"min=1,max=10"`
+	Rate      *float64   `json:"rate" validate:"min=0.5"`
+	Tags      []string   `json:"tags" validate:"max=2"`
+	Status    string     `json:"status" validate:"enum=client_status"`
+	Period    string     `json:"period" validate:"oneof=HOUR DAY MONTH"`
+	Currency  string     `json:"currency" validate:"currency"`
+	StartDate string     `json:"start_date" validate:"date"`
+	EndDate   string     `json:"end_date" validate:"date,gtefield=StartDate"`
+	Month     string     `json:"month" validate:"month"`
+	Website   string     `json:"website" validate:"url"`
+	OwnerID   string     `json:"owner_id" validate:"uuid"`
+	ClientID  uuid.UUID  `json:"client_id" validate:"uuid"`
+	Address   *address   `json:"address"`
+	Items     []lineItem `json:"items"`
+	Created   time.Time  `json:"created_at" validate:"readonly"`
+	Internal  string     `json:"-" validate:"required"`
+}
+
+// valid returns an input that passes, for the cases to break one field of.
+func valid() input {
+	return input{Name: "Acme", Count: 1, Internal: "x"}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(in *input)
+		want   []FieldError
+	}{
+		{name: "valid", modify: func(in *input) {}},
+		{
+			name:   "required missing",
+			modify: func(in *input) { in.Name = "" },
+			want:   []FieldError{{"name", "is required"}},
+		},
+		{
+			name:   "required blank",
+			modify: func(in *input) { in.Name = "   " },
+			want:   []FieldError{{"name", "is required"}},
+		},
+		{
+			name:   "string too long counts characters",
+			modify: func(in *input) { in.Name = "Zürich" },
+			want:   []FieldError{{"name", "must have at most 5 characters"}},
+		},
+		{name: "string at max", modify: func(in *input) { in.Name = "Zürch" }},
+		{
+			name:   "number below min, zero is unset and skipped",
+			modify: func(in *input) { in.Count = 0; in.Rate = ptr(0.25) },
+			want:   []FieldError{{"rate", "must be at least 0.5"}},
+		},
+		{
+			name:   "number above max",
+			modify: func(in *input) { in.Count = 11 },
+			want:   []FieldError{{"count", "must be at most 10"}},
+		},
+		{name: "number at bounds", modify: func(in *input) { in.Count = 10; in.Rate = ptr(0.5) }},
+		{
+			name:   "slice too long",
+			modify: func(in *input) { in.Tags = []string{"a", "b", "c"} },
+			want:   []FieldError{{"tags", "must have at most 2 items"}},
+		},
+		{
+			name:   "email",
+			modify: func(in *input) { in.Email = ptr("Jane <jane@example.com>") },
+			want:   []FieldError{{"email", "must be a valid email address"}},
+		},
+		{
+			name:   "email without domain dot",
+			modify: func(in *input) { in.Email = ptr("jane@localhost") },
+			want:   []FieldError{{"email", "must be a valid email address"}},
+		},
+		{
+			name:   "enum",
+			modify: func(in *input) { in.Status = "active" },
+			want:   []FieldError{{"status", "must be one of LEAD, QUALIFIED, ACTIVE, CHURNED, ARCHIVED"}},
+		},
+		{name: "enum value", modify: func(in *input) { in.Status = "CHURNED" }},
+		{
+			name:   "oneof",
+			modify: func(in *input) { in.Period = "WEEK" },
+			want:   []FieldError{{"period", "must be one of HOUR, DAY, MONTH"}},
+		},
+		{
+			name:   "currency",
+			modify: func(in *input) { in.Currency = "usd" },
+			want:   []FieldError{{"currency", "must be an ISO 4217 currency code, like USD"}},
+		},
+		{name: "currency code", modify: func(in *input) { in.Currency = "AED" }},
+		{
+			name:   "date",
+			modify: func(in *input) { in.StartDate = "2026-02-30" },
+			want:   []FieldError{{"start_date", "must be a date (YYYY-MM-DD)"}},
+		},
+		{
+			name:   "date before its lower field",
+			modify: func(in *input) { in.StartDate = "2026-03-01"; in.EndDate = "2026-02-28" },
+			want:   []FieldError{{"end_date", "must not be before start_date"}},
+		},
+		{name: "date on its lower field", modify: func(in *input) { in.StartDate = "2026-03-01"; in.EndDate = "2026-03-01" }},
+		{
+			name:   "month",
+			modify: func(in *input) { in.Month = "2026-13" },
+			want:   []FieldError{{"month", "must be a month (YYYY-MM)"}},
+		},
+		{
+			name:   "url",
+			modify: func(in *input) { in.Website = "javascript:alert(1)" },
+			want:   []FieldError{{"website", "must be an http or https URL"}},
+		},
+		{
+			name:   "uuid",
+			modify: func(in *input) { in.OwnerID = "42" },
+			want:   []FieldError{{"owner_id", "must be a UUID"}},
+		},
+		{
+			name:   "nested struct",
+			modify: func(in *input) { in.Address = &address{Country: "UAE"} },
+			want:   []FieldError{{"address.country", "must have at most 2 characters"}},
+		},
+		{
+			name: "slice of structs",
+			modify: func(in *input) {
+				in.Items = []lineItem{{Description: "ok", Amount: 1}, {Amount: -1}}
+			},
+			want: []FieldError{
+				{"items[1].description", "is required"},
+				{"items[1].amount", "must be at least 0"},
+			},
+		},
+		{
+			name:   "every broken field reported, first rule only",
+			modify: func(in *input) { in.Name = ""; in.Count = 20; in.Currency = "XXX" },
+			want: []FieldError{
+				{"name", "is required"},
+				{"count", "must be at most 10"},
+				{"currency", "must be an ISO 4217 currency code, like USD"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.modify(&in)
+			got := Struct(&in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructNonStruct(t *testing.T) {
+	var nilInput *input
+	for _, v := range []any{nil, nilInput, "text", 3} {
+		if errs := Struct(v); errs != nil {
+			t.Errorf("Struct(%#v) = %v, want nil", v, errs)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value, rules, want string
+	}{
+		{"", "required", "is required"},
+		{"", "currency", ""},
+		{"EUR", "required,currency", ""},
+		{"EURO", "required,currency", "must be an ISO 4217 currency code, like USD"},
+		{"2026-01", "month", ""},
+		{"abc", "max=2", "must have at most 2 characters"},
+	}
+	for _, tt := range tests {
+		if got := Value(tt.value, tt.rules); got != tt.want {
+			t.Errorf("Value(%q, %q) = %q, want %q", tt.value, tt.rules, got, tt.want)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	type record struct {
+		Name    string    `json:"name" validate:"required"`
+		Notes   *string   `json:"notes"`
+		Version int       `json:"version" validate:"readonly"`
+		Created time.Time `json:"created_at" validate:"readonly"`
+		Secret  string    `json:"-"`
+	}
+	got := Complete(&record{}, map[string]bool{"name": true})
+	want := []FieldError{{"notes", "must be sent; null clears it"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Complete() = %v, want %v", got, want)
+	}
+	if got := Complete(record{}, map[string]bool{"name": true, "notes": true}); got != nil {
+		t.Errorf("Complete() with every field = %v, want nil", got)
+	}
+}
+
+func TestBadTagsPanic(t *testing.T) {
+	tests := map[string]any{
+		"unknown rule": &struct {
+			A string `json:"a" validate:"shiny"`
+		}{A: "x"},
+		"unknown enum": &struct {
+			A string `json:"a" validate:"enum=colour"`
+		}{A: "x"},
+		"bad bound": &struct {
+			A int `json:"a" validate:"min=one"`
+		}{A: 1},
+		"unknown field": &struct {
+			A string `json:"a" validate:"gtefield=B"`
+		}{A: "2026-01-01"},
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Struct didn't panic")
+				}
+			}()
+			Struct(v)
+		})
+	}
+}
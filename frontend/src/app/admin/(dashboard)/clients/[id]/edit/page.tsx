@@ -12,8 +12,8 @@ import { toast } from "sonner";
 // Helper since backend get(id) is not widely available, or we use list logic
 // Using api.clients.list and filtering for now, but update relies on id
 function useClientDetail(id: string) {
-    const { data } = useQuery({ queryKey: ["clients"], queryFn: api.clients.list });
-    return data?.find(t => t.id === id);
+    const { data } = useQuery({ queryKey: ["clients", id], queryFn: () => api.clients.get(id) });
+    return data;
 }
 
 import { Skeleton } from "@/components/ui/skeleton";
@@ -41,7 +41,7 @@ export default function EditClientPage() {
   const client = useClientDetail(id);
 
   const mutation = useMutation({
-    mutationFn: (data: ClientFormData) => api.clients.update(id, data),
+    mutationFn: (data: ClientFormData) => api.clients.update(id, data, client?.version),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["clients"] });
       toast.success("Client updated successfully");
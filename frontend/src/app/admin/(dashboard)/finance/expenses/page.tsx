@@ -23,7 +23,7 @@ export default function ExpensesPage() {
     
     const onSubmit = async (data: any) => {
         try {
-            await api.finance.expenses.create({ ...data, amount: Number(data.amount) });
+            await api.finance.expenses.create({ ...data, amount: Number(data.amount), currency: data.currency?.toUpperCase() || undefined });
             queryClient.invalidateQueries({ queryKey: ["finance", "expenses"] });
             toast.success("Expense recorded");
             setOpen(false);
@@ -43,6 +43,7 @@ export default function ExpensesPage() {
                             <div className="space-y-2"><Label>Description</Label><Input {...register("description")} placeholder="e.g. Server Cost" required /></div>
                             <div className="space-y-2"><Label>Category</Label><Input {...register("category")} placeholder="Software" required /></div>
                             <div className="space-y-2"><Label>Amount</Label><Input type="number" step="0.01" {...register("amount")} required /></div>
+                            <div className="space-y-2"><Label>Currency</Label><Input {...register("currency")} placeholder="USD" maxLength={3} /></div>
                             <div className="space-y-2"><Label>Date</Label><Input type="date" {...register("date")} required /></div>
                             
                             <div className="space-y-2">
@@ -79,7 +80,7 @@ export default function ExpensesPage() {
                                 <TableCell>{e.date}</TableCell>
                                 <TableCell className="font-medium">{e.description}</TableCell>
                                 <TableCell><span className="inline-flex items-center px-2 py-0.5 rounded-full text-xs font-medium bg-muted text-muted-foreground">{e.category}</span></TableCell>
-                                <TableCell className="text-right font-bold">-${e.amount.toLocaleString()}</TableCell>
+                                <TableCell className="text-right font-bold">-{e.amount.toLocaleString()} {e.currency}</TableCell>
                             </TableRow>
                         ))}
                     </TableBody>
@@ -37,7 +37,7 @@ export default function EditAssignmentPage() {
             monthly_contractor_cost: data.monthly_contractor_cost ? Number(data.monthly_contractor_cost) : undefined,
             start_date: new Date(data.start_date as string).toISOString()
         }
-        return api.projects.assignments.update(assignmentId, payload)
+        return api.projects.assignments.update(assignmentId, payload, assignment?.version)
     },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["assignments"] });
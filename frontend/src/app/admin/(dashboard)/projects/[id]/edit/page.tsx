@@ -40,7 +40,7 @@ export default function EditProjectPage() {
 
   const mutation = useMutation({
     mutationFn: (data: ParentProjectFormData) => {
-        return api.projects.update(id, data as any)
+        return api.projects.update(id, data as any, project?.version)
     },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["projects"] });
@@ -116,12 +116,7 @@ export default function ProjectDetailPage() {
   // Queries
   const { data: assignments, isLoading: assignmentsLoading } = useQuery({
       queryKey: ["assignments", id],
-      queryFn: () => api.projects.assignments.listByProject(id)
-  });
-
-  const { data: talent } = useQuery({
-      queryKey: ["talent"],
-      queryFn: () => api.talent.list()
+      queryFn: () => api.projects.assignments.listByProject(id, "talent")
   });
 
   const deleteAssignmentMutation = useMutation({
@@ -143,7 +138,7 @@ export default function ProjectDetailPage() {
   
   
   const updateStatusMutation = useMutation({
-      mutationFn: (newStatus: string) => api.projects.update(id, { status: newStatus as any }),
+      mutationFn: (newStatus: string) => api.projects.update(id, { status: newStatus as any }, project?.version),
       onSuccess: () => {
           queryClient.invalidateQueries({ queryKey: ["projects", id] });
           queryClient.invalidateQueries({ queryKey: ["projects"] });
@@ -379,7 +374,7 @@ export default function ProjectDetailPage() {
                 </TableRow>
                 ) : (
                 assignments?.map((assignment) => {
-                    const t = talent?.find(x => x.id === assignment.talent_id);
+                    const t = assignment.talent;
                     const dailyBill = assignment.daily_bill_rate || 0;
                     const dailyPay = assignment.daily_payout_rate || 0;
                     const margin = dailyBill - dailyPay;
@@ -448,7 +443,7 @@ export default function ProjectDetailPage() {
         open={isEditModalOpen}
         onOpenChange={setIsEditModalOpen}
         assignment={editAssignment}
-        talentName={talent?.find((x: any) => x.id === editAssignment?.talent_id)?.first_name + " " + talent?.find((x: any) => x.id === editAssignment?.talent_id)?.last_name || ""}
+        talentName={editAssignment?.talent ? `${editAssignment.talent.first_name} ${editAssignment.talent.last_name}` : ""}
       />
     </div>
   );
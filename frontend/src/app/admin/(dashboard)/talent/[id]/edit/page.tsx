@@ -39,7 +39,7 @@ export default function EditTalentPage() {
   });
 
   const mutation = useMutation({
-    mutationFn: (data: Partial<Talent>) => api.talent.update(id, data),
+    mutationFn: (data: Partial<Talent>) => api.talent.update(id, data, talent?.version),
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ["talent"] });
       queryClient.invalidateQueries({ queryKey: ["talent", id] });
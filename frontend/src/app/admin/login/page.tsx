@@ -1,19 +1,38 @@
 "use client";
 
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import { useAuth } from "@/components/auth-provider";
 import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { MfaLoginStep } from "@/components/mfa-login-step";
 import { api } from "@/lib/api";
 
+const BACKEND_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const SSO_ENABLED = process.env.NEXT_PUBLIC_SSO_ENABLED === "true";
+
+// Why single sign-on sent the browser back here, from /login?error=
+const SSO_ERRORS: Record<string, string> = {
+  sso_failed: "Single sign-on failed. Please try again.",
+  sso_expired: "The single sign-on attempt expired. Please try again.",
+  sso_denied: "Your account has no access to this application.",
+};
+
 export default function LoginPage() {
   const { login } = useAuth();
   const [username, setUsername] = useState("");
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
   const [loading, setLoading] = useState(false);
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
+
+  useEffect(() => {
+    const reason = new URLSearchParams(window.location.search).get("error");
+    if (reason) {
+      setError(SSO_ERRORS[reason] ?? SSO_ERRORS.sso_failed);
+    }
+  }, []);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -22,8 +41,13 @@ export default function LoginPage() {
 
     try {
       // Use username for login
-      const { token, user } = await api.auth.login(username, password);
-      login(token, user);
+      const result = await api.auth.login(username, password);
+      if (result.mfa_required && result.mfa_token) {
+        setMfaToken(result.mfa_token);
+        setLoading(false);
+        return;
+      }
+      login(result.token ?? "", result.user);
     } catch (err) {
       setError("Invalid credentials");
       setLoading(false); // Stop loading if error
@@ -42,34 +66,50 @@ export default function LoginPage() {
           <CardDescription className="text-center">Sign in to your account</CardDescription>
         </CardHeader>
         <CardContent>
-          <form onSubmit={handleSubmit} className="space-y-4">
-            <div className="space-y-2">
-              <Label htmlFor="username">Username</Label>
-              <Input
-                id="username"
-                type="text"
-                placeholder="admin"
-                value={username}
-                onChange={(e) => setUsername(e.target.value)}
-                required
-              />
-            </div>
-            <div className="space-y-2">
-              <Label htmlFor="password">Password</Label>
-              <Input
-                id="password"
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                required
-              />
-            </div>
-            {error && <div className="text-sm text-red-500 font-medium">{error}</div>}
-            <Button type="submit" className="w-full" disabled={loading}>
-              {loading ? "Signing in..." : "Sign In"}
-            </Button>
+          {mfaToken ? (
+            <MfaLoginStep
+              mfaToken={mfaToken}
+              onSignedIn={(result) => login(result.token ?? "", result.user)}
+              onCancel={() => {
+                setMfaToken(null);
+                setPassword("");
+              }}
+            />
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              <div className="space-y-2">
+                <Label htmlFor="username">Username</Label>
+                <Input
+                  id="username"
+                  type="text"
+                  placeholder="admin"
+                  value={username}
+                  onChange={(e) => setUsername(e.target.value)}
+                  required
+                />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="password">Password</Label>
+                <Input
+                  id="password"
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                />
+              </div>
+              {error && <div className="text-sm text-red-500 font-medium">{error}</div>}
+              <Button type="submit" className="w-full" disabled={loading}>
+                {loading ? "Signing in..." : "Sign In"}
+              </Button>
+              {SSO_ENABLED && (
+                <Button variant="outline" className="w-full" asChild>
+                  <a href={`${BACKEND_URL}/auth/oidc/login`}>Sign in with SSO</a>
+                </Button>
+              )}
             
-          </form>
+            </form>
+          )}
         </CardContent>
       </Card>
     </div>
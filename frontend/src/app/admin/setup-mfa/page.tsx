@@ -0,0 +1,5 @@
+import { MfaSetup } from "@/components/mfa-setup";
+
+export default function SetupMfaPage() {
+  return <MfaSetup />;
+}
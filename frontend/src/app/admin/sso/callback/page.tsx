@@ -0,0 +1,57 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import Link from "next/link";
+import { useAuth } from "@/components/auth-provider";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+
+// Single sign-on lands here with #token=...&redirect=... from the backend.
+export default function SsoCallbackPage() {
+  const { login } = useAuth();
+  const [error, setError] = useState("");
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    // Keep the token out of the history and anything the page links to
+    window.history.replaceState(null, "", window.location.pathname);
+
+    const token = params.get("token");
+    if (!token) {
+      setError("The sign-in link is incomplete. Please sign in again.");
+      return;
+    }
+    // Only paths on this site, not //other.host
+    const redirect = params.get("redirect");
+    const next = redirect && redirect.startsWith("/") && !redirect.startsWith("//") ? redirect : "/";
+
+    fetch("/api/auth/session", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ token }),
+    })
+      .then(async (res) => {
+        if (!res.ok) {
+          throw new Error("Your session could not be started. Please sign in again.");
+        }
+        const { user } = await res.json();
+        login(token, user, next);
+      })
+      .catch((err) => setError(err instanceof Error ? err.message : "Sign-in failed"));
+  }, []);
+
+  return (
+    <div className="flex h-screen items-center justify-center bg-background">
+      <Card className="w-full max-w-md">
+        <CardHeader>
+          <CardTitle className="text-2xl text-center">{error ? "Sign-in failed" : "Signing you in..."}</CardTitle>
+          {error && <CardDescription className="text-center">{error}</CardDescription>}
+        </CardHeader>
+        {error && (
+          <CardContent className="text-center">
+            <Link href="/login" className="underline">Back to sign in</Link>
+          </CardContent>
+        )}
+      </Card>
+    </div>
+  );
+}
@@ -27,12 +27,19 @@ export async function PUT(
   return handleRequest(request, proxy, "PUT");
 }
 
+export async function PATCH(
+  request: Request,
+  { params }: { params: Promise<{ proxy: string[] }> }
+) {
+  const { proxy } = await params;
+  return handleRequest(request, proxy, "PATCH");
+}
+
 export async function DELETE(
   request: Request,
   { params }: { params: Promise<{ proxy: string[] }> }
 ) {
   const { proxy } = await params;
-  console.log("DEBUG: Proxy DELETE called for path:", proxy.join("/"));
   return handleRequest(request, proxy, "DELETE");
 }
 
@@ -45,13 +52,24 @@ async function handleRequest(request: Request, proxyPath: string[], method: stri
   const cookieStore = await cookies();
   const token = cookieStore.get("auth_token")?.value;
 
-  const headers: HeadersInit = {
-    "Content-Type": "application/json",
+  const headers: Record<string, string> = {
+    // PATCH bodies are sent as application/merge-patch+json
+    "Content-Type": request.headers.get("Content-Type") || "application/json",
   };
 
   if (token) {
     headers["Authorization"] = `Bearer ${token}`;
   }
+  // The backend only believes this when TRUSTED_PROXIES covers this server
+  const forwardedFor = request.headers.get("X-Forwarded-For");
+  if (forwardedFor) {
+    headers["X-Forwarded-For"] = forwardedFor;
+  }
+  // Optimistic concurrency: the backend rejects stale If-Match versions with 412
+  const ifMatch = request.headers.get("If-Match");
+  if (ifMatch) {
+    headers["If-Match"] = ifMatch;
+  }
 
   try {
     const body = method !== "GET" && method !== "DELETE" ? await request.json() : undefined;
@@ -64,11 +82,17 @@ async function handleRequest(request: Request, proxyPath: string[], method: stri
 
     const data = await response.json().catch(() => ({}));
 
+    const responseHeaders: Record<string, string> = {};
+    const etag = response.headers.get("ETag");
+    if (etag) {
+      responseHeaders["ETag"] = etag;
+    }
+
     if (response.status === 204) {
-      return new NextResponse(null, { status: 204 });
+      return new NextResponse(null, { status: 204, headers: responseHeaders });
     }
 
-    return NextResponse.json(data, { status: response.status });
+    return NextResponse.json(data, { status: response.status, headers: responseHeaders });
   } catch (error) {
     console.error(`Proxy error (${method} ${path}):`, error);
     return NextResponse.json({ error: "Internal Server Error" }, { status: 500 });
@@ -0,0 +1,5 @@
+import { forwardAuth } from "@/lib/session";
+
+export async function POST(request: Request) {
+  return forwardAuth(request, "/auth/login/mfa");
+}
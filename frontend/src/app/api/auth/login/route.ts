@@ -1,51 +1,7 @@
-import { NextResponse } from "next/server";
-import { cookies } from "next/headers";
-
-const BACKEND_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+import { forwardAuth } from "@/lib/session";
 
+// Accounts with two-factor auth get { mfa_required, mfa_token } here and
+// finish signing in at /api/auth/login/mfa.
 export async function POST(request: Request) {
-  try {
-    const body = await request.json();
-    
-    const response = await fetch(`${BACKEND_URL}/auth/login`, {
-      method: "POST",
-      headers: {
-        "Content-Type": "application/json",
-      },
-      body: JSON.stringify(body),
-    });
-
-    let data;
-    const contentType = response.headers.get("content-type");
-    if (contentType && contentType.includes("application/json")) {
-        data = await response.json();
-    } else {
-        data = await response.text();
-        try {
-            data = JSON.parse(data);
-        } catch (e) {
-            // If text, wrap it
-            data = { message: data, error: data };
-        }
-    }
-
-    if (!response.ok) {
-      return NextResponse.json(data, { status: response.status });
-    }
-
-    // Set cookie on success
-    const cookieStore = await cookies();
-    cookieStore.set("auth_token", data.token, {
-      httpOnly: true,
-      secure: process.env.NODE_ENV === "production",
-      sameSite: "strict",
-      path: "/",
-      maxAge: 60 * 60 * 24, // 1 day
-    });
-
-    return NextResponse.json(data);
-  } catch (error) {
-    console.error("Login error:", error);
-    return NextResponse.json({ error: "Internal Server Error" }, { status: 500 });
-  }
+  return forwardAuth(request, "/auth/login");
 }
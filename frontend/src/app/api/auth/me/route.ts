@@ -1,7 +1,6 @@
 import { NextResponse } from "next/server";
 import { cookies } from "next/headers";
-
-const BACKEND_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+import { BACKEND_URL, sessionFlags } from "@/lib/session";
 
 export async function GET() {
   const cookieStore = await cookies();
@@ -24,7 +23,7 @@ export async function GET() {
       return NextResponse.json(data, { status: response.status });
     }
 
-    return NextResponse.json(data);
+    return NextResponse.json({ ...data, ...sessionFlags(token) });
   } catch (error) {
     console.error("Auth me error:", error);
     return NextResponse.json({ error: "Internal Server Error" }, { status: 500 });
@@ -0,0 +1,6 @@
+import { forwardAuth } from "@/lib/session";
+
+// Enabling 2FA on an account that must have it issues an unrestricted session.
+export async function POST(request: Request) {
+  return forwardAuth(request, "/auth/mfa/enable", true);
+}
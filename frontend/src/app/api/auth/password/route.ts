@@ -0,0 +1,7 @@
+import { forwardAuth } from "@/lib/session";
+
+// Changing the password issues a new session without the forced-change
+// restriction, so it can't go through the generic proxy.
+export async function POST(request: Request) {
+  return forwardAuth(request, "/auth/password", true);
+}
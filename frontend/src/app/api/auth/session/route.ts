@@ -0,0 +1,30 @@
+import { NextResponse } from "next/server";
+import { BACKEND_URL, sessionFlags, setSessionCookie } from "@/lib/session";
+
+// Single sign-on hands the browser a session token in the URL fragment of
+// /sso/callback. That page posts it here so it ends up in the HttpOnly cookie
+// like a password sign-in, once the backend has accepted it.
+export async function POST(request: Request) {
+  try {
+    const { token } = await request.json();
+    if (typeof token !== "string" || !token) {
+      return NextResponse.json({ error: "Missing token" }, { status: 400 });
+    }
+
+    const response = await fetch(`${BACKEND_URL}/auth/me`, {
+      headers: {
+        Authorization: `Bearer ${token}`,
+      },
+    });
+    const data = await response.json().catch(() => ({}));
+    if (!response.ok) {
+      return NextResponse.json(data, { status: response.status });
+    }
+
+    await setSessionCookie(token);
+    return NextResponse.json({ user: { ...data, ...sessionFlags(token) } });
+  } catch (error) {
+    console.error("Session error:", error);
+    return NextResponse.json({ error: "Internal Server Error" }, { status: 500 });
+  }
+}
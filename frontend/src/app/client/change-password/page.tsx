@@ -0,0 +1,5 @@
+import { ChangePasswordForm } from "@/components/change-password-form";
+
+export default function ChangePasswordPage() {
+  return <ChangePasswordForm />;
+}
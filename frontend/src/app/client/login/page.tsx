@@ -6,6 +6,7 @@ import { Button } from "@/components/ui/button";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { MfaLoginStep } from "@/components/mfa-login-step";
 import { api } from "@/lib/api";
 
 export default function ClientLoginPage() {
@@ -14,6 +15,7 @@ export default function ClientLoginPage() {
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
   const [loading, setLoading] = useState(false);
+  const [mfaToken, setMfaToken] = useState<string | null>(null);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
@@ -23,12 +25,18 @@ export default function ClientLoginPage() {
     try {
       // NOTE: Using same login API for now. 
       // In real scenario, might want distinct endpoints or just role check on response.
-      const { token, user } = await api.auth.login(email, password);
+      const result = await api.auth.login(email, password);
+      if (result.mfa_required && result.mfa_token) {
+        setMfaToken(result.mfa_token);
+        setLoading(false);
+        return;
+      }
+      const { token, user } = result;
       
       // Simple role check (optional, backend should enforce)
       // if (user.role !== 'CLIENT') throw new Error("Unauthorized");
       
-      login(token, user);
+      login(token ?? "", user);
     } catch (err) {
       setError("Invalid credentials");
       setLoading(false);
@@ -44,33 +52,44 @@ export default function ClientLoginPage() {
           <CardDescription className="text-center">Secure access to your projects</CardDescription>
         </CardHeader>
         <CardContent>
-          <form onSubmit={handleSubmit} className="space-y-4">
-            <div className="space-y-2">
-              <Label htmlFor="email">Email</Label>
-              <Input
-                id="email"
-                type="text"
-                placeholder="name@company.com"
-                value={email}
-                onChange={(e) => setEmail(e.target.value)}
-                required
-              />
-            </div>
-            <div className="space-y-2">
-              <Label htmlFor="password">Password</Label>
-              <Input
-                id="password"
-                type="password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-                required
-              />
-            </div>
-            {error && <div className="text-sm text-red-500 font-medium text-center">{error}</div>}
-            <Button type="submit" className="w-full font-bold" disabled={loading}>
-              {loading ? "Accessing Secure Portal..." : "Sign In"}
-            </Button>
-          </form>
+          {mfaToken ? (
+            <MfaLoginStep
+              mfaToken={mfaToken}
+              onSignedIn={(result) => login(result.token ?? "", result.user)}
+              onCancel={() => {
+                setMfaToken(null);
+                setPassword("");
+              }}
+            />
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              <div className="space-y-2">
+                <Label htmlFor="email">Email</Label>
+                <Input
+                  id="email"
+                  type="text"
+                  placeholder="name@company.com"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  required
+                />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="password">Password</Label>
+                <Input
+                  id="password"
+                  type="password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                />
+              </div>
+              {error && <div className="text-sm text-red-500 font-medium text-center">{error}</div>}
+              <Button type="submit" className="w-full font-bold" disabled={loading}>
+                {loading ? "Accessing Secure Portal..." : "Sign In"}
+              </Button>
+            </form>
+          )}
         </CardContent>
       </Card>
     </div>
@@ -10,11 +10,15 @@ interface User {
   id: string;
   email: string;
   role: UserRole;
+  mfa_enabled?: boolean;
+  // Until these are dealt with the backend refuses everything else
+  must_change_password?: boolean;
+  mfa_setup_required?: boolean;
 }
 
 interface AuthContextType {
   user: User | null;
-  login: (token: string, user: User) => void;
+  login: (token: string, user: User, next?: string) => void;
   logout: () => void;
   isLoading: boolean;
 }
@@ -53,7 +57,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     initAuth();
   }, []);
 
-  const login = (token: string, user: User) => {
+  const login = (token: string, user: User, next = "/") => {
     // Note: The /api/auth/login route already set the HttpOnly cookie.
     // We still store user info in localStorage for quick access, but token is in cookie.
     localStorage.setItem("user", JSON.stringify(user));
@@ -61,7 +65,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     setUser(user);
     
     // Redirect to root. Middleware handles the rewrite to /admin/dashboard or /client based on domain.
-    router.push("/");
+    router.push(next);
   };
 
   const logout = async () => {
@@ -78,9 +82,15 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
 
   useEffect(() => {
       // Basic route protection
-      if (!isLoading && !user && pathname !== "/login" && pathname !== "/") {
+      if (!isLoading && !user && pathname !== "/login" && pathname !== "/" && pathname !== "/sso/callback") {
           router.push("/login");
       }
+      // Restricted sessions can only change the password or set up 2FA
+      if (user?.must_change_password && pathname !== "/change-password") {
+          router.push("/change-password");
+      } else if (!user?.must_change_password && user?.mfa_setup_required && pathname !== "/setup-mfa") {
+          router.push("/setup-mfa");
+      }
   }, [user, isLoading, pathname, router]);
 
   return (
@@ -0,0 +1,97 @@
+"use client";
+
+import { useState } from "react";
+import { useAuth } from "@/components/auth-provider";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { api } from "@/lib/api";
+
+// ChangePasswordForm is where accounts created with a temporary password
+// choose their own before they can do anything else.
+export function ChangePasswordForm() {
+  const { user, login, logout } = useAuth();
+  const [currentPassword, setCurrentPassword] = useState("");
+  const [newPassword, setNewPassword] = useState("");
+  const [confirmPassword, setConfirmPassword] = useState("");
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+    if (newPassword !== confirmPassword) {
+      setError("The new passwords don't match");
+      return;
+    }
+    setLoading(true);
+
+    try {
+      const { token, user } = await api.auth.changePassword(currentPassword, newPassword);
+      login(token ?? "", user);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Failed to change password");
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex min-h-screen items-center justify-center bg-background">
+      <Card className="w-full max-w-md">
+        <CardHeader>
+          <CardTitle className="text-2xl text-center">Choose a new password</CardTitle>
+          <CardDescription className="text-center">
+            {user?.must_change_password
+              ? "Your account was set up with a temporary password. Choose your own to continue."
+              : "Change the password you sign in with."}
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form onSubmit={handleSubmit} className="space-y-4">
+            <div className="space-y-2">
+              <Label htmlFor="current-password">Current password</Label>
+              <Input
+                id="current-password"
+                type="password"
+                autoComplete="current-password"
+                value={currentPassword}
+                onChange={(e) => setCurrentPassword(e.target.value)}
+                required
+              />
+            </div>
+            <div className="space-y-2">
+              <Label htmlFor="new-password">New password</Label>
+              <Input
+                id="new-password"
+                type="password"
+                autoComplete="new-password"
+                value={newPassword}
+                onChange={(e) => setNewPassword(e.target.value)}
+                required
+              />
+            </div>
+            <div className="space-y-2">
+              <Label htmlFor="confirm-password">Confirm new password</Label>
+              <Input
+                id="confirm-password"
+                type="password"
+                autoComplete="new-password"
+                value={confirmPassword}
+                onChange={(e) => setConfirmPassword(e.target.value)}
+                required
+              />
+            </div>
+            {error && <div className="text-sm text-red-500 font-medium">{error}</div>}
+            <Button type="submit" className="w-full" disabled={loading}>
+              {loading ? "Saving..." : "Change password"}
+            </Button>
+            <Button type="button" variant="ghost" className="w-full" onClick={logout}>
+              Sign out
+            </Button>
+          </form>
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
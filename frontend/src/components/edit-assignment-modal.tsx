@@ -49,15 +49,15 @@ export function EditAssignmentModal({ open, onOpenChange, assignment, talentName
     e.preventDefault();
     setIsSubmitting(true);
     try {
+      // Only the fields this form edits; ids, totals and timestamps are the server's
       await api.projects.assignments.update(assignment.id, {
-        ...assignment,
         role: formData.role,
         daily_bill_rate: parseFloat(formData.dailyBillRate),
         daily_payout_rate: parseFloat(formData.dailyPayoutRate),
         hours_per_week: parseInt(formData.hoursPerWeek),
         status: formData.status,
         start_date: new Date(formData.startDate).toISOString()
-      });
+      }, assignment.version);
       
       toast.success("Assignment updated successfully");
       queryClient.invalidateQueries({ queryKey: ["projects", assignment.project_id] });
@@ -0,0 +1,83 @@
+"use client";
+
+import { useState } from "react";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { api, type LoginResult } from "@/lib/api";
+
+interface MfaLoginStepProps {
+  mfaToken: string;
+  onSignedIn: (result: LoginResult) => void;
+  onCancel: () => void;
+}
+
+// MfaLoginStep finishes signing in to an account with two-factor auth, using
+// the authenticator app or one of the recovery codes.
+export function MfaLoginStep({ mfaToken, onSignedIn, onCancel }: MfaLoginStepProps) {
+  const [code, setCode] = useState("");
+  const [useRecoveryCode, setUseRecoveryCode] = useState(false);
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+    setLoading(true);
+
+    try {
+      const result = await api.auth.verifyMfa(
+        mfaToken,
+        useRecoveryCode ? { recovery_code: code.trim() } : { code: code.replace(/\s/g, "") }
+      );
+      onSignedIn(result);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Invalid code");
+      setLoading(false);
+    }
+  };
+
+  return (
+    <form onSubmit={handleSubmit} className="space-y-4">
+      <div className="space-y-2">
+        <Label htmlFor="mfa-code">{useRecoveryCode ? "Recovery code" : "Authentication code"}</Label>
+        <Input
+          id="mfa-code"
+          type="text"
+          inputMode={useRecoveryCode ? "text" : "numeric"}
+          autoComplete="one-time-code"
+          placeholder={useRecoveryCode ? "xxxxx-xxxxx" : "123456"}
+          value={code}
+          onChange={(e) => setCode(e.target.value)}
+          autoFocus
+          required
+        />
+        <p className="text-sm text-muted-foreground">
+          {useRecoveryCode
+            ? "Each recovery code can only be used once."
+            : "Enter the 6-digit code from your authenticator app."}
+        </p>
+      </div>
+      {error && <div className="text-sm text-red-500 font-medium">{error}</div>}
+      <Button type="submit" className="w-full" disabled={loading}>
+        {loading ? "Verifying..." : "Verify"}
+      </Button>
+      <div className="flex justify-between text-sm">
+        <button
+          type="button"
+          className="text-muted-foreground hover:text-foreground"
+          onClick={() => {
+            setUseRecoveryCode(!useRecoveryCode);
+            setCode("");
+            setError("");
+          }}
+        >
+          {useRecoveryCode ? "Use authenticator app" : "Use a recovery code"}
+        </button>
+        <button type="button" className="text-muted-foreground hover:text-foreground" onClick={onCancel}>
+          Back
+        </button>
+      </div>
+    </form>
+  );
+}
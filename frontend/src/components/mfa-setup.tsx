@@ -0,0 +1,122 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import { useAuth } from "@/components/auth-provider";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card";
+import { api, type MfaSetup as MfaSecret } from "@/lib/api";
+
+// MfaSetup enrolls the signed-in user in two-factor auth: it shows the secret
+// for their authenticator app, checks a first code and then shows the
+// recovery codes, which are never shown again.
+export function MfaSetup() {
+  const { user, login, logout } = useAuth();
+  const [secret, setSecret] = useState<MfaSecret | null>(null);
+  const [code, setCode] = useState("");
+  const [recoveryCodes, setRecoveryCodes] = useState<string[] | null>(null);
+  const [error, setError] = useState("");
+  const [loading, setLoading] = useState(false);
+
+  useEffect(() => {
+    api.auth.setupMfa()
+      .then(setSecret)
+      .catch((err) => setError(err instanceof Error ? err.message : "Failed to start two-factor setup"));
+  }, []);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+    setLoading(true);
+
+    try {
+      const { recovery_codes } = await api.auth.enableMfa(code.replace(/\s/g, ""));
+      setRecoveryCodes(recovery_codes);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : "Invalid code");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const handleDone = async () => {
+    // The session may have been replaced with an unrestricted one
+    const me = await api.auth.me();
+    login("", me);
+  };
+
+  return (
+    <div className="flex min-h-screen items-center justify-center bg-background">
+      <Card className="w-full max-w-md">
+        <CardHeader>
+          <CardTitle className="text-2xl text-center">Two-factor authentication</CardTitle>
+          <CardDescription className="text-center">
+            {recoveryCodes
+              ? "Save these recovery codes somewhere safe. Each one signs you in once if you lose your authenticator."
+              : user?.mfa_setup_required
+                ? "Your account requires two-factor authentication. Set it up to continue."
+                : "Protect your account with an authenticator app."}
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          {recoveryCodes ? (
+            <div className="space-y-4">
+              <ul className="grid grid-cols-2 gap-2 rounded-md border p-4 font-mono text-sm">
+                {recoveryCodes.map((c) => (
+                  <li key={c}>{c}</li>
+                ))}
+              </ul>
+              <Button
+                type="button"
+                variant="outline"
+                className="w-full"
+                onClick={() => navigator.clipboard.writeText(recoveryCodes.join("\n"))}
+              >
+                Copy codes
+              </Button>
+              <Button type="button" className="w-full" onClick={handleDone}>
+                I have saved my codes
+              </Button>
+            </div>
+          ) : (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              {secret && (
+                <div className="space-y-2 text-sm">
+                  <p>
+                    Add this account to your authenticator app.{" "}
+                    <a href={secret.provisioning_uri} className="underline">
+                      Open in authenticator app
+                    </a>{" "}
+                    or enter the key by hand:
+                  </p>
+                  <code className="block break-all rounded-md border p-3 font-mono">{secret.secret}</code>
+                </div>
+              )}
+              <div className="space-y-2">
+                <Label htmlFor="mfa-code">Code from the app</Label>
+                <Input
+                  id="mfa-code"
+                  type="text"
+                  inputMode="numeric"
+                  autoComplete="one-time-code"
+                  placeholder="123456"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  required
+                />
+              </div>
+              {error && <div className="text-sm text-red-500 font-medium">{error}</div>}
+              <Button type="submit" className="w-full" disabled={loading || !secret}>
+                {loading ? "Verifying..." : "Turn on two-factor authentication"}
+              </Button>
+              <Button type="button" variant="ghost" className="w-full" onClick={logout}>
+                Sign out
+              </Button>
+            </form>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
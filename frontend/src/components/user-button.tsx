@@ -11,7 +11,7 @@ import {
   DropdownMenuTrigger,
 } from "@/components/ui/dropdown-menu";
 import { useAuth } from "@/components/auth-provider";
-import { LogOut, User } from "lucide-react";
+import { KeyRound, LogOut, ShieldCheck, User } from "lucide-react";
 import { useRouter } from "next/navigation";
 
 export function UserButton() {
@@ -49,6 +49,16 @@ export function UserButton() {
           <User className="mr-2 h-4 w-4" />
           <span>Profile</span>
         </DropdownMenuItem>
+        <DropdownMenuItem onClick={() => router.push("/change-password")}>
+          <KeyRound className="mr-2 h-4 w-4" />
+          <span>Change password</span>
+        </DropdownMenuItem>
+        {!user.mfa_enabled && (
+          <DropdownMenuItem onClick={() => router.push("/setup-mfa")}>
+            <ShieldCheck className="mr-2 h-4 w-4" />
+            <span>Set up two-factor auth</span>
+          </DropdownMenuItem>
+        )}
         <DropdownMenuItem onClick={handleLogout}>
           <LogOut className="mr-2 h-4 w-4" />
           <span>Log out</span>
@@ -9,6 +9,7 @@ export interface Client {
     address?: string;
     notes?: string;
     created_at: string;
+    version?: number;
 }
 
 export interface PlannedRole {
@@ -39,12 +40,18 @@ export interface ParentProject {
   billable_days_per_month?: number;
   planned_roles?: PlannedRole[];
   team_members?: TeamMember[];
+  // Only present when requested with ?expand=
+  client?: Client;
+  assignments?: ProjectAssignment[];
+  contracts?: any[];
+  documents?: Document[];
   active_assignments_count?: number;
   current_weekly_hours?: number;
   actual_monthly_revenue?: number;
   actual_monthly_cost?: number;
   planned_monthly_revenue?: number;
   created_at: string;
+  version?: number;
 }
 
 export interface ProjectAssignment {
@@ -62,6 +69,11 @@ export interface ProjectAssignment {
     trial_end_date?: string;
     status: string;
     created_at: string;
+    version?: number;
+    // Only present when requested with ?expand=
+    client?: Client;
+    project?: ParentProject;
+    talent?: Talent;
 }
 
 
@@ -98,6 +110,7 @@ export interface Talent {
         changed_at: string;
     }[];
     created_at: string;
+    version?: number;
 }
 
 export interface Invoice {
@@ -163,6 +176,7 @@ export interface Expense {
   id: string;
   description: string;
   amount: number;
+  currency: string;
   category: string;
   date: string;
   budget_id?: string;
@@ -180,6 +194,26 @@ export interface Investment {
 
 
 
+export interface Page<T> {
+    data: T[];
+    next_cursor: string | null;
+    total: number;
+}
+
+// LoginResult is a session, or for accounts with two-factor auth the token
+// to finish signing in with at verifyMfa.
+export interface LoginResult {
+    token?: string;
+    user?: any;
+    mfa_required?: boolean;
+    mfa_token?: string;
+}
+
+export interface MfaSetup {
+    secret: string;
+    provisioning_uri: string;
+}
+
 const NEXT_PUBLIC_API_URL = "/api";
 
 class ApiClient {
@@ -208,12 +242,15 @@ class ApiClient {
 
     if (!res.ok) {
       const error = await res.text();
+      let message = error || `API request failed: ${res.statusText}`;
       try {
+          // {"error": {"code", "message", "fields", "request_id"}}
           const jsonError = JSON.parse(error);
-          throw new Error(jsonError.error || jsonError.message || `API request failed: ${res.statusText}`);
+          message = jsonError.error?.message || jsonError.message || message;
       } catch (e) {
-          throw new Error(error || `API request failed: ${res.statusText}`);
+          // Not JSON, use the text as is
       }
+      throw new Error(message);
     }
 
     if (res.status === 204) {
@@ -228,12 +265,49 @@ class ApiClient {
     return {} as T;
   }
 
+  // listAll follows next_cursor through a paginated list and returns every item.
+  private async listAll<T>(endpoint: string): Promise<T[]> {
+    const items: T[] = [];
+    let cursor: string | null = null;
+    do {
+      const query: string = `limit=200${cursor ? `&cursor=${encodeURIComponent(cursor)}` : ""}`;
+      const page: Page<T> = await this.request<Page<T>>(`${endpoint}${endpoint.includes("?") ? "&" : "?"}${query}`);
+      items.push(...page.data);
+      cursor = page.next_cursor;
+    } while (cursor);
+    return items;
+  }
+
+  // patch sends a JSON Merge Patch. With the version the record was read at,
+  // the backend refuses the change (412) if someone else saved it since.
+  private patch<T>(endpoint: string, data: unknown, version?: number): Promise<T> {
+    const headers: Record<string, string> = { "Content-Type": "application/merge-patch+json" };
+    if (version) {
+      headers["If-Match"] = `"${version}"`;
+    }
+    return this.request<T>(endpoint, { method: "PATCH", headers, body: JSON.stringify(data) });
+  }
+
   get auth() {
       return {
-          login: (username: string, password: string) => this.request<{ token: string; user: any }>("/auth/login", { 
+          login: (username: string, password: string) => this.request<LoginResult>("/auth/login", { 
               method: "POST", 
               body: JSON.stringify({ username, password }) 
           }),
+          // Second sign-in step, with an authenticator code or a recovery code
+          verifyMfa: (mfaToken: string, code: { code?: string; recovery_code?: string }) => this.request<LoginResult>("/auth/login/mfa", {
+              method: "POST",
+              body: JSON.stringify({ mfa_token: mfaToken, ...code })
+          }),
+          changePassword: (currentPassword: string, newPassword: string) => this.request<LoginResult>("/auth/password", {
+              method: "POST",
+              body: JSON.stringify({ current_password: currentPassword, new_password: newPassword })
+          }),
+          setupMfa: () => this.request<MfaSetup>("/auth/mfa/setup", { method: "POST" }),
+          enableMfa: (code: string) => this.request<{ recovery_codes: string[] }>("/auth/mfa/enable", {
+              method: "POST",
+              body: JSON.stringify({ code })
+          }),
           register: (data: any) => this.request<any>("/auth/register", {
               method: "POST",
               body: JSON.stringify(data)
@@ -268,15 +342,16 @@ class ApiClient {
       list: () => this.request<Talent[]>("/talent"),
       get: (id: string) => this.request<Talent>(`/talent/${id}`),
       create: (data: Partial<Talent>) => this.request<Talent>("/talent", { method: "POST", body: JSON.stringify(data) }),
-      update: (id: string, data: Partial<Talent>) => this.request<Talent>(`/talent/${id}`, { method: "PUT", body: JSON.stringify(data) }),
+      update: (id: string, data: Partial<Talent>, version?: number) => this.patch<Talent>(`/talent/${id}`, data, version),
     };
   }
 
   get clients() {
     return {
-      list: () => this.request<Client[]>("/clients"),
+      list: () => this.listAll<Client>("/clients"),
       create: (data: Partial<Client>) => this.request<Client>("/clients", { method: "POST", body: JSON.stringify(data) }),
-      update: (id: string, data: Partial<Client>) => this.request<Client>(`/clients/${id}`, { method: "PUT", body: JSON.stringify(data) }),
+      get: (id: string) => this.request<Client>(`/clients/${id}`),
+      update: (id: string, data: Partial<Client>, version?: number) => this.patch<Client>(`/clients/${id}`, data, version),
       createContact: (clientId: string, data: any) => this.request<any>(`/clients/${clientId}/contacts`, {
           method: "POST",
           body: JSON.stringify(data)
@@ -305,39 +380,39 @@ class ApiClient {
   
     return {
       list: () => this.request<ParentProject[]>("/projects"),
-      get: (id: string) => this.request<ParentProject>(`/projects/${id}`),
+      get: (id: string, expand?: string) => this.request<ParentProject>(`/projects/${id}${expand ? `?expand=${expand}` : ''}`),
       create: (data: Partial<ParentProject>) => this.request<ParentProject>("/projects", { method: "POST", body: JSON.stringify(data) }),
-      update: (id: string, data: Partial<ParentProject>) => this.request<ParentProject>(`/projects/${id}`, { method: "PUT", body: JSON.stringify(data) }),
+      update: (id: string, data: Partial<ParentProject>, version?: number) => this.patch<ParentProject>(`/projects/${id}`, data, version),
       delete: (id: string) => this.request<void>(`/projects/${id}`, { method: "DELETE" }),
       
       assignments: {
-          list: () => this.request<ProjectAssignment[]>("/assignments"),
+          list: () => this.listAll<ProjectAssignment>("/assignments"),
           get: (id: string) => this.request<ProjectAssignment>(`/assignments/${id}`),
           create: (data: Partial<ProjectAssignment>) => this.request<ProjectAssignment>("/assignments", { method: "POST", body: JSON.stringify(data) }),
-          update: (id: string, data: Partial<ProjectAssignment>) => this.request<ProjectAssignment>(`/assignments/${id}`, { method: "PUT", body: JSON.stringify(data) }),
+          update: (id: string, data: Partial<ProjectAssignment>, version?: number) => this.patch<ProjectAssignment>(`/assignments/${id}`, data, version),
           delete: (id: string) => this.request<void>(`/assignments/${id}`, { method: "DELETE" }),
-          listByProject: (projectId: string) => this.request<ProjectAssignment[]>(`/projects/${projectId}/assignments`),
+          listByProject: (projectId: string, expand?: string) => this.request<ProjectAssignment[]>(`/projects/${projectId}/assignments${expand ? `?expand=${expand}` : ''}`),
       },
     }
   }
 
   get invoices() {
       return {
-          list: () => this.request<Invoice[]>("/invoices"),
+          list: () => this.listAll<Invoice>("/invoices"),
           create: (data: Partial<Invoice>) => this.request<Invoice>("/invoices", { method: "POST", body: JSON.stringify(data) }),
       }
   }
 
   get payments() {
       return {
-          list: () => this.request<ContractorPayment[]>("/payments"),
+          list: () => this.listAll<ContractorPayment>("/payments"),
           create: (data: Partial<ContractorPayment>) => this.request<ContractorPayment>("/payments", { method: "POST", body: JSON.stringify(data) }),
       }
   }
 
   get contracts() {
       return {
-          list: () => this.request<any[]>("/contracts"),
+          list: () => this.listAll<any>("/contracts"),
           create: (data: any) => this.request<any>("/contracts", { method: "POST", body: JSON.stringify(data) }),
           delete: (id: string) => this.request<void>(`/contracts/${id}`, { method: "DELETE" }),
       }
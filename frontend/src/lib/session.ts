@@ -0,0 +1,95 @@
+import { NextResponse } from "next/server";
+import { cookies } from "next/headers";
+
+export const BACKEND_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+
+// Restrictions the backend puts on a session until the user deals with them.
+// They are claims of the token, which the browser can't read from the
+// HttpOnly cookie, so the auth routes hand them over with the user.
+export interface SessionFlags {
+  must_change_password: boolean;
+  mfa_setup_required: boolean;
+}
+
+export function sessionFlags(token: string): SessionFlags {
+  try {
+    const claims = JSON.parse(Buffer.from(token.split(".")[1], "base64url").toString());
+    return {
+      must_change_password: claims.must_change_password === true,
+      mfa_setup_required: claims.mfa_setup_required === true,
+    };
+  } catch {
+    return { must_change_password: false, mfa_setup_required: false };
+  }
+}
+
+export async function setSessionCookie(token: string) {
+  const cookieStore = await cookies();
+  cookieStore.set("auth_token", token, {
+    httpOnly: true,
+    secure: process.env.NODE_ENV === "production",
+    sameSite: "strict",
+    path: "/",
+    maxAge: 60 * 60 * 24, // 1 day
+  });
+}
+
+// forwardAuth posts the request body to a backend auth endpoint, as the
+// signed-in user when withSession is set.
+export async function forwardAuth(request: Request, endpoint: string, withSession = false) {
+  const headers: Record<string, string> = {
+    "Content-Type": "application/json",
+  };
+  // Sign-in throttling and history go by the caller's address and browser
+  const forwardedFor = request.headers.get("X-Forwarded-For");
+  if (forwardedFor) {
+    headers["X-Forwarded-For"] = forwardedFor;
+  }
+  const userAgent = request.headers.get("User-Agent");
+  if (userAgent) {
+    headers["User-Agent"] = userAgent;
+  }
+  if (withSession) {
+    const cookieStore = await cookies();
+    const token = cookieStore.get("auth_token")?.value;
+    if (!token) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+    headers["Authorization"] = `Bearer ${token}`;
+  }
+
+  try {
+    const response = await fetch(`${BACKEND_URL}${endpoint}`, {
+      method: "POST",
+      headers,
+      body: await request.text(),
+    });
+
+    let data;
+    const text = await response.text();
+    try {
+      data = JSON.parse(text);
+    } catch (e) {
+      // If text, wrap it
+      data = { message: text, error: text };
+    }
+
+    if (!response.ok) {
+      return NextResponse.json(data, { status: response.status });
+    }
+
+    // A new session token replaces the cookie. Answers without one, like the
+    // MFA challenge after a password, leave the current session alone.
+    if (typeof data.token === "string" && data.token) {
+      await setSessionCookie(data.token);
+      if (data.user) {
+        data.user = { ...data.user, ...sessionFlags(data.token) };
+      }
+    }
+
+    return NextResponse.json(data);
+  } catch (error) {
+    console.error(`Auth error (${endpoint}):`, error);
+    return NextResponse.json({ error: "Internal Server Error" }, { status: 500 });
+  }
+}